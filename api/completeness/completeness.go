@@ -0,0 +1,191 @@
+// /api/completeness/completeness.go
+package completeness
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Tier é a faixa de qualidade do lead pela completude dos dados.
+type Tier string
+
+const (
+	TierExcellent Tier = "excellent"
+	TierGood      Tier = "good"
+	TierFair      Tier = "fair"
+	TierPoor      Tier = "poor"
+)
+
+// Tiers em ordem, da melhor para a pior.
+var Tiers = []Tier{TierExcellent, TierGood, TierFair, TierPoor}
+
+// DefaultWeights são os campos do lead que contam para a completude e o peso
+// de cada um. Os de contato e identificação pesam mais.
+var DefaultWeights = map[string]float64{
+	"CompanyRegistrationID": 3,
+	"Phone":                 2,
+	"Email":                 2,
+	"Owner":                 2,
+	"Website":               1.5,
+	"Whatsapp":              1.5,
+	"RegisteredName":        1,
+	"Address":               1,
+	"City":                  0.5,
+	"FoundationDate":        0.5,
+	"PrimaryActivity":       0.5,
+	"EquityCapital":         0.5,
+	"Instagram":             0.5,
+	"Facebook":              0.5,
+}
+
+// DefaultThresholds é a pontuação mínima (0 a 100) de cada faixa; abaixo da
+// última o lead é "poor".
+var DefaultThresholds = map[Tier]float64{
+	TierExcellent: 80,
+	TierGood:      60,
+	TierFair:      35,
+}
+
+// Config define os pesos e as faixas usados no cálculo.
+type Config struct {
+	Weights    map[string]float64
+	Thresholds map[Tier]float64
+}
+
+// Result é a completude calculada para um lead.
+type Result struct {
+	Score        float64  `json:"score"`
+	FieldsFilled int      `json:"fields_filled"`
+	Tier         Tier     `json:"tier"`
+	Missing      []string `json:"missing"`
+}
+
+// Default devolve a configuração padrão.
+func Default() *Config {
+	c := &Config{Weights: make(map[string]float64), Thresholds: make(map[Tier]float64)}
+	for field, weight := range DefaultWeights {
+		c.Weights[field] = weight
+	}
+	for tier, min := range DefaultThresholds {
+		c.Thresholds[tier] = min
+	}
+	return c
+}
+
+// New parte da configuração padrão e aplica os ajustes. weights tem o formato
+// "Campo:peso,Campo:peso" (peso 0 tira o campo do cálculo) e thresholds
+// "excellent:85,good:65,fair:40".
+func New(weights, thresholds string) (*Config, error) {
+	c := Default()
+	for _, item := range splitList(weights) {
+		field, value, err := parsePair(item)
+		if err != nil {
+			return nil, fmt.Errorf("peso de completude inválido %q: %v", item, err)
+		}
+		if value < 0 {
+			return nil, fmt.Errorf("peso de completude negativo para %s", field)
+		}
+		if value == 0 {
+			delete(c.Weights, field)
+			continue
+		}
+		c.Weights[field] = value
+	}
+	if len(c.Weights) == 0 {
+		return nil, fmt.Errorf("nenhum campo com peso para a completude")
+	}
+
+	for _, item := range splitList(thresholds) {
+		name, value, err := parsePair(item)
+		if err != nil {
+			return nil, fmt.Errorf("faixa de completude inválida %q: %v", item, err)
+		}
+		tier := Tier(strings.ToLower(name))
+		if _, ok := DefaultThresholds[tier]; !ok {
+			return nil, fmt.Errorf("faixa de completude desconhecida %q: use excellent, good ou fair", name)
+		}
+		if value < 0 || value > 100 {
+			return nil, fmt.Errorf("faixa %s deve ficar entre 0 e 100", tier)
+		}
+		c.Thresholds[tier] = value
+	}
+	if !(c.Thresholds[TierExcellent] >= c.Thresholds[TierGood] && c.Thresholds[TierGood] >= c.Thresholds[TierFair]) {
+		return nil, fmt.Errorf("faixas de completude fora de ordem: excellent >= good >= fair")
+	}
+	return c, nil
+}
+
+// FromEnv monta a configuração a partir de COMPLETENESS_WEIGHTS e
+// COMPLETENESS_TIERS.
+func FromEnv() (*Config, error) {
+	return New(os.Getenv("COMPLETENESS_WEIGHTS"), os.Getenv("COMPLETENESS_TIERS"))
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func parsePair(item string) (string, float64, error) {
+	name, value, ok := strings.Cut(item, ":")
+	name = strings.TrimSpace(name)
+	if !ok || name == "" {
+		return "", 0, fmt.Errorf("esperava nome:valor")
+	}
+	v, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return "", 0, err
+	}
+	return name, v, nil
+}
+
+// Fields devolve os campos considerados, em ordem alfabética.
+func (c *Config) Fields() []string {
+	fields := make([]string, 0, len(c.Weights))
+	for field := range c.Weights {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// Evaluate calcula a completude. filled diz se o campo do lead está
+// preenchido.
+func (c *Config) Evaluate(filled func(field string) bool) Result {
+	result := Result{Missing: []string{}}
+	var total, got float64
+	for _, field := range c.Fields() {
+		weight := c.Weights[field]
+		total += weight
+		if filled(field) {
+			got += weight
+			result.FieldsFilled++
+		} else {
+			result.Missing = append(result.Missing, field)
+		}
+	}
+	if total > 0 {
+		result.Score = math.Round(got/total*1000) / 10
+	}
+	result.Tier = c.TierFor(result.Score)
+	return result
+}
+
+// TierFor devolve a faixa da pontuação.
+func (c *Config) TierFor(score float64) Tier {
+	for _, tier := range Tiers[:len(Tiers)-1] {
+		if score >= c.Thresholds[tier] {
+			return tier
+		}
+	}
+	return TierPoor
+}
@@ -0,0 +1,61 @@
+package completeness
+
+import "testing"
+
+func filledSet(fields ...string) func(string) bool {
+	set := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		set[f] = true
+	}
+	return func(field string) bool { return set[field] }
+}
+
+func TestEvaluateDefault(t *testing.T) {
+	c := Default()
+
+	empty := c.Evaluate(filledSet())
+	if empty.Score != 0 || empty.FieldsFilled != 0 || empty.Tier != TierPoor || len(empty.Missing) != len(DefaultWeights) {
+		t.Errorf("empty lead = %+v", empty)
+	}
+
+	all := c.Fields()
+	full := c.Evaluate(filledSet(all...))
+	if full.Score != 100 || full.FieldsFilled != len(all) || full.Tier != TierExcellent || len(full.Missing) != 0 {
+		t.Errorf("full lead = %+v", full)
+	}
+
+	// 3 + 2 + 2 + 2 + 1.5 = 10.5 de 17
+	contact := c.Evaluate(filledSet("CompanyRegistrationID", "Phone", "Email", "Owner", "Website"))
+	if contact.Score != 61.8 || contact.FieldsFilled != 5 || contact.Tier != TierGood {
+		t.Errorf("contact lead = %+v", contact)
+	}
+}
+
+func TestNew(t *testing.T) {
+	c, err := New("Phone:10, Instagram:0, TikTok:1", "excellent:90,good:50,fair:10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Weights["Phone"] != 10 || c.Weights["TikTok"] != 1 {
+		t.Errorf("weights = %v", c.Weights)
+	}
+	if _, ok := c.Weights["Instagram"]; ok {
+		t.Error("weight 0 should remove the field")
+	}
+	if c.TierFor(89.9) != TierGood || c.TierFor(90) != TierExcellent || c.TierFor(9) != TierPoor {
+		t.Errorf("thresholds = %v", c.Thresholds)
+	}
+
+	for _, tc := range []struct{ weights, tiers string }{
+		{"Phone", ""},
+		{"Phone:abc", ""},
+		{"Phone:-1", ""},
+		{"", "great:90"},
+		{"", "excellent:50,good:70"},
+		{"", "fair:120"},
+	} {
+		if _, err := New(tc.weights, tc.tiers); err == nil {
+			t.Errorf("New(%q, %q) should fail", tc.weights, tc.tiers)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+// /api/db/completeness.go
+package db
+
+import (
+	"fmt"
+	"log"
+	"reflect"
+
+	"gorm.io/gorm"
+
+	"github.com/wbrunovieira/LeadSearchVersion2/completeness"
+)
+
+var completenessConfig = completeness.Default()
+
+// SetCompleteness troca os pesos e faixas usados para calcular a completude
+// dos leads. Todos os campos com peso precisam existir em Lead.
+func SetCompleteness(c *completeness.Config) error {
+	leadType := reflect.TypeOf(Lead{})
+	for _, field := range c.Fields() {
+		if _, ok := leadType.FieldByName(field); !ok {
+			return fmt.Errorf("campo de completude desconhecido: %s", field)
+		}
+	}
+	completenessConfig = c
+	return nil
+}
+
+// Completeness calcula a completude do lead com a configuração atual.
+func Completeness(l *Lead) completeness.Result {
+	v := reflect.ValueOf(l).Elem()
+	return completenessConfig.Evaluate(func(field string) bool {
+		f := v.FieldByName(field)
+		return f.IsValid() && !f.IsZero()
+	})
+}
+
+func (l *Lead) applyCompleteness() {
+	result := Completeness(l)
+	l.FieldsFilled = result.FieldsFilled
+	l.CompletenessScore = result.Score
+	l.Quality = string(result.Tier)
+}
+
+// RecalculateCompleteness recalcula a completude de todos os leads e grava
+// só os que mudaram, para que uma troca de pesos valha também para os leads
+// antigos.
+func RecalculateCompleteness() error {
+	var leads []Lead
+	updated := 0
+	result := DB.FindInBatches(&leads, 500, func(tx *gorm.DB, batch int) error {
+		for i := range leads {
+			lead := &leads[i]
+			before := [3]interface{}{lead.FieldsFilled, lead.CompletenessScore, lead.Quality}
+			lead.applyCompleteness()
+			if before == [3]interface{}{lead.FieldsFilled, lead.CompletenessScore, lead.Quality} {
+				continue
+			}
+			err := DB.Model(&Lead{}).Where("id = ?", lead.ID).UpdateColumns(map[string]interface{}{
+				"fields_filled":      lead.FieldsFilled,
+				"completeness_score": lead.CompletenessScore,
+				"quality":            lead.Quality,
+			}).Error
+			if err != nil {
+				return err
+			}
+			updated++
+		}
+		return nil
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if updated > 0 {
+		log.Printf("Completude recalculada em %d leads", updated)
+	}
+	return nil
+}
+
+// CompletenessWeights devolve o peso de cada campo na configuração atual.
+func CompletenessWeights() map[string]float64 {
+	weights := make(map[string]float64, len(completenessConfig.Weights))
+	for field, weight := range completenessConfig.Weights {
+		weights[field] = weight
+	}
+	return weights
+}
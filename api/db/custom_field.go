@@ -0,0 +1,113 @@
+// /api/db/custom_field.go
+package db
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+// Tipos de campo personalizado.
+const (
+	CustomFieldText   = "text"
+	CustomFieldNumber = "number"
+	CustomFieldDate   = "date"
+	CustomFieldEnum   = "enum"
+)
+
+var CustomFieldTypes = []string{CustomFieldText, CustomFieldNumber, CustomFieldDate, CustomFieldEnum}
+
+// CustomFieldDefinition é um campo que o workspace acrescenta aos leads sem
+// precisar de coluna nova. Os valores ficam em Lead.CustomFields pela Key:
+// texto e opção de enum como string, número como número e data como
+// "AAAA-MM-DD".
+type CustomFieldDefinition struct {
+	ID          uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	WorkspaceID uuid.UUID `gorm:"type:uuid;uniqueIndex:idx_custom_field_definitions_key" json:"workspace_id"`
+	Key         string    `gorm:"size:64;uniqueIndex:idx_custom_field_definitions_key" json:"key"`
+	Label       string    `gorm:"size:255" json:"label"`
+	Type        string    `gorm:"size:10" json:"type"`
+
+	// Options são os valores aceitos por um campo enum.
+	Options pq.StringArray `gorm:"type:text[]" json:"options,omitempty"`
+
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// ErrCustomFieldExists indica que o workspace já tem um campo com a chave.
+var ErrCustomFieldExists = errors.New("já existe um campo personalizado com essa chave")
+
+// GetCustomFieldDefinitions lista os campos personalizados do workspace do
+// escopo, em ordem de chave.
+func GetCustomFieldDefinitions(scope Scope) ([]CustomFieldDefinition, error) {
+	var definitions []CustomFieldDefinition
+	result := DB.Where("workspace_id = ?", scope.target()).Order("key").Find(&definitions)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return definitions, nil
+}
+
+// GetCustomFieldDefinition devolve o campo do workspace do escopo com a
+// chave, ou nil se ele não existir.
+func GetCustomFieldDefinition(scope Scope, key string) (*CustomFieldDefinition, error) {
+	var definition CustomFieldDefinition
+	err := DB.Where("workspace_id = ? AND key = ?", scope.target(), key).First(&definition).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &definition, nil
+}
+
+// CreateCustomFieldDefinition grava um campo novo no workspace do escopo.
+func CreateCustomFieldDefinition(scope Scope, definition *CustomFieldDefinition) error {
+	existing, err := GetCustomFieldDefinition(scope, definition.Key)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return fmt.Errorf("%w: %s", ErrCustomFieldExists, definition.Key)
+	}
+	definition.ID = uuid.New()
+	definition.WorkspaceID = scope.target()
+	if err := DB.Create(definition).Error; err != nil {
+		return fmt.Errorf("erro ao criar o campo personalizado: %v", err)
+	}
+	log.Printf("Campo personalizado %s (%s) criado no %s", definition.Key, definition.Type, scope)
+	return nil
+}
+
+// SaveCustomFieldDefinition grava a alteração de rótulo ou opções de um
+// campo existente.
+func SaveCustomFieldDefinition(definition *CustomFieldDefinition) error {
+	result := DB.Model(definition).Select("label", "options", "updated_at").Updates(definition)
+	if result.Error != nil {
+		return fmt.Errorf("erro ao atualizar o campo personalizado: %v", result.Error)
+	}
+	return nil
+}
+
+// DeleteCustomFieldDefinition apaga o campo e tira o valor dele de todos os
+// leads do workspace.
+func DeleteCustomFieldDefinition(definition *CustomFieldDefinition) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&Lead{}).Where("workspace_id = ? AND custom_fields -> ?::text IS NOT NULL", definition.WorkspaceID, definition.Key).
+			UpdateColumn("custom_fields", gorm.Expr("custom_fields - ?::text", definition.Key)).Error; err != nil {
+			return fmt.Errorf("erro ao remover o campo dos leads: %v", err)
+		}
+		if err := tx.Delete(definition).Error; err != nil {
+			return fmt.Errorf("erro ao apagar o campo personalizado: %v", err)
+		}
+		log.Printf("Campo personalizado %s apagado do workspace %s", definition.Key, definition.WorkspaceID)
+		return nil
+	})
+}
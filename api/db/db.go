@@ -4,6 +4,7 @@ package db
 import (
 	"fmt"
 	"log"
+	"os"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -13,7 +14,13 @@ var DB *gorm.DB
 
 func Connect() error {
 	var err error
-	dsn := "host=db-leads user=leads_user password=secret_password dbname=leads_db port=5432 sslmode=disable"
+	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable",
+		envOr("DB_HOST", "db-leads"),
+		envOr("DB_USER", "leads_user"),
+		envOr("DB_PASSWORD", "secret_password"),
+		envOr("DB_NAME", "leads_db"),
+		envOr("DB_PORT", "5432"),
+	)
 	DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{})
 	if err != nil {
 		log.Fatal("Falha ao conectar ao banco de dados:", err)
@@ -22,6 +29,14 @@ func Connect() error {
 	return nil
 }
 
+// envOr lê a variável de ambiente ou devolve o valor padrão do docker-compose.
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
 func Close() {
 	sqlDB, err := DB.DB()
 	if err != nil {
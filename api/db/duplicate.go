@@ -0,0 +1,331 @@
+// /api/db/duplicate.go
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Situações de um par na fila de revisão de duplicatas.
+const (
+	DuplicatePending   = "pending"
+	DuplicateMerged    = "merged"
+	DuplicateDismissed = "dismissed"
+)
+
+// DuplicateCandidate é um par de leads do mesmo workspace que podem ser a
+// mesma empresa. LeadID é sempre o menor dos dois IDs, para o par aparecer
+// uma vez só. Signals guarda os indícios que levaram à pontuação.
+type DuplicateCandidate struct {
+	ID          uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	WorkspaceID uuid.UUID `gorm:"type:uuid;index" json:"workspace_id"`
+	LeadID      uuid.UUID `gorm:"type:uuid;uniqueIndex:idx_duplicate_candidates_pair" json:"lead_id"`
+	OtherLeadID uuid.UUID `gorm:"type:uuid;uniqueIndex:idx_duplicate_candidates_pair;index" json:"other_lead_id"`
+
+	Score   float64         `gorm:"type:numeric;index" json:"score"`
+	Signals json.RawMessage `gorm:"type:jsonb" json:"signals"`
+	Status  string          `gorm:"size:20;index;default:pending" json:"status"`
+
+	ReviewedBy string       `gorm:"size:255" json:"reviewed_by,omitempty"`
+	ReviewedAt sql.NullTime `gorm:"type:timestamptz" json:"reviewed_at"`
+
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// LeadMerge registra uma mesclagem: quem foi mantido, quem foi absorvido, de
+// onde veio cada campo alterado e uma cópia dos dois leads como estavam.
+type LeadMerge struct {
+	ID           uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	WorkspaceID  uuid.UUID `gorm:"type:uuid;index" json:"workspace_id"`
+	KeptLeadID   uuid.UUID `gorm:"type:uuid;index" json:"kept_lead_id"`
+	MergedLeadID uuid.UUID `gorm:"type:uuid;index" json:"merged_lead_id"`
+	MergedBy     string    `gorm:"size:255" json:"merged_by"`
+
+	Fields       json.RawMessage `gorm:"type:jsonb" json:"fields"`
+	KeptBefore   json.RawMessage `gorm:"type:jsonb" json:"kept_before"`
+	MergedBefore json.RawMessage `gorm:"type:jsonb" json:"merged_before"`
+
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// FindDuplicateCandidates devolve os leads do workspace que valem comparar com
+// o lead: mesmo telefone, domínio, raiz de CNPJ, a menos de ~1 km ou com uma
+// palavra do nome em comum na mesma cidade. Leads já mesclados ficam de fora.
+func FindDuplicateCandidates(lead *Lead, nameToken string) ([]Lead, error) {
+	match := DB.Where("1 = 0")
+	var phones []string
+	for _, p := range []string{lead.Phone, lead.Whatsapp} {
+		if p != "" {
+			phones = append(phones, p)
+		}
+	}
+	if len(phones) > 0 {
+		match = match.Or("(phone IN ? OR whatsapp IN ?)", phones, phones)
+	}
+	if lead.Domain != "" {
+		match = match.Or("domain = ?", lead.Domain)
+	}
+	if len(lead.CompanyRegistrationID) == 14 {
+		match = match.Or("company_registration_id LIKE ?", lead.CompanyRegistrationID[:8]+"%")
+	}
+	if lead.Latitude != 0 || lead.Longitude != 0 {
+		match = match.Or("(latitude BETWEEN ? AND ? AND longitude BETWEEN ? AND ?)",
+			lead.Latitude-0.01, lead.Latitude+0.01, lead.Longitude-0.01, lead.Longitude+0.01)
+	}
+	if nameToken != "" && lead.City != "" {
+		match = match.Or("(lower(city) = lower(?) AND business_name ILIKE ?)", lead.City, "%"+nameToken+"%")
+	}
+
+	var leads []Lead
+	result := DB.Where("workspace_id = ? AND id <> ? AND merged_into_id IS NULL", lead.WorkspaceID, lead.ID).
+		Where(match).
+		Order("created_at DESC").
+		Limit(500).
+		Find(&leads)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return leads, nil
+}
+
+// SaveDuplicateCandidate grava ou atualiza a pontuação do par. Pares já
+// revisados (mesclados ou descartados) não voltam para a fila.
+func SaveDuplicateCandidate(c *DuplicateCandidate) error {
+	if c.OtherLeadID.String() < c.LeadID.String() {
+		c.LeadID, c.OtherLeadID = c.OtherLeadID, c.LeadID
+	}
+	c.ID = uuid.New()
+	c.Status = DuplicatePending
+	result := DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "lead_id"}, {Name: "other_lead_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"score", "signals", "updated_at"}),
+		Where: clause.Where{Exprs: []clause.Expression{
+			clause.Eq{Column: clause.Column{Table: "duplicate_candidates", Name: "status"}, Value: DuplicatePending},
+		}},
+	}).Create(c)
+	if result.Error != nil {
+		return fmt.Errorf("erro ao salvar possível duplicata: %v", result.Error)
+	}
+	return nil
+}
+
+// GetDuplicateCandidates lista os pares do escopo com a situação informada e
+// pontuação mínima, dos mais prováveis para os menos.
+func GetDuplicateCandidates(scope Scope, status string, minScore float64) ([]DuplicateCandidate, error) {
+	var candidates []DuplicateCandidate
+	result := scope.db().Where("status = ? AND score >= ?", status, minScore).
+		Order("score DESC, created_at").
+		Find(&candidates)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return candidates, nil
+}
+
+func GetDuplicateCandidate(scope Scope, id uuid.UUID) (*DuplicateCandidate, error) {
+	var candidate DuplicateCandidate
+	result := scope.db().First(&candidate, "id = ?", id)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, result.Error
+	}
+	return &candidate, nil
+}
+
+// GetDuplicateCandidateByPair devolve o par formado pelos dois leads, se ele
+// estiver na fila.
+func GetDuplicateCandidateByPair(scope Scope, a, b uuid.UUID) (*DuplicateCandidate, error) {
+	if b.String() < a.String() {
+		a, b = b, a
+	}
+	var candidate DuplicateCandidate
+	result := scope.db().First(&candidate, "lead_id = ? AND other_lead_id = ?", a, b)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, result.Error
+	}
+	return &candidate, nil
+}
+
+// DismissDuplicateCandidate marca o par como "não é duplicata".
+func DismissDuplicateCandidate(scope Scope, id uuid.UUID, reviewer string) error {
+	result := scope.db().Model(&DuplicateCandidate{}).
+		Where("id = ? AND status = ?", id, DuplicatePending).
+		Updates(map[string]interface{}{
+			"status":      DuplicateDismissed,
+			"reviewed_by": reviewer,
+			"reviewed_at": time.Now(),
+		})
+	if result.Error != nil {
+		return fmt.Errorf("erro ao descartar possível duplicata: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("par %s não está pendente no %s", id, scope)
+	}
+	return nil
+}
+
+// GetLeadsByIDs devolve os leads do escopo com os IDs informados.
+func GetLeadsByIDs(scope Scope, ids []uuid.UUID) (map[uuid.UUID]Lead, error) {
+	leads := make(map[uuid.UUID]Lead, len(ids))
+	if len(ids) == 0 {
+		return leads, nil
+	}
+	var found []Lead
+	if err := scope.db().Where("id IN ?", ids).Find(&found).Error; err != nil {
+		return nil, err
+	}
+	for _, lead := range found {
+		leads[lead.ID] = lead
+	}
+	return leads, nil
+}
+
+// ErrLeadMerged indica que um dos leads da mesclagem não existe mais no
+// escopo ou já foi absorvido por outra mesclagem.
+var ErrLeadMerged = errors.New("lead não encontrado ou já mesclado")
+
+// mergedLeadColumns são as colunas que BeforeSave recalcula a partir dos
+// campos do lead e que vão junto com os campos mesclados.
+var mergedLeadColumns = []string{"Domain", "Quality", "FieldsFilled", "CompletenessScore", "ICPScore", "ICPVersion", "UpdatedAt"}
+
+// MergeLeads absorve o lead mergedID no keptID numa transação. Os dois são
+// lidos e travados (SELECT ... FOR UPDATE) dentro dela, sem merged_into_id, e
+// merge mescla os campos de merged em kept e devolve os nomes dos campos do
+// Lead que mudaram e o registro da mesclagem. Só esses campos (e os que
+// BeforeSave recalcula) são gravados no mantido, para não desfazer mudanças
+// de etapa, de responsável ou do enriquecimento feitas em paralelo.
+//
+// Em seguida marca o absorvido com MergedIntoID, copia para o mantido os
+// telefones e perfis sociais do absorvido (com a origem original), move para
+// ele as atividades do absorvido e registra a mesclagem. O lead absorvido
+// continua no banco como histórico e para que o mesmo PlaceID não volte a ser
+// importado. Devolve ErrLeadMerged se algum dos dois já tiver sido mesclado.
+func MergeLeads(scope Scope, keptID, mergedID uuid.UUID, merge func(kept, merged *Lead) ([]string, *LeadMerge)) (*Lead, *LeadMerge, error) {
+	var kept, merged *Lead
+	var record *LeadMerge
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		// Trava sempre na mesma ordem para duas mesclagens cruzadas (A em B
+		// e B em A) esperarem uma pela outra em vez de travarem.
+		first, second := keptID, mergedID
+		if second.String() < first.String() {
+			first, second = second, first
+		}
+		locked := make(map[uuid.UUID]*Lead, 2)
+		for _, id := range []uuid.UUID{first, second} {
+			lead, err := findLeadForUpdate(tx, scope, id)
+			if err != nil {
+				return err
+			}
+			if lead == nil {
+				return ErrLeadMerged
+			}
+			locked[id] = lead
+		}
+		kept, merged = locked[keptID], locked[mergedID]
+		if kept.WorkspaceID != merged.WorkspaceID {
+			return fmt.Errorf("leads de workspaces diferentes não podem ser mesclados")
+		}
+
+		var fields []string
+		fields, record = merge(kept, merged)
+		if len(fields) > 0 {
+			columns := append(fields, mergedLeadColumns...)
+			if err := tx.Model(kept).Select(columns).Updates(kept).Error; err != nil {
+				return fmt.Errorf("erro ao salvar o lead mantido: %v", err)
+			}
+		}
+		if err := tx.Model(&Lead{}).Where("id = ?", merged.ID).UpdateColumn("merged_into_id", kept.ID).Error; err != nil {
+			return fmt.Errorf("erro ao marcar o lead absorvido: %v", err)
+		}
+		// Leads que já tinham sido absorvidos pelo lead absorvido passam a
+		// apontar para o mantido.
+		if err := tx.Model(&Lead{}).Where("merged_into_id = ?", merged.ID).UpdateColumn("merged_into_id", kept.ID).Error; err != nil {
+			return fmt.Errorf("erro ao atualizar mesclagens anteriores: %v", err)
+		}
+
+		var phones []LeadPhone
+		if err := tx.Where("lead_id = ?", merged.ID).Find(&phones).Error; err != nil {
+			return err
+		}
+		if len(phones) > 0 {
+			for i := range phones {
+				phones[i].ID, phones[i].LeadID = uuid.New(), kept.ID
+			}
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&phones).Error; err != nil {
+				return fmt.Errorf("erro ao copiar telefones: %v", err)
+			}
+		}
+
+		var profiles []LeadSocialProfile
+		if err := tx.Where("lead_id = ?", merged.ID).Find(&profiles).Error; err != nil {
+			return err
+		}
+		if len(profiles) > 0 {
+			for i := range profiles {
+				profiles[i].ID, profiles[i].LeadID = uuid.New(), kept.ID
+			}
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&profiles).Error; err != nil {
+				return fmt.Errorf("erro ao copiar perfis sociais: %v", err)
+			}
+		}
+
+		// A linha do tempo do absorvido passa para o mantido.
+		if err := tx.Model(&LeadActivity{}).Where("lead_id = ?", merged.ID).UpdateColumn("lead_id", kept.ID).Error; err != nil {
+			return fmt.Errorf("erro ao mover atividades: %v", err)
+		}
+
+		now := time.Now()
+		a, b := kept.ID, merged.ID
+		if b.String() < a.String() {
+			a, b = b, a
+		}
+		if err := tx.Model(&DuplicateCandidate{}).Where("lead_id = ? AND other_lead_id = ?", a, b).
+			Updates(map[string]interface{}{"status": DuplicateMerged, "reviewed_by": record.MergedBy, "reviewed_at": now}).Error; err != nil {
+			return err
+		}
+		// Os outros pares do absorvido perdem o sentido; o mantido é
+		// comparado de novo depois da mesclagem.
+		if err := tx.Where("status = ? AND (lead_id = ? OR other_lead_id = ?)", DuplicatePending, merged.ID, merged.ID).
+			Delete(&DuplicateCandidate{}).Error; err != nil {
+			return err
+		}
+
+		record.ID = uuid.New()
+		record.WorkspaceID = kept.WorkspaceID
+		record.KeptLeadID = kept.ID
+		record.MergedLeadID = merged.ID
+		if err := tx.Create(record).Error; err != nil {
+			return fmt.Errorf("erro ao registrar a mesclagem: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return kept, record, nil
+}
+
+// GetLeadMerges devolve as mesclagens em que o lead foi mantido ou absorvido.
+func GetLeadMerges(scope Scope, leadID uuid.UUID) ([]LeadMerge, error) {
+	var merges []LeadMerge
+	result := scope.db().Where("kept_lead_id = ? OR merged_lead_id = ?", leadID, leadID).
+		Order("created_at").
+		Find(&merges)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return merges, nil
+}
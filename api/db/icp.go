@@ -0,0 +1,333 @@
+// /api/db/icp.go
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/wbrunovieira/LeadSearchVersion2/icp"
+)
+
+// ICPRuleSet é uma versão das regras de ICP (perfil de cliente ideal) de um
+// workspace. Versões gravadas não mudam: editar as regras cria a versão
+// seguinte, e cada pontuação guarda a versão com que foi calculada. Só uma
+// versão por workspace fica ativa.
+type ICPRuleSet struct {
+	ID          uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	WorkspaceID uuid.UUID `gorm:"type:uuid;uniqueIndex:idx_icp_rule_sets_version" json:"workspace_id"`
+	Version     int       `gorm:"uniqueIndex:idx_icp_rule_sets_version" json:"version"`
+	Name        string    `gorm:"size:255" json:"name"`
+
+	Rules  json.RawMessage `gorm:"type:jsonb" json:"rules"`
+	Format string          `gorm:"size:10" json:"format"`
+	Source string          `gorm:"type:text" json:"source"`
+
+	Active    bool      `gorm:"index" json:"active"`
+	CreatedBy string    `gorm:"size:255" json:"created_by"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// RuleSet devolve as regras da versão.
+func (s *ICPRuleSet) RuleSet() (*icp.RuleSet, error) {
+	var set icp.RuleSet
+	if err := json.Unmarshal(s.Rules, &set); err != nil {
+		return nil, fmt.Errorf("regras da versão %d ilegíveis: %v", s.Version, err)
+	}
+	return &set, nil
+}
+
+// LeadICPScore é uma entrada do histórico de pontuação de ICP do lead. Uma
+// entrada nova só é gravada quando a versão das regras, a pontuação ou as
+// regras que casaram mudam, de modo que cada mudança de nota tem explicação.
+type LeadICPScore struct {
+	ID             uuid.UUID       `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	WorkspaceID    uuid.UUID       `gorm:"type:uuid;index" json:"workspace_id"`
+	LeadID         uuid.UUID       `gorm:"type:uuid;index" json:"lead_id"`
+	RuleSetID      uuid.UUID       `gorm:"type:uuid;index" json:"rule_set_id"`
+	RuleSetVersion int             `json:"rule_set_version"`
+	Score          float64         `gorm:"type:numeric" json:"score"`
+	Breakdown      json.RawMessage `gorm:"type:jsonb" json:"breakdown"`
+	CreatedAt      time.Time       `gorm:"autoCreateTime" json:"created_at"`
+}
+
+type activeICP struct {
+	ruleSet *ICPRuleSet
+	rules   *icp.RuleSet
+}
+
+// activeICPCache guarda a versão ativa de cada workspace (nil quando não há
+// regras) para não consultá-la a cada lead gravado. activeICPGen muda a cada
+// troca de versão, para uma leitura feita antes da troca não ficar no cache.
+var (
+	activeICPMu    sync.RWMutex
+	activeICPCache = map[uuid.UUID]*activeICP{}
+	activeICPGen   uint64
+)
+
+func loadActiveICP(tx *gorm.DB, workspaceID uuid.UUID) (*activeICP, error) {
+	activeICPMu.RLock()
+	cached, ok := activeICPCache[workspaceID]
+	gen := activeICPGen
+	activeICPMu.RUnlock()
+	if ok {
+		return cached, nil
+	}
+
+	var ruleSet ICPRuleSet
+	err := tx.Where("workspace_id = ? AND active", workspaceID).First(&ruleSet).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if err == nil {
+		rules, err := ruleSet.RuleSet()
+		if err != nil {
+			return nil, err
+		}
+		cached = &activeICP{ruleSet: &ruleSet, rules: rules}
+	}
+
+	activeICPMu.Lock()
+	if gen == activeICPGen {
+		activeICPCache[workspaceID] = cached
+	}
+	activeICPMu.Unlock()
+	return cached, nil
+}
+
+func forgetActiveICP(workspaceID uuid.UUID) {
+	activeICPMu.Lock()
+	delete(activeICPCache, workspaceID)
+	activeICPGen++
+	activeICPMu.Unlock()
+}
+
+// ICPInput monta a entrada da pontuação a partir do lead.
+func ICPInput(l *Lead) icp.Input {
+	in := icp.Input{
+		Rating:              l.Rating,
+		UserRatingsTotal:    l.UserRatingsTotal,
+		PriceLevel:          l.PriceLevel,
+		BusinessStatus:      l.BusinessStatus,
+		EquityCapital:       l.EquityCapital,
+		PrimaryActivity:     l.PrimaryActivity,
+		SecondaryActivities: l.SecondaryActivities,
+		City:                l.City,
+	}
+	if l.FoundationDate.Valid {
+		in.HasCompanyAge = true
+		in.CompanyAge = time.Since(l.FoundationDate.Time).Hours() / 24 / 365.25
+	}
+	return in
+}
+
+// applyICP pontua o lead com as regras ativas do workspace. Sem regras, a
+// pontuação fica zerada.
+func (l *Lead) applyICP(tx *gorm.DB) error {
+	active, err := loadActiveICP(tx.Session(&gorm.Session{NewDB: true}), l.WorkspaceID)
+	if err != nil {
+		return fmt.Errorf("erro ao carregar as regras de ICP: %v", err)
+	}
+	if active == nil {
+		l.ICPScore, l.ICPVersion, l.icp = 0, 0, nil
+		return nil
+	}
+	result := active.rules.Evaluate(ICPInput(l))
+	l.ICPScore = result.Score
+	l.ICPVersion = active.ruleSet.Version
+	l.icp = &leadICP{ruleSet: active.ruleSet, result: result}
+	return nil
+}
+
+// recordICPScore grava a pontuação calculada em applyICP no histórico, se
+// ela mudou desde a última entrada do lead.
+func (l *Lead) recordICPScore(tx *gorm.DB) error {
+	if l.icp == nil {
+		return nil
+	}
+	tx = tx.Session(&gorm.Session{NewDB: true})
+
+	var last LeadICPScore
+	err := tx.Where("lead_id = ?", l.ID).Order("created_at DESC").First(&last).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if err == nil && last.RuleSetID == l.icp.ruleSet.ID && last.Score == l.icp.result.Score &&
+		matchedRules(last.Breakdown) == matchedRules(l.icp.breakdown()) {
+		return nil
+	}
+
+	entry := LeadICPScore{
+		WorkspaceID:    l.WorkspaceID,
+		LeadID:         l.ID,
+		RuleSetID:      l.icp.ruleSet.ID,
+		RuleSetVersion: l.icp.ruleSet.Version,
+		Score:          l.icp.result.Score,
+		Breakdown:      l.icp.breakdown(),
+	}
+	if err := tx.Create(&entry).Error; err != nil {
+		return fmt.Errorf("erro ao gravar a pontuação de ICP do lead %s: %v", l.ID, err)
+	}
+	return nil
+}
+
+type leadICP struct {
+	ruleSet *ICPRuleSet
+	result  icp.Result
+}
+
+func (p *leadICP) breakdown() json.RawMessage {
+	data, _ := json.Marshal(p.result.Breakdown)
+	return data
+}
+
+func matchedRules(breakdown json.RawMessage) string {
+	var rules []icp.RuleResult
+	if err := json.Unmarshal(breakdown, &rules); err != nil {
+		return ""
+	}
+	var matched []string
+	for _, r := range rules {
+		if r.Matched {
+			matched = append(matched, r.RuleID)
+		}
+	}
+	return strings.Join(matched, ",")
+}
+
+// CreateICPRuleSet grava as regras como a próxima versão do workspace do
+// escopo e a ativa.
+func CreateICPRuleSet(scope Scope, ruleSet *ICPRuleSet) error {
+	ruleSet.WorkspaceID = scope.target()
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var last int
+		if err := tx.Model(&ICPRuleSet{}).Where("workspace_id = ?", ruleSet.WorkspaceID).
+			Select("COALESCE(MAX(version), 0)").Scan(&last).Error; err != nil {
+			return err
+		}
+		ruleSet.ID = uuid.New()
+		ruleSet.Version = last + 1
+		ruleSet.Active = true
+		if err := tx.Model(&ICPRuleSet{}).Where("workspace_id = ? AND active", ruleSet.WorkspaceID).
+			Update("active", false).Error; err != nil {
+			return err
+		}
+		return tx.Create(ruleSet).Error
+	})
+	forgetActiveICP(ruleSet.WorkspaceID)
+	if err != nil {
+		return fmt.Errorf("erro ao gravar as regras de ICP: %v", err)
+	}
+	log.Printf("Regras de ICP versão %d ativadas no %s", ruleSet.Version, scope)
+	return nil
+}
+
+// ActivateICPRuleSet volta a usar uma versão anterior das regras.
+func ActivateICPRuleSet(scope Scope, version int) (*ICPRuleSet, error) {
+	ruleSet, err := GetICPRuleSet(scope, version)
+	if err != nil || ruleSet == nil {
+		return ruleSet, err
+	}
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&ICPRuleSet{}).Where("workspace_id = ? AND active", ruleSet.WorkspaceID).
+			Update("active", false).Error; err != nil {
+			return err
+		}
+		return tx.Model(ruleSet).Update("active", true).Error
+	})
+	forgetActiveICP(ruleSet.WorkspaceID)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ativar a versão %d das regras de ICP: %v", version, err)
+	}
+	ruleSet.Active = true
+	log.Printf("Regras de ICP versão %d reativadas no %s", version, scope)
+	return ruleSet, nil
+}
+
+// GetICPRuleSet devolve uma versão das regras do workspace do escopo, ou a
+// ativa com version 0. Devolve nil se ela não existir.
+func GetICPRuleSet(scope Scope, version int) (*ICPRuleSet, error) {
+	query := DB.Where("workspace_id = ?", scope.target())
+	if version > 0 {
+		query = query.Where("version = ?", version)
+	} else {
+		query = query.Where("active")
+	}
+	var ruleSet ICPRuleSet
+	if err := query.First(&ruleSet).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &ruleSet, nil
+}
+
+// GetICPRuleSets lista as versões das regras do workspace do escopo, da mais
+// nova para a mais antiga.
+func GetICPRuleSets(scope Scope) ([]ICPRuleSet, error) {
+	var ruleSets []ICPRuleSet
+	result := DB.Where("workspace_id = ?", scope.target()).Order("version DESC").Find(&ruleSets)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return ruleSets, nil
+}
+
+// RescoreICP recalcula a pontuação de ICP de todos os leads do workspace do
+// escopo com as regras ativas e devolve quantos mudaram de nota.
+func RescoreICP(scope Scope) (int, error) {
+	var leads []Lead
+	rescored := 0
+	result := DB.Where("workspace_id = ? AND merged_into_id IS NULL", scope.target()).
+		FindInBatches(&leads, 500, func(tx *gorm.DB, batch int) error {
+			for i := range leads {
+				lead := &leads[i]
+				before := [2]interface{}{lead.ICPScore, lead.ICPVersion}
+				if err := lead.applyICP(DB); err != nil {
+					return err
+				}
+				if err := lead.recordICPScore(DB); err != nil {
+					return err
+				}
+				if before == [2]interface{}{lead.ICPScore, lead.ICPVersion} {
+					continue
+				}
+				err := DB.Model(&Lead{}).Where("id = ?", lead.ID).UpdateColumns(map[string]interface{}{
+					"icp_score":   lead.ICPScore,
+					"icp_version": lead.ICPVersion,
+				}).Error
+				if err != nil {
+					return err
+				}
+				rescored++
+			}
+			return nil
+		})
+	if result.Error != nil {
+		return rescored, fmt.Errorf("erro ao recalcular a pontuação de ICP: %v", result.Error)
+	}
+	log.Printf("Pontuação de ICP recalculada em %d leads do %s", rescored, scope)
+	return rescored, nil
+}
+
+// GetLeadICPScores devolve o histórico de pontuação de ICP do lead, da
+// entrada mais nova para a mais antiga.
+func GetLeadICPScores(scope Scope, leadID uuid.UUID) ([]LeadICPScore, error) {
+	if err := checkLeadInScope(scope, leadID); err != nil {
+		return nil, err
+	}
+	var scores []LeadICPScore
+	result := DB.Where("lead_id = ?", leadID).Order("created_at DESC").Find(&scores)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return scores, nil
+}
@@ -0,0 +1,556 @@
+// /api/db/integration.go
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Eventos de lead que uma integração pode assinar.
+const (
+	EventLeadCreated = "lead.created"
+	EventLeadUpdated = "lead.updated"
+)
+
+var LeadEvents = []string{EventLeadCreated, EventLeadUpdated}
+
+// Marcos do enriquecimento: o lead gravado pela API, enriquecido na web pelo
+// Data Collector, extraído pelo modelo no Forwarder e indexado no Datalake.
+// Os três últimos chegam pela exchange lead_events do RabbitMQ.
+const (
+	EventLeadSaved        = "lead.saved"
+	EventLeadWebEnriched  = "lead.web_enriched"
+	EventLeadLLMExtracted = "lead.llm_extracted"
+	EventLeadIndexed      = "lead.indexed"
+)
+
+var MilestoneEvents = []string{EventLeadSaved, EventLeadWebEnriched, EventLeadLLMExtracted, EventLeadIndexed}
+
+// Operadores dos filtros das integrações.
+const (
+	FilterPresent = "present" // o campo tem valor (não vazio, zero ou false)
+	FilterMissing = "missing" // o campo está vazio
+	FilterIn      = "in"      // o valor (ou um dos valores, em listas) está em values
+	FilterNotIn   = "not_in"  // nenhum valor está em values
+)
+
+// DetailsPrefix marca, nos filtros, um campo dos detalhes do marco em vez de
+// um campo do lead (details.cnpj_found).
+const DetailsPrefix = "details."
+
+// Tipos de integração: webhook recebe o evento assinado num envelope; rest
+// cria o registro no CRM com POST e depois o atualiza pelo ID devolvido.
+const (
+	IntegrationWebhook = "webhook"
+	IntegrationREST    = "rest"
+)
+
+var IntegrationKinds = []string{IntegrationWebhook, IntegrationREST}
+
+// Situações de uma entrega.
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// Integration envia os leads do workspace para um sistema externo. Mapping
+// liga cada campo do destino (com "." para objetos aninhados) a um campo do
+// lead ou custom.<chave>; vazio usa o mapeamento padrão. Secret assina o
+// corpo com HMAC-SHA256 e Headers vai em toda requisição (por exemplo o token
+// do CRM).
+type Integration struct {
+	ID          uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	WorkspaceID uuid.UUID `gorm:"type:uuid;index" json:"workspace_id"`
+	Name        string    `gorm:"size:255" json:"name"`
+	Kind        string    `gorm:"size:20" json:"kind"`
+	URL         string    `gorm:"type:text" json:"url"`
+	Secret      string    `gorm:"size:128" json:"secret,omitempty"`
+
+	Events  pq.StringArray      `gorm:"type:text[]" json:"events"`
+	Filters []IntegrationFilter `gorm:"type:jsonb;serializer:json" json:"filters,omitempty"`
+	Mapping map[string]string   `gorm:"type:jsonb;serializer:json" json:"mapping,omitempty"`
+	Headers map[string]string   `gorm:"type:jsonb;serializer:json" json:"headers,omitempty"`
+
+	// Só para rest: método e URL da atualização ({id} vira o ID no CRM;
+	// padrão PATCH em URL/{id}) e o campo da resposta com o ID (padrão "id",
+	// com "." para campos aninhados).
+	UpdateMethod string `gorm:"size:10" json:"update_method,omitempty"`
+	UpdateURL    string `gorm:"type:text" json:"update_url,omitempty"`
+	IDField      string `gorm:"size:100" json:"id_field,omitempty"`
+
+	Active    bool      `gorm:"not null;default:true" json:"active"`
+	CreatedBy string    `gorm:"size:255" json:"created_by"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// IntegrationFilter restringe os leads enviados: todos os filtros da
+// integração precisam casar. Field é um campo do lead, custom.<chave> ou
+// details.<chave> (detalhes do marco, como details.cnpj_found).
+type IntegrationFilter struct {
+	Field  string   `json:"field"`
+	Op     string   `json:"op"`
+	Values []string `json:"values,omitempty"`
+}
+
+// Subscribes diz se a integração ativa assina o evento.
+func (i *Integration) Subscribes(event string) bool {
+	if !i.Active {
+		return false
+	}
+	for _, e := range i.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Matches diz se o lead (e os detalhes do marco, se houver) passa por todos
+// os filtros da integração.
+func (i *Integration) Matches(lead *Lead, details map[string]interface{}) bool {
+	for _, f := range i.Filters {
+		values := filterValues(lead, details, f.Field)
+		switch f.Op {
+		case FilterPresent:
+			if len(values) == 0 {
+				return false
+			}
+		case FilterMissing:
+			if len(values) > 0 {
+				return false
+			}
+		case FilterIn, FilterNotIn:
+			found := false
+			for _, v := range values {
+				for _, want := range f.Values {
+					found = found || strings.EqualFold(v, strings.TrimSpace(want))
+				}
+			}
+			if found != (f.Op == FilterIn) {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// filterValues devolve os valores não vazios do campo como texto; listas
+// viram um valor por item.
+func filterValues(lead *Lead, details map[string]interface{}, field string) []string {
+	var value interface{}
+	switch {
+	case strings.HasPrefix(field, DetailsPrefix):
+		value = details[strings.TrimPrefix(field, DetailsPrefix)]
+	case strings.HasPrefix(field, "custom."):
+		value = lead.CustomFields[strings.TrimPrefix(field, "custom.")]
+	default:
+		f := reflect.ValueOf(lead).Elem().FieldByName(field)
+		if !f.IsValid() {
+			return nil
+		}
+		value = f.Interface()
+	}
+
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case sql.NullTime:
+		if !v.Valid {
+			return nil
+		}
+		return []string{v.Time.Format("2006-01-02")}
+	case pq.StringArray:
+		return filterValuesOf([]string(v))
+	case []string:
+		return filterValuesOf(v)
+	case []interface{}:
+		var values []string
+		for _, item := range v {
+			values = append(values, filterValuesOf([]string{fmt.Sprint(item)})...)
+		}
+		return values
+	}
+	rv := reflect.ValueOf(value)
+	if rv.IsZero() {
+		return nil
+	}
+	return []string{fmt.Sprint(value)}
+}
+
+func filterValuesOf(list []string) []string {
+	var values []string
+	for _, s := range list {
+		if s = strings.TrimSpace(s); s != "" {
+			values = append(values, s)
+		}
+	}
+	return values
+}
+
+// IntegrationLink guarda o ID que o CRM deu ao lead numa integração rest.
+type IntegrationLink struct {
+	IntegrationID uuid.UUID `gorm:"type:uuid;primaryKey" json:"integration_id"`
+	LeadID        uuid.UUID `gorm:"type:uuid;primaryKey" json:"lead_id"`
+	ExternalID    string    `gorm:"size:255" json:"external_id"`
+	CreatedAt     time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt     time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// IntegrationDelivery é um evento a entregar (ou já entregue) a uma
+// integração. O corpo é montado na hora do envio com o lead como estiver, de
+// modo que várias alterações seguidas viram uma entrega só enquanto ela não
+// for tentada.
+type IntegrationDelivery struct {
+	ID            uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	WorkspaceID   uuid.UUID `gorm:"type:uuid;index" json:"workspace_id"`
+	IntegrationID uuid.UUID `gorm:"type:uuid;index" json:"integration_id"`
+	LeadID        uuid.UUID `gorm:"type:uuid;index" json:"lead_id"`
+	Event         string    `gorm:"size:50" json:"event"`
+	// Details são os dados do marco do enriquecimento, enviados no envelope.
+	Details map[string]interface{} `gorm:"type:jsonb;serializer:json" json:"details,omitempty"`
+
+	Status         string       `gorm:"size:20;index" json:"status"`
+	Attempts       int          `gorm:"default:0" json:"attempts"`
+	NextAttemptAt  time.Time    `gorm:"type:timestamptz;index" json:"next_attempt_at"`
+	LastStatusCode int          `json:"last_status_code,omitempty"`
+	LastError      string       `gorm:"type:text" json:"last_error,omitempty"`
+	DeliveredAt    sql.NullTime `gorm:"type:timestamptz" json:"delivered_at"`
+
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// DeliveryAttempt registra cada tentativa de uma entrega.
+type DeliveryAttempt struct {
+	ID         uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	DeliveryID uuid.UUID `gorm:"type:uuid;index" json:"delivery_id"`
+	Attempt    int       `json:"attempt"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Error      string    `gorm:"type:text" json:"error,omitempty"`
+	DurationMS int64     `json:"duration_ms"`
+	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// integrationsCache guarda as integrações ativas de cada workspace para não
+// consultá-las a cada lead gravado, como o cache do ICP.
+var (
+	integrationsMu    sync.RWMutex
+	integrationsCache = map[uuid.UUID][]Integration{}
+	integrationsGen   uint64
+)
+
+func loadIntegrations(tx *gorm.DB, workspaceID uuid.UUID) ([]Integration, error) {
+	integrationsMu.RLock()
+	cached, ok := integrationsCache[workspaceID]
+	gen := integrationsGen
+	integrationsMu.RUnlock()
+	if ok {
+		return cached, nil
+	}
+
+	if err := tx.Where("workspace_id = ? AND active", workspaceID).Find(&cached).Error; err != nil {
+		return nil, err
+	}
+	integrationsMu.Lock()
+	if gen == integrationsGen {
+		integrationsCache[workspaceID] = cached
+	}
+	integrationsMu.Unlock()
+	return cached, nil
+}
+
+func forgetIntegrations(workspaceID uuid.UUID) {
+	integrationsMu.Lock()
+	delete(integrationsCache, workspaceID)
+	integrationsGen++
+	integrationsMu.Unlock()
+}
+
+// coalesced são os eventos pendentes que já cobrem um novo evento: o corpo é
+// montado no envio, então uma criação ainda não enviada já leva a alteração.
+// Os marcos nunca se juntam, porque cada um traz os próprios detalhes.
+var coalesced = map[string][]string{
+	EventLeadCreated: {EventLeadCreated},
+	EventLeadUpdated: {EventLeadCreated, EventLeadUpdated},
+}
+
+// enqueueLeadEvent agenda, na mesma transação que gravou o lead, uma entrega
+// do evento para cada integração do workspace que o assina e cujos filtros o
+// lead satisfaz. Se já houver uma entrega equivalente do lead esperando a
+// primeira tentativa (e ainda não reservada pelo envio), ela serve para as
+// duas.
+func enqueueLeadEvent(tx *gorm.DB, lead *Lead, event string, details map[string]interface{}) error {
+	if lead.ID == uuid.Nil {
+		return nil
+	}
+	integrations, err := loadIntegrations(tx, lead.WorkspaceID)
+	if err != nil || len(integrations) == 0 {
+		return err
+	}
+	for _, integration := range integrations {
+		if !integration.Subscribes(event) || !integration.Matches(lead, details) {
+			continue
+		}
+		if events := coalesced[event]; len(events) > 0 {
+			var waiting int64
+			err := tx.Model(&IntegrationDelivery{}).
+				Where("integration_id = ? AND lead_id = ? AND event IN ? AND status = ? AND attempts = 0 AND next_attempt_at <= ?",
+					integration.ID, lead.ID, events, DeliveryPending, time.Now()).
+				Count(&waiting).Error
+			if err != nil {
+				return err
+			}
+			if waiting > 0 {
+				continue
+			}
+		}
+		delivery := IntegrationDelivery{
+			ID:            uuid.New(),
+			WorkspaceID:   lead.WorkspaceID,
+			IntegrationID: integration.ID,
+			LeadID:        lead.ID,
+			Event:         event,
+			Details:       details,
+			Status:        DeliveryPending,
+			NextAttemptAt: time.Now(),
+		}
+		if err := tx.Create(&delivery).Error; err != nil {
+			return fmt.Errorf("erro ao agendar a entrega para a integração %s: %v", integration.Name, err)
+		}
+	}
+	return nil
+}
+
+// EnqueueLeadMilestone agenda as entregas de um marco do enriquecimento do
+// lead, em qualquer workspace. Lead inexistente (apagado ou mesclado) é
+// ignorado.
+func EnqueueLeadMilestone(leadID uuid.UUID, event string, details map[string]interface{}) error {
+	var lead Lead
+	err := DB.First(&lead, "id = ?", leadID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Printf("Marco %s do lead %s ignorado: lead não encontrado", event, leadID)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return enqueueLeadEvent(DB, &lead, event, details)
+}
+
+// GetIntegrations lista as integrações do escopo.
+func GetIntegrations(scope Scope) ([]Integration, error) {
+	var integrations []Integration
+	result := scope.db().Order("created_at").Find(&integrations)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return integrations, nil
+}
+
+// GetIntegration devolve a integração do escopo, ou nil se ela não existir.
+func GetIntegration(scope Scope, id uuid.UUID) (*Integration, error) {
+	var integration Integration
+	err := scope.db().First(&integration, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &integration, nil
+}
+
+// CreateIntegration grava uma integração nova no workspace do escopo.
+func CreateIntegration(scope Scope, integration *Integration) error {
+	integration.ID = uuid.New()
+	integration.WorkspaceID = scope.target()
+	integration.Active = true
+	if err := DB.Create(integration).Error; err != nil {
+		return fmt.Errorf("erro ao criar a integração: %v", err)
+	}
+	forgetIntegrations(integration.WorkspaceID)
+	log.Printf("Integração %s (%s) criada no %s", integration.Name, integration.Kind, scope)
+	return nil
+}
+
+// SaveIntegration grava a alteração de uma integração existente.
+func SaveIntegration(integration *Integration) error {
+	result := DB.Model(integration).
+		Select("name", "url", "secret", "events", "filters", "mapping", "headers", "update_method", "update_url", "id_field", "active", "updated_at").
+		Updates(integration)
+	if result.Error != nil {
+		return fmt.Errorf("erro ao atualizar a integração: %v", result.Error)
+	}
+	forgetIntegrations(integration.WorkspaceID)
+	return nil
+}
+
+// DeleteIntegration apaga a integração com as entregas, tentativas e IDs no
+// CRM.
+func DeleteIntegration(integration *Integration) error {
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		deliveries := tx.Model(&IntegrationDelivery{}).Select("id").Where("integration_id = ?", integration.ID)
+		if err := tx.Where("delivery_id IN (?)", deliveries).Delete(&DeliveryAttempt{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("integration_id = ?", integration.ID).Delete(&IntegrationDelivery{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("integration_id = ?", integration.ID).Delete(&IntegrationLink{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(integration).Error
+	})
+	if err != nil {
+		return fmt.Errorf("erro ao apagar a integração: %v", err)
+	}
+	forgetIntegrations(integration.WorkspaceID)
+	log.Printf("Integração %s apagada do workspace %s", integration.Name, integration.WorkspaceID)
+	return nil
+}
+
+// ClaimDueDeliveries reserva até limit entregas pendentes cuja vez já chegou,
+// adiando a próxima tentativa em lease para que outro ciclo (ou outra
+// instância da API) não as pegue enquanto são enviadas.
+func ClaimDueDeliveries(now time.Time, limit int, lease time.Duration) ([]IntegrationDelivery, error) {
+	var deliveries []IntegrationDelivery
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", DeliveryPending, now).
+			Order("next_attempt_at").
+			Limit(limit).
+			Find(&deliveries)
+		if result.Error != nil || len(deliveries) == 0 {
+			return result.Error
+		}
+		ids := make([]uuid.UUID, len(deliveries))
+		for i := range deliveries {
+			ids[i] = deliveries[i].ID
+		}
+		return tx.Model(&IntegrationDelivery{}).Where("id IN ?", ids).
+			UpdateColumn("next_attempt_at", now.Add(lease)).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("erro ao reservar entregas: %v", err)
+	}
+	return deliveries, nil
+}
+
+// RecordDeliveryAttempt grava a tentativa e a nova situação da entrega.
+func RecordDeliveryAttempt(delivery *IntegrationDelivery, attempt *DeliveryAttempt) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		attempt.ID = uuid.New()
+		attempt.DeliveryID = delivery.ID
+		if err := tx.Create(attempt).Error; err != nil {
+			return err
+		}
+		return tx.Model(delivery).
+			Select("status", "attempts", "next_attempt_at", "last_status_code", "last_error", "delivered_at", "updated_at").
+			Updates(delivery).Error
+	})
+}
+
+// GetIntegrationLink devolve o ID do lead no CRM da integração, ou "" se o
+// lead ainda não foi criado lá.
+func GetIntegrationLink(integrationID, leadID uuid.UUID) (string, error) {
+	var link IntegrationLink
+	err := DB.First(&link, "integration_id = ? AND lead_id = ?", integrationID, leadID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return link.ExternalID, nil
+}
+
+// SaveIntegrationLink grava o ID que o CRM deu ao lead.
+func SaveIntegrationLink(integrationID, leadID uuid.UUID, externalID string) error {
+	link := IntegrationLink{IntegrationID: integrationID, LeadID: leadID, ExternalID: externalID}
+	return DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "integration_id"}, {Name: "lead_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"external_id", "updated_at"}),
+	}).Create(&link).Error
+}
+
+// DeliveryFilter são os filtros da listagem de entregas.
+type DeliveryFilter struct {
+	IntegrationID *uuid.UUID
+	LeadID        *uuid.UUID
+	Event         string
+	Status        string
+	Limit         int
+}
+
+// GetIntegrationDeliveries lista as entregas do escopo, das mais recentes
+// para as mais antigas.
+func GetIntegrationDeliveries(scope Scope, filter DeliveryFilter) ([]IntegrationDelivery, error) {
+	tx := scope.db()
+	if filter.IntegrationID != nil {
+		tx = tx.Where("integration_id = ?", *filter.IntegrationID)
+	}
+	if filter.LeadID != nil {
+		tx = tx.Where("lead_id = ?", *filter.LeadID)
+	}
+	if filter.Event != "" {
+		tx = tx.Where("event = ?", filter.Event)
+	}
+	if filter.Status != "" {
+		tx = tx.Where("status = ?", filter.Status)
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = 100
+	}
+	var deliveries []IntegrationDelivery
+	result := tx.Order("created_at DESC").Limit(filter.Limit).Find(&deliveries)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return deliveries, nil
+}
+
+// GetIntegrationDelivery devolve a entrega do escopo, ou nil se ela não
+// existir.
+func GetIntegrationDelivery(scope Scope, id uuid.UUID) (*IntegrationDelivery, error) {
+	var delivery IntegrationDelivery
+	err := scope.db().First(&delivery, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// GetDeliveryAttempts devolve as tentativas da entrega, da primeira à última.
+func GetDeliveryAttempts(deliveryID uuid.UUID) ([]DeliveryAttempt, error) {
+	var attempts []DeliveryAttempt
+	result := DB.Where("delivery_id = ?", deliveryID).Order("attempt").Find(&attempts)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return attempts, nil
+}
+
+// RetryIntegrationDelivery devolve uma entrega à fila para ser tentada de
+// novo agora.
+func RetryIntegrationDelivery(delivery *IntegrationDelivery) error {
+	delivery.Status, delivery.NextAttemptAt = DeliveryPending, time.Now()
+	return DB.Model(delivery).Select("status", "next_attempt_at", "updated_at").Updates(delivery).Error
+}
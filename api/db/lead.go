@@ -7,14 +7,20 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/wbrunovieira/LeadSearchVersion2/weburl"
 )
 
 type Lead struct {
-	ID uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	ID          uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	WorkspaceID uuid.UUID `gorm:"type:uuid;index"`
 
 	BusinessName   string       `gorm:"size:255"`
 	RegisteredName string       `gorm:"size:255"`
@@ -29,6 +35,8 @@ type Lead struct {
 	Phone          string       `gorm:"size:50"`
 	Whatsapp       string       `gorm:"size:50"`
 	Website        string       `gorm:"type:text"`
+	Domain         string       `gorm:"size:255;index"`
+	LinkInBio      string       `gorm:"type:text"`
 	Email          string       `gorm:"type:text"`
 
 	Instagram             string  `gorm:"type:text"`
@@ -54,34 +62,115 @@ type Lead struct {
 
 	BusinessStatus string `gorm:"type:text"`
 
-	Quality      string `gorm:"size:50"`
-	SearchTerm   string `gorm:"size:50"`
-	FieldsFilled int    `gorm:"default:0"`
-	GoogleId     string `gorm:"type:text"`
+	// Quality, FieldsFilled e CompletenessScore são calculados ao gravar o
+	// lead (ver completeness.go).
+	Quality           string  `gorm:"size:50;index"`
+	SearchTerm        string  `gorm:"size:50"`
+	FieldsFilled      int     `gorm:"default:0"`
+	CompletenessScore float64 `gorm:"type:numeric;index"`
+	GoogleId          string  `gorm:"type:text"`
+
+	// ICPScore é a nota do lead pelas regras de ICP ativas do workspace, na
+	// versão ICPVersion (0 sem regras). Também é calculada ao gravar (ver
+	// icp.go).
+	ICPScore   float64 `gorm:"type:numeric;index"`
+	ICPVersion int     `gorm:"default:0"`
+	icp        *leadICP
 
 	Category string `gorm:"type:text"`
 	Radius   int    `gorm:"default:0"`
 
+	Latitude      float64 `gorm:"type:numeric"`
+	Longitude     float64 `gorm:"type:numeric"`
+	GoogleMapsURL string  `gorm:"type:text"`
+	OpeningHours  string  `gorm:"type:text"`
+
+	DetailsFetchedAt sql.NullTime `gorm:"type:timestamptz"`
+	// DetailsAttemptedAt é a última tentativa sem sucesso de atualizar os
+	// detalhes; tira o lead do começo da fila de GetStalePlaceIDs.
+	DetailsAttemptedAt sql.NullTime `gorm:"type:timestamptz"`
+
+	SearchJobID   string `gorm:"size:64;index"`
+	SavedSearchID string `gorm:"size:36;index"`
+
+	// Stage é a etapa do lead no funil de vendas (ver pipeline.go) e
+	// AssignedTo o vendedor responsável. Os dois só mudam pelas rotas do
+	// funil, que registram a mudança em lead_activities.
+	Stage          string       `gorm:"size:20;index;default:new"`
+	StageChangedAt sql.NullTime `gorm:"type:timestamptz"`
+	AssignedTo     string       `gorm:"size:255;index"`
+
+	// Tags são rótulos livres do lead, já normalizados (minúsculas, sem
+	// repetição). CustomFields guarda os valores dos campos personalizados do
+	// workspace, pela chave da definição (ver custom_field.go).
+	Tags         pq.StringArray         `gorm:"type:text[];index:,type:gin"`
+	CustomFields map[string]interface{} `gorm:"type:jsonb;serializer:json"`
+
+	// MergedIntoID aponta para o lead que absorveu este numa mesclagem de
+	// duplicatas. Leads mesclados saem da listagem.
+	MergedIntoID *uuid.UUID `gorm:"type:uuid;index"`
+
 	CreatedAt time.Time `gorm:"autoCreateTime"`
 	UpdatedAt time.Time `gorm:"autoUpdateTime"`
 }
 
-func CreateLead(lead *Lead) error {
-	var existingLead Lead
+// BeforeSave mantém Domain em dia com Website e recalcula a completude e a
+// pontuação de ICP, qualquer que seja o caminho que alterou o lead.
+func (l *Lead) BeforeSave(tx *gorm.DB) error {
+	l.Domain = weburl.DomainOf(l.Website)
+	if l.Stage == "" {
+		l.Stage = StageNew
+	}
+	l.applyCompleteness()
+	return l.applyICP(tx)
+}
 
-	result := DB.Where("google_id = ?", lead.GoogleId).First(&existingLead)
-	if result.Error == nil {
-		log.Printf("Lead com GoogleId %s já existe. Ignorando inserção.", lead.GoogleId)
+// AfterSave registra a pontuação de ICP no histórico do lead.
+func (l *Lead) AfterSave(tx *gorm.DB) error {
+	return l.recordICPScore(tx)
+}
 
-		*lead = existingLead
-		return nil
+// AfterCreate e AfterUpdate agendam o evento do lead para as integrações do
+// workspace; a criação é também o marco lead.saved. Gravações por
+// UpdateColumns não passam por aqui e agendam o evento por conta própria
+// quando interessa ao CRM (etapa e responsável).
+func (l *Lead) AfterCreate(tx *gorm.DB) error {
+	if err := enqueueLeadEvent(tx, l, EventLeadCreated, nil); err != nil {
+		return err
 	}
+	return enqueueLeadEvent(tx, l, EventLeadSaved, map[string]interface{}{"source": l.Source})
+}
+
+func (l *Lead) AfterUpdate(tx *gorm.DB) error {
+	return enqueueLeadEvent(tx, l, EventLeadUpdated, nil)
+}
+
+// CreateLead grava o lead no workspace do escopo. Se o workspace já tiver um
+// lead com o mesmo GoogleId, o lead existente é devolvido em vez de duplicar.
+func CreateLead(scope Scope, lead *Lead) error {
+	lead.WorkspaceID = scope.target()
+
+	if lead.GoogleId != "" {
+		var existingLead Lead
+		result := DB.Where("workspace_id = ? AND google_id = ?", lead.WorkspaceID, lead.GoogleId).First(&existingLead)
+		if result.Error == nil {
+			log.Printf("Lead com GoogleId %s já existe no workspace. Ignorando inserção.", lead.GoogleId)
+
+			if existingLead.MergedIntoID != nil {
+				if err := DB.First(&existingLead, "id = ?", *existingLead.MergedIntoID).Error; err != nil {
+					return fmt.Errorf("erro ao buscar o lead que absorveu o GoogleId %s: %v", lead.GoogleId, err)
+				}
+			}
+			*lead = existingLead
+			return nil
+		}
 
-	if result.Error != nil && result.Error != gorm.ErrRecordNotFound {
-		return fmt.Errorf("erro ao verificar se o lead já existe: %v", result.Error)
+		if result.Error != nil && result.Error != gorm.ErrRecordNotFound {
+			return fmt.Errorf("erro ao verificar se o lead já existe: %v", result.Error)
+		}
 	}
 
-	result = DB.Create(lead)
+	result := DB.Create(lead)
 	if result.Error != nil {
 		return fmt.Errorf("falha ao salvar lead no banco de dados: %v", result.Error)
 	}
@@ -89,30 +178,179 @@ func CreateLead(lead *Lead) error {
 	return nil
 }
 
-func GetLeads() ([]Lead, error) {
+func GetLeads(scope Scope) ([]Lead, error) {
+	return ListLeads(scope, LeadFilter{})
+}
+
+// ErrInvalidFilter indica um filtro ou ordenação desconhecidos em ListLeads.
+var ErrInvalidFilter = errors.New("filtro de leads inválido")
+
+// LeadFilter são os filtros e a ordenação da listagem de leads. Sort é o
+// nome de um campo de leadSorts, com "-" na frente para ordem decrescente.
+type LeadFilter struct {
+	Qualities       []string
+	MinCompleteness float64
+	MinICPScore     *float64
+	Stages          []string
+	AssignedTo      string
+	// Tags filtra os leads que têm todas as tags; Custom, os que têm cada
+	// campo personalizado com o valor informado.
+	Tags   []string
+	Custom map[string]string
+	Sort   string
+}
+
+var leadSorts = map[string]string{
+	"completeness":  "completeness_score",
+	"icp":           "icp_score",
+	"fields_filled": "fields_filled",
+	"created_at":    "created_at",
+	"name":          "business_name",
+}
+
+// leadQuery monta a consulta dos leads do escopo que passam no filtro. Leads
+// mesclados em outro ficam de fora.
+func leadQuery(scope Scope, filter LeadFilter) (*gorm.DB, error) {
+	tx := scope.db().Where("merged_into_id IS NULL")
+	if len(filter.Qualities) > 0 {
+		tx = tx.Where("quality IN ?", filter.Qualities)
+	}
+	if filter.MinCompleteness > 0 {
+		tx = tx.Where("completeness_score >= ?", filter.MinCompleteness)
+	}
+	for _, stage := range filter.Stages {
+		if !validStage(stage) {
+			return nil, fmt.Errorf("%w: etapa %q desconhecida", ErrInvalidFilter, stage)
+		}
+	}
+	if len(filter.Stages) > 0 {
+		tx = tx.Where("stage IN ?", filter.Stages)
+	}
+	if filter.AssignedTo != "" {
+		tx = tx.Where("assigned_to = ?", filter.AssignedTo)
+	}
+	if len(filter.Tags) > 0 {
+		tx = tx.Where("tags @> ?", pq.StringArray(filter.Tags))
+	}
+	for key, value := range filter.Custom {
+		tx = tx.Where("custom_fields ->> ?::text = ?", key, value)
+	}
+	if filter.MinICPScore != nil {
+		tx = tx.Where("icp_score >= ?", *filter.MinICPScore)
+	}
+	if filter.Sort != "" {
+		name, desc := strings.TrimPrefix(filter.Sort, "-"), strings.HasPrefix(filter.Sort, "-")
+		column, ok := leadSorts[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: ordenação %q desconhecida", ErrInvalidFilter, filter.Sort)
+		}
+		tx = tx.Order(clause.OrderByColumn{Column: clause.Column{Name: column}, Desc: desc}).Order("created_at")
+	}
+	return tx, nil
+}
+
+// ListLeads devolve os leads do escopo que passam no filtro.
+func ListLeads(scope Scope, filter LeadFilter) ([]Lead, error) {
+	tx, err := leadQuery(scope, filter)
+	if err != nil {
+		return nil, err
+	}
 	var leads []Lead
-	result := DB.Find(&leads)
+	result := tx.Find(&leads)
 	if result.Error != nil {
 		return nil, result.Error
 	}
 	return leads, nil
 }
 
-func GetLeadByGoogleId(googleId string) (*Lead, error) {
-	var lead Lead
-	result := DB.Where("google_id = ?", googleId).First(&lead)
-	if result.Error != nil {
-		if result.Error == gorm.ErrRecordNotFound {
-			return nil, fmt.Errorf("Lead não encontrado")
+// StreamLeads chama fn para cada lead do escopo que passa no filtro, lendo
+// uma linha do Postgres por vez em vez de carregar todos os leads. Sem
+// ordenação no filtro, os leads vêm em ordem de criação. Um erro de fn
+// interrompe a leitura e é devolvido.
+func StreamLeads(scope Scope, filter LeadFilter, fn func(*Lead) error) error {
+	tx, err := leadQuery(scope, filter)
+	if err != nil {
+		return err
+	}
+	if filter.Sort == "" {
+		tx = tx.Order("created_at")
+	}
+	rows, err := tx.Model(&Lead{}).Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var lead Lead
+		if err := DB.ScanRows(rows, &lead); err != nil {
+			return err
+		}
+		if err := fn(&lead); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// FindExistingLead procura no workspace do escopo um lead da mesma empresa
+// pelo CNPJ, pelo domínio do site ou por um dos telefones (inclusive os de
+// lead_phones), nessa ordem. Devolve o lead e o critério que casou ("cnpj",
+// "domain" ou "phone"), ou nil se não houver nenhum.
+func FindExistingLead(scope Scope, lead *Lead) (*Lead, string, error) {
+	var phones []string
+	for _, p := range []string{lead.Phone, lead.Whatsapp} {
+		if p != "" {
+			phones = append(phones, p)
 		}
+	}
+	checks := []struct {
+		match string
+		ok    bool
+		query func(*gorm.DB) *gorm.DB
+	}{
+		{"cnpj", lead.CompanyRegistrationID != "", func(tx *gorm.DB) *gorm.DB {
+			return tx.Where("company_registration_id = ?", lead.CompanyRegistrationID)
+		}},
+		{"domain", weburl.DomainOf(lead.Website) != "", func(tx *gorm.DB) *gorm.DB {
+			return tx.Where("domain = ?", weburl.DomainOf(lead.Website))
+		}},
+		{"phone", len(phones) > 0, func(tx *gorm.DB) *gorm.DB {
+			return tx.Where("(phone IN ? OR whatsapp IN ? OR id IN (?))", phones, phones,
+				DB.Model(&LeadPhone{}).Select("lead_id").Where("number IN ?", phones))
+		}},
+	}
+	for _, c := range checks {
+		if !c.ok {
+			continue
+		}
+		var existing Lead
+		err := c.query(DB.Where("workspace_id = ? AND merged_into_id IS NULL", scope.target())).
+			Order("created_at").First(&existing).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		return &existing, c.match, nil
+	}
+	return nil, "", nil
+}
+
+// GetLeadsByGoogleId devolve os leads do estabelecimento no escopo: no máximo
+// um por workspace.
+func GetLeadsByGoogleId(scope Scope, googleId string) ([]Lead, error) {
+	var leads []Lead
+	result := scope.db().Where("google_id = ?", googleId).Find(&leads)
+	if result.Error != nil {
 		return nil, result.Error
 	}
-	return &lead, nil
+	return leads, nil
 }
 
-func GetLeadIdByGoogleId(googleId string) (uuid.UUID, error) {
+func GetLeadIdByGoogleId(scope Scope, googleId string) (uuid.UUID, error) {
 	var lead Lead
-	result := DB.Select("id").Where("google_id = ?", googleId).First(&lead)
+	result := scope.db().Select("id").Where("google_id = ?", googleId).First(&lead)
 	if result.Error != nil {
 		if result.Error == gorm.ErrRecordNotFound {
 			return uuid.Nil, fmt.Errorf("Lead não encontrado para o Google ID: %s", googleId)
@@ -122,10 +360,10 @@ func GetLeadIdByGoogleId(googleId string) (uuid.UUID, error) {
 	return lead.ID, nil
 }
 
-func GetLeadByID(leadID uuid.UUID) (*Lead, error) {
-	log.Printf("GetLeadByID: Buscando lead com ID: %s", leadID.String())
+func GetLeadByID(scope Scope, leadID uuid.UUID) (*Lead, error) {
+	log.Printf("GetLeadByID: Buscando lead com ID: %s (%s)", leadID.String(), scope)
 	var lead Lead
-	result := DB.First(&lead, "id = ?", leadID)
+	result := scope.db().First(&lead, "id = ?", leadID)
 	if result.Error != nil {
 		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
 			log.Printf("GetLeadByID: Lead não encontrado para ID: %s", leadID.String())
@@ -138,9 +376,20 @@ func GetLeadByID(leadID uuid.UUID) (*Lead, error) {
 	return &lead, nil
 }
 
-func UpdateLead(lead *Lead) error {
+// SaveLead grava o lead como está, sem a mesclagem campo a campo de UpdateLead.
+func SaveLead(scope Scope, lead *Lead) error {
+	if !scope.allows(lead.WorkspaceID) {
+		return fmt.Errorf("lead %s fora do %s", lead.ID, scope)
+	}
+	if result := DB.Save(lead); result.Error != nil {
+		return fmt.Errorf("erro ao salvar o lead: %v", result.Error)
+	}
+	return nil
+}
+
+func UpdateLead(scope Scope, lead *Lead) error {
 	log.Printf("UpdateLead: Tentando atualizar lead com ID: %s", lead.ID.String())
-	existingLead, err := GetLeadByID(lead.ID)
+	existingLead, err := GetLeadByID(scope, lead.ID)
 	if err != nil {
 		log.Printf("UpdateLead: Erro ao buscar o lead: %v", err)
 		return fmt.Errorf("erro ao buscar o lead: %v", err)
@@ -164,6 +413,7 @@ func UpdateLead(lead *Lead) error {
 	existingLead.Phone = lead.Phone
 	existingLead.Whatsapp = lead.Whatsapp
 	existingLead.Website = lead.Website
+	existingLead.LinkInBio = lead.LinkInBio
 	existingLead.Email = lead.Email
 	existingLead.Instagram = lead.Instagram
 	existingLead.Facebook = lead.Facebook
@@ -196,6 +446,13 @@ func UpdateLead(lead *Lead) error {
 	existingLead.FieldsFilled = lead.FieldsFilled
 	existingLead.Category = lead.Category
 	existingLead.Radius = lead.Radius
+	existingLead.Latitude = lead.Latitude
+	existingLead.Longitude = lead.Longitude
+	existingLead.GoogleMapsURL = lead.GoogleMapsURL
+	existingLead.OpeningHours = lead.OpeningHours
+	existingLead.DetailsFetchedAt = lead.DetailsFetchedAt
+	existingLead.Tags = lead.Tags
+	existingLead.CustomFields = lead.CustomFields
 
 	log.Printf("UpdateLead: Dados atualizados para salvar: %+v", existingLead)
 	result := DB.Save(existingLead)
@@ -0,0 +1,101 @@
+// /api/db/lead_domain.go
+package db
+
+import (
+	"log"
+
+	"github.com/google/uuid"
+
+	"github.com/wbrunovieira/LeadSearchVersion2/weburl"
+)
+
+// DomainDuplicate é um domínio compartilhado por mais de um lead do
+// workspace: os leads são possíveis duplicatas.
+type DomainDuplicate struct {
+	WorkspaceID uuid.UUID       `json:"workspace_id"`
+	Domain      string          `json:"domain"`
+	Leads       []DuplicateLead `json:"leads"`
+}
+
+type DuplicateLead struct {
+	ID           uuid.UUID `json:"id"`
+	BusinessName string    `json:"business_name"`
+	City         string    `json:"city"`
+	Website      string    `json:"website"`
+	GoogleId     string    `json:"google_id"`
+}
+
+// GetDomainDuplicates devolve os domínios com mais de um lead no escopo. Com
+// domain informado, devolve só aquele domínio. Leads de workspaces diferentes
+// não são duplicatas entre si.
+func GetDomainDuplicates(scope Scope, domain string) ([]DomainDuplicate, error) {
+	shared := scope.db().Model(&Lead{}).Select("workspace_id, domain").Where("domain <> ''").
+		Group("workspace_id, domain").Having("COUNT(*) > 1")
+	if domain != "" {
+		shared = shared.Where("domain = ?", domain)
+	}
+
+	var leads []Lead
+	result := scope.db().
+		Select("id, workspace_id, business_name, city, website, domain, google_id").
+		Where("(workspace_id, domain) IN (?)", shared).
+		Order("domain, workspace_id, created_at").
+		Find(&leads)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	duplicates := []DomainDuplicate{}
+	for _, lead := range leads {
+		n := len(duplicates)
+		if n == 0 || duplicates[n-1].Domain != lead.Domain || duplicates[n-1].WorkspaceID != lead.WorkspaceID {
+			duplicates = append(duplicates, DomainDuplicate{WorkspaceID: lead.WorkspaceID, Domain: lead.Domain})
+			n++
+		}
+		duplicates[n-1].Leads = append(duplicates[n-1].Leads, DuplicateLead{
+			ID:           lead.ID,
+			BusinessName: lead.BusinessName,
+			City:         lead.City,
+			Website:      lead.Website,
+			GoogleId:     lead.GoogleId,
+		})
+	}
+	return duplicates, nil
+}
+
+// CountLeadsWithDomain conta os outros leads do workspace do lead que têm o
+// mesmo domínio.
+func CountLeadsWithDomain(lead *Lead) (int64, error) {
+	if lead.Domain == "" {
+		return 0, nil
+	}
+	var count int64
+	result := DB.Model(&Lead{}).
+		Where("workspace_id = ? AND domain = ? AND id <> ?", lead.WorkspaceID, lead.Domain, lead.ID).
+		Count(&count)
+	return count, result.Error
+}
+
+// backfillLeadDomains preenche Domain dos leads gravados antes da coluna
+// existir. Grava direto na coluna, sem passar pelos hooks do Lead.
+func backfillLeadDomains() error {
+	var leads []Lead
+	if err := DB.Select("id, website").Where("website <> '' AND (domain IS NULL OR domain = '')").Find(&leads).Error; err != nil {
+		return err
+	}
+	filled := 0
+	for _, lead := range leads {
+		domain := weburl.DomainOf(lead.Website)
+		if domain == "" {
+			continue
+		}
+		if err := DB.Model(&Lead{}).Where("id = ?", lead.ID).UpdateColumn("domain", domain).Error; err != nil {
+			return err
+		}
+		filled++
+	}
+	if filled > 0 {
+		log.Printf("Domínio preenchido em %d leads existentes", filled)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+// /api/db/lead_phone.go
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm/clause"
+)
+
+// Origens dos telefones de um lead.
+const (
+	PhoneSourceGoogle     = "google_places"
+	PhoneSourceCNPJBiz    = "cnpj_biz"
+	PhoneSourceTavily     = "tavily"
+	PhoneSourceLLM        = "llm"
+	PhoneSourceManual     = "manual"
+	PhoneSourceEnrichment = "enrichment"
+	PhoneSourceImport     = "import"
+)
+
+// LeadPhone é um telefone do lead já normalizado em E.164. O mesmo número
+// aparece uma vez por origem.
+type LeadPhone struct {
+	ID     uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	LeadID uuid.UUID `gorm:"type:uuid;uniqueIndex:idx_lead_phones_lead_number_source" json:"lead_id"`
+
+	Number      string `gorm:"size:20;uniqueIndex:idx_lead_phones_lead_number_source" json:"number"`
+	Kind        string `gorm:"size:20" json:"kind"`
+	WhatsAppURL string `gorm:"type:text" json:"whatsapp_url,omitempty"`
+	Source      string `gorm:"size:50;uniqueIndex:idx_lead_phones_lead_number_source" json:"source"`
+
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// AddLeadPhones grava os telefones do lead, ignorando os que já existem para
+// a mesma origem. Devolve quantos eram novos.
+func AddLeadPhones(scope Scope, leadID uuid.UUID, phones []LeadPhone) (int64, error) {
+	if err := checkLeadInScope(scope, leadID); err != nil {
+		return 0, err
+	}
+	if len(phones) == 0 {
+		return 0, nil
+	}
+	for i := range phones {
+		phones[i].ID = uuid.New()
+		phones[i].LeadID = leadID
+	}
+	result := DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&phones)
+	if result.Error != nil {
+		return 0, fmt.Errorf("erro ao salvar telefones: %v", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+func GetLeadPhones(scope Scope, leadID uuid.UUID) ([]LeadPhone, error) {
+	var phones []LeadPhone
+	result := DB.Where("lead_id = ? AND lead_id IN (?)", leadID, scopedLeadIDs(scope)).Order("created_at").Find(&phones)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return phones, nil
+}
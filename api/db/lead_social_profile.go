@@ -0,0 +1,62 @@
+// /api/db/lead_social_profile.go
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm/clause"
+)
+
+// Origens dos perfis sociais de um lead.
+const (
+	SocialSourceGoogle     = "google_places"
+	SocialSourceTavily     = "tavily"
+	SocialSourceLLM        = "llm"
+	SocialSourceEnrichment = "enrichment"
+)
+
+// LeadSocialProfile é um perfil de rede social do lead, com a URL canônica.
+// Instagram, Facebook e TikTok também vão para as colunas do lead; LinkedIn,
+// YouTube e X só existem aqui.
+type LeadSocialProfile struct {
+	ID     uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	LeadID uuid.UUID `gorm:"type:uuid;uniqueIndex:idx_lead_social_profiles_lead_url_source" json:"lead_id"`
+
+	Network string `gorm:"size:20;index" json:"network"`
+	Handle  string `gorm:"size:255" json:"handle"`
+	URL     string `gorm:"type:text;uniqueIndex:idx_lead_social_profiles_lead_url_source" json:"url"`
+	Source  string `gorm:"size:50;uniqueIndex:idx_lead_social_profiles_lead_url_source" json:"source"`
+
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// AddLeadSocialProfiles grava os perfis do lead, ignorando os que já existem
+// para a mesma origem. Devolve quantos eram novos.
+func AddLeadSocialProfiles(scope Scope, leadID uuid.UUID, profiles []LeadSocialProfile) (int64, error) {
+	if err := checkLeadInScope(scope, leadID); err != nil {
+		return 0, err
+	}
+	if len(profiles) == 0 {
+		return 0, nil
+	}
+	for i := range profiles {
+		profiles[i].ID = uuid.New()
+		profiles[i].LeadID = leadID
+	}
+	result := DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&profiles)
+	if result.Error != nil {
+		return 0, fmt.Errorf("erro ao salvar perfis sociais: %v", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+func GetLeadSocialProfiles(scope Scope, leadID uuid.UUID) ([]LeadSocialProfile, error) {
+	var profiles []LeadSocialProfile
+	result := DB.Where("lead_id = ? AND lead_id IN (?)", leadID, scopedLeadIDs(scope)).Order("network, created_at").Find(&profiles)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return profiles, nil
+}
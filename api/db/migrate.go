@@ -12,10 +12,28 @@ func Migrate() error {
 		log.Fatalf("Falha ao criar a extensão uuid-ossp: %v", err)
 	}
 
-	err = DB.AutoMigrate(&Lead{})
+	err = DB.AutoMigrate(&Workspace{}, &Lead{}, &LeadReview{}, &LeadPhoto{}, &LeadPhone{}, &LeadSocialProfile{}, &DuplicateCandidate{}, &LeadMerge{}, &ICPRuleSet{}, &LeadICPScore{}, &LeadActivity{}, &CustomFieldDefinition{}, &Integration{}, &IntegrationLink{}, &IntegrationDelivery{}, &DeliveryAttempt{}, &SavedSearch{}, &User{})
 	if err != nil {
 		panic("Falha ao migrar banco de dados: " + err.Error())
 	}
 
+	if err := ensureDefaultWorkspace(); err != nil {
+		return err
+	}
+
+	// O mesmo estabelecimento pode ser lead de dois workspaces, mas só uma vez
+	// em cada. Leads sem google_id (importados) ficam fora do índice.
+	err = DB.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_leads_workspace_google_id ON leads (workspace_id, google_id) WHERE google_id <> ''").Error
+	if err != nil {
+		log.Printf("Não foi possível criar o índice único de google_id por workspace: %v", err)
+	}
+
+	if err := backfillLeadDomains(); err != nil {
+		log.Printf("Não foi possível preencher o domínio dos leads existentes: %v", err)
+	}
+	if err := RecalculateCompleteness(); err != nil {
+		log.Printf("Não foi possível recalcular a completude dos leads: %v", err)
+	}
+
 	return nil
 }
@@ -0,0 +1,295 @@
+// /api/db/pipeline.go
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Etapas do funil de vendas, na ordem em que o lead avança.
+const (
+	StageNew       = "new"
+	StageContacted = "contacted"
+	StageQualified = "qualified"
+	StageProposal  = "proposal"
+	StageWon       = "won"
+	StageLost      = "lost"
+)
+
+var PipelineStages = []string{StageNew, StageContacted, StageQualified, StageProposal, StageWon, StageLost}
+
+// Tipos de atividade. Ligações, e-mails, reuniões e notas são registrados
+// pelos vendedores; mudanças de etapa e de responsável, pela API.
+const (
+	ActivityCall        = "call"
+	ActivityEmail       = "email"
+	ActivityMeeting     = "meeting"
+	ActivityNote        = "note"
+	ActivityStageChange = "stage_change"
+	ActivityAssignment  = "assignment"
+)
+
+// ManualActivities são os tipos que podem ser registrados por
+// /lead-activities.
+var ManualActivities = []string{ActivityCall, ActivityEmail, ActivityMeeting, ActivityNote}
+
+// ErrInvalidStage indica uma etapa fora de PipelineStages.
+var ErrInvalidStage = errors.New("etapa desconhecida")
+
+// LeadActivity é um registro na linha do tempo do lead. OccurredAt é quando a
+// atividade aconteceu (uma ligação pode ser registrada depois); FromStage e
+// ToStage só existem nas mudanças de etapa.
+type LeadActivity struct {
+	ID          uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	WorkspaceID uuid.UUID `gorm:"type:uuid;index" json:"workspace_id"`
+	LeadID      uuid.UUID `gorm:"type:uuid;index" json:"lead_id"`
+
+	Type      string `gorm:"size:20;index" json:"type"`
+	Body      string `gorm:"type:text" json:"body,omitempty"`
+	FromStage string `gorm:"size:20" json:"from_stage,omitempty"`
+	ToStage   string `gorm:"size:20" json:"to_stage,omitempty"`
+	Author    string `gorm:"size:255" json:"author"`
+
+	OccurredAt time.Time `gorm:"type:timestamptz;index" json:"occurred_at"`
+	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func validStage(stage string) bool {
+	for _, s := range PipelineStages {
+		if s == stage {
+			return true
+		}
+	}
+	return false
+}
+
+// findLeadForUpdate busca o lead do escopo dentro da transação e trava a linha
+// (SELECT ... FOR UPDATE) até o fim dela, para duas mudanças de etapa ou de
+// responsável no mesmo lead não se sobreporem. Devolve nil se ele não existir.
+func findLeadForUpdate(tx *gorm.DB, scope Scope, leadID uuid.UUID) (*Lead, error) {
+	var lead Lead
+	err := scope.apply(tx).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("merged_into_id IS NULL").First(&lead, "id = ?", leadID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &lead, nil
+}
+
+// MoveLeadStage leva o lead para a etapa e registra a mudança, com a nota
+// opcional, na linha do tempo. Devolve nil se o lead não existir no escopo.
+func MoveLeadStage(scope Scope, leadID uuid.UUID, stage, author, note string) (*Lead, error) {
+	if !validStage(stage) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidStage, stage)
+	}
+	var lead *Lead
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if lead, err = findLeadForUpdate(tx, scope, leadID); err != nil || lead == nil {
+			return err
+		}
+		if lead.Stage == stage {
+			return nil
+		}
+
+		now := time.Now()
+		activity := LeadActivity{
+			ID:          uuid.New(),
+			WorkspaceID: lead.WorkspaceID,
+			LeadID:      lead.ID,
+			Type:        ActivityStageChange,
+			Body:        note,
+			FromStage:   lead.Stage,
+			ToStage:     stage,
+			Author:      author,
+			OccurredAt:  now,
+		}
+		if err := tx.Create(&activity).Error; err != nil {
+			return err
+		}
+		lead.Stage, lead.StageChangedAt, lead.UpdatedAt = stage, sql.NullTime{Time: now, Valid: true}, now
+		err = tx.Model(&Lead{}).Where("id = ?", lead.ID).UpdateColumns(map[string]interface{}{
+			"stage":            stage,
+			"stage_changed_at": now,
+			"updated_at":       now,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return enqueueLeadEvent(tx, lead, EventLeadUpdated, nil)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("erro ao mudar a etapa do lead %s: %v", leadID, err)
+	}
+	if lead != nil {
+		log.Printf("Lead %s na etapa %s (%s)", lead.ID, lead.Stage, author)
+	}
+	return lead, nil
+}
+
+// AssignLead define o responsável pelo lead (vazio tira o responsável) e
+// registra a troca na linha do tempo. Devolve nil se o lead não existir no
+// escopo.
+func AssignLead(scope Scope, leadID uuid.UUID, assignee, author string) (*Lead, error) {
+	var lead *Lead
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if lead, err = findLeadForUpdate(tx, scope, leadID); err != nil || lead == nil {
+			return err
+		}
+		if lead.AssignedTo == assignee {
+			return nil
+		}
+
+		now := time.Now()
+		body := "Responsável: " + assignee
+		if assignee == "" {
+			body = "Sem responsável (antes: " + lead.AssignedTo + ")"
+		}
+		activity := LeadActivity{
+			ID:          uuid.New(),
+			WorkspaceID: lead.WorkspaceID,
+			LeadID:      lead.ID,
+			Type:        ActivityAssignment,
+			Body:        body,
+			Author:      author,
+			OccurredAt:  now,
+		}
+		if err := tx.Create(&activity).Error; err != nil {
+			return err
+		}
+		lead.AssignedTo, lead.UpdatedAt = assignee, now
+		err = tx.Model(&Lead{}).Where("id = ?", lead.ID).UpdateColumns(map[string]interface{}{
+			"assigned_to": assignee,
+			"updated_at":  now,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return enqueueLeadEvent(tx, lead, EventLeadUpdated, nil)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("erro ao atribuir o lead %s: %v", leadID, err)
+	}
+	return lead, nil
+}
+
+// AddLeadActivity registra uma atividade na linha do tempo do lead.
+func AddLeadActivity(scope Scope, activity *LeadActivity) error {
+	var lead Lead
+	if err := scope.db().Select("id", "workspace_id").First(&lead, "id = ?", activity.LeadID).Error; err != nil {
+		return fmt.Errorf("lead %s fora do %s", activity.LeadID, scope)
+	}
+	activity.ID = uuid.New()
+	activity.WorkspaceID = lead.WorkspaceID
+	if activity.OccurredAt.IsZero() {
+		activity.OccurredAt = time.Now()
+	}
+	if err := DB.Create(activity).Error; err != nil {
+		return fmt.Errorf("erro ao registrar atividade: %v", err)
+	}
+	return nil
+}
+
+// GetLeadActivities devolve a linha do tempo do lead, da atividade mais
+// recente para a mais antiga.
+func GetLeadActivities(scope Scope, leadID uuid.UUID) ([]LeadActivity, error) {
+	var activities []LeadActivity
+	result := scope.db().Where("lead_id = ?", leadID).Order("occurred_at DESC, created_at DESC").Find(&activities)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return activities, nil
+}
+
+// ConversionRow é uma linha do relatório de conversão. Rate é a fração dos
+// leads do grupo que foram ganhos e WinRate a fração dos encerrados (ganhos
+// ou perdidos) que foram ganhos.
+type ConversionRow struct {
+	Category string         `json:"category,omitempty"`
+	City     string         `json:"city,omitempty"`
+	Total    int            `json:"total"`
+	Stages   map[string]int `json:"stages"`
+	Rate     float64        `json:"conversion_rate"`
+	WinRate  float64        `json:"win_rate"`
+}
+
+// ConversionGroups são os agrupamentos aceitos pelo relatório de conversão.
+var ConversionGroups = map[string]string{
+	"category": "category",
+	"city":     "city",
+}
+
+// GetConversionReport conta os leads do escopo por etapa, agrupados pelas
+// colunas de groupBy (chaves de ConversionGroups), do grupo com mais leads
+// para o com menos.
+func GetConversionReport(scope Scope, groupBy []string) ([]ConversionRow, error) {
+	selects := map[string]string{"category": "'' AS category", "city": "'' AS city"}
+	var groups []string
+	for _, g := range groupBy {
+		column, ok := ConversionGroups[g]
+		if !ok {
+			return nil, fmt.Errorf("%w: agrupamento %q desconhecido", ErrInvalidFilter, g)
+		}
+		selects[g] = "COALESCE(" + column + ", '') AS " + g
+		groups = append(groups, column)
+	}
+
+	var counts []struct {
+		Category string
+		City     string
+		Stage    string
+		Count    int
+	}
+	query := scope.db().Model(&Lead{}).
+		Select(selects["category"] + ", " + selects["city"] + ", stage, COUNT(*) AS count").
+		Where("merged_into_id IS NULL")
+	for _, column := range append(groups, "stage") {
+		query = query.Group(column)
+	}
+	if err := query.Scan(&counts).Error; err != nil {
+		return nil, err
+	}
+
+	index := make(map[[2]string]*ConversionRow)
+	var rows []*ConversionRow
+	for _, c := range counts {
+		key := [2]string{c.Category, c.City}
+		row, ok := index[key]
+		if !ok {
+			row = &ConversionRow{Category: c.Category, City: c.City, Stages: make(map[string]int, len(PipelineStages))}
+			for _, s := range PipelineStages {
+				row.Stages[s] = 0
+			}
+			index[key] = row
+			rows = append(rows, row)
+		}
+		row.Total += c.Count
+		row.Stages[c.Stage] += c.Count
+	}
+
+	report := make([]ConversionRow, 0, len(rows))
+	for _, row := range rows {
+		won, lost := row.Stages[StageWon], row.Stages[StageLost]
+		if row.Total > 0 {
+			row.Rate = float64(won) / float64(row.Total)
+		}
+		if won+lost > 0 {
+			row.WinRate = float64(won) / float64(won+lost)
+		}
+		report = append(report, *row)
+	}
+	sort.SliceStable(report, func(i, j int) bool { return report[i].Total > report[j].Total })
+	return report, nil
+}
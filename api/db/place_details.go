@@ -0,0 +1,157 @@
+// /api/db/place_details.go
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type LeadReview struct {
+	ID     uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	LeadID uuid.UUID `gorm:"type:uuid;index"`
+
+	AuthorName   string    `gorm:"type:text"`
+	Rating       int       `gorm:"default:0"`
+	Text         string    `gorm:"type:text"`
+	Language     string    `gorm:"size:10"`
+	ReviewedAt   time.Time `gorm:"type:timestamptz"`
+	RelativeTime string    `gorm:"type:text"`
+
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+type LeadPhoto struct {
+	ID     uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	LeadID uuid.UUID `gorm:"type:uuid;index"`
+
+	Reference    string `gorm:"type:text"`
+	Width        int    `gorm:"default:0"`
+	Height       int    `gorm:"default:0"`
+	Attributions string `gorm:"type:text"`
+
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// ReplaceLeadReviews troca as avaliações guardadas do lead pelas mais recentes
+// devolvidas pelo Google, que só expõe um punhado delas por vez.
+func ReplaceLeadReviews(scope Scope, leadID uuid.UUID, reviews []LeadReview) error {
+	if err := checkLeadInScope(scope, leadID); err != nil {
+		return err
+	}
+	if err := DB.Where("lead_id = ?", leadID).Delete(&LeadReview{}).Error; err != nil {
+		return fmt.Errorf("erro ao remover avaliações antigas: %v", err)
+	}
+	if len(reviews) == 0 {
+		return nil
+	}
+	for i := range reviews {
+		reviews[i].LeadID = leadID
+	}
+	if err := DB.Create(&reviews).Error; err != nil {
+		return fmt.Errorf("erro ao salvar avaliações: %v", err)
+	}
+	return nil
+}
+
+func ReplaceLeadPhotos(scope Scope, leadID uuid.UUID, photos []LeadPhoto) error {
+	if err := checkLeadInScope(scope, leadID); err != nil {
+		return err
+	}
+	if err := DB.Where("lead_id = ?", leadID).Delete(&LeadPhoto{}).Error; err != nil {
+		return fmt.Errorf("erro ao remover fotos antigas: %v", err)
+	}
+	if len(photos) == 0 {
+		return nil
+	}
+	for i := range photos {
+		photos[i].LeadID = leadID
+	}
+	if err := DB.Create(&photos).Error; err != nil {
+		return fmt.Errorf("erro ao salvar fotos: %v", err)
+	}
+	return nil
+}
+
+func GetLeadReviews(scope Scope, leadID uuid.UUID) ([]LeadReview, error) {
+	var reviews []LeadReview
+	result := DB.Where("lead_id = ? AND lead_id IN (?)", leadID, scopedLeadIDs(scope)).Order("reviewed_at desc").Find(&reviews)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return reviews, nil
+}
+
+func GetLeadPhotos(scope Scope, leadID uuid.UUID) ([]LeadPhoto, error) {
+	var photos []LeadPhoto
+	result := DB.Where("lead_id = ? AND lead_id IN (?)", leadID, scopedLeadIDs(scope)).Find(&photos)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return photos, nil
+}
+
+// GetPlacesFetchedSince devolve, dentre os PlaceIDs informados, os que já são
+// leads com detalhes buscados depois de since.
+func GetPlacesFetchedSince(scope Scope, placeIDs []string, since time.Time) ([]string, error) {
+	var fresh []string
+	if len(placeIDs) == 0 {
+		return fresh, nil
+	}
+	result := scope.db().Model(&Lead{}).
+		Where("google_id IN ? AND details_fetched_at >= ?", placeIDs, since).
+		Pluck("google_id", &fresh)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return fresh, nil
+}
+
+// GetStalePlaceIDs devolve os PlaceIDs dos leads cujos detalhes não são
+// atualizados desde before, começando pelos mais antigos. Um estabelecimento
+// que é lead em vários workspaces aparece uma vez só. Places cuja última
+// tentativa falhou depois de before ficam de fora, para não encabeçarem todo
+// lote.
+func GetStalePlaceIDs(scope Scope, before time.Time, limit int) ([]string, error) {
+	var ids []string
+	result := scope.db().Model(&Lead{}).
+		Where("google_id <> '' AND (details_fetched_at IS NULL OR details_fetched_at < ?)", before).
+		Where("details_attempted_at IS NULL OR details_attempted_at < ?", before).
+		Group("google_id").
+		Order("min(greatest(details_fetched_at, details_attempted_at)) asc nulls first").
+		Limit(limit).
+		Pluck("google_id", &ids)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return ids, nil
+}
+
+// MarkDetailsAttempted registra que a atualização dos detalhes dos PlaceIDs
+// falhou em at. Não dispara eventos de integração: o lead não mudou.
+func MarkDetailsAttempted(scope Scope, placeIDs []string, at time.Time) (int64, error) {
+	if len(placeIDs) == 0 {
+		return 0, nil
+	}
+	result := scope.db().Model(&Lead{}).
+		Where("google_id IN ?", placeIDs).
+		UpdateColumn("details_attempted_at", at)
+	return result.RowsAffected, result.Error
+}
+
+func scopedLeadIDs(scope Scope) *gorm.DB {
+	return scope.db().Model(&Lead{}).Select("id")
+}
+
+func checkLeadInScope(scope Scope, leadID uuid.UUID) error {
+	var count int64
+	if err := scope.db().Model(&Lead{}).Where("id = ?", leadID).Count(&count).Error; err != nil {
+		return fmt.Errorf("erro ao verificar o lead %s: %v", leadID, err)
+	}
+	if count == 0 {
+		return fmt.Errorf("lead %s fora do %s", leadID, scope)
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+// /api/db/saved_search.go
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SavedSearch é uma busca do Google Places salva para rodar periodicamente
+// pelo agendador do search-google.
+type SavedSearch struct {
+	ID          uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	WorkspaceID uuid.UUID `gorm:"type:uuid;index" json:"workspace_id"`
+
+	Name       string  `gorm:"size:255" json:"name"`
+	CategoryID string  `gorm:"type:text" json:"category_id"`
+	ZipCode    string  `gorm:"size:20" json:"zipcode"`
+	Country    string  `gorm:"size:10" json:"country"`
+	Radius     int     `gorm:"default:0" json:"radius"`
+	MaxResults int     `gorm:"default:20" json:"max_results"`
+	BudgetUSD  float64 `gorm:"type:numeric" json:"budget_usd"`
+
+	Schedule  string       `gorm:"size:100" json:"schedule"`
+	Enabled   bool         `gorm:"default:true" json:"enabled"`
+	NextRunAt sql.NullTime `gorm:"type:timestamptz;index" json:"next_run_at"`
+	LastRunAt sql.NullTime `gorm:"type:timestamptz" json:"last_run_at"`
+	LastJobID string       `gorm:"size:64" json:"last_job_id"`
+	LastError string       `gorm:"type:text" json:"last_error"`
+
+	// WorkspaceSlug só é preenchido por ClaimDueSavedSearches, para o
+	// agendador identificar o workspace das buscas no consumo do Google.
+	WorkspaceSlug string `gorm:"-" json:"workspace_slug,omitempty"`
+
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func CreateSavedSearch(scope Scope, search *SavedSearch) error {
+	search.WorkspaceID = scope.target()
+	if result := DB.Create(search); result.Error != nil {
+		return fmt.Errorf("falha ao salvar busca agendada: %v", result.Error)
+	}
+	return nil
+}
+
+func GetSavedSearches(scope Scope) ([]SavedSearch, error) {
+	var searches []SavedSearch
+	result := scope.db().Order("created_at").Find(&searches)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return searches, nil
+}
+
+func GetSavedSearchByID(scope Scope, id uuid.UUID) (*SavedSearch, error) {
+	var search SavedSearch
+	result := scope.db().First(&search, "id = ?", id)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, result.Error
+	}
+	return &search, nil
+}
+
+func SaveSavedSearch(scope Scope, search *SavedSearch) error {
+	if !scope.allows(search.WorkspaceID) {
+		return fmt.Errorf("busca agendada %s fora do %s", search.ID, scope)
+	}
+	if result := DB.Save(search); result.Error != nil {
+		return fmt.Errorf("falha ao atualizar busca agendada: %v", result.Error)
+	}
+	return nil
+}
+
+func DeleteSavedSearch(scope Scope, id uuid.UUID) error {
+	if result := scope.db().Delete(&SavedSearch{}, "id = ?", id); result.Error != nil {
+		return fmt.Errorf("falha ao remover busca agendada: %v", result.Error)
+	}
+	return nil
+}
+
+// ClaimDueSavedSearches devolve as buscas habilitadas cuja próxima execução já
+// passou e, na mesma transação, avança next_run_at com nextRun. Assim uma
+// busca demorada não é disparada de novo pelo próximo ciclo do agendador.
+func ClaimDueSavedSearches(scope Scope, now time.Time, nextRun func(*SavedSearch) sql.NullTime) ([]SavedSearch, error) {
+	var searches []SavedSearch
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		result := scope.apply(tx).Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("enabled = ? AND next_run_at IS NOT NULL AND next_run_at <= ?", true, now).
+			Order("next_run_at").
+			Find(&searches)
+		if result.Error != nil {
+			return result.Error
+		}
+		for i := range searches {
+			next := nextRun(&searches[i])
+			if err := tx.Model(&SavedSearch{}).Where("id = ?", searches[i].ID).Update("next_run_at", next).Error; err != nil {
+				return err
+			}
+			searches[i].NextRunAt = next
+		}
+		return fillWorkspaceSlugs(tx, searches)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("erro ao reservar buscas agendadas: %v", err)
+	}
+	return searches, nil
+}
+
+func fillWorkspaceSlugs(tx *gorm.DB, searches []SavedSearch) error {
+	if len(searches) == 0 {
+		return nil
+	}
+	var workspaces []Workspace
+	if err := tx.Find(&workspaces).Error; err != nil {
+		return err
+	}
+	slugs := make(map[uuid.UUID]string, len(workspaces))
+	for _, ws := range workspaces {
+		slugs[ws.ID] = ws.Slug
+	}
+	for i := range searches {
+		searches[i].WorkspaceSlug = slugs[searches[i].WorkspaceID]
+	}
+	return nil
+}
+
+// GetLeadsBySavedSearchSince devolve os leads que uma busca agendada encontrou
+// pela primeira vez a partir de since.
+func GetLeadsBySavedSearchSince(scope Scope, savedSearchID uuid.UUID, since time.Time) ([]Lead, error) {
+	var leads []Lead
+	result := scope.db().Where("saved_search_id = ? AND created_at >= ?", savedSearchID.String(), since).
+		Order("created_at desc").
+		Find(&leads)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return leads, nil
+}
+
+// GetKnownPlaceIDs devolve, dentre os PlaceIDs informados, os que já são leads.
+func GetKnownPlaceIDs(scope Scope, placeIDs []string) ([]string, error) {
+	var known []string
+	if len(placeIDs) == 0 {
+		return known, nil
+	}
+	result := scope.db().Model(&Lead{}).Where("google_id IN ?", placeIDs).Pluck("google_id", &known)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return known, nil
+}
@@ -0,0 +1,181 @@
+// /api/db/user.go
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// User é uma pessoa que entra no front-web com e-mail e senha. O login troca
+// a senha por um JWT curto com o papel e os workspaces do usuário; as API
+// keys de AUTH_API_KEYS ficam para os serviços.
+type User struct {
+	ID           uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	Email        string    `gorm:"size:255;uniqueIndex" json:"email"`
+	Name         string    `gorm:"size:255" json:"name"`
+	PasswordHash string    `gorm:"size:100" json:"-"`
+	Role         string    `gorm:"size:16" json:"role"`
+
+	// Workspaces são os slugs que o usuário pode acessar; vazio é todos.
+	Workspaces pq.StringArray `gorm:"type:text[]" json:"workspaces"`
+	Active     bool           `gorm:"default:true" json:"active"`
+
+	LastLoginAt sql.NullTime `json:"last_login_at"`
+	CreatedAt   time.Time    `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time    `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// MinPasswordLength é o tamanho mínimo das senhas de usuário.
+const MinPasswordLength = 10
+
+var (
+	ErrUserExists   = errors.New("já existe um usuário com esse e-mail")
+	ErrWeakPassword = fmt.Errorf("a senha precisa ter pelo menos %d caracteres", MinPasswordLength)
+)
+
+// dummyPasswordHash é comparado quando o e-mail não existe, para o login
+// demorar o mesmo tanto com e sem usuário.
+var dummyPasswordHash = []byte("$2a$10$CEMtU2w0x/Gitp1AqkpiTOfvHdpxijgfsTXFt.oSnVvbEwaCChe4S")
+
+// NormalizeEmail é a forma do e-mail gravada e usada nas buscas.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// SetPassword troca o hash da senha do usuário.
+func (u *User) SetPassword(password string) error {
+	if len(password) < MinPasswordLength {
+		return ErrWeakPassword
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("erro ao gerar o hash da senha: %v", err)
+	}
+	u.PasswordHash = string(hash)
+	return nil
+}
+
+// CheckLogin devolve o usuário ativo com o e-mail e a senha informados, ou
+// nil se não houver.
+func CheckLogin(email, password string) (*User, error) {
+	user, err := GetUserByEmail(email)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+		return nil, nil
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil || !user.Active {
+		return nil, nil
+	}
+	now := time.Now()
+	if err := DB.Model(user).UpdateColumn("last_login_at", now).Error; err != nil {
+		log.Printf("Erro ao registrar o login de %s: %v", user.Email, err)
+	}
+	user.LastLoginAt = sql.NullTime{Time: now, Valid: true}
+	return user, nil
+}
+
+func GetUsers() ([]User, error) {
+	var users []User
+	if result := DB.Order("email").Find(&users); result.Error != nil {
+		return nil, result.Error
+	}
+	return users, nil
+}
+
+// GetUser devolve o usuário com o ID, ou nil se ele não existir.
+func GetUser(id uuid.UUID) (*User, error) {
+	var user User
+	err := DB.First(&user, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// GetUserByEmail devolve o usuário com o e-mail, ou nil se ele não existir.
+func GetUserByEmail(email string) (*User, error) {
+	var user User
+	err := DB.First(&user, "email = ?", NormalizeEmail(email)).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func CreateUser(user *User) error {
+	user.Email = NormalizeEmail(user.Email)
+	existing, err := GetUserByEmail(user.Email)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return fmt.Errorf("%w: %s", ErrUserExists, user.Email)
+	}
+	user.ID = uuid.New()
+	user.Active = true
+	if err := DB.Create(user).Error; err != nil {
+		return fmt.Errorf("erro ao criar o usuário: %v", err)
+	}
+	log.Printf("Usuário %s criado com papel %s", user.Email, user.Role)
+	return nil
+}
+
+// SaveUser grava a alteração de nome, senha, papel, workspaces ou status de
+// um usuário existente.
+func SaveUser(user *User) error {
+	result := DB.Model(user).Select("name", "password_hash", "role", "workspaces", "active", "updated_at").Updates(user)
+	if result.Error != nil {
+		return fmt.Errorf("erro ao atualizar o usuário: %v", result.Error)
+	}
+	return nil
+}
+
+func DeleteUser(user *User) error {
+	if err := DB.Delete(user).Error; err != nil {
+		return fmt.Errorf("erro ao apagar o usuário: %v", err)
+	}
+	log.Printf("Usuário %s apagado", user.Email)
+	return nil
+}
+
+// EnsureBootstrapAdmin cria o primeiro admin ("email:senha") quando ainda não
+// existe nenhum usuário. Com usuários cadastrados não faz nada.
+func EnsureBootstrapAdmin(spec string) error {
+	if spec == "" {
+		return nil
+	}
+	var count int64
+	if err := DB.Model(&User{}).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	email, password, ok := strings.Cut(spec, ":")
+	if !ok || strings.TrimSpace(email) == "" {
+		return fmt.Errorf("AUTH_BOOTSTRAP_ADMIN inválido: esperava email:senha")
+	}
+	user := User{Email: email, Name: "Administrador", Role: "admin"}
+	if err := user.SetPassword(password); err != nil {
+		return err
+	}
+	return CreateUser(&user)
+}
@@ -0,0 +1,155 @@
+// /api/db/workspace.go
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Workspace separa os leads e buscas de cada cliente da agência.
+type Workspace struct {
+	ID   uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	Slug string    `gorm:"size:64;uniqueIndex" json:"slug"`
+	Name string    `gorm:"size:255" json:"name"`
+
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// DefaultWorkspaceSlug é o workspace que recebe os leads anteriores aos
+// workspaces e as requisições que não escolhem um.
+const DefaultWorkspaceSlug = "default"
+
+// DefaultWorkspaceID é preenchido por Migrate.
+var DefaultWorkspaceID uuid.UUID
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+// Scope restringe as consultas do pacote db a um workspace. Toda função que
+// lê ou grava leads e buscas recebe um Scope; AllWorkspaces só é usado pelas
+// rotas de serviço, que trabalham com leads de qualquer workspace por ID.
+type Scope struct {
+	workspaceID uuid.UUID
+	all         bool
+}
+
+func InWorkspace(id uuid.UUID) Scope {
+	return Scope{workspaceID: id}
+}
+
+func AllWorkspaces() Scope {
+	return Scope{all: true}
+}
+
+// WorkspaceID devolve o workspace do escopo; false quando o escopo é global.
+func (s Scope) WorkspaceID() (uuid.UUID, bool) {
+	return s.workspaceID, !s.all
+}
+
+func (s Scope) String() string {
+	if s.all {
+		return "todos os workspaces"
+	}
+	return "workspace " + s.workspaceID.String()
+}
+
+// apply filtra tx pelo workspace do escopo. Um Scope zero não casa com nada.
+func (s Scope) apply(tx *gorm.DB) *gorm.DB {
+	if s.all {
+		return tx
+	}
+	return tx.Where("workspace_id = ?", s.workspaceID)
+}
+
+func (s Scope) db() *gorm.DB {
+	return s.apply(DB)
+}
+
+// allows diz se um registro do workspace informado está dentro do escopo.
+func (s Scope) allows(workspaceID uuid.UUID) bool {
+	return s.all || s.workspaceID == workspaceID
+}
+
+// target é o workspace onde registros novos são criados: o do escopo ou, no
+// escopo global, o padrão.
+func (s Scope) target() uuid.UUID {
+	if s.all {
+		return DefaultWorkspaceID
+	}
+	return s.workspaceID
+}
+
+type scopeKey struct{}
+
+func WithScope(ctx context.Context, s Scope) context.Context {
+	return context.WithValue(ctx, scopeKey{}, s)
+}
+
+// ScopeFromContext devolve o escopo resolvido pelo middleware. Sem escopo no
+// contexto devolve o Scope zero, que não enxerga nenhum registro.
+func ScopeFromContext(ctx context.Context) Scope {
+	s, _ := ctx.Value(scopeKey{}).(Scope)
+	return s
+}
+
+func CreateWorkspace(ws *Workspace) error {
+	if !slugPattern.MatchString(ws.Slug) {
+		return fmt.Errorf("slug inválido %q: use letras minúsculas, números e hífen", ws.Slug)
+	}
+	if result := DB.Create(ws); result.Error != nil {
+		return fmt.Errorf("falha ao criar workspace: %v", result.Error)
+	}
+	return nil
+}
+
+func GetWorkspaces() ([]Workspace, error) {
+	var workspaces []Workspace
+	if result := DB.Order("slug").Find(&workspaces); result.Error != nil {
+		return nil, result.Error
+	}
+	return workspaces, nil
+}
+
+// GetWorkspace busca um workspace pelo slug ou pelo ID.
+func GetWorkspace(ref string) (*Workspace, error) {
+	var ws Workspace
+	query := DB.Where("slug = ?", ref)
+	if id, err := uuid.Parse(ref); err == nil {
+		query = DB.Where("id = ?", id)
+	}
+	result := query.First(&ws)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, result.Error
+	}
+	return &ws, nil
+}
+
+// ensureDefaultWorkspace cria o workspace padrão e move para ele os leads e
+// buscas gravados antes de existirem workspaces.
+func ensureDefaultWorkspace() error {
+	ws := Workspace{Slug: DefaultWorkspaceSlug, Name: "Padrão"}
+	if err := DB.Where("slug = ?", ws.Slug).FirstOrCreate(&ws).Error; err != nil {
+		return fmt.Errorf("falha ao criar o workspace padrão: %v", err)
+	}
+	DefaultWorkspaceID = ws.ID
+
+	for _, model := range []interface{}{&Lead{}, &SavedSearch{}} {
+		result := DB.Model(model).Where("workspace_id IS NULL").Update("workspace_id", ws.ID)
+		if result.Error != nil {
+			return fmt.Errorf("falha ao mover registros para o workspace padrão: %v", result.Error)
+		}
+		if result.RowsAffected > 0 {
+			log.Printf("%d registros movidos para o workspace padrão", result.RowsAffected)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,240 @@
+// /api/dedupe/dedupe.go
+package dedupe
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/wbrunovieira/LeadSearchVersion2/shared/cnpj"
+	"github.com/wbrunovieira/LeadSearchVersion2/textnorm"
+)
+
+// Threshold é a pontuação mínima para um par entrar na fila de revisão.
+const Threshold = 0.6
+
+// Candidate são os dados de um lead usados na comparação. Phones já vêm em
+// E.164 e Domain é o domínio registrável do site.
+type Candidate struct {
+	Name      string
+	CNPJ      string
+	Phones    []string
+	Domain    string
+	Address   string
+	City      string
+	Latitude  float64
+	Longitude float64
+}
+
+// Signal é um indício de que dois leads são a mesma empresa. Score vai de 0 a
+// 1 e Weight é o quanto o indício pesa sozinho.
+type Signal struct {
+	Name   string  `json:"signal"`
+	Score  float64 `json:"score"`
+	Weight float64 `json:"weight"`
+	Detail string  `json:"detail,omitempty"`
+}
+
+// Match é o resultado da comparação de dois leads.
+type Match struct {
+	Score   float64  `json:"score"`
+	Signals []Signal `json:"signals"`
+}
+
+// Pesos dos indícios. Um CNPJ igual praticamente decide; raiz de CNPJ e
+// domínio iguais também aparecem em filiais de uma mesma rede e pesam menos.
+const (
+	weightCNPJ     = 0.95
+	weightCNPJRoot = 0.35
+	weightPhone    = 0.8
+	weightDomain   = 0.5
+	weightName     = 0.6
+	weightGeo      = 0.5
+	weightAddress  = 0.4
+
+	// farApart é a distância a partir da qual, sem CNPJ ou telefone em comum,
+	// dois leads parecidos são tratados como unidades diferentes.
+	farApart = 2000.0
+)
+
+// Compare pontua a chance de a e b serem a mesma empresa. Os indícios são
+// combinados como probabilidades independentes (1 - Π(1 - peso·score)), de
+// modo que vários indícios fracos somam e um forte basta. CNPJs válidos
+// diferentes derrubam a pontuação.
+func Compare(a, b Candidate) Match {
+	var signals []Signal
+	add := func(name string, score, weight float64, detail string) {
+		if score > 0 {
+			signals = append(signals, Signal{Name: name, Score: round(score), Weight: weight, Detail: detail})
+		}
+	}
+
+	cnpjA, errA := cnpj.Normalize(a.CNPJ)
+	cnpjB, errB := cnpj.Normalize(b.CNPJ)
+	bothCNPJ := errA == nil && errB == nil
+	sameCNPJ := bothCNPJ && cnpjA == cnpjB
+	sameRoot := bothCNPJ && cnpjA[:8] == cnpjB[:8]
+	switch {
+	case sameCNPJ:
+		add("cnpj", 1, weightCNPJ, cnpjA)
+	case sameRoot:
+		add("cnpj_root", 1, weightCNPJRoot, cnpjA[:8])
+	}
+
+	samePhone := ""
+	for _, p := range a.Phones {
+		for _, q := range b.Phones {
+			if p != "" && p == q {
+				samePhone = p
+			}
+		}
+	}
+	if samePhone != "" {
+		add("phone", 1, weightPhone, samePhone)
+	}
+
+	if a.Domain != "" && a.Domain == b.Domain {
+		add("domain", 1, weightDomain, a.Domain)
+	}
+
+	if sim := NameSimilarity(a.Name, b.Name); sim >= 0.5 {
+		add("name", sim, weightName, fmt.Sprintf("%q ~ %q", NormalizeName(a.Name), NormalizeName(b.Name)))
+	}
+
+	distance := -1.0
+	if hasCoords(a) && hasCoords(b) {
+		distance = Distance(a.Latitude, a.Longitude, b.Latitude, b.Longitude)
+		add("geo", geoScore(distance), weightGeo, fmt.Sprintf("%.0f m", distance))
+	} else if addr := textnorm.Words(a.Address); addr != "" && addr == textnorm.Words(b.Address) {
+		add("address", 1, weightAddress, addr)
+	}
+
+	miss := 1.0
+	for _, s := range signals {
+		miss *= 1 - s.Weight*s.Score
+	}
+	score := 1 - miss
+
+	if bothCNPJ && !sameRoot {
+		score *= 0.2
+		signals = append(signals, Signal{Name: "cnpj_conflict", Detail: cnpjA + " != " + cnpjB})
+	}
+	if distance > farApart && !sameCNPJ && samePhone == "" {
+		score *= 0.5
+		signals = append(signals, Signal{Name: "far_apart", Detail: fmt.Sprintf("%.0f m", distance)})
+	}
+	return Match{Score: round(score), Signals: signals}
+}
+
+func hasCoords(c Candidate) bool {
+	return c.Latitude != 0 || c.Longitude != 0
+}
+
+func geoScore(meters float64) float64 {
+	switch {
+	case meters <= 50:
+		return 1
+	case meters <= 250:
+		return 0.6
+	case meters <= 1000:
+		return 0.2
+	}
+	return 0
+}
+
+func round(v float64) float64 {
+	return math.Round(v*1000) / 1000
+}
+
+// Distance devolve a distância em metros entre dois pontos (haversine).
+func Distance(lat1, lng1, lat2, lng2 float64) float64 {
+	const earthRadius = 6371000.0
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+	dLat := toRad(lat2 - lat1)
+	dLng := toRad(lng2 - lng1)
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRad(lat1))*math.Cos(toRad(lat2))*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(h))
+}
+
+// Palavras que não distinguem empresas: natureza jurídica e conectivos.
+var nameStopwords = map[string]bool{
+	"ltda": true, "me": true, "epp": true, "eireli": true, "sa": true, "s": true, "a": true, "cia": true,
+	"mei": true, "limitada": true, "de": true, "da": true, "do": true, "das": true, "dos": true, "e": true,
+	"the": true, "and": true,
+}
+
+// NormalizeName normaliza o nome da empresa para comparação: sem acento,
+// pontuação, natureza jurídica (LTDA, ME, EIRELI...) e conectivos.
+func NormalizeName(s string) string {
+	var tokens []string
+	for _, t := range strings.Fields(textnorm.Words(s)) {
+		if !nameStopwords[t] {
+			tokens = append(tokens, t)
+		}
+	}
+	return strings.Join(tokens, " ")
+}
+
+// NameSimilarity compara dois nomes depois de NormalizeName e devolve o
+// maior entre a semelhança das palavras (Jaccard) e a dos caracteres
+// (Levenshtein), de 0 a 1.
+func NameSimilarity(a, b string) float64 {
+	a, b = NormalizeName(a), NormalizeName(b)
+	if a == "" || b == "" {
+		return 0
+	}
+	if a == b {
+		return 1
+	}
+
+	tokensA, tokensB := strings.Fields(a), strings.Fields(b)
+	inA := make(map[string]bool, len(tokensA))
+	for _, t := range tokensA {
+		inA[t] = true
+	}
+	union := len(inA)
+	common := 0
+	seen := make(map[string]bool, len(tokensB))
+	for _, t := range tokensB {
+		if seen[t] {
+			continue
+		}
+		seen[t] = true
+		if inA[t] {
+			common++
+		} else {
+			union++
+		}
+	}
+	jaccard := float64(common) / float64(union)
+
+	ra, rb := []rune(a), []rune(b)
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	chars := 1 - float64(levenshtein(ra, rb))/float64(longest)
+
+	return math.Max(jaccard, chars)
+}
+
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
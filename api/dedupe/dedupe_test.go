@@ -0,0 +1,79 @@
+package dedupe
+
+import (
+	"math"
+	"testing"
+)
+
+func TestNormalizeName(t *testing.T) {
+	cases := map[string]string{
+		"Padaria Bela Ltda.":             "padaria bela",
+		"PADARIA BELA - ME":              "padaria bela",
+		"Padaria & Confeitaria São João": "padaria confeitaria sao joao",
+		"Cia. do Pão S/A":                "pao",
+	}
+	for in, want := range cases {
+		if got := NormalizeName(in); got != want {
+			t.Errorf("NormalizeName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestNameSimilarity(t *testing.T) {
+	if got := NameSimilarity("Padaria Bela LTDA", "padaria bela"); got != 1 {
+		t.Errorf("same name = %v", got)
+	}
+	if got := NameSimilarity("Padaria Bela Vista", "Padaria Bella Vista"); got < 0.9 {
+		t.Errorf("typo = %v, want >= 0.9", got)
+	}
+	if got := NameSimilarity("Padaria Bela", "Oficina do Zé"); got > 0.3 {
+		t.Errorf("different names = %v, want <= 0.3", got)
+	}
+	if got := NameSimilarity("", "Padaria"); got != 0 {
+		t.Errorf("empty name = %v", got)
+	}
+}
+
+func TestDistance(t *testing.T) {
+	// Praça da Sé -> MASP, cerca de 2,7 km.
+	d := Distance(-23.5503, -46.6339, -23.5614, -46.6559)
+	if math.Abs(d-2600) > 200 {
+		t.Errorf("Distance = %.0f m", d)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	base := Candidate{
+		Name:      "Padaria Bela Ltda",
+		CNPJ:      "12.345.678/0001-95",
+		Phones:    []string{"+551133334444"},
+		Domain:    "padariabela.com.br",
+		Address:   "Rua das Flores, 100 - São Paulo",
+		Latitude:  -23.5503,
+		Longitude: -46.6339,
+	}
+
+	cases := []struct {
+		name     string
+		noCoords bool
+		other    Candidate
+		min, max float64
+	}{
+		{"duplicate listing", false, Candidate{Name: "Padaria Bela", Latitude: -23.5504, Longitude: -46.6340}, 0.75, 1},
+		{"same cnpj", false, Candidate{Name: "Panificadora BL", CNPJ: "12345678000195"}, 0.95, 1},
+		{"relocated with same phone", false, Candidate{Name: "Padaria Bela", Phones: []string{"+551133334444"}, Latitude: -23.60, Longitude: -46.70}, 0.9, 1},
+		{"branch of the chain", false, Candidate{Name: "Padaria Bela", CNPJ: "12345678000276", Domain: "padariabela.com.br", Latitude: -23.60, Longitude: -46.70}, 0, Threshold},
+		{"different company nearby", false, Candidate{Name: "Oficina do Zé", CNPJ: "98765432000198", Latitude: -23.5503, Longitude: -46.6339}, 0, 0.2},
+		{"same address without coordinates", true, Candidate{Name: "Padaria Bela", Address: "Rua das Flores 100, São Paulo"}, 0.7, 1},
+	}
+	for _, c := range cases {
+		a := base
+		if c.noCoords {
+			a.Latitude, a.Longitude = 0, 0
+		}
+		m := Compare(a, c.other)
+		if m.Score < c.min || m.Score > c.max {
+			t.Errorf("%s: score %.3f outside [%.2f, %.2f]; signals %+v", c.name, m.Score, c.min, c.max, m.Signals)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+// /api/export/columns.go
+package export
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"github.com/wbrunovieira/LeadSearchVersion2/db"
+	"github.com/wbrunovieira/LeadSearchVersion2/leadfields"
+)
+
+// ErrUnknownColumn indica uma coluna que não existe no lead nem entre os
+// campos personalizados do workspace.
+var ErrUnknownColumn = errors.New("coluna desconhecida")
+
+// Date é uma data sem horário (fundação, campos personalizados de data). Os
+// demais time.Time saem com data e hora.
+type Date time.Time
+
+// Column é uma coluna da planilha: Name é o campo do lead (ou
+// custom.<chave>) e Label o cabeçalho em português.
+type Column struct {
+	Name  string
+	Label string
+	value func(*db.Lead) interface{}
+}
+
+// Value devolve o valor da coluna para o lead.
+func (c Column) Value(lead *db.Lead) interface{} {
+	return c.value(lead)
+}
+
+// DefaultColumns são as colunas exportadas quando nenhuma é pedida, seguidas
+// dos campos personalizados do workspace.
+var DefaultColumns = []string{
+	"BusinessName", "RegisteredName", "CompanyRegistrationID", "Phone", "Whatsapp", "Email", "Website",
+	"Instagram", "Address", "City", "State", "ZIPCode", "Category", "Rating", "UserRatingsTotal",
+	"Quality", "CompletenessScore", "ICPScore", "Stage", "AssignedTo", "Tags",
+}
+
+// labels completa os rótulos de leadfields para as colunas que não são
+// editáveis pela API.
+var labels = map[string]string{
+	"ID":                "ID",
+	"Category":          "Categoria",
+	"Rating":            "Nota no Google",
+	"UserRatingsTotal":  "Avaliações no Google",
+	"PriceLevel":        "Nível de preço",
+	"BusinessStatus":    "Situação no Google",
+	"Domain":            "Domínio",
+	"Source":            "Origem",
+	"Quality":           "Qualidade",
+	"FieldsFilled":      "Campos preenchidos",
+	"CompletenessScore": "Completude",
+	"ICPScore":          "Pontuação ICP",
+	"ICPVersion":        "Versão do ICP",
+	"Stage":             "Etapa",
+	"StageChangedAt":    "Etapa alterada em",
+	"AssignedTo":        "Responsável",
+	"Latitude":          "Latitude",
+	"Longitude":         "Longitude",
+	"GoogleMapsURL":     "Google Maps",
+	"OpeningHours":      "Horário de funcionamento",
+	"SearchTerm":        "Termo de busca",
+	"CreatedAt":         "Criado em",
+	"UpdatedAt":         "Atualizado em",
+}
+
+// dateOnly são os campos do lead guardados como data, sem horário.
+var dateOnly = map[string]bool{"FoundationDate": true}
+
+var leadType = reflect.TypeOf(db.Lead{})
+
+// Columns resolve os nomes pedidos, na ordem pedida. Os nomes dos campos do
+// lead não diferenciam maiúsculas; custom.<chave> precisa ser um campo do
+// workspace. Sem nomes, usa DefaultColumns e todos os campos personalizados.
+func Columns(names []string, custom []db.CustomFieldDefinition) ([]Column, error) {
+	if len(names) == 0 {
+		names = append([]string(nil), DefaultColumns...)
+		for _, d := range custom {
+			names = append(names, leadfields.CustomPrefix+d.Key)
+		}
+	}
+	columns := make([]Column, 0, len(names))
+	for _, name := range names {
+		column, err := resolve(name, custom)
+		if err != nil {
+			return nil, err
+		}
+		columns = append(columns, column)
+	}
+	return columns, nil
+}
+
+func resolve(name string, custom []db.CustomFieldDefinition) (Column, error) {
+	if key := strings.TrimPrefix(name, leadfields.CustomPrefix); key != name {
+		for _, d := range custom {
+			if d.Key == key {
+				return customColumn(d), nil
+			}
+		}
+		return Column{}, fmt.Errorf("%w: %s não é um campo personalizado do workspace", ErrUnknownColumn, name)
+	}
+
+	field, ok := leadType.FieldByNameFunc(func(n string) bool { return strings.EqualFold(n, name) })
+	if !ok || !field.IsExported() || field.Type.Kind() == reflect.Map {
+		return Column{}, fmt.Errorf("%w: %s", ErrUnknownColumn, name)
+	}
+	label := labels[field.Name]
+	if f, ok := leadfields.Lookup(field.Name); ok {
+		label = f.Label
+	}
+	if label == "" {
+		label = field.Name
+	}
+	index, date := field.Index, dateOnly[field.Name]
+	return Column{
+		Name:  field.Name,
+		Label: label,
+		value: func(l *db.Lead) interface{} {
+			v := cell(reflect.ValueOf(l).Elem().FieldByIndex(index).Interface())
+			if t, ok := v.(time.Time); ok && date {
+				return Date(t)
+			}
+			return v
+		},
+	}, nil
+}
+
+func customColumn(d db.CustomFieldDefinition) Column {
+	key, typ := d.Key, d.Type
+	label := d.Label
+	if label == "" {
+		label = d.Key
+	}
+	return Column{
+		Name:  leadfields.CustomPrefix + key,
+		Label: label,
+		value: func(l *db.Lead) interface{} {
+			v := l.CustomFields[key]
+			if s, ok := v.(string); ok && typ == db.CustomFieldDate {
+				if t, err := time.Parse("2006-01-02", s); err == nil {
+					return Date(t)
+				}
+			}
+			return v
+		},
+	}
+}
+
+// cell converte os tipos do lead nos valores que os escritores entendem.
+func cell(v interface{}) interface{} {
+	switch v := v.(type) {
+	case sql.NullTime:
+		if !v.Valid {
+			return nil
+		}
+		return v.Time
+	case pq.StringArray:
+		return []string(v)
+	case uuid.UUID:
+		return v.String()
+	case *uuid.UUID:
+		if v == nil {
+			return nil
+		}
+		return v.String()
+	}
+	return v
+}
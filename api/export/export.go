@@ -0,0 +1,282 @@
+// /api/export/export.go
+package export
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Formatos de arquivo.
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+// Options controla a formatação dos valores. Locale "br" equivale a
+// delimitador ";", datas dd/mm/aaaa, vírgula decimal e BOM UTF-8 no CSV (para
+// o Excel abrir com acentos).
+type Options struct {
+	Delimiter    rune
+	DateLayout   string
+	DecimalComma bool
+	BOM          bool
+}
+
+const (
+	DateISO = "2006-01-02"
+	DateBR  = "02/01/2006"
+)
+
+// DefaultOptions é o padrão: vírgula como delimitador e datas ISO.
+func DefaultOptions() Options {
+	return Options{Delimiter: ',', DateLayout: DateISO}
+}
+
+// BrazilianOptions é o formato usado nas planilhas brasileiras.
+func BrazilianOptions() Options {
+	return Options{Delimiter: ';', DateLayout: DateBR, DecimalComma: true, BOM: true}
+}
+
+// Writer escreve linhas de uma planilha à medida que chegam, sem guardar o
+// arquivo inteiro em memória. Close termina o arquivo.
+type Writer interface {
+	WriteRow(values []interface{}) error
+	Close() error
+}
+
+// NewWriter cria o escritor do formato pedido sobre w.
+func NewWriter(w io.Writer, format string, opts Options) (Writer, error) {
+	switch format {
+	case FormatCSV, "":
+		return newCSVWriter(w, opts)
+	case FormatXLSX:
+		return newXLSXWriter(w, opts)
+	}
+	return nil, fmt.Errorf("formato de exportação desconhecido %q: use csv ou xlsx", format)
+}
+
+// ContentType devolve o tipo MIME do formato.
+func ContentType(format string) string {
+	if format == FormatXLSX {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// formatValue converte um valor de célula em texto seguindo as opções.
+// Valores aceitos: nil, string, bool, inteiros, float64, Date, time.Time e
+// []string.
+func formatValue(v interface{}, opts Options) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool:
+		if v {
+			return "true"
+		}
+		return "false"
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		s := strconv.FormatFloat(v, 'f', -1, 64)
+		if opts.DecimalComma {
+			s = strings.Replace(s, ".", ",", 1)
+		}
+		return s
+	case Date:
+		if time.Time(v).IsZero() {
+			return ""
+		}
+		return time.Time(v).Format(opts.DateLayout)
+	case time.Time:
+		if v.IsZero() {
+			return ""
+		}
+		return v.Format(opts.DateLayout + " 15:04")
+	case []string:
+		return strings.Join(v, ", ")
+	case fmt.Stringer:
+		return v.String()
+	}
+	return fmt.Sprint(v)
+}
+
+// signedNumber é um texto que o Excel lê como número, e não como fórmula,
+// mesmo começando por + ou - (telefones E.164, por exemplo).
+var signedNumber = regexp.MustCompile(`^[+-]?[0-9]+([.,][0-9]+)?$`)
+
+// escapeFormula põe um apóstrofo na frente dos textos que o Excel e o
+// LibreOffice executariam como fórmula ao abrir o CSV (=, +, -, @, tab ou CR
+// no início). Só vale para o CSV: no XLSX as células são texto inline.
+func escapeFormula(s string) string {
+	if s == "" || signedNumber.MatchString(s) {
+		return s
+	}
+	switch s[0] {
+	case '=', '+', '-', '@', '\t', '\r':
+		return "'" + s
+	}
+	return s
+}
+
+type csvWriter struct {
+	w    *csv.Writer
+	opts Options
+	rows int
+}
+
+func newCSVWriter(w io.Writer, opts Options) (*csvWriter, error) {
+	if opts.BOM {
+		if _, err := io.WriteString(w, "\ufeff"); err != nil {
+			return nil, err
+		}
+	}
+	cw := csv.NewWriter(w)
+	if opts.Delimiter != 0 {
+		cw.Comma = opts.Delimiter
+	}
+	return &csvWriter{w: cw, opts: opts}, nil
+}
+
+func (c *csvWriter) WriteRow(values []interface{}) error {
+	record := make([]string, len(values))
+	for i, v := range values {
+		record[i] = formatValue(v, c.opts)
+		switch v.(type) {
+		case string, []string:
+			record[i] = escapeFormula(record[i])
+		}
+	}
+	if err := c.w.Write(record); err != nil {
+		return err
+	}
+	// Descarrega a cada lote para o cliente começar a receber logo.
+	c.rows++
+	if c.rows%200 == 0 {
+		c.w.Flush()
+		return c.w.Error()
+	}
+	return nil
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// xlsxWriter monta um XLSX mínimo (uma planilha, textos inline) direto no
+// zip, de modo que as linhas vão para a saída conforme são escritas.
+type xlsxWriter struct {
+	zip   *zip.Writer
+	sheet *bufio.Writer
+	opts  Options
+	row   int
+}
+
+var xlsxParts = []struct{ name, body string }{
+	{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/><Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/></Types>`},
+	{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`},
+	{"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="Leads" sheetId="1" r:id="rId1"/></sheets></workbook>`},
+	{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/><Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/></Relationships>`},
+	{"xl/styles.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts><fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills><borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders><cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs><cellXfs count="2"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/><xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/></cellXfs></styleSheet>`},
+}
+
+func newXLSXWriter(w io.Writer, opts Options) (*xlsxWriter, error) {
+	z := zip.NewWriter(w)
+	for _, part := range xlsxParts {
+		f, err := z.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(f, part.body); err != nil {
+			return nil, err
+		}
+	}
+	f, err := z.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	sheet := bufio.NewWriter(f)
+	sheet.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n" +
+		`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	return &xlsxWriter{zip: z, sheet: sheet, opts: opts}, nil
+}
+
+// WriteRow escreve números como células numéricas e o resto como texto. A
+// primeira linha (o cabeçalho) sai em negrito.
+func (x *xlsxWriter) WriteRow(values []interface{}) error {
+	x.row++
+	style := ""
+	if x.row == 1 {
+		style = ` s="1"`
+	}
+	fmt.Fprintf(x.sheet, `<row r="%d">`, x.row)
+	for _, v := range values {
+		switch n := v.(type) {
+		case int:
+			fmt.Fprintf(x.sheet, `<c%s><v>%d</v></c>`, style, n)
+			continue
+		case int64:
+			fmt.Fprintf(x.sheet, `<c%s><v>%d</v></c>`, style, n)
+			continue
+		case float64:
+			fmt.Fprintf(x.sheet, `<c%s><v>%s</v></c>`, style, strconv.FormatFloat(n, 'f', -1, 64))
+			continue
+		}
+		s := formatValue(v, x.opts)
+		if s == "" {
+			x.sheet.WriteString("<c/>")
+			continue
+		}
+		fmt.Fprintf(x.sheet, `<c t="inlineStr"%s><is><t xml:space="preserve">`, style)
+		writeXMLText(x.sheet, s)
+		x.sheet.WriteString(`</t></is></c>`)
+	}
+	x.sheet.WriteString("</row>")
+	if x.row%200 == 0 {
+		return x.sheet.Flush()
+	}
+	return nil
+}
+
+func (x *xlsxWriter) Close() error {
+	x.sheet.WriteString("</sheetData></worksheet>")
+	if err := x.sheet.Flush(); err != nil {
+		return err
+	}
+	return x.zip.Close()
+}
+
+// writeXMLText escapa o texto e descarta caracteres que o XML não aceita.
+func writeXMLText(w *bufio.Writer, s string) {
+	for _, r := range s {
+		switch {
+		case r == '&':
+			w.WriteString("&amp;")
+		case r == '<':
+			w.WriteString("&lt;")
+		case r == '>':
+			w.WriteString("&gt;")
+		case r == '"':
+			w.WriteString("&quot;")
+		case r == '\t' || r == '\n' || r == '\r' || (r >= 0x20 && r <= 0xD7FF) || (r >= 0xE000 && r <= 0xFFFD) || r >= 0x10000:
+			w.WriteRune(r)
+		}
+	}
+}
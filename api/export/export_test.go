@@ -0,0 +1,191 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"database/sql"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/wbrunovieira/LeadSearchVersion2/db"
+)
+
+func testLead() *db.Lead {
+	return &db.Lead{
+		BusinessName:   "Padaria São João",
+		City:           "Curitiba",
+		Rating:         4.5,
+		FoundationDate: sql.NullTime{Time: time.Date(2010, 3, 7, 0, 0, 0, 0, time.UTC), Valid: true},
+		CreatedAt:      time.Date(2024, 5, 1, 14, 30, 0, 0, time.UTC),
+		Tags:           pq.StringArray{"vip", "feira"},
+		CustomFields:   map[string]interface{}{"renovacao": "2025-02-01", "lojas": float64(3)},
+	}
+}
+
+var testDefinitions = []db.CustomFieldDefinition{
+	{Key: "renovacao", Label: "Renovação", Type: db.CustomFieldDate},
+	{Key: "lojas", Label: "Lojas", Type: db.CustomFieldNumber},
+}
+
+func writeLeads(t *testing.T, format string, opts Options, names []string) []byte {
+	t.Helper()
+	columns, err := Columns(names, testDefinitions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, format, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := make([]interface{}, len(columns))
+	row := make([]interface{}, len(columns))
+	for i, c := range columns {
+		header[i] = c.Label
+		row[i] = c.Value(testLead())
+	}
+	if err := w.WriteRow(header); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteRow(row); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestColumns(t *testing.T) {
+	columns, err := Columns([]string{"city", "BusinessName", "custom.lojas", "Stage"}, testDefinitions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, c := range columns {
+		got = append(got, c.Name+"="+c.Label)
+	}
+	want := "City=Cidade BusinessName=Nome fantasia custom.lojas=Lojas Stage=Etapa"
+	if strings.Join(got, " ") != want {
+		t.Errorf("Columns = %q, want %q", strings.Join(got, " "), want)
+	}
+
+	for _, name := range []string{"Nope", "CustomFields", "custom.inexistente", "icp"} {
+		if _, err := Columns([]string{name}, testDefinitions); !errors.Is(err, ErrUnknownColumn) {
+			t.Errorf("Columns(%q) error = %v, want ErrUnknownColumn", name, err)
+		}
+	}
+
+	columns, err = Columns(nil, testDefinitions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(DefaultColumns) + len(testDefinitions); len(columns) != n {
+		t.Errorf("default columns = %d, want %d", len(columns), n)
+	}
+}
+
+func TestCSV(t *testing.T) {
+	names := []string{"BusinessName", "Rating", "FoundationDate", "CreatedAt", "Tags", "custom.renovacao", "custom.lojas", "Email"}
+
+	got := string(writeLeads(t, FormatCSV, DefaultOptions(), names))
+	want := "Nome fantasia,Nota no Google,Data de fundação,Criado em,Tags,Renovação,Lojas,E-mail\n" +
+		"Padaria São João,4.5,2010-03-07,2024-05-01 14:30,\"vip, feira\",2025-02-01,3,\n"
+	if got != want {
+		t.Errorf("CSV =\n%s\nwant\n%s", got, want)
+	}
+
+	got = string(writeLeads(t, FormatCSV, BrazilianOptions(), names))
+	want = "\ufeffNome fantasia;Nota no Google;Data de fundação;Criado em;Tags;Renovação;Lojas;E-mail\n" +
+		"Padaria São João;4,5;07/03/2010;01/05/2024 14:30;vip, feira;01/02/2025;3;\n"
+	if got != want {
+		t.Errorf("CSV br =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestCSVEscapesFormulas(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, FormatCSV, DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	row := []interface{}{
+		`=HYPERLINK("http://evil.example","clique")`,
+		"+cmd|' /C calc'!A0",
+		"-2+3",
+		"@SUM(A1:A2)",
+		"\tpadaria",
+		[]string{"=1+1", "vip"},
+		"+5511999990000",
+		-1.5,
+		"Padaria",
+	}
+	if err := w.WriteRow(row); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	want := `"'=HYPERLINK(""http://evil.example"",""clique"")",'+cmd|' /C calc'!A0,'-2+3,'@SUM(A1:A2),'` + "\tpadaria,\"'=1+1, vip\",+5511999990000,-1.5,Padaria\n"
+	if got := buf.String(); got != want {
+		t.Errorf("CSV =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestXLSX(t *testing.T) {
+	data := writeLeads(t, FormatXLSX, BrazilianOptions(), []string{"BusinessName", "Rating", "FoundationDate"})
+	z, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string]string)
+	for _, f := range z.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(r)
+		r.Close()
+		files[f.Name] = string(body)
+	}
+	for _, name := range []string{"[Content_Types].xml", "xl/workbook.xml", "xl/styles.xml"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("missing %s", name)
+		}
+	}
+	sheet := files["xl/worksheets/sheet1.xml"]
+	for _, want := range []string{
+		`<row r="1"><c t="inlineStr" s="1"><is><t xml:space="preserve">Nome fantasia</t>`,
+		`<t xml:space="preserve">Padaria São João</t>`,
+		`<c><v>4.5</v></c>`,
+		`<t xml:space="preserve">07/03/2010</t>`,
+		`</sheetData></worksheet>`,
+	} {
+		if !strings.Contains(sheet, want) {
+			t.Errorf("sheet missing %s:\n%s", want, sheet)
+		}
+	}
+}
+
+func TestXMLEscape(t *testing.T) {
+	var buf bytes.Buffer
+	w, _ := NewWriter(&buf, FormatXLSX, DefaultOptions())
+	w.WriteRow([]interface{}{"A & B <c>\x01"})
+	w.Close()
+	z, _ := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	for _, f := range z.File {
+		if f.Name != "xl/worksheets/sheet1.xml" {
+			continue
+		}
+		r, _ := f.Open()
+		body, _ := io.ReadAll(r)
+		if !strings.Contains(string(body), ">A &amp; B &lt;c&gt;</t>") {
+			t.Errorf("sheet = %s", body)
+		}
+	}
+}
@@ -20,7 +20,12 @@ require (
 	github.com/joho/godotenv v1.5.1
 	github.com/lib/pq v1.10.9
 	github.com/streadway/amqp v1.1.0
-	golang.org/x/crypto v0.35.0 // indirect
+	github.com/wbrunovieira/LeadSearchVersion2/shared v0.0.0
+	golang.org/x/crypto v0.35.0
+	golang.org/x/net v0.35.0
 	golang.org/x/sync v0.11.0 // indirect
-	golang.org/x/text v0.22.0 // indirect
+	golang.org/x/text v0.22.0
+	gopkg.in/yaml.v3 v3.0.1
 )
+
+replace github.com/wbrunovieira/LeadSearchVersion2/shared => ../shared
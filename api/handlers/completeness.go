@@ -0,0 +1,41 @@
+// api/handlers/completeness.go
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/google/uuid"
+
+	"github.com/wbrunovieira/LeadSearchVersion2/completeness"
+	"github.com/wbrunovieira/LeadSearchVersion2/db"
+)
+
+type leadCompletenessResponse struct {
+	LeadID uuid.UUID `json:"lead_id"`
+	completeness.Result
+	Weights map[string]float64 `json:"weights"`
+}
+
+// LeadCompletenessHandler mostra a completude do lead, os campos que faltam e
+// o peso de cada campo no cálculo.
+func LeadCompletenessHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método não permitido. Use GET.", http.StatusMethodNotAllowed)
+		return
+	}
+	leadID, err := uuid.Parse(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, "ID inválido", http.StatusBadRequest)
+		return
+	}
+	lead, err := db.GetLeadByID(db.ScopeFromContext(r.Context()), leadID)
+	if err != nil || lead == nil {
+		http.Error(w, "Lead não encontrado", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, leadCompletenessResponse{
+		LeadID:  lead.ID,
+		Result:  db.Completeness(lead),
+		Weights: db.CompletenessWeights(),
+	})
+}
@@ -0,0 +1,120 @@
+// api/handlers/custom_fields.go
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/wbrunovieira/LeadSearchVersion2/db"
+	"github.com/wbrunovieira/LeadSearchVersion2/leadfields"
+)
+
+// customField devolve o campo personalizado do workspace do lead com a chave,
+// ou nil se o workspace não tiver esse campo.
+func customField(lead *db.Lead, key string) (*leadfields.Field, error) {
+	definition, err := db.GetCustomFieldDefinition(db.InWorkspace(lead.WorkspaceID), key)
+	if err != nil {
+		return nil, fmt.Errorf("Falha ao buscar o campo personalizado '%s': %v", key, err)
+	}
+	if definition == nil {
+		return nil, nil
+	}
+	return leadfields.Custom(*definition), nil
+}
+
+// CustomFieldsHandler gerencia os campos personalizados do workspace:
+// GET lista, POST cria ({key, label, type, options}), PUT ?key=X altera
+// rótulo e opções e DELETE ?key=X apaga o campo e os valores nos leads. A
+// chave e o tipo não mudam depois de criados.
+func CustomFieldsHandler(w http.ResponseWriter, r *http.Request) {
+	scope := db.ScopeFromContext(r.Context())
+	switch r.Method {
+	case http.MethodGet:
+		definitions, err := db.GetCustomFieldDefinitions(scope)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Falha ao buscar campos personalizados: %v", err), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, definitions)
+
+	case http.MethodPost:
+		var definition db.CustomFieldDefinition
+		if err := json.NewDecoder(r.Body).Decode(&definition); err != nil {
+			http.Error(w, "JSON inválido", http.StatusBadRequest)
+			return
+		}
+		if err := leadfields.ValidateCustomDefinition(&definition); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		err := db.CreateCustomFieldDefinition(scope, &definition)
+		if errors.Is(err, db.ErrCustomFieldExists) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusCreated, definition)
+
+	case http.MethodPut:
+		definition, ok := loadCustomField(w, r)
+		if !ok {
+			return
+		}
+		var req struct {
+			Label   *string  `json:"label"`
+			Options []string `json:"options"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "JSON inválido", http.StatusBadRequest)
+			return
+		}
+		if req.Label != nil {
+			definition.Label = *req.Label
+		}
+		if req.Options != nil {
+			definition.Options = req.Options
+		}
+		if err := leadfields.ValidateCustomDefinition(definition); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := db.SaveCustomFieldDefinition(definition); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, definition)
+
+	case http.MethodDelete:
+		definition, ok := loadCustomField(w, r)
+		if !ok {
+			return
+		}
+		if err := db.DeleteCustomFieldDefinition(definition); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+
+	default:
+		http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+	}
+}
+
+func loadCustomField(w http.ResponseWriter, r *http.Request) (*db.CustomFieldDefinition, bool) {
+	key := r.URL.Query().Get("key")
+	definition, err := db.GetCustomFieldDefinition(db.ScopeFromContext(r.Context()), key)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Falha ao buscar o campo personalizado: %v", err), http.StatusInternalServerError)
+		return nil, false
+	}
+	if definition == nil {
+		http.Error(w, "Campo personalizado não encontrado", http.StatusNotFound)
+		return nil, false
+	}
+	return definition, true
+}
@@ -0,0 +1,398 @@
+// api/handlers/duplicates.go
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/wbrunovieira/LeadSearchVersion2/db"
+	"github.com/wbrunovieira/LeadSearchVersion2/dedupe"
+	"github.com/wbrunovieira/LeadSearchVersion2/leadfields"
+	"github.com/wbrunovieira/LeadSearchVersion2/shared/auth"
+)
+
+func dedupeCandidate(lead *db.Lead) dedupe.Candidate {
+	return dedupe.Candidate{
+		Name:      lead.BusinessName,
+		CNPJ:      lead.CompanyRegistrationID,
+		Phones:    []string{lead.Phone, lead.Whatsapp},
+		Domain:    lead.Domain,
+		Address:   lead.Address,
+		City:      lead.City,
+		Latitude:  lead.Latitude,
+		Longitude: lead.Longitude,
+	}
+}
+
+// nameToken escolhe a palavra mais longa do nome normalizado para buscar
+// leads parecidos na mesma cidade. Palavras curtas trariam leads demais.
+func nameToken(name string) string {
+	token := ""
+	for _, t := range strings.Fields(dedupe.NormalizeName(name)) {
+		if len([]rune(t)) >= 4 && len(t) > len(token) {
+			token = t
+		}
+	}
+	return token
+}
+
+// detectDuplicates compara o lead com os parecidos do mesmo workspace e põe
+// na fila de revisão os pares acima de dedupe.Threshold. Devolve quantos
+// pares foram gravados.
+func detectDuplicates(lead *db.Lead) (int, error) {
+	if lead.MergedIntoID != nil {
+		return 0, nil
+	}
+	others, err := db.FindDuplicateCandidates(lead, nameToken(lead.BusinessName))
+	if err != nil {
+		return 0, fmt.Errorf("erro ao buscar leads parecidos: %v", err)
+	}
+
+	found := 0
+	candidate := dedupeCandidate(lead)
+	for i := range others {
+		match := dedupe.Compare(candidate, dedupeCandidate(&others[i]))
+		if match.Score < dedupe.Threshold {
+			continue
+		}
+		signals, err := json.Marshal(match.Signals)
+		if err != nil {
+			return found, err
+		}
+		pair := db.DuplicateCandidate{
+			WorkspaceID: lead.WorkspaceID,
+			LeadID:      lead.ID,
+			OtherLeadID: others[i].ID,
+			Score:       match.Score,
+			Signals:     signals,
+		}
+		if err := db.SaveDuplicateCandidate(&pair); err != nil {
+			return found, err
+		}
+		found++
+	}
+	if found > 0 {
+		log.Printf("Lead %s (%s) tem %d possíveis duplicatas", lead.ID, lead.BusinessName, found)
+	}
+	return found, nil
+}
+
+// DuplicatesHandler lista a fila de revisão de duplicatas com os dois leads
+// de cada par. Aceita ?status= (pending, merged, dismissed; padrão pending) e
+// ?min_score= (padrão dedupe.Threshold).
+func DuplicatesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método não permitido. Use GET.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "":
+		status = db.DuplicatePending
+	case db.DuplicatePending, db.DuplicateMerged, db.DuplicateDismissed:
+	default:
+		http.Error(w, "status deve ser pending, merged ou dismissed", http.StatusBadRequest)
+		return
+	}
+	minScore := dedupe.Threshold
+	if v := r.URL.Query().Get("min_score"); v != "" {
+		parsed, err := strconv.ParseFloat(v, 64)
+		if err != nil || parsed < 0 || parsed > 1 {
+			http.Error(w, "min_score deve ser um número entre 0 e 1", http.StatusBadRequest)
+			return
+		}
+		minScore = parsed
+	}
+
+	scope := db.ScopeFromContext(r.Context())
+	candidates, err := db.GetDuplicateCandidates(scope, status, minScore)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Falha ao buscar duplicatas: %v", err), http.StatusInternalServerError)
+		return
+	}
+	ids := make([]uuid.UUID, 0, 2*len(candidates))
+	for _, c := range candidates {
+		ids = append(ids, c.LeadID, c.OtherLeadID)
+	}
+	leads, err := db.GetLeadsByIDs(scope, ids)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Falha ao buscar leads: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	type review struct {
+		db.DuplicateCandidate
+		Lead      db.Lead `json:"lead"`
+		OtherLead db.Lead `json:"other_lead"`
+	}
+	reviews := make([]review, 0, len(candidates))
+	for _, c := range candidates {
+		reviews = append(reviews, review{DuplicateCandidate: c, Lead: leads[c.LeadID], OtherLead: leads[c.OtherLeadID]})
+	}
+	writeJSON(w, http.StatusOK, reviews)
+}
+
+// DuplicateScanHandler compara todos os leads do workspace e atualiza a fila
+// de revisão. Útil para leads gravados antes da detecção existir.
+func DuplicateScanHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método não permitido. Use POST.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	leads, err := db.GetLeads(db.ScopeFromContext(r.Context()))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Falha ao buscar leads: %v", err), http.StatusInternalServerError)
+		return
+	}
+	pairs := 0
+	for i := range leads {
+		n, err := detectDuplicates(&leads[i])
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Falha ao procurar duplicatas: %v", err), http.StatusInternalServerError)
+			return
+		}
+		pairs += n
+	}
+	log.Printf("DuplicateScanHandler: %d leads comparados, %d pares acima do limite", len(leads), pairs)
+	writeJSON(w, http.StatusOK, map[string]int{"leads": len(leads), "pairs": pairs})
+}
+
+// DuplicateDismissHandler recebe {"id": "..."} e marca o par como "não é
+// duplicata"; ele não volta para a fila.
+func DuplicateDismissHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método não permitido. Use POST.", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "JSON inválido", http.StatusBadRequest)
+		return
+	}
+	id, err := uuid.Parse(req.ID)
+	if err != nil {
+		http.Error(w, "ID inválido", http.StatusBadRequest)
+		return
+	}
+
+	principal, _ := auth.FromContext(r.Context())
+	if err := db.DismissDuplicateCandidate(db.ScopeFromContext(r.Context()), id, principal.Name); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": db.DuplicateDismissed})
+}
+
+// mergedField é a procedência de um campo alterado numa mesclagem.
+type mergedField struct {
+	From uuid.UUID   `json:"from"`
+	Old  interface{} `json:"old"`
+	New  interface{} `json:"new"`
+}
+
+// mergeLeadFields preenche os campos vazios de kept com os de merged, campo a
+// campo do registro leadfields e dos campos personalizados. Campos em
+// preferMerged ficam com o valor de merged mesmo quando kept já tem um.
+// Devolve a procedência do que mudou.
+func mergeLeadFields(kept, merged *db.Lead, preferMerged map[string]bool) map[string]mergedField {
+	fields := make(map[string]mergedField)
+	for _, f := range leadfields.All() {
+		keptValue, mergedValue := f.Get(kept), f.Get(merged)
+		if reflect.ValueOf(mergedValue).IsZero() || reflect.DeepEqual(keptValue, mergedValue) {
+			continue
+		}
+		if reflect.ValueOf(keptValue).IsZero() || preferMerged[f.Name] {
+			f.Set(kept, mergedValue)
+			fields[f.Name] = mergedField{From: merged.ID, Old: keptValue, New: mergedValue}
+		}
+	}
+	for key, mergedValue := range merged.CustomFields {
+		name := leadfields.CustomPrefix + key
+		keptValue, ok := kept.CustomFields[key]
+		if ok && (!preferMerged[name] || reflect.DeepEqual(keptValue, mergedValue)) {
+			continue
+		}
+		if kept.CustomFields == nil {
+			kept.CustomFields = make(map[string]interface{})
+		}
+		kept.CustomFields[key] = mergedValue
+		fields[name] = mergedField{From: merged.ID, Old: keptValue, New: mergedValue}
+	}
+	return fields
+}
+
+// mergedLeadColumns converte os campos devolvidos por mergeLeadFields nos
+// campos do Lead a gravar; os personalizados ficam todos em CustomFields.
+func mergedLeadColumns(changed map[string]mergedField) []string {
+	columns := make([]string, 0, len(changed))
+	custom := false
+	for name := range changed {
+		if strings.HasPrefix(name, leadfields.CustomPrefix) {
+			custom = true
+			continue
+		}
+		columns = append(columns, name)
+	}
+	if custom {
+		columns = append(columns, "CustomFields")
+	}
+	return columns
+}
+
+// MergeLeadsHandler mescla dois leads. Body: {"id": par da fila} ou
+// {"keep_id", "merge_id"}, e opcionalmente "prefer_merged": [campos] para
+// ficar com o valor do lead absorvido. O lead mantido ganha os campos que
+// não tinha, os telefones e os perfis sociais do outro; o absorvido some da
+// listagem mas fica no banco, e a mesclagem fica registrada em
+// /lead-merges.
+func MergeLeadsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método não permitido. Use POST.", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		ID           string   `json:"id"`
+		KeepID       string   `json:"keep_id"`
+		MergeID      string   `json:"merge_id"`
+		PreferMerged []string `json:"prefer_merged"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "JSON inválido", http.StatusBadRequest)
+		return
+	}
+
+	scope := db.ScopeFromContext(r.Context())
+	var keepID, mergeID uuid.UUID
+	var err error
+	if req.KeepID != "" {
+		if keepID, err = uuid.Parse(req.KeepID); err != nil {
+			http.Error(w, "keep_id inválido", http.StatusBadRequest)
+			return
+		}
+	}
+	if req.MergeID != "" {
+		if mergeID, err = uuid.Parse(req.MergeID); err != nil {
+			http.Error(w, "merge_id inválido", http.StatusBadRequest)
+			return
+		}
+	}
+	// Com o par da fila, keep_id escolhe qual dos dois fica; sem ele, fica o
+	// lead mais antigo.
+	chooseOlder := false
+	if req.ID != "" {
+		id, err := uuid.Parse(req.ID)
+		if err != nil {
+			http.Error(w, "ID inválido", http.StatusBadRequest)
+			return
+		}
+		pair, err := db.GetDuplicateCandidate(scope, id)
+		if err != nil || pair == nil {
+			http.Error(w, "Par de duplicatas não encontrado", http.StatusNotFound)
+			return
+		}
+		switch keepID {
+		case uuid.Nil:
+			keepID, mergeID, chooseOlder = pair.LeadID, pair.OtherLeadID, true
+		case pair.LeadID:
+			mergeID = pair.OtherLeadID
+		case pair.OtherLeadID:
+			mergeID = pair.LeadID
+		default:
+			http.Error(w, "keep_id não faz parte do par", http.StatusBadRequest)
+			return
+		}
+	}
+	if keepID == uuid.Nil || mergeID == uuid.Nil || keepID == mergeID {
+		http.Error(w, "Informe o par (id) ou dois leads diferentes (keep_id e merge_id)", http.StatusBadRequest)
+		return
+	}
+
+	prefer := make(map[string]bool, len(req.PreferMerged))
+	for _, name := range req.PreferMerged {
+		if _, ok := leadfields.Lookup(name); !ok {
+			http.Error(w, fmt.Sprintf("Campo '%s' não existe ou não pode ser mesclado", name), http.StatusBadRequest)
+			return
+		}
+		prefer[name] = true
+	}
+
+	leads, err := db.GetLeadsByIDs(scope, []uuid.UUID{keepID, mergeID})
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Falha ao buscar leads: %v", err), http.StatusInternalServerError)
+		return
+	}
+	kept, okKept := leads[keepID]
+	merged, okMerged := leads[mergeID]
+	if !okKept || !okMerged {
+		http.Error(w, "Lead não encontrado", http.StatusNotFound)
+		return
+	}
+	if chooseOlder && merged.CreatedAt.Before(kept.CreatedAt) {
+		kept, merged = merged, kept
+	}
+	if kept.MergedIntoID != nil || merged.MergedIntoID != nil {
+		http.Error(w, "Um dos leads já foi mesclado", http.StatusConflict)
+		return
+	}
+
+	// A mescla dos campos roda dentro da transação, sobre os leads relidos e
+	// travados; o que foi lido acima só decide qual lead fica.
+	principal, _ := auth.FromContext(r.Context())
+	result, record, err := db.MergeLeads(scope, kept.ID, merged.ID, func(kept, merged *db.Lead) ([]string, *db.LeadMerge) {
+		keptBefore, _ := json.Marshal(kept)
+		mergedBefore, _ := json.Marshal(merged)
+		changed := mergeLeadFields(kept, merged, prefer)
+		fields, _ := json.Marshal(changed)
+		return mergedLeadColumns(changed), &db.LeadMerge{
+			MergedBy:     principal.Name,
+			Fields:       fields,
+			KeptBefore:   keptBefore,
+			MergedBefore: mergedBefore,
+		}
+	})
+	if errors.Is(err, db.ErrLeadMerged) {
+		http.Error(w, "Um dos leads já foi mesclado", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Falha ao mesclar os leads: %v", err), http.StatusInternalServerError)
+		return
+	}
+	log.Printf("MergeLeadsHandler: lead %s absorvido por %s (%s)", merged.ID, kept.ID, principal.Name)
+
+	if _, err := detectDuplicates(result); err != nil {
+		log.Printf("Falha ao procurar duplicatas do lead %s: %v", kept.ID, err)
+	}
+	writeJSON(w, http.StatusOK, record)
+}
+
+// LeadMergesHandler devolve o histórico de mesclagens do lead (GET ?id=X).
+func LeadMergesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método não permitido. Use GET.", http.StatusMethodNotAllowed)
+		return
+	}
+	leadID, err := uuid.Parse(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, "ID inválido", http.StatusBadRequest)
+		return
+	}
+	merges, err := db.GetLeadMerges(db.ScopeFromContext(r.Context()), leadID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Falha ao buscar mesclagens: %v", err), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, merges)
+}
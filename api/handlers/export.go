@@ -0,0 +1,169 @@
+// api/handlers/export.go
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/wbrunovieira/LeadSearchVersion2/db"
+	"github.com/wbrunovieira/LeadSearchVersion2/export"
+)
+
+// ExportLeadsHandler baixa os leads em CSV ou XLSX (?format=csv|xlsx). Aceita
+// os mesmos filtros de /list-leads, ?columns= com os campos na ordem desejada
+// e as opções de formatação: locale=br (";", datas dd/mm/aaaa, vírgula
+// decimal e BOM), delimiter=;|,|tab, date_format=dd/mm/yyyy|yyyy-mm-dd,
+// decimal=comma|dot e header=names para usar os nomes dos campos no
+// cabeçalho. As linhas vão saindo enquanto são lidas do banco.
+func ExportLeadsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método não permitido. Use GET.", http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	scope := db.ScopeFromContext(r.Context())
+
+	filter, err := parseLeadFilter(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	format := strings.ToLower(query.Get("format"))
+	if format == "" {
+		format = export.FormatCSV
+	}
+	if format != export.FormatCSV && format != export.FormatXLSX {
+		http.Error(w, "format deve ser csv ou xlsx", http.StatusBadRequest)
+		return
+	}
+	opts, err := parseExportOptions(query.Get("locale"), query.Get("delimiter"), query.Get("date_format"), query.Get("decimal"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	definitions, err := db.GetCustomFieldDefinitions(scope)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Falha ao buscar campos personalizados: %v", err), http.StatusInternalServerError)
+		return
+	}
+	var names []string
+	for _, name := range strings.Split(query.Get("columns"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	columns, err := export.Columns(names, definitions)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	header := make([]interface{}, len(columns))
+	for i, c := range columns {
+		header[i] = c.Label
+		if query.Get("header") == "names" {
+			header[i] = c.Name
+		}
+	}
+
+	// O arquivo só começa depois que o filtro foi aceito pelo banco, para que
+	// um filtro inválido ainda possa virar 400.
+	var out export.Writer
+	start := func() error {
+		filename := fmt.Sprintf("leads-%s.%s", time.Now().Format("20060102-150405"), format)
+		w.Header().Set("Content-Type", export.ContentType(format))
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		var err error
+		if out, err = export.NewWriter(w, format, opts); err != nil {
+			return err
+		}
+		return out.WriteRow(header)
+	}
+
+	count := 0
+	row := make([]interface{}, len(columns))
+	err = db.StreamLeads(scope, filter, func(lead *db.Lead) error {
+		if out == nil {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		for i, c := range columns {
+			row[i] = c.Value(lead)
+		}
+		count++
+		return out.WriteRow(row)
+	})
+	if out == nil {
+		if errors.Is(err, db.ErrInvalidFilter) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Falha ao buscar leads: %v", err), http.StatusInternalServerError)
+			return
+		}
+		if err = start(); err != nil {
+			log.Printf("Erro ao iniciar a exportação: %v", err)
+			return
+		}
+	}
+	if err != nil {
+		// O cabeçalho HTTP já foi enviado: resta registrar e entregar o
+		// arquivo truncado.
+		log.Printf("Exportação de leads interrompida após %d leads: %v", count, err)
+	}
+	if err := out.Close(); err != nil {
+		log.Printf("Erro ao finalizar a exportação: %v", err)
+		return
+	}
+	log.Printf("Exportados %d leads em %s (%s)", count, format, scope)
+}
+
+// parseExportOptions parte do padrão (ou do locale br) e aplica as opções
+// avulsas por cima.
+func parseExportOptions(locale, delimiter, dateFormat, decimal string) (export.Options, error) {
+	opts := export.DefaultOptions()
+	switch strings.ToLower(locale) {
+	case "":
+	case "br", "pt-br", "pt_br":
+		opts = export.BrazilianOptions()
+	default:
+		return opts, fmt.Errorf("locale %q desconhecido: use br", locale)
+	}
+	switch delimiter {
+	case "":
+	case ",", ";", "|":
+		opts.Delimiter = rune(delimiter[0])
+	case "tab", "\t":
+		opts.Delimiter = '\t'
+	default:
+		return opts, errors.New("delimiter deve ser ',', ';', '|' ou tab")
+	}
+	switch strings.ToLower(dateFormat) {
+	case "":
+	case "dd/mm/yyyy", "dd/mm/aaaa":
+		opts.DateLayout = export.DateBR
+	case "yyyy-mm-dd", "aaaa-mm-dd", "iso":
+		opts.DateLayout = export.DateISO
+	default:
+		return opts, errors.New("date_format deve ser dd/mm/yyyy ou yyyy-mm-dd")
+	}
+	switch strings.ToLower(decimal) {
+	case "":
+	case "comma", "virgula", ",":
+		opts.DecimalComma = true
+	case "dot", "ponto", ".":
+		opts.DecimalComma = false
+	default:
+		return opts, errors.New("decimal deve ser comma ou dot")
+	}
+	if opts.DecimalComma && opts.Delimiter == ',' {
+		return opts, errors.New("vírgula decimal não combina com o delimitador ','; use delimiter=;")
+	}
+	return opts, nil
+}
@@ -4,17 +4,26 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
-	"reflect"
+	"net/url"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
 
+	"github.com/wbrunovieira/LeadSearchVersion2/completeness"
 	"github.com/wbrunovieira/LeadSearchVersion2/db"
+	"github.com/wbrunovieira/LeadSearchVersion2/leadfields"
 	"github.com/wbrunovieira/LeadSearchVersion2/rabbitmq"
+	"github.com/wbrunovieira/LeadSearchVersion2/shared/auth"
+	"github.com/wbrunovieira/LeadSearchVersion2/shared/phone"
+	"github.com/wbrunovieira/LeadSearchVersion2/shared/places"
+	"github.com/wbrunovieira/LeadSearchVersion2/weburl"
 )
 
 func SaveLeadsHandler(w http.ResponseWriter, r *http.Request) {
@@ -24,16 +33,24 @@ func SaveLeadsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var leadsData []map[string]interface{}
+	var leadsData []places.Place
 	if err := json.NewDecoder(r.Body).Decode(&leadsData); err != nil {
 		http.Error(w, "JSON inválido", http.StatusBadRequest)
 		return
 	}
 	log.Printf("Recebidos %d leads para salvar", len(leadsData))
 
+	for i, data := range leadsData {
+		if err := data.Validate(); err != nil {
+			http.Error(w, fmt.Sprintf("Lead #%d inválido: %v", i+1, err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	scope := db.ScopeFromContext(r.Context())
 	for i, data := range leadsData {
 		log.Printf("Processando lead #%d: %+v", i+1, data)
-		lead, err := saveLead(data)
+		lead, err := saveLead(scope, data)
 		if err != nil {
 			http.Error(w, fmt.Sprintf("Falha ao salvar um lead: %v", err), http.StatusInternalServerError)
 			return
@@ -42,6 +59,18 @@ func SaveLeadsHandler(w http.ResponseWriter, r *http.Request) {
 		if err := rabbitmq.PublishLead(lead); err != nil {
 			log.Printf("Falha ao publicar o lead %+v no RabbitMQ: %v", lead, err)
 		}
+		if data.JobID != "" {
+			event := rabbitmq.LeadEvent{
+				Event:     db.EventLeadSaved,
+				LeadID:    lead.ID.String(),
+				JobID:     data.JobID,
+				Workspace: lead.WorkspaceID.String(),
+				Details:   map[string]interface{}{"name": lead.BusinessName, "city": lead.City},
+			}
+			if err := rabbitmq.PublishLeadEvent(event); err != nil {
+				log.Printf("Falha ao anunciar o lead %s no RabbitMQ: %v", lead.ID, err)
+			}
+		}
 		log.Printf("Lead #%d salvo e publicado com sucesso", i+1)
 	}
 
@@ -57,7 +86,17 @@ func ListLeadsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	leads, err := db.GetLeads()
+	filter, err := parseLeadFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	leads, err := db.ListLeads(db.ScopeFromContext(r.Context()), filter)
+	if errors.Is(err, db.ErrInvalidFilter) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		log.Printf("Erro ao buscar leads: %v", err)
 		http.Error(w, fmt.Sprintf("Falha ao buscar leads: %v", err), http.StatusInternalServerError)
@@ -81,6 +120,58 @@ func ListLeadsHandler(w http.ResponseWriter, r *http.Request) {
 	log.Printf("Retornados %d leads com sucesso.", len(leads))
 }
 
+// parseLeadFilter lê os filtros da listagem de leads da query string:
+// quality, min_completeness, min_icp_score, stage, assigned_to, tag,
+// custom.<chave> e sort. O erro descreve o parâmetro inválido.
+func parseLeadFilter(query url.Values) (db.LeadFilter, error) {
+	filter := db.LeadFilter{Sort: query.Get("sort")}
+	if v := query.Get("quality"); v != "" {
+		for _, q := range strings.Split(v, ",") {
+			tier := completeness.Tier(strings.TrimSpace(q))
+			if !slices.Contains(completeness.Tiers, tier) {
+				return filter, fmt.Errorf("quality %q inválida: use excellent, good, fair ou poor", q)
+			}
+			filter.Qualities = append(filter.Qualities, string(tier))
+		}
+	}
+	if v := query.Get("min_completeness"); v != "" {
+		min, err := strconv.ParseFloat(v, 64)
+		if err != nil || min < 0 || min > 100 {
+			return filter, errors.New("min_completeness deve ser um número entre 0 e 100")
+		}
+		filter.MinCompleteness = min
+	}
+	if v := query.Get("min_icp_score"); v != "" {
+		min, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return filter, errors.New("min_icp_score deve ser um número")
+		}
+		filter.MinICPScore = &min
+	}
+	for _, stage := range strings.Split(query.Get("stage"), ",") {
+		if stage = strings.TrimSpace(stage); stage != "" {
+			filter.Stages = append(filter.Stages, strings.ToLower(stage))
+		}
+	}
+	filter.AssignedTo = strings.TrimSpace(query.Get("assigned_to"))
+	if v := query.Get("tag"); v != "" {
+		tags, err := leadfields.ParseTags(v)
+		if err != nil {
+			return filter, fmt.Errorf("tag: %v", err)
+		}
+		filter.Tags = tags
+	}
+	for name, values := range query {
+		if key := strings.TrimPrefix(name, leadfields.CustomPrefix); key != name && len(values) > 0 {
+			if filter.Custom == nil {
+				filter.Custom = make(map[string]string)
+			}
+			filter.Custom[key] = values[0]
+		}
+	}
+	return filter, nil
+}
+
 func HealthHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
@@ -90,99 +181,142 @@ func HealthHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("OK"))
 }
 
-func saveLead(placeDetails map[string]interface{}) (*db.Lead, error) {
+func saveLead(scope db.Scope, place places.Place) (*db.Lead, error) {
 	lead := db.Lead{
-		ID:     uuid.New(),
-		Source: "GooglePlaces",
+		ID:                uuid.New(),
+		Source:            "GooglePlaces",
+		BusinessName:      place.Name,
+		Address:           place.FormattedAddress,
+		City:              place.City,
+		State:             place.State,
+		ZIPCode:           place.ZIPCode,
+		Country:           place.Country,
+		Radius:            place.Radius,
+		Category:          place.Category,
+		Phone:             normalizedPhone(place.InternationalPhoneNumber),
+		Rating:            place.Rating,
+		UserRatingsTotal:  place.UserRatingsTotal,
+		PriceLevel:        place.PriceLevel,
+		BusinessStatus:    place.BusinessStatus,
+		Vicinity:          place.Vicinity,
+		PermanentlyClosed: place.PermanentlyClosed,
+		Categories:        strings.Join(place.Types, ", "),
+		GoogleId:          place.PlaceID,
+		Latitude:          place.Latitude,
+		Longitude:         place.Longitude,
+		GoogleMapsURL:     place.GoogleMapsURL,
+		OpeningHours:      strings.Join(place.OpeningHours, "\n"),
+		DetailsFetchedAt:  sql.NullTime{Time: time.Now(), Valid: true},
+		SearchJobID:       place.JobID,
+		SavedSearchID:     place.SavedSearchID,
 	}
 
-	if v, ok := placeDetails["Name"].(string); ok {
-		lead.BusinessName = v
-	}
-	if v, ok := placeDetails["FormattedAddress"].(string); ok {
-		lead.Address = v
+	if n, err := phone.Parse(place.InternationalPhoneNumber); err == nil && n.Kind == phone.KindMobile {
+		lead.Whatsapp = n.E164
 	}
-	if v, ok := placeDetails["City"].(string); ok {
-		lead.City = v
-	}
-	if v, ok := placeDetails["State"].(string); ok {
-		lead.State = v
+	applyWebsite(&lead, place.Website)
+	if v := place.Description; !strings.Contains(strings.TrimSpace(v), "No description available") {
+		log.Printf("Valor recebido para Description no saveLead api: [%s]", v)
+		lead.Description = v
 	}
-	if v, ok := placeDetails["ZIPCode"].(string); ok {
-		lead.ZIPCode = v
+
+	if err := db.CreateLead(scope, &lead); err != nil {
+		return nil, fmt.Errorf("failed to save lead to database: %v", err)
 	}
-	if v, ok := placeDetails["Country"].(string); ok {
-		lead.Country = v
+	if err := savePlaceExtras(scope, lead.ID, place); err != nil {
+		log.Printf("Falha ao salvar avaliações/fotos do lead %s: %v", lead.ID, err)
 	}
-	if v, ok := placeDetails["Radius"].(float64); ok {
-		lead.Radius = int(v)
-	} else if v, ok := placeDetails["Radius"].(int); ok {
-		lead.Radius = v
+
+	if n, err := db.CountLeadsWithDomain(&lead); err != nil {
+		log.Printf("Falha ao procurar leads com o domínio %s: %v", lead.Domain, err)
+	} else if n > 0 {
+		log.Printf("Lead %s compartilha o domínio %s com outros %d leads: possível duplicata", lead.ID, lead.Domain, n)
 	}
-	if v, ok := placeDetails["Category"].(string); ok {
-		lead.Category = v
+	if _, err := detectDuplicates(&lead); err != nil {
+		log.Printf("Falha ao procurar duplicatas do lead %s: %v", lead.ID, err)
 	}
-	if v, ok := placeDetails["InternationalPhoneNumber"].(string); ok {
-		lead.Phone = v
+
+	log.Printf("Lead salvo no banco de dados: %+v", lead)
+	log.Printf("Após CreateLead, lead.ID = %s", lead.ID.String())
+	return &lead, nil
+}
+
+func savePlaceExtras(scope db.Scope, leadID uuid.UUID, place places.Place) error {
+	if err := saveGooglePhone(scope, leadID, place.InternationalPhoneNumber); err != nil {
+		return err
 	}
-	if v, ok := placeDetails["Email"].(string); ok {
-		lead.Email = v
+	if err := saveWebsiteLinks(scope, leadID, place.Website); err != nil {
+		return err
 	}
-	if v, ok := placeDetails["Website"].(string); ok {
-		lead.Website = v
 
-		if strings.HasPrefix(lead.Website, "https://www.instagram.com") {
-			lead.Instagram = lead.Website
-			lead.Website = ""
+	if len(place.Reviews) > 0 {
+		reviews := make([]db.LeadReview, 0, len(place.Reviews))
+		for _, r := range place.Reviews {
+			reviews = append(reviews, db.LeadReview{
+				AuthorName:   r.AuthorName,
+				Rating:       r.Rating,
+				Text:         r.Text,
+				Language:     r.Language,
+				ReviewedAt:   time.Unix(r.Time, 0),
+				RelativeTime: r.RelativeTime,
+			})
 		}
-		if strings.HasPrefix(lead.Website, "https://www.facebook.com") {
-			lead.Facebook = lead.Website
-			lead.Website = ""
+		if err := db.ReplaceLeadReviews(scope, leadID, reviews); err != nil {
+			return err
 		}
 	}
-	if v, ok := placeDetails["Description"].(string); ok && !strings.Contains(strings.TrimSpace(v), "No description available") {
-		log.Printf("Valor recebido para Description no saveLead api: [%s]", v)
-		lead.Description = v
-	}
-	if v, ok := placeDetails["Rating"].(float64); ok {
-		lead.Rating = v
-	}
-	if v, ok := placeDetails["UserRatingsTotal"].(float64); ok {
-		lead.UserRatingsTotal = int(v)
-	}
-	if v, ok := placeDetails["PriceLevel"].(float64); ok {
-		lead.PriceLevel = int(v)
-	}
-	if v, ok := placeDetails["BusinessStatus"].(string); ok {
-		lead.BusinessStatus = v
+
+	if len(place.Photos) > 0 {
+		photos := make([]db.LeadPhoto, 0, len(place.Photos))
+		for _, p := range place.Photos {
+			photos = append(photos, db.LeadPhoto{
+				Reference:    p.Reference,
+				Width:        p.Width,
+				Height:       p.Height,
+				Attributions: strings.Join(p.Attributions, "\n"),
+			})
+		}
+		if err := db.ReplaceLeadPhotos(scope, leadID, photos); err != nil {
+			return err
+		}
 	}
-	if v, ok := placeDetails["Vicinity"].(string); ok {
-		lead.Vicinity = v
+	return nil
+}
+
+func LeadPlaceDetailsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método não permitido. Use GET.", http.StatusMethodNotAllowed)
+		return
 	}
-	if v, ok := placeDetails["PermanentlyClosed"].(bool); ok {
-		lead.PermanentlyClosed = v
+
+	leadID, err := uuid.Parse(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, "ID inválido", http.StatusBadRequest)
+		return
 	}
-	if v, ok := placeDetails["Types"].([]interface{}); ok {
-		var types []string
-		for _, t := range v {
-			if typeStr, ok := t.(string); ok {
-				types = append(types, typeStr)
-			}
-		}
-		lead.Categories = strings.Join(types, ", ")
+
+	scope := db.ScopeFromContext(r.Context())
+	reviews, err := db.GetLeadReviews(scope, leadID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Falha ao buscar avaliações: %v", err), http.StatusInternalServerError)
+		return
 	}
-	if v, ok := placeDetails["PlaceID"].(string); ok {
-		lead.GoogleId = v
+	photos, err := db.GetLeadPhotos(scope, leadID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Falha ao buscar fotos: %v", err), http.StatusInternalServerError)
+		return
 	}
 
-	if err := db.CreateLead(&lead); err != nil {
-		return nil, fmt.Errorf("failed to save lead to database: %v", err)
-	}
-	log.Printf("Lead salvo no banco de dados: %+v", lead)
-	log.Printf("Após CreateLead, lead.ID = %s", lead.ID.String())
-	return &lead, nil
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"reviews": reviews,
+		"photos":  photos,
+	})
 }
 
+// UpdateLeadHandler altera campos de um lead. Aceita um campo
+// ({id, field, value}) ou vários ({id, fields: {campo: valor}}); só campos do
+// registro leadfields são aceitos, e todos são validados antes de gravar.
 func UpdateLeadHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPut {
 		http.Error(w, "Método não permitido. Use PUT.", http.StatusMethodNotAllowed)
@@ -190,16 +324,18 @@ func UpdateLeadHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req struct {
-		ID    string      `json:"id"`
-		Field string      `json:"field"`
-		Value interface{} `json:"value"`
+		ID     string                 `json:"id"`
+		Field  string                 `json:"field"`
+		Value  interface{}            `json:"value"`
+		Fields map[string]interface{} `json:"fields"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "JSON inválido", http.StatusBadRequest)
 		return
 	}
-	log.Printf("UpdateLeadHandler - Payload recebido: %+v", req)
+	principal, _ := auth.FromContext(r.Context())
+	log.Printf("UpdateLeadHandler - Payload recebido de %s (%s): %+v", principal.Name, principal.Role, req)
 
 	leadID, err := uuid.Parse(req.ID)
 	if err != nil {
@@ -207,96 +343,124 @@ func UpdateLeadHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	lead, err := db.GetLeadByID(leadID)
-	if err != nil || lead == nil {
-		http.Error(w, "Lead não encontrado", http.StatusNotFound)
-		return
+	values := req.Fields
+	if req.Field != "" {
+		if values == nil {
+			values = make(map[string]interface{}, 1)
+		}
+		values[req.Field] = req.Value
 	}
-
-	leadValue := reflect.ValueOf(lead).Elem()
-	fieldVal := leadValue.FieldByName(req.Field)
-	if !fieldVal.IsValid() {
-		http.Error(w, fmt.Sprintf("Campo '%s' não existe", req.Field), http.StatusBadRequest)
+	if len(values) == 0 {
+		http.Error(w, "Nenhum campo informado", http.StatusBadRequest)
 		return
 	}
-	if !fieldVal.CanSet() {
-		http.Error(w, fmt.Sprintf("Campo '%s' não pode ser alterado", req.Field), http.StatusBadRequest)
+
+	scope := db.ScopeFromContext(r.Context())
+	lead, err := db.GetLeadByID(scope, leadID)
+	if err != nil || lead == nil {
+		http.Error(w, "Lead não encontrado", http.StatusNotFound)
 		return
 	}
 
-	oldValue := fieldVal.Interface()
-	log.Printf("UpdateLeadHandler - Atualizando campo '%s': valor antigo = %+v", req.Field, oldValue)
-
-	switch fieldVal.Kind() {
-	case reflect.String:
-		if v, ok := req.Value.(string); ok {
-
-			if req.Field == "Description" && strings.TrimSpace(v) == "No description available" {
-				log.Printf("UpdateLeadHandler - Ignorando atualização para o campo '%s' com valor padrão", req.Field)
-			} else {
-				fieldVal.SetString(v)
-				log.Printf("UpdateLeadHandler - Campo '%s' atualizado: '%s' -> '%s'", req.Field, oldValue, v)
+	type change struct {
+		field *leadfields.Field
+		value interface{}
+	}
+	var changes []change
+	for name, value := range values {
+		field, ok := leadfields.Lookup(name)
+		if !ok && strings.HasPrefix(name, leadfields.CustomPrefix) {
+			field, err = customField(lead, strings.TrimPrefix(name, leadfields.CustomPrefix))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
 			}
-		} else {
-			http.Error(w, fmt.Sprintf("Tipo inválido para o campo '%s', esperava string", req.Field), http.StatusBadRequest)
-			return
+			ok = field != nil
 		}
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		if v, ok := req.Value.(float64); ok {
-			newVal := int64(v)
-			fieldVal.SetInt(newVal)
-			log.Printf("UpdateLeadHandler - Campo '%s' atualizado: '%v' -> '%v'", req.Field, oldValue, newVal)
-		} else {
-			http.Error(w, fmt.Sprintf("Tipo inválido para o campo '%s', esperava número", req.Field), http.StatusBadRequest)
+		if !ok {
+			http.Error(w, fmt.Sprintf("Campo '%s' não existe ou não pode ser alterado", name), http.StatusBadRequest)
 			return
 		}
-	case reflect.Float32, reflect.Float64:
-		if v, ok := req.Value.(float64); ok {
-			fieldVal.SetFloat(v)
-			log.Printf("UpdateLeadHandler - Campo '%s' atualizado: '%v' -> '%v'", req.Field, oldValue, v)
-		} else {
-			http.Error(w, fmt.Sprintf("Tipo inválido para o campo '%s', esperava número", req.Field), http.StatusBadRequest)
+		if !field.CanEdit(principal) {
+			log.Printf("UpdateLeadHandler - %s (%s) não pode alterar o campo '%s'", principal.Name, principal.Role, name)
+			http.Error(w, fmt.Sprintf("Sem permissão para alterar o campo '%s'", name), http.StatusForbidden)
 			return
 		}
-	case reflect.Bool:
-		if v, ok := req.Value.(bool); ok {
-			fieldVal.SetBool(v)
-			log.Printf("UpdateLeadHandler - Campo '%s' atualizado: '%v' -> '%v'", req.Field, oldValue, v)
-		} else {
-			http.Error(w, fmt.Sprintf("Tipo inválido para o campo '%s', esperava booleano", req.Field), http.StatusBadRequest)
+		parsed, ignore, err := field.Parse(value)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-	case reflect.Struct:
-
-		if fieldVal.Type() == reflect.TypeOf(sql.NullTime{}) {
-			if dateStr, ok := req.Value.(string); ok {
-				parsedDate, err := time.Parse("2006-01-02", dateStr)
-				if err != nil {
-					http.Error(w, fmt.Sprintf("Formato de data inválido para o campo '%s': %v", req.Field, err), http.StatusBadRequest)
-					return
-				}
-				nt := sql.NullTime{Time: parsedDate, Valid: true}
-				fieldVal.Set(reflect.ValueOf(nt))
-				log.Printf("UpdateLeadHandler - Campo '%s' atualizado: '%+v' -> '%+v'", req.Field, oldValue, nt)
-			} else {
-				http.Error(w, fmt.Sprintf("Tipo inválido para o campo '%s', esperava string no formato YYYY-MM-DD", req.Field), http.StatusBadRequest)
-				return
-			}
-		} else {
-			http.Error(w, fmt.Sprintf("Tipo do campo '%s' não suportado para atualização", req.Field), http.StatusBadRequest)
-			return
+		if ignore {
+			log.Printf("UpdateLeadHandler - Ignorando atualização para o campo '%s' com valor padrão", name)
+			continue
 		}
-	default:
-		http.Error(w, fmt.Sprintf("Tipo do campo '%s' não suportado para atualização", req.Field), http.StatusBadRequest)
-		return
+		changes = append(changes, change{field: field, value: parsed})
+	}
+
+	for _, c := range changes {
+		oldValue := c.field.Get(lead)
+		c.field.Set(lead, c.value)
+		log.Printf("UpdateLeadHandler - Campo '%s' atualizado: '%+v' -> '%+v'", c.field.Name, oldValue, c.value)
 	}
 
-	if err := db.UpdateLead(lead); err != nil {
+	if err := db.UpdateLead(scope, lead); err != nil {
 		http.Error(w, fmt.Sprintf("Erro ao atualizar o lead: %v", err), http.StatusInternalServerError)
 		return
 	}
 
+	source := db.PhoneSourceManual
+	if principal.Role == auth.RoleService {
+		source = db.PhoneSourceEnrichment
+	}
+	for _, c := range changes {
+		if c.field.Format != leadfields.FormatPhone || c.value == "" {
+			continue
+		}
+		if n, err := phone.Parse(c.value.(string)); err == nil {
+			if _, err := db.AddLeadPhones(scope, lead.ID, []db.LeadPhone{leadPhoneFrom(n, source)}); err != nil {
+				log.Printf("UpdateLeadHandler - Falha ao registrar telefone do lead %s: %v", lead.ID, err)
+			}
+		}
+	}
+
+	// UpdateLead grava uma cópia do lead; o domínio recalculado ao salvar não
+	// volta para esta.
+	lead.Domain = weburl.DomainOf(lead.Website)
+	if _, err := detectDuplicates(lead); err != nil {
+		log.Printf("UpdateLeadHandler - Falha ao procurar duplicatas do lead %s: %v", lead.ID, err)
+	}
+
 	log.Printf("UpdateLeadHandler - Atualização concluída para o lead com ID: %s", lead.ID)
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("Lead atualizado com sucesso"))
 }
+
+// LeadFieldsHandler expõe o registro de campos editáveis e os campos
+// personalizados do workspace, indicando quais o principal pode alterar.
+func LeadFieldsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método não permitido. Use GET.", http.StatusMethodNotAllowed)
+		return
+	}
+	principal, _ := auth.FromContext(r.Context())
+
+	type fieldSchema struct {
+		*leadfields.Field
+		Editable bool `json:"editable"`
+	}
+	fields := leadfields.All()
+	definitions, err := db.GetCustomFieldDefinitions(db.ScopeFromContext(r.Context()))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Falha ao buscar campos personalizados: %v", err), http.StatusInternalServerError)
+		return
+	}
+	for _, d := range definitions {
+		fields = append(fields, leadfields.Custom(d))
+	}
+	schema := make([]fieldSchema, 0, len(fields))
+	for _, f := range fields {
+		schema = append(schema, fieldSchema{Field: f, Editable: f.CanEdit(principal)})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"fields": schema})
+}
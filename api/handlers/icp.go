@@ -0,0 +1,216 @@
+// api/handlers/icp.go
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/wbrunovieira/LeadSearchVersion2/db"
+	"github.com/wbrunovieira/LeadSearchVersion2/icp"
+	"github.com/wbrunovieira/LeadSearchVersion2/shared/auth"
+)
+
+const maxICPRulesSize = 1 << 20
+
+// ruleSetFormat escolhe o formato das regras por ?format= ou, sem ele, pelo
+// Content-Type (YAML se o tipo mencionar yaml, JSON no resto).
+func ruleSetFormat(r *http.Request, header string) string {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return strings.ToLower(format)
+	}
+	if strings.Contains(strings.ToLower(r.Header.Get(header)), "yaml") {
+		return icp.FormatYAML
+	}
+	return ""
+}
+
+// ICPRulesHandler gerencia as regras de ICP do workspace:
+// GET devolve a versão ativa (ou ?version=N) e POST grava uma versão nova,
+// em JSON ou YAML, a ativa e repontua os leads.
+func ICPRulesHandler(w http.ResponseWriter, r *http.Request) {
+	scope := db.ScopeFromContext(r.Context())
+	switch r.Method {
+	case http.MethodGet:
+		version := 0
+		if v := r.URL.Query().Get("version"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 {
+				http.Error(w, "version inválida", http.StatusBadRequest)
+				return
+			}
+			version = n
+		}
+		ruleSet, err := db.GetICPRuleSet(scope, version)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Falha ao buscar regras de ICP: %v", err), http.StatusInternalServerError)
+			return
+		}
+		if ruleSet == nil {
+			http.Error(w, "Regras de ICP não encontradas", http.StatusNotFound)
+			return
+		}
+
+		// Com ?format= (ou Accept: application/yaml) devolve só o documento das
+		// regras, pronto para ser editado e enviado de volta.
+		format := ruleSetFormat(r, "Accept")
+		if format == "" {
+			writeJSON(w, http.StatusOK, ruleSet)
+			return
+		}
+		rules, err := ruleSet.RuleSet()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		data, err := rules.Marshal(format)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if format == icp.FormatJSON {
+			w.Header().Set("Content-Type", "application/json")
+		} else {
+			w.Header().Set("Content-Type", "application/yaml")
+		}
+		w.Write(data)
+
+	case http.MethodPost:
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxICPRulesSize))
+		if err != nil {
+			http.Error(w, "Erro ao ler o corpo da requisição", http.StatusBadRequest)
+			return
+		}
+		format := ruleSetFormat(r, "Content-Type")
+		rules, err := icp.Parse(body, format)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		encoded, err := json.Marshal(rules)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if format == "" || format == icp.FormatJSON {
+			format = icp.FormatJSON
+		} else {
+			format = icp.FormatYAML
+		}
+
+		principal, _ := auth.FromContext(r.Context())
+		ruleSet := db.ICPRuleSet{
+			Name:      rules.Name,
+			Rules:     encoded,
+			Format:    format,
+			Source:    string(body),
+			CreatedBy: principal.Name,
+		}
+		if err := db.CreateICPRuleSet(scope, &ruleSet); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		rescored, err := db.RescoreICP(scope)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		log.Printf("ICPRulesHandler: versão %d gravada por %s, %d leads repontuados", ruleSet.Version, principal.Name, rescored)
+		writeJSON(w, http.StatusCreated, map[string]interface{}{"rule_set": ruleSet, "rescored": rescored})
+
+	default:
+		http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+	}
+}
+
+// ICPRuleVersionsHandler lista todas as versões das regras de ICP do
+// workspace, da mais nova para a mais antiga.
+func ICPRuleVersionsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método não permitido. Use GET.", http.StatusMethodNotAllowed)
+		return
+	}
+	ruleSets, err := db.GetICPRuleSets(db.ScopeFromContext(r.Context()))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Falha ao buscar regras de ICP: %v", err), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, ruleSets)
+}
+
+// ICPActivateHandler recebe {"version": N}, volta a usar essa versão das
+// regras e repontua os leads.
+func ICPActivateHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método não permitido. Use POST.", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		Version int `json:"version"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Version < 1 {
+		http.Error(w, "JSON inválido: informe version", http.StatusBadRequest)
+		return
+	}
+
+	scope := db.ScopeFromContext(r.Context())
+	ruleSet, err := db.ActivateICPRuleSet(scope, req.Version)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if ruleSet == nil {
+		http.Error(w, "Versão das regras de ICP não encontrada", http.StatusNotFound)
+		return
+	}
+	rescored, err := db.RescoreICP(scope)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"rule_set": ruleSet, "rescored": rescored})
+}
+
+type leadICPResponse struct {
+	LeadID  uuid.UUID         `json:"lead_id"`
+	Score   float64           `json:"score"`
+	Version int               `json:"version"`
+	History []db.LeadICPScore `json:"history"`
+}
+
+// LeadICPScoresHandler mostra a pontuação de ICP atual do lead e o histórico,
+// com a versão das regras e o resultado de cada regra em cada entrada.
+func LeadICPScoresHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método não permitido. Use GET.", http.StatusMethodNotAllowed)
+		return
+	}
+	leadID, err := uuid.Parse(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, "ID inválido", http.StatusBadRequest)
+		return
+	}
+	scope := db.ScopeFromContext(r.Context())
+	lead, err := db.GetLeadByID(scope, leadID)
+	if err != nil || lead == nil {
+		http.Error(w, "Lead não encontrado", http.StatusNotFound)
+		return
+	}
+	history, err := db.GetLeadICPScores(scope, leadID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Falha ao buscar pontuações de ICP: %v", err), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, leadICPResponse{
+		LeadID:  lead.ID,
+		Score:   lead.ICPScore,
+		Version: lead.ICPVersion,
+		History: history,
+	})
+}
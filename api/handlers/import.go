@@ -0,0 +1,251 @@
+// api/handlers/import.go
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/wbrunovieira/LeadSearchVersion2/db"
+	"github.com/wbrunovieira/LeadSearchVersion2/leadimport"
+	"github.com/wbrunovieira/LeadSearchVersion2/rabbitmq"
+	"github.com/wbrunovieira/LeadSearchVersion2/shared/auth"
+	"github.com/wbrunovieira/LeadSearchVersion2/shared/phone"
+	"github.com/wbrunovieira/LeadSearchVersion2/weburl"
+)
+
+// maxImportSize é o tamanho máximo do CSV aceito por /import-leads.
+const maxImportSize = 20 << 20
+
+// Situações de uma linha importada.
+const (
+	importCreated   = "created"
+	importDuplicate = "duplicate"
+	importInvalid   = "invalid"
+	// importFailed é uma linha válida que não pôde ser gravada; as outras
+	// linhas do arquivo seguem.
+	importFailed = "failed"
+)
+
+type importRow struct {
+	Row    int                   `json:"row"`
+	Status string                `json:"status"`
+	LeadID *uuid.UUID            `json:"lead_id,omitempty"`
+	Match  string                `json:"match,omitempty"`
+	SameAs int                   `json:"same_as_row,omitempty"`
+	Errors []leadimport.RowError `json:"errors,omitempty"`
+	Error  string                `json:"error,omitempty"`
+}
+
+type importReport struct {
+	File           string             `json:"file"`
+	DryRun         bool               `json:"dry_run"`
+	Columns        leadimport.Mapping `json:"columns"`
+	IgnoredColumns []string           `json:"ignored_columns"`
+	Rows           int                `json:"rows"`
+	Created        int                `json:"created"`
+	Duplicates     int                `json:"duplicates"`
+	Invalid        int                `json:"invalid"`
+	Failed         int                `json:"failed"`
+	Results        []importRow        `json:"results"`
+
+	// Error é o erro que interrompeu a leitura do arquivo; as linhas
+	// anteriores a ele já estão em Results (e gravadas, fora do dry_run).
+	Error string `json:"error,omitempty"`
+}
+
+// ImportLeadsHandler importa leads de um CSV enviado em multipart: "file" é
+// o arquivo, "mapping" um JSON {"coluna do CSV": "Campo"} (opcional para as
+// colunas com o nome ou o rótulo do campo), "delimiter" força ";", ",", "|"
+// ou tab e "dry_run=true" só valida. Cada linha é validada como em
+// /update-lead-field; linhas com o CNPJ, o domínio ou um telefone de um lead
+// do workspace (ou de uma linha anterior do arquivo) não são importadas. Os
+// leads novos recebem Source com o nome do arquivo e são publicados no
+// lead_exchange para o enriquecimento. Uma linha que não pôde ser gravada
+// fica como failed e a importação continua; se o arquivo deixar de ser
+// legível no meio, a resposta é 400 com o relatório das linhas anteriores.
+func ImportLeadsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método não permitido. Use POST.", http.StatusMethodNotAllowed)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+	if err := r.ParseMultipartForm(8 << 20); err != nil {
+		http.Error(w, fmt.Sprintf("Envie o CSV em multipart/form-data no campo 'file' (até %d MB): %v", maxImportSize>>20, err), http.StatusBadRequest)
+		return
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "Campo 'file' ausente", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	var mapping leadimport.Mapping
+	if v := r.FormValue("mapping"); v != "" {
+		if err := json.Unmarshal([]byte(v), &mapping); err != nil {
+			http.Error(w, "mapping deve ser um JSON {\"coluna\": \"Campo\"}", http.StatusBadRequest)
+			return
+		}
+	}
+	var delimiter rune
+	switch v := r.FormValue("delimiter"); v {
+	case "":
+	case ",", ";", "|":
+		delimiter = rune(v[0])
+	case "tab", "\t":
+		delimiter = '\t'
+	default:
+		http.Error(w, "delimiter deve ser ',', ';', '|' ou tab", http.StatusBadRequest)
+		return
+	}
+
+	scope := db.ScopeFromContext(r.Context())
+	definitions, err := db.GetCustomFieldDefinitions(scope)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Falha ao buscar campos personalizados: %v", err), http.StatusInternalServerError)
+		return
+	}
+	reader, err := leadimport.NewReader(file, delimiter, mapping, definitions)
+	if errors.Is(err, leadimport.ErrInvalidFile) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Falha ao ler o CSV: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	principal, _ := auth.FromContext(r.Context())
+	report := importReport{
+		File:           filepath.Base(strings.ReplaceAll(header.Filename, "\\", "/")),
+		DryRun:         r.FormValue("dry_run") == "true",
+		Columns:        reader.Columns(),
+		IgnoredColumns: reader.Ignored(),
+		Results:        []importRow{},
+	}
+	log.Printf("Importação de %s por %s no %s (colunas: %v)", report.File, principal.Name, scope, report.Columns)
+
+	// seen guarda as chaves de deduplicação das linhas já aceitas do arquivo.
+	seen := make(map[string]int)
+	for {
+		lead, row, rowErrors, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			report.Error = fmt.Sprintf("Falha ao ler o CSV na linha %d: %v", report.Rows+2, err)
+			break
+		}
+		report.Rows++
+		result := importRow{Row: row}
+
+		switch {
+		case len(rowErrors) > 0:
+			result.Status, result.Errors = importInvalid, rowErrors
+			report.Invalid++
+
+		default:
+			keys := importKeys(lead)
+			for _, key := range keys {
+				if first, ok := seen[key]; ok {
+					result.Status, result.Match, result.SameAs = importDuplicate, strings.SplitN(key, ":", 2)[0], first
+					break
+				}
+			}
+			if result.Status == "" {
+				existing, match, err := db.FindExistingLead(scope, lead)
+				if err != nil {
+					log.Printf("Importação de %s: falha ao procurar leads existentes para a linha %d: %v", report.File, row, err)
+					result.Status, result.Error = importFailed, fmt.Sprintf("falha ao procurar leads existentes: %v", err)
+					report.Failed++
+					break
+				}
+				if existing != nil {
+					result.Status, result.Match, result.LeadID = importDuplicate, match, &existing.ID
+				}
+			}
+			if result.Status == importDuplicate {
+				report.Duplicates++
+				break
+			}
+
+			if !report.DryRun {
+				if err := importLead(scope, lead, report.File); err != nil {
+					log.Printf("Importação de %s: falha ao gravar o lead da linha %d: %v", report.File, row, err)
+					result.Status, result.Error = importFailed, fmt.Sprintf("falha ao gravar o lead: %v", err)
+					report.Failed++
+					break
+				}
+				result.LeadID = &lead.ID
+			}
+			for _, key := range keys {
+				seen[key] = row
+			}
+			result.Status = importCreated
+			report.Created++
+		}
+		report.Results = append(report.Results, result)
+	}
+
+	if report.Error != "" {
+		log.Printf("Importação de %s interrompida: %s", report.File, report.Error)
+		writeJSON(w, http.StatusBadRequest, report)
+		return
+	}
+	log.Printf("Importação de %s: %d linhas, %d novos, %d duplicados, %d inválidos, %d com falha (dry_run=%v)",
+		report.File, report.Rows, report.Created, report.Duplicates, report.Invalid, report.Failed, report.DryRun)
+	writeJSON(w, http.StatusOK, report)
+}
+
+// importKeys são as chaves usadas para achar linhas repetidas no arquivo,
+// com os mesmos critérios de db.FindExistingLead.
+func importKeys(lead *db.Lead) []string {
+	var keys []string
+	if lead.CompanyRegistrationID != "" {
+		keys = append(keys, "cnpj:"+lead.CompanyRegistrationID)
+	}
+	if domain := weburl.DomainOf(lead.Website); domain != "" {
+		keys = append(keys, "domain:"+domain)
+	}
+	for _, p := range []string{lead.Phone, lead.Whatsapp} {
+		if p != "" {
+			keys = append(keys, "phone:"+p)
+		}
+	}
+	return keys
+}
+
+// importLead grava o lead importado, registra os telefones, procura
+// duplicatas prováveis e publica o lead para o enriquecimento.
+func importLead(scope db.Scope, lead *db.Lead, source string) error {
+	lead.ID = uuid.New()
+	lead.Source = source
+	if err := db.CreateLead(scope, lead); err != nil {
+		return err
+	}
+
+	var phones []db.LeadPhone
+	for _, raw := range []string{lead.Phone, lead.Whatsapp} {
+		if n, err := phone.Parse(raw); err == nil {
+			phones = append(phones, leadPhoneFrom(n, db.PhoneSourceImport))
+		}
+	}
+	if _, err := db.AddLeadPhones(scope, lead.ID, phones); err != nil {
+		log.Printf("Falha ao salvar telefones do lead importado %s: %v", lead.ID, err)
+	}
+	if _, err := detectDuplicates(lead); err != nil {
+		log.Printf("Falha ao procurar duplicatas do lead %s: %v", lead.ID, err)
+	}
+	if err := rabbitmq.PublishLead(lead); err != nil {
+		log.Printf("Falha ao publicar o lead importado %s no RabbitMQ: %v", lead.ID, err)
+	}
+	return nil
+}
@@ -0,0 +1,350 @@
+// api/handlers/integrations.go
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/wbrunovieira/LeadSearchVersion2/db"
+	"github.com/wbrunovieira/LeadSearchVersion2/integrations"
+	"github.com/wbrunovieira/LeadSearchVersion2/shared/auth"
+)
+
+// redacted substitui os valores dos cabeçalhos nas respostas. Um PUT que
+// devolve o valor assim mantém o que estava gravado.
+const redacted = "********"
+
+// IntegrationsHandler gerencia as integrações com CRMs do workspace:
+// GET lista, POST cria, PUT ?id=X altera e DELETE ?id=X apaga a integração e
+// o histórico de entregas. O segredo de assinatura só aparece na resposta do
+// POST e do PUT com "rotate_secret": true; o tipo não muda depois de criado.
+func IntegrationsHandler(w http.ResponseWriter, r *http.Request) {
+	scope := db.ScopeFromContext(r.Context())
+	switch r.Method {
+	case http.MethodGet:
+		list, err := db.GetIntegrations(scope)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Falha ao buscar integrações: %v", err), http.StatusInternalServerError)
+			return
+		}
+		for i := range list {
+			redactIntegration(&list[i])
+		}
+		writeJSON(w, http.StatusOK, list)
+
+	case http.MethodPost:
+		var integration db.Integration
+		if err := json.NewDecoder(r.Body).Decode(&integration); err != nil {
+			http.Error(w, "JSON inválido", http.StatusBadRequest)
+			return
+		}
+		integration.Secret = ""
+		if !validateIntegration(w, scope, &integration) {
+			return
+		}
+		principal, _ := auth.FromContext(r.Context())
+		integration.CreatedBy = principal.Name
+		if err := db.CreateIntegration(scope, &integration); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusCreated, integration)
+
+	case http.MethodPut:
+		integration, ok := loadIntegration(w, r)
+		if !ok {
+			return
+		}
+		saved := *integration
+		req := struct {
+			*db.Integration
+			RotateSecret bool `json:"rotate_secret"`
+		}{Integration: integration}
+		// Mapas ausentes no corpo ficam como estavam; {} os esvazia.
+		integration.Mapping, integration.Headers = nil, nil
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "JSON inválido", http.StatusBadRequest)
+			return
+		}
+		integration.ID, integration.WorkspaceID, integration.Kind = saved.ID, saved.WorkspaceID, saved.Kind
+		integration.CreatedBy, integration.CreatedAt = saved.CreatedBy, saved.CreatedAt
+		integration.Secret = saved.Secret
+		if integration.Mapping == nil {
+			integration.Mapping = saved.Mapping
+		}
+		if integration.Headers == nil {
+			integration.Headers = saved.Headers
+		}
+		if req.RotateSecret {
+			integration.Secret = integrations.NewSecret()
+		}
+		for name, value := range integration.Headers {
+			if value == redacted {
+				integration.Headers[name] = saved.Headers[name]
+			}
+		}
+		if !validateIntegration(w, scope, integration) {
+			return
+		}
+		if err := db.SaveIntegration(integration); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !req.RotateSecret {
+			redactIntegration(integration)
+		}
+		writeJSON(w, http.StatusOK, integration)
+
+	case http.MethodDelete:
+		integration, ok := loadIntegration(w, r)
+		if !ok {
+			return
+		}
+		if err := db.DeleteIntegration(integration); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("Integração removida"))
+
+	default:
+		http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+	}
+}
+
+type integrationTestResponse struct {
+	StatusCode int                    `json:"status_code,omitempty"`
+	ExternalID string                 `json:"external_id,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+	DurationMS int64                  `json:"duration_ms"`
+	Payload    map[string]interface{} `json:"payload"`
+}
+
+// IntegrationTestHandler envia na hora um evento integration.test para a
+// integração ?id=X com o lead ?lead_id=Y (ou um lead de exemplo) e devolve a
+// resposta do destino. O teste não entra no histórico de entregas nem grava o
+// ID devolvido por um CRM rest.
+func IntegrationTestHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método não permitido. Use POST.", http.StatusMethodNotAllowed)
+		return
+	}
+	integration, ok := loadIntegration(w, r)
+	if !ok {
+		return
+	}
+	scope := db.ScopeFromContext(r.Context())
+
+	lead := sampleLead(integration.WorkspaceID)
+	if v := r.URL.Query().Get("lead_id"); v != "" {
+		leadID, err := uuid.Parse(v)
+		if err != nil {
+			http.Error(w, "lead_id inválido", http.StatusBadRequest)
+			return
+		}
+		if lead, err = db.GetLeadByID(scope, leadID); err != nil || lead == nil {
+			http.Error(w, "Lead não encontrado", http.StatusNotFound)
+			return
+		}
+	}
+	custom, err := db.GetCustomFieldDefinitions(scope)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Falha ao buscar campos personalizados: %v", err), http.StatusInternalServerError)
+		return
+	}
+	externalID := ""
+	if integration.Kind == db.IntegrationREST {
+		if externalID, err = db.GetIntegrationLink(integration.ID, lead.ID); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	event := integrations.Event{
+		ID:          uuid.New(),
+		Event:       integrations.EventTest,
+		OccurredAt:  time.Now(),
+		WorkspaceID: integration.WorkspaceID,
+		LeadID:      lead.ID,
+		Data:        integrations.Build(lead, integration.Mapping, custom),
+	}
+	started := time.Now()
+	result, err := integrations.NewSender().Send(r.Context(), integration, event, externalID)
+	response := integrationTestResponse{
+		StatusCode: result.StatusCode,
+		ExternalID: result.ExternalID,
+		DurationMS: time.Since(started).Milliseconds(),
+		Payload:    event.Data,
+	}
+	if err != nil {
+		response.Error = err.Error()
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+type integrationDeliveryResponse struct {
+	db.IntegrationDelivery
+	History []db.DeliveryAttempt `json:"history"`
+}
+
+// IntegrationDeliveriesHandler é o histórico de entregas, das mais recentes
+// para as mais antigas, com filtros ?integration_id, ?lead_id, ?event,
+// ?status (pending, delivered ou failed) e ?limit (padrão 100). Com ?id=X devolve a
+// entrega e cada uma das tentativas.
+func IntegrationDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método não permitido. Use GET.", http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	if query.Get("id") != "" {
+		delivery, ok := loadIntegrationDelivery(w, r)
+		if !ok {
+			return
+		}
+		history, err := db.GetDeliveryAttempts(delivery.ID)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Falha ao buscar tentativas: %v", err), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, integrationDeliveryResponse{IntegrationDelivery: *delivery, History: history})
+		return
+	}
+
+	var filter db.DeliveryFilter
+	for param, target := range map[string]**uuid.UUID{"integration_id": &filter.IntegrationID, "lead_id": &filter.LeadID} {
+		if v := query.Get(param); v != "" {
+			id, err := uuid.Parse(v)
+			if err != nil {
+				http.Error(w, param+" inválido", http.StatusBadRequest)
+				return
+			}
+			*target = &id
+		}
+	}
+	filter.Event = query.Get("event")
+	filter.Status = query.Get("status")
+	if filter.Status != "" && filter.Status != db.DeliveryPending && filter.Status != db.DeliveryDelivered && filter.Status != db.DeliveryFailed {
+		http.Error(w, "status deve ser pending, delivered ou failed", http.StatusBadRequest)
+		return
+	}
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > 1000 {
+			http.Error(w, "limit deve ser um número entre 1 e 1000", http.StatusBadRequest)
+			return
+		}
+		filter.Limit = limit
+	}
+
+	deliveries, err := db.GetIntegrationDeliveries(db.ScopeFromContext(r.Context()), filter)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Falha ao buscar entregas: %v", err), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, deliveries)
+}
+
+// IntegrationDeliveryRetryHandler devolve a entrega ?id=X à fila para ser
+// enviada de novo no próximo ciclo, mesmo que já tenha falhado de vez ou sido
+// entregue. Uma entrega que já esgotou as tentativas ganha só mais uma.
+func IntegrationDeliveryRetryHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método não permitido. Use POST.", http.StatusMethodNotAllowed)
+		return
+	}
+	delivery, ok := loadIntegrationDelivery(w, r)
+	if !ok {
+		return
+	}
+	if err := db.RetryIntegrationDelivery(delivery); err != nil {
+		http.Error(w, fmt.Sprintf("Falha ao reagendar a entrega: %v", err), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, delivery)
+}
+
+func loadIntegration(w http.ResponseWriter, r *http.Request) (*db.Integration, bool) {
+	id, err := uuid.Parse(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, "ID inválido", http.StatusBadRequest)
+		return nil, false
+	}
+	integration, err := db.GetIntegration(db.ScopeFromContext(r.Context()), id)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Falha ao buscar a integração: %v", err), http.StatusInternalServerError)
+		return nil, false
+	}
+	if integration == nil {
+		http.Error(w, "Integração não encontrada", http.StatusNotFound)
+		return nil, false
+	}
+	return integration, true
+}
+
+func loadIntegrationDelivery(w http.ResponseWriter, r *http.Request) (*db.IntegrationDelivery, bool) {
+	id, err := uuid.Parse(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, "ID inválido", http.StatusBadRequest)
+		return nil, false
+	}
+	delivery, err := db.GetIntegrationDelivery(db.ScopeFromContext(r.Context()), id)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Falha ao buscar a entrega: %v", err), http.StatusInternalServerError)
+		return nil, false
+	}
+	if delivery == nil {
+		http.Error(w, "Entrega não encontrada", http.StatusNotFound)
+		return nil, false
+	}
+	return delivery, true
+}
+
+// validateIntegration confere a configuração contra os campos personalizados
+// do workspace e responde o erro se houver.
+func validateIntegration(w http.ResponseWriter, scope db.Scope, integration *db.Integration) bool {
+	custom, err := db.GetCustomFieldDefinitions(scope)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Falha ao buscar campos personalizados: %v", err), http.StatusInternalServerError)
+		return false
+	}
+	if err := integrations.Validate(integration, custom); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, integrations.ErrInvalid) {
+			status = http.StatusBadRequest
+		}
+		http.Error(w, err.Error(), status)
+		return false
+	}
+	return true
+}
+
+func redactIntegration(integration *db.Integration) {
+	integration.Secret = ""
+	for name := range integration.Headers {
+		integration.Headers[name] = redacted
+	}
+}
+
+// sampleLead é o lead enviado no teste quando nenhum é indicado.
+func sampleLead(workspaceID uuid.UUID) *db.Lead {
+	return &db.Lead{
+		ID:                    uuid.New(),
+		WorkspaceID:           workspaceID,
+		BusinessName:          "Empresa Exemplo",
+		RegisteredName:        "Empresa Exemplo Ltda",
+		CompanyRegistrationID: "11222333000181",
+		Phone:                 "+551133334444",
+		Email:                 "contato@exemplo.com.br",
+		Website:               "https://exemplo.com.br",
+		City:                  "São Paulo",
+		State:                 "SP",
+		Stage:                 db.StageNew,
+	}
+}
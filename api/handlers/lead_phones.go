@@ -0,0 +1,136 @@
+// api/handlers/lead_phones.go
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/wbrunovieira/LeadSearchVersion2/db"
+	"github.com/wbrunovieira/LeadSearchVersion2/shared/phone"
+)
+
+func leadPhoneFrom(n phone.Number, source string) db.LeadPhone {
+	return db.LeadPhone{
+		Number:      n.E164,
+		Kind:        string(n.Kind),
+		WhatsAppURL: n.WhatsAppURL(),
+		Source:      source,
+	}
+}
+
+// normalizedPhone devolve o telefone em E.164 ou, se não der para
+// interpretá-lo, o valor original.
+func normalizedPhone(raw string) string {
+	if n, err := phone.Parse(raw); err == nil {
+		return n.E164
+	}
+	return raw
+}
+
+// saveGooglePhone registra o telefone do Google Places do lead.
+func saveGooglePhone(scope db.Scope, leadID uuid.UUID, raw string) error {
+	if raw == "" {
+		return nil
+	}
+	n, err := phone.Parse(raw)
+	if err != nil {
+		log.Printf("Telefone do Google %q do lead %s ignorado: %v", raw, leadID, err)
+		return nil
+	}
+	_, err = db.AddLeadPhones(scope, leadID, []db.LeadPhone{leadPhoneFrom(n, db.PhoneSourceGoogle)})
+	return err
+}
+
+// LeadPhonesHandler lista (GET ?id=X) e acrescenta (POST) telefones de um
+// lead. No POST, números inválidos são devolvidos em "rejected"; o primeiro
+// telefone válido preenche Phone e o primeiro celular preenche Whatsapp se
+// eles estiverem vazios.
+func LeadPhonesHandler(w http.ResponseWriter, r *http.Request) {
+	scope := db.ScopeFromContext(r.Context())
+
+	switch r.Method {
+	case http.MethodGet:
+		leadID, err := uuid.Parse(r.URL.Query().Get("id"))
+		if err != nil {
+			http.Error(w, "ID inválido", http.StatusBadRequest)
+			return
+		}
+		phones, err := db.GetLeadPhones(scope, leadID)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Falha ao buscar telefones: %v", err), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, phones)
+
+	case http.MethodPost:
+		var req struct {
+			ID     string `json:"id"`
+			Phones []struct {
+				Number string `json:"number"`
+				Source string `json:"source"`
+			} `json:"phones"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "JSON inválido", http.StatusBadRequest)
+			return
+		}
+		leadID, err := uuid.Parse(req.ID)
+		if err != nil {
+			http.Error(w, "ID inválido", http.StatusBadRequest)
+			return
+		}
+		lead, err := db.GetLeadByID(scope, leadID)
+		if err != nil || lead == nil {
+			http.Error(w, "Lead não encontrado", http.StatusNotFound)
+			return
+		}
+
+		var phones []db.LeadPhone
+		rejected := []string{}
+		for _, p := range req.Phones {
+			n, err := phone.Parse(p.Number)
+			if err != nil {
+				rejected = append(rejected, p.Number)
+				continue
+			}
+			source := strings.TrimSpace(p.Source)
+			if source == "" {
+				source = db.PhoneSourceEnrichment
+			}
+			phones = append(phones, leadPhoneFrom(n, source))
+		}
+
+		added, err := db.AddLeadPhones(scope, leadID, phones)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		changed := false
+		for _, p := range phones {
+			if lead.Phone == "" {
+				lead.Phone, changed = p.Number, true
+			}
+			if lead.Whatsapp == "" && p.Kind == string(phone.KindMobile) {
+				lead.Whatsapp, changed = p.Number, true
+			}
+		}
+		if changed {
+			if err := db.SaveLead(scope, lead); err != nil {
+				http.Error(w, fmt.Sprintf("Falha ao atualizar o lead: %v", err), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		log.Printf("LeadPhonesHandler: lead %s recebeu %d telefones novos (%d recusados)", leadID, added, len(rejected))
+		writeJSON(w, http.StatusOK, map[string]interface{}{"added": added, "rejected": rejected})
+
+	default:
+		http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+	}
+}
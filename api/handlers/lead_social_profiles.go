@@ -0,0 +1,122 @@
+// api/handlers/lead_social_profiles.go
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/wbrunovieira/LeadSearchVersion2/db"
+	"github.com/wbrunovieira/LeadSearchVersion2/shared/social"
+)
+
+// socialColumn devolve a coluna do lead que guarda o perfil da rede, se houver.
+func socialColumn(lead *db.Lead, network social.Network) *string {
+	switch network {
+	case social.Instagram:
+		return &lead.Instagram
+	case social.Facebook:
+		return &lead.Facebook
+	case social.TikTok:
+		return &lead.TikTok
+	}
+	return nil
+}
+
+// LeadSocialProfilesHandler lista (GET ?id=X) e acrescenta (POST) perfis de
+// redes sociais de um lead. No POST, cada link é validado e canonicalizado;
+// os que não são perfis voltam em "rejected". Instagram, Facebook e TikTok
+// preenchem as colunas do lead quando elas estão vazias ou não têm um perfil
+// válido.
+func LeadSocialProfilesHandler(w http.ResponseWriter, r *http.Request) {
+	scope := db.ScopeFromContext(r.Context())
+
+	switch r.Method {
+	case http.MethodGet:
+		leadID, err := uuid.Parse(r.URL.Query().Get("id"))
+		if err != nil {
+			http.Error(w, "ID inválido", http.StatusBadRequest)
+			return
+		}
+		profiles, err := db.GetLeadSocialProfiles(scope, leadID)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Falha ao buscar perfis sociais: %v", err), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, profiles)
+
+	case http.MethodPost:
+		var req struct {
+			ID       string `json:"id"`
+			Profiles []struct {
+				URL    string `json:"url"`
+				Source string `json:"source"`
+			} `json:"profiles"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "JSON inválido", http.StatusBadRequest)
+			return
+		}
+		leadID, err := uuid.Parse(req.ID)
+		if err != nil {
+			http.Error(w, "ID inválido", http.StatusBadRequest)
+			return
+		}
+		lead, err := db.GetLeadByID(scope, leadID)
+		if err != nil || lead == nil {
+			http.Error(w, "Lead não encontrado", http.StatusNotFound)
+			return
+		}
+
+		var profiles []db.LeadSocialProfile
+		rejected := []string{}
+		changed := false
+		for _, p := range req.Profiles {
+			profile, err := social.Parse(p.URL)
+			if err != nil {
+				if !errors.Is(err, social.ErrEmpty) {
+					rejected = append(rejected, p.URL)
+				}
+				continue
+			}
+			source := strings.TrimSpace(p.Source)
+			if source == "" {
+				source = db.SocialSourceEnrichment
+			}
+			profiles = append(profiles, db.LeadSocialProfile{
+				Network: string(profile.Network),
+				Handle:  profile.Handle,
+				URL:     profile.URL,
+				Source:  source,
+			})
+			if column := socialColumn(lead, profile.Network); column != nil {
+				if _, err := social.Parse(*column); err != nil {
+					*column, changed = profile.URL, true
+				}
+			}
+		}
+
+		added, err := db.AddLeadSocialProfiles(scope, leadID, profiles)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if changed {
+			if err := db.SaveLead(scope, lead); err != nil {
+				http.Error(w, fmt.Sprintf("Falha ao atualizar o lead: %v", err), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		log.Printf("LeadSocialProfilesHandler: lead %s recebeu %d perfis novos (%d recusados)", leadID, added, len(rejected))
+		writeJSON(w, http.StatusOK, map[string]interface{}{"added": added, "rejected": rejected})
+
+	default:
+		http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,192 @@
+// api/handlers/pipeline.go
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/wbrunovieira/LeadSearchVersion2/db"
+	"github.com/wbrunovieira/LeadSearchVersion2/shared/auth"
+)
+
+// LeadStageHandler recebe {"id", "stage", "note"} e move o lead para a etapa
+// do funil, registrando a mudança na linha do tempo.
+func LeadStageHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método não permitido. Use POST.", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		ID    string `json:"id"`
+		Stage string `json:"stage"`
+		Note  string `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "JSON inválido", http.StatusBadRequest)
+		return
+	}
+	leadID, err := uuid.Parse(req.ID)
+	if err != nil {
+		http.Error(w, "ID inválido", http.StatusBadRequest)
+		return
+	}
+
+	principal, _ := auth.FromContext(r.Context())
+	stage := strings.ToLower(strings.TrimSpace(req.Stage))
+	lead, err := db.MoveLeadStage(db.ScopeFromContext(r.Context()), leadID, stage, principal.Name, req.Note)
+	if errors.Is(err, db.ErrInvalidStage) {
+		http.Error(w, fmt.Sprintf("%v; use %s", err, strings.Join(db.PipelineStages, ", ")), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if lead == nil {
+		http.Error(w, "Lead não encontrado", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, lead)
+}
+
+// LeadAssignHandler recebe {"id", "assigned_to"} e troca o responsável pelo
+// lead; assigned_to vazio tira o responsável.
+func LeadAssignHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método não permitido. Use POST.", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		ID         string `json:"id"`
+		AssignedTo string `json:"assigned_to"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "JSON inválido", http.StatusBadRequest)
+		return
+	}
+	leadID, err := uuid.Parse(req.ID)
+	if err != nil {
+		http.Error(w, "ID inválido", http.StatusBadRequest)
+		return
+	}
+
+	principal, _ := auth.FromContext(r.Context())
+	lead, err := db.AssignLead(db.ScopeFromContext(r.Context()), leadID, strings.TrimSpace(req.AssignedTo), principal.Name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if lead == nil {
+		http.Error(w, "Lead não encontrado", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, lead)
+}
+
+// LeadActivitiesHandler mostra (GET ?id=X) a linha do tempo do lead ou
+// registra (POST) uma ligação, e-mail, reunião ou nota:
+// {"id", "type", "body", "occurred_at"}, com occurred_at em RFC 3339
+// (padrão: agora).
+func LeadActivitiesHandler(w http.ResponseWriter, r *http.Request) {
+	scope := db.ScopeFromContext(r.Context())
+
+	switch r.Method {
+	case http.MethodGet:
+		leadID, err := uuid.Parse(r.URL.Query().Get("id"))
+		if err != nil {
+			http.Error(w, "ID inválido", http.StatusBadRequest)
+			return
+		}
+		activities, err := db.GetLeadActivities(scope, leadID)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Falha ao buscar atividades: %v", err), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, activities)
+
+	case http.MethodPost:
+		var req struct {
+			ID         string `json:"id"`
+			Type       string `json:"type"`
+			Body       string `json:"body"`
+			OccurredAt string `json:"occurred_at"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "JSON inválido", http.StatusBadRequest)
+			return
+		}
+		leadID, err := uuid.Parse(req.ID)
+		if err != nil {
+			http.Error(w, "ID inválido", http.StatusBadRequest)
+			return
+		}
+		activityType := strings.ToLower(strings.TrimSpace(req.Type))
+		valid := false
+		for _, t := range db.ManualActivities {
+			valid = valid || t == activityType
+		}
+		if !valid {
+			http.Error(w, "Tipo de atividade inválido; use "+strings.Join(db.ManualActivities, ", "), http.StatusBadRequest)
+			return
+		}
+		activity := db.LeadActivity{LeadID: leadID, Type: activityType, Body: req.Body}
+		if req.OccurredAt != "" {
+			activity.OccurredAt, err = time.Parse(time.RFC3339, req.OccurredAt)
+			if err != nil {
+				http.Error(w, "occurred_at deve estar em RFC 3339 (2024-05-01T14:30:00-03:00)", http.StatusBadRequest)
+				return
+			}
+		}
+		if lead, err := db.GetLeadByID(scope, leadID); err != nil || lead == nil {
+			http.Error(w, "Lead não encontrado", http.StatusNotFound)
+			return
+		}
+
+		principal, _ := auth.FromContext(r.Context())
+		activity.Author = principal.Name
+		if err := db.AddLeadActivity(scope, &activity); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusCreated, activity)
+
+	default:
+		http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+	}
+}
+
+// PipelineReportHandler mostra quantos leads há em cada etapa e a taxa de
+// conversão, agrupados por ?group_by=category, city ou category,city
+// (padrão: category).
+func PipelineReportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método não permitido. Use GET.", http.StatusMethodNotAllowed)
+		return
+	}
+	groupBy := []string{"category"}
+	if v := r.URL.Query().Get("group_by"); v != "" {
+		groupBy = nil
+		for _, g := range strings.Split(v, ",") {
+			if g = strings.TrimSpace(g); g != "" {
+				groupBy = append(groupBy, strings.ToLower(g))
+			}
+		}
+	}
+
+	report, err := db.GetConversionReport(db.ScopeFromContext(r.Context()), groupBy)
+	if errors.Is(err, db.ErrInvalidFilter) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Falha ao gerar o relatório: %v", err), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
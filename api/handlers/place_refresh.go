@@ -0,0 +1,243 @@
+// api/handlers/place_refresh.go
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wbrunovieira/LeadSearchVersion2/db"
+	"github.com/wbrunovieira/LeadSearchVersion2/shared/places"
+)
+
+// KnownPlacesHandler recebe {"place_ids": [...], "max_age_days": N} e devolve
+// os PlaceIDs que já são leads com detalhes mais novos que N dias, para que o
+// search-google não pague o Place Details de novo. Com "any_age": true devolve
+// todos os que já são leads, independente da idade.
+func KnownPlacesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método não permitido. Use POST.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		PlaceIDs   []string `json:"place_ids"`
+		MaxAgeDays int      `json:"max_age_days"`
+		AnyAge     bool     `json:"any_age"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "JSON inválido", http.StatusBadRequest)
+		return
+	}
+	if req.MaxAgeDays <= 0 {
+		req.MaxAgeDays = 30
+	}
+
+	scope := db.ScopeFromContext(r.Context())
+	var fresh []string
+	var err error
+	if req.AnyAge {
+		fresh, err = db.GetKnownPlaceIDs(scope, req.PlaceIDs)
+	} else {
+		fresh, err = db.GetPlacesFetchedSince(scope, req.PlaceIDs, time.Now().AddDate(0, 0, -req.MaxAgeDays))
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Falha ao consultar places conhecidos: %v", err), http.StatusInternalServerError)
+		return
+	}
+	log.Printf("KnownPlacesHandler: %d de %d places já têm detalhes recentes", len(fresh), len(req.PlaceIDs))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string][]string{"fresh": fresh})
+}
+
+// StalePlacesHandler lista os PlaceIDs de leads com detalhes mais velhos que
+// older_than_days, usados pelo job de atualização do search-google.
+func StalePlacesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método não permitido. Use GET.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	olderThan := 30
+	if v := r.URL.Query().Get("older_than_days"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed < 0 {
+			http.Error(w, "older_than_days inválido", http.StatusBadRequest)
+			return
+		}
+		olderThan = parsed
+	}
+	limit := 50
+	if v := r.URL.Query().Get("limit"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed <= 0 {
+			http.Error(w, "limit inválido", http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+
+	ids, err := db.GetStalePlaceIDs(db.ScopeFromContext(r.Context()), time.Now().AddDate(0, 0, -olderThan), limit)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Falha ao buscar places desatualizados: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string][]string{"place_ids": ids})
+}
+
+// RefreshLeadsHandler aplica detalhes recém-buscados do Google a leads já
+// existentes (fechamentos, troca de telefone, variação de nota). O mesmo
+// estabelecimento é atualizado em todos os workspaces do escopo.
+func RefreshLeadsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método não permitido. Use POST.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var refreshed []places.Place
+	if err := json.NewDecoder(r.Body).Decode(&refreshed); err != nil {
+		http.Error(w, "JSON inválido", http.StatusBadRequest)
+		return
+	}
+
+	scope := db.ScopeFromContext(r.Context())
+	updated := 0
+	for _, place := range refreshed {
+		if err := place.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		leads, err := db.GetLeadsByGoogleId(scope, place.PlaceID)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Falha ao buscar leads do PlaceID %s: %v", place.PlaceID, err), http.StatusInternalServerError)
+			return
+		}
+		if len(leads) == 0 {
+			log.Printf("RefreshLeadsHandler: nenhum lead para o PlaceID %s", place.PlaceID)
+			continue
+		}
+
+		for i := range leads {
+			lead := &leads[i]
+			changes := applyPlaceRefresh(lead, place)
+			lead.DetailsFetchedAt = sql.NullTime{Time: time.Now(), Valid: true}
+			if err := db.SaveLead(scope, lead); err != nil {
+				http.Error(w, fmt.Sprintf("Falha ao atualizar o lead %s: %v", lead.ID, err), http.StatusInternalServerError)
+				return
+			}
+			if err := savePlaceExtras(scope, lead.ID, place); err != nil {
+				log.Printf("RefreshLeadsHandler: falha ao salvar avaliações/fotos do lead %s: %v", lead.ID, err)
+			}
+			if len(changes) > 0 {
+				log.Printf("RefreshLeadsHandler: lead %s mudou no Google: %s", lead.ID, strings.Join(changes, "; "))
+			}
+			updated++
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "%d leads atualizados", updated)
+}
+
+// RefreshFailuresHandler recebe {"place_ids": [...], "not_found": [...]} com os
+// places cujo Place Details falhou na atualização. Todos ganham a data da
+// tentativa, para o próximo lote de /stale-places seguir adiante; os que o
+// Google não acha mais (NOT_FOUND) são marcados como fechados.
+func RefreshFailuresHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método não permitido. Use POST.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		PlaceIDs []string `json:"place_ids"`
+		NotFound []string `json:"not_found"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "JSON inválido", http.StatusBadRequest)
+		return
+	}
+
+	scope := db.ScopeFromContext(r.Context())
+	closed := 0
+	for _, placeID := range req.NotFound {
+		leads, err := db.GetLeadsByGoogleId(scope, placeID)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Falha ao buscar leads do PlaceID %s: %v", placeID, err), http.StatusInternalServerError)
+			return
+		}
+		for i := range leads {
+			lead := &leads[i]
+			if lead.PermanentlyClosed {
+				continue
+			}
+			lead.PermanentlyClosed = true
+			if err := db.SaveLead(scope, lead); err != nil {
+				http.Error(w, fmt.Sprintf("Falha ao atualizar o lead %s: %v", lead.ID, err), http.StatusInternalServerError)
+				return
+			}
+			log.Printf("RefreshFailuresHandler: PlaceID %s não existe mais no Google; lead %s marcado como fechado", placeID, lead.ID)
+			closed++
+		}
+	}
+
+	marked, err := db.MarkDetailsAttempted(scope, append(req.PlaceIDs, req.NotFound...), time.Now())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Falha ao registrar a tentativa: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "%d leads adiados, %d marcados como fechados", marked, closed)
+}
+
+// applyPlaceRefresh copia para o lead os atributos vindos do Google e devolve
+// uma descrição das mudanças. Campos vazios na resposta não apagam dados.
+func applyPlaceRefresh(lead *db.Lead, place places.Place) []string {
+	var changes []string
+	setString := func(name string, target *string, value string) {
+		if value != "" && *target != value {
+			changes = append(changes, fmt.Sprintf("%s: %q -> %q", name, *target, value))
+			*target = value
+		}
+	}
+
+	setString("BusinessName", &lead.BusinessName, place.Name)
+	setString("Address", &lead.Address, place.FormattedAddress)
+	setString("Phone", &lead.Phone, normalizedPhone(place.InternationalPhoneNumber))
+	setString("BusinessStatus", &lead.BusinessStatus, place.BusinessStatus)
+	setString("GoogleMapsURL", &lead.GoogleMapsURL, place.GoogleMapsURL)
+	setString("OpeningHours", &lead.OpeningHours, strings.Join(place.OpeningHours, "\n"))
+
+	if place.Rating != 0 && place.Rating != lead.Rating {
+		changes = append(changes, fmt.Sprintf("Rating: %.1f -> %.1f", lead.Rating, place.Rating))
+		lead.Rating = place.Rating
+	}
+	if place.UserRatingsTotal != 0 && place.UserRatingsTotal != lead.UserRatingsTotal {
+		changes = append(changes, fmt.Sprintf("UserRatingsTotal: %d -> %d", lead.UserRatingsTotal, place.UserRatingsTotal))
+		lead.UserRatingsTotal = place.UserRatingsTotal
+	}
+	if place.PriceLevel != 0 {
+		lead.PriceLevel = place.PriceLevel
+	}
+	if place.BusinessStatus != "" {
+		closed := place.PermanentlyClosed || place.BusinessStatus == "CLOSED_PERMANENTLY"
+		if closed != lead.PermanentlyClosed {
+			changes = append(changes, fmt.Sprintf("PermanentlyClosed: %v -> %v", lead.PermanentlyClosed, closed))
+			lead.PermanentlyClosed = closed
+		}
+	}
+	if place.Latitude != 0 || place.Longitude != 0 {
+		lead.Latitude = place.Latitude
+		lead.Longitude = place.Longitude
+	}
+	return changes
+}
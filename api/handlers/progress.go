@@ -0,0 +1,107 @@
+// api/handlers/progress.go
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/wbrunovieira/LeadSearchVersion2/db"
+	"github.com/wbrunovieira/LeadSearchVersion2/progress"
+)
+
+// progressHeartbeat é o intervalo dos comentários que mantêm a conexão SSE
+// aberta em proxies que derrubam conexões ociosas.
+const progressHeartbeat = 15 * time.Second
+
+// SearchProgressHandler transmite por server-sent events o progresso do job
+// de busca ?job_id=: os eventos search.* do search-google e os marcos de
+// enriquecimento (lead.*) dos leads gravados pelo job. Cada evento sai com
+// id, e o navegador que reconectar com Last-Event-ID (ou ?last_event_id=)
+// recebe só o que perdeu. Como o EventSource não envia cabeçalhos, o token
+// vai em ?access_token=.
+func SearchProgressHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método não permitido. Use GET.", http.StatusMethodNotAllowed)
+		return
+	}
+	jobID := r.URL.Query().Get("job_id")
+	if jobID == "" {
+		http.Error(w, "job_id é obrigatório", http.StatusBadRequest)
+		return
+	}
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = r.URL.Query().Get("last_event_id")
+	}
+	var after uint64
+	if lastID != "" {
+		var err error
+		if after, err = strconv.ParseUint(lastID, 10, 64); err != nil {
+			http.Error(w, "Last-Event-ID inválido", http.StatusBadRequest)
+			return
+		}
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming não suportado", http.StatusInternalServerError)
+		return
+	}
+	scope := db.ScopeFromContext(r.Context())
+	workspaceID, scoped := scope.WorkspaceID()
+
+	backlog, events, cancel := progress.Default.Subscribe(jobID, after)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	// Reconecta em 3s se a conexão cair.
+	fmt.Fprint(w, "retry: 3000\n\n")
+
+	send := func(e progress.Event) error {
+		if scoped && e.WorkspaceID != workspaceID {
+			return nil
+		}
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Event, data)
+		return err
+	}
+	for _, e := range backlog {
+		if err := send(e); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(progressHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-events:
+			if !ok {
+				// O cliente ficou para trás; o EventSource reconecta com o
+				// último ID e recebe o restante do backlog.
+				return
+			}
+			if err := send(e); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
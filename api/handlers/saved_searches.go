@@ -0,0 +1,234 @@
+// api/handlers/saved_searches.go
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/wbrunovieira/LeadSearchVersion2/db"
+	"github.com/wbrunovieira/LeadSearchVersion2/schedule"
+)
+
+// SavedSearchesHandler gerencia as buscas agendadas:
+// GET lista, POST cria, PUT ?id=X atualiza e DELETE ?id=X remove.
+func SavedSearchesHandler(w http.ResponseWriter, r *http.Request) {
+	scope := db.ScopeFromContext(r.Context())
+	switch r.Method {
+	case http.MethodGet:
+		searches, err := db.GetSavedSearches(scope)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Falha ao buscar buscas agendadas: %v", err), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, searches)
+
+	case http.MethodPost:
+		var search db.SavedSearch
+		if err := json.NewDecoder(r.Body).Decode(&search); err != nil {
+			http.Error(w, "JSON inválido", http.StatusBadRequest)
+			return
+		}
+		search.ID = uuid.New()
+		if err := prepareSavedSearch(&search); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := db.CreateSavedSearch(scope, &search); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		log.Printf("Busca agendada criada: %s (%s)", search.Name, search.Schedule)
+		writeJSON(w, http.StatusCreated, search)
+
+	case http.MethodPut:
+		search, ok := loadSavedSearch(w, r)
+		if !ok {
+			return
+		}
+		id, workspaceID := search.ID, search.WorkspaceID
+		if err := json.NewDecoder(r.Body).Decode(search); err != nil {
+			http.Error(w, "JSON inválido", http.StatusBadRequest)
+			return
+		}
+		search.ID, search.WorkspaceID = id, workspaceID
+		if err := prepareSavedSearch(search); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := db.SaveSavedSearch(scope, search); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, search)
+
+	case http.MethodDelete:
+		search, ok := loadSavedSearch(w, r)
+		if !ok {
+			return
+		}
+		if err := db.DeleteSavedSearch(scope, search.ID); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("Busca agendada removida"))
+
+	default:
+		http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+	}
+}
+
+// ClaimDueSavedSearchesHandler é chamado pelo agendador do search-google.
+// Devolve as buscas que devem rodar agora e já agenda a próxima execução.
+func ClaimDueSavedSearchesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método não permitido. Use POST.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	now := time.Now()
+	searches, err := db.ClaimDueSavedSearches(db.ScopeFromContext(r.Context()), now, func(search *db.SavedSearch) sql.NullTime {
+		return nextRunAt(search.Schedule, now)
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, searches)
+}
+
+// SavedSearchRunHandler registra o resultado de uma execução.
+// Body: {"id": "...", "job_id": "...", "error": ""}.
+func SavedSearchRunHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método não permitido. Use POST.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		ID    string `json:"id"`
+		JobID string `json:"job_id"`
+		Error string `json:"error"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "JSON inválido", http.StatusBadRequest)
+		return
+	}
+	id, err := uuid.Parse(req.ID)
+	if err != nil {
+		http.Error(w, "ID inválido", http.StatusBadRequest)
+		return
+	}
+	scope := db.ScopeFromContext(r.Context())
+	search, err := db.GetSavedSearchByID(scope, id)
+	if err != nil || search == nil {
+		http.Error(w, "Busca agendada não encontrada", http.StatusNotFound)
+		return
+	}
+
+	search.LastRunAt = sql.NullTime{Time: time.Now(), Valid: true}
+	search.LastJobID = req.JobID
+	search.LastError = req.Error
+	if err := db.SaveSavedSearch(scope, search); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("Execução registrada"))
+}
+
+// SavedSearchNewLeadsHandler é o feed "empresas novas no meu território":
+// leads encontrados pela primeira vez pela busca ?id=X desde ?since=AAAA-MM-DD
+// (padrão: últimos 7 dias).
+func SavedSearchNewLeadsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método não permitido. Use GET.", http.StatusMethodNotAllowed)
+		return
+	}
+	search, ok := loadSavedSearch(w, r)
+	if !ok {
+		return
+	}
+
+	since := time.Now().AddDate(0, 0, -7)
+	if v := r.URL.Query().Get("since"); v != "" {
+		parsed, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			http.Error(w, "Formato de data inválido para since, use AAAA-MM-DD", http.StatusBadRequest)
+			return
+		}
+		since = parsed
+	}
+
+	leads, err := db.GetLeadsBySavedSearchSince(db.ScopeFromContext(r.Context()), search.ID, since)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Falha ao buscar leads: %v", err), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, leads)
+}
+
+func loadSavedSearch(w http.ResponseWriter, r *http.Request) (*db.SavedSearch, bool) {
+	id, err := uuid.Parse(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, "ID inválido", http.StatusBadRequest)
+		return nil, false
+	}
+	search, err := db.GetSavedSearchByID(db.ScopeFromContext(r.Context()), id)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Falha ao buscar busca agendada: %v", err), http.StatusInternalServerError)
+		return nil, false
+	}
+	if search == nil {
+		http.Error(w, "Busca agendada não encontrada", http.StatusNotFound)
+		return nil, false
+	}
+	return search, true
+}
+
+func prepareSavedSearch(search *db.SavedSearch) error {
+	if search.CategoryID == "" || search.ZipCode == "" {
+		return fmt.Errorf("category_id e zipcode são obrigatórios")
+	}
+	if search.Country == "" {
+		search.Country = "br"
+	}
+	if search.MaxResults <= 0 {
+		search.MaxResults = 20
+	}
+	search.Schedule = strings.TrimSpace(search.Schedule)
+	if _, err := schedule.Parse(search.Schedule); err != nil {
+		return err
+	}
+	if search.Enabled {
+		search.NextRunAt = nextRunAt(search.Schedule, time.Now())
+	} else {
+		search.NextRunAt = sql.NullTime{}
+	}
+	return nil
+}
+
+func nextRunAt(expr string, after time.Time) sql.NullTime {
+	s, err := schedule.Parse(expr)
+	if err != nil {
+		log.Printf("Expressão cron inválida %q: %v", expr, err)
+		return sql.NullTime{}
+	}
+	next := s.Next(after)
+	return sql.NullTime{Time: next, Valid: !next.IsZero()}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Erro ao converter resposta para JSON: %v", err)
+	}
+}
@@ -0,0 +1,248 @@
+// api/handlers/users.go
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/wbrunovieira/LeadSearchVersion2/db"
+	"github.com/wbrunovieira/LeadSearchVersion2/shared/auth"
+	"github.com/wbrunovieira/LeadSearchVersion2/shared/ratelimit"
+)
+
+// LoginLimits seguram tentativas de senha: cada requisição gasta uma ficha do
+// IP e uma do e-mail informado, no mesmo Limiter, como os limites do
+// search-google. TrustProxy identifica o IP pelo X-Forwarded-For.
+type LoginLimits struct {
+	Limiter    *ratelimit.Limiter
+	TrustProxy bool
+}
+
+// LoginHandler troca e-mail e senha (POST {email, password}) por um JWT com o
+// papel e os workspaces do usuário, válido por ttl. É o token que o front-web
+// manda para a API e o search-google; nada de credencial fica no bundle.
+func LoginHandler(a *auth.Authenticator, ttl time.Duration, limits LoginLimits) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Método não permitido. Use POST.", http.StatusMethodNotAllowed)
+			return
+		}
+		client := ratelimit.ClientKey(r, limits.TrustProxy)
+		if ok, wait := limits.Limiter.Allow("ip:" + client); !ok {
+			log.Printf("Login: %s acima do limite de tentativas", client)
+			loginTooManyRequests(w, wait)
+			return
+		}
+		var req struct {
+			Email    string `json:"email"`
+			Password string `json:"password"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "JSON inválido", http.StatusBadRequest)
+			return
+		}
+		email := db.NormalizeEmail(req.Email)
+		if ok, wait := limits.Limiter.Allow("user:" + email); !ok {
+			log.Printf("Login: %s acima do limite de tentativas (de %s)", email, client)
+			loginTooManyRequests(w, wait)
+			return
+		}
+
+		user, err := db.CheckLogin(req.Email, req.Password)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Falha ao verificar o login: %v", err), http.StatusInternalServerError)
+			return
+		}
+		if user == nil {
+			log.Printf("Login recusado para %s de %s", email, client)
+			http.Error(w, "E-mail ou senha inválidos", http.StatusUnauthorized)
+			return
+		}
+
+		token, expiresAt, err := a.IssueToken(user.Email, auth.Role(user.Role), ttl, user.Workspaces...)
+		if errors.Is(err, auth.ErrJWTDisabled) {
+			http.Error(w, "Login desabilitado: AUTH_JWT_SECRET não definido", http.StatusServiceUnavailable)
+			return
+		}
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Falha ao emitir o token: %v", err), http.StatusInternalServerError)
+			return
+		}
+		log.Printf("Login de %s (%s)", user.Email, user.Role)
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"token":      token,
+			"expires_at": expiresAt,
+			"user":       user,
+		})
+	}
+}
+
+func loginTooManyRequests(w http.ResponseWriter, wait time.Duration) {
+	seconds := ratelimit.SetRetryAfter(w, wait)
+	http.Error(w, fmt.Sprintf("Muitas tentativas de login. Tente de novo em %d segundos.", seconds), http.StatusTooManyRequests)
+}
+
+type userRequest struct {
+	Email      *string   `json:"email"`
+	Name       *string   `json:"name"`
+	Password   *string   `json:"password"`
+	Role       *string   `json:"role"`
+	Workspaces *[]string `json:"workspaces"`
+	Active     *bool     `json:"active"`
+}
+
+// UsersHandler gerencia os usuários do front-web: GET lista, POST cria
+// ({email, name, password, role, workspaces}), PUT ?id=X altera nome, senha,
+// papel, workspaces e active, e DELETE ?id=X apaga. Só admin sem restrição de
+// workspace gerencia usuários, como nos workspaces.
+func UsersHandler(w http.ResponseWriter, r *http.Request) {
+	principal, _ := auth.FromContext(r.Context())
+	if principal.Role != auth.RoleAdmin || len(principal.Workspaces) > 0 {
+		http.Error(w, "Só administradores sem restrição de workspace podem gerenciar usuários", http.StatusForbidden)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		users, err := db.GetUsers()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Falha ao buscar usuários: %v", err), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, users)
+
+	case http.MethodPost:
+		var req userRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "JSON inválido", http.StatusBadRequest)
+			return
+		}
+		if req.Email == nil || !strings.Contains(*req.Email, "@") {
+			http.Error(w, "E-mail inválido", http.StatusBadRequest)
+			return
+		}
+		if req.Password == nil || req.Role == nil {
+			http.Error(w, "password e role são obrigatórios", http.StatusBadRequest)
+			return
+		}
+		user := db.User{Email: *req.Email}
+		if err := applyUserRequest(&user, req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		err := db.CreateUser(&user)
+		if errors.Is(err, db.ErrUserExists) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		log.Printf("Usuário %s criado por %s", user.Email, principal.Name)
+		writeJSON(w, http.StatusCreated, user)
+
+	case http.MethodPut:
+		user, ok := loadUser(w, r)
+		if !ok {
+			return
+		}
+		var req userRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "JSON inválido", http.StatusBadRequest)
+			return
+		}
+		if req.Email != nil && db.NormalizeEmail(*req.Email) != user.Email {
+			http.Error(w, "O e-mail de um usuário não muda; crie outro usuário", http.StatusBadRequest)
+			return
+		}
+		if err := applyUserRequest(user, req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := db.SaveUser(user); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, user)
+
+	case http.MethodDelete:
+		user, ok := loadUser(w, r)
+		if !ok {
+			return
+		}
+		if err := db.DeleteUser(user); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+
+	default:
+		http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+	}
+}
+
+// applyUserRequest copia para o usuário os campos presentes na requisição.
+// Usuários não recebem o papel service, que é das API keys dos serviços.
+func applyUserRequest(user *db.User, req userRequest) error {
+	if req.Name != nil {
+		user.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Role != nil {
+		role, err := auth.ParseRole(*req.Role)
+		if err != nil {
+			return err
+		}
+		if role == auth.RoleService {
+			return fmt.Errorf("usuários não podem ter o papel %s", auth.RoleService)
+		}
+		user.Role = string(role)
+	}
+	if req.Workspaces != nil {
+		workspaces := make([]string, 0, len(*req.Workspaces))
+		for _, slug := range *req.Workspaces {
+			ws, err := db.GetWorkspace(slug)
+			if err != nil {
+				return fmt.Errorf("falha ao buscar o workspace %s: %v", slug, err)
+			}
+			if ws == nil {
+				return fmt.Errorf("workspace %q não encontrado", slug)
+			}
+			workspaces = append(workspaces, ws.Slug)
+		}
+		user.Workspaces = workspaces
+	}
+	if req.Active != nil {
+		user.Active = *req.Active
+	}
+	if req.Password != nil {
+		if err := user.SetPassword(*req.Password); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func loadUser(w http.ResponseWriter, r *http.Request) (*db.User, bool) {
+	id, err := uuid.Parse(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, "id inválido", http.StatusBadRequest)
+		return nil, false
+	}
+	user, err := db.GetUser(id)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Falha ao buscar o usuário: %v", err), http.StatusInternalServerError)
+		return nil, false
+	}
+	if user == nil {
+		http.Error(w, "Usuário não encontrado", http.StatusNotFound)
+		return nil, false
+	}
+	return user, true
+}
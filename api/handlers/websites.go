@@ -0,0 +1,123 @@
+// api/handlers/websites.go
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/wbrunovieira/LeadSearchVersion2/db"
+	"github.com/wbrunovieira/LeadSearchVersion2/shared/phone"
+	"github.com/wbrunovieira/LeadSearchVersion2/shared/social"
+	"github.com/wbrunovieira/LeadSearchVersion2/weburl"
+)
+
+// websiteLinks é o que um "site" vindo do Google representa de fato.
+type websiteLinks struct {
+	link     weburl.Link
+	profile  *social.Profile
+	whatsapp *phone.Number
+}
+
+func classifyWebsite(raw string) (websiteLinks, error) {
+	link, err := weburl.Classify(raw)
+	if err != nil {
+		return websiteLinks{}, err
+	}
+	links := websiteLinks{link: link, profile: link.Profile}
+	if link.Kind != weburl.KindMessaging {
+		return links, nil
+	}
+	switch link.Service {
+	case weburl.WhatsApp:
+		if n, err := phone.Parse(link.URL); err == nil {
+			links.whatsapp = &n
+		}
+	case weburl.Messenger:
+		// m.me/pagina é a página do Facebook.
+		if u, err := url.Parse(link.URL); err == nil {
+			if profile, err := social.Parse("https://www.facebook.com" + u.Path); err == nil {
+				links.profile = &profile
+			}
+		}
+	}
+	return links, nil
+}
+
+// applyWebsite distribui o site do Google nos campos do lead: sites próprios
+// ficam em Website na forma canônica, perfis sociais vão para a coluna da
+// rede, agregadores para LinkInBio e links de WhatsApp para Whatsapp. Colunas
+// já preenchidas não são sobrescritas.
+func applyWebsite(lead *db.Lead, raw string) {
+	lead.Website = ""
+	if strings.TrimSpace(raw) == "" {
+		return
+	}
+	links, err := classifyWebsite(raw)
+	if err != nil {
+		log.Printf("Site %q do lead %s ignorado: %v", raw, lead.BusinessName, err)
+		return
+	}
+
+	switch links.link.Kind {
+	case weburl.KindWebsite:
+		lead.Website = links.link.URL
+	case weburl.KindLinkInBio:
+		if lead.LinkInBio == "" {
+			lead.LinkInBio = links.link.URL
+		}
+	}
+	if links.profile != nil {
+		if column := socialColumn(lead, links.profile.Network); column != nil && *column == "" {
+			*column = links.profile.URL
+		}
+	}
+	if links.whatsapp != nil && lead.Whatsapp == "" {
+		lead.Whatsapp = links.whatsapp.E164
+	}
+}
+
+// saveWebsiteLinks registra em lead_social_profiles e lead_phones o perfil ou
+// WhatsApp que veio no lugar do site do Google.
+func saveWebsiteLinks(scope db.Scope, leadID uuid.UUID, raw string) error {
+	if strings.TrimSpace(raw) == "" {
+		return nil
+	}
+	links, err := classifyWebsite(raw)
+	if err != nil {
+		return nil
+	}
+	if p := links.profile; p != nil {
+		profile := db.LeadSocialProfile{Network: string(p.Network), Handle: p.Handle, URL: p.URL, Source: db.SocialSourceGoogle}
+		if _, err := db.AddLeadSocialProfiles(scope, leadID, []db.LeadSocialProfile{profile}); err != nil {
+			return err
+		}
+	}
+	if n := links.whatsapp; n != nil {
+		if _, err := db.AddLeadPhones(scope, leadID, []db.LeadPhone{leadPhoneFrom(*n, db.PhoneSourceGoogle)}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DomainDuplicatesHandler lista os domínios compartilhados por mais de um
+// lead do workspace (possíveis duplicatas). Aceita ?domain= para ver um só.
+func DomainDuplicatesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método não permitido. Use GET.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	domain := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("domain")))
+	duplicates, err := db.GetDomainDuplicates(db.ScopeFromContext(r.Context()), domain)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Falha ao buscar domínios duplicados: %v", err), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, duplicates)
+}
@@ -0,0 +1,62 @@
+// api/handlers/workspaces.go
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/wbrunovieira/LeadSearchVersion2/db"
+	"github.com/wbrunovieira/LeadSearchVersion2/shared/auth"
+)
+
+// WorkspacesHandler lista (GET) os workspaces visíveis para quem chama e cria
+// (POST) novos. Só admin sem restrição de workspace pode criar.
+func WorkspacesHandler(w http.ResponseWriter, r *http.Request) {
+	principal, _ := auth.FromContext(r.Context())
+
+	switch r.Method {
+	case http.MethodGet:
+		workspaces, err := db.GetWorkspaces()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Falha ao buscar workspaces: %v", err), http.StatusInternalServerError)
+			return
+		}
+		visible := make([]db.Workspace, 0, len(workspaces))
+		for _, ws := range workspaces {
+			if principal.CanAccessWorkspace(ws.Slug) {
+				visible = append(visible, ws)
+			}
+		}
+		writeJSON(w, http.StatusOK, visible)
+
+	case http.MethodPost:
+		if principal.Role != auth.RoleAdmin || len(principal.Workspaces) > 0 {
+			http.Error(w, "Só administradores sem restrição de workspace podem criar workspaces", http.StatusForbidden)
+			return
+		}
+		var ws db.Workspace
+		if err := json.NewDecoder(r.Body).Decode(&ws); err != nil {
+			http.Error(w, "JSON inválido", http.StatusBadRequest)
+			return
+		}
+		ws.Slug = strings.ToLower(strings.TrimSpace(ws.Slug))
+		if existing, err := db.GetWorkspace(ws.Slug); err == nil && existing != nil {
+			http.Error(w, fmt.Sprintf("Workspace %q já existe", ws.Slug), http.StatusConflict)
+			return
+		}
+		ws.ID = uuid.Nil
+		if err := db.CreateWorkspace(&ws); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("Workspace criado por %s: %s (%s)", principal.Name, ws.Slug, ws.Name)
+		writeJSON(w, http.StatusCreated, ws)
+
+	default:
+		http.Error(w, "Método não permitido", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,355 @@
+// /api/icp/icp.go
+package icp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/wbrunovieira/LeadSearchVersion2/textnorm"
+	"gopkg.in/yaml.v3"
+)
+
+// Campos do lead que as regras podem avaliar.
+const (
+	FieldRating           = "rating"
+	FieldUserRatingsTotal = "user_ratings_total"
+	FieldPriceLevel       = "price_level"
+	FieldBusinessStatus   = "business_status"
+	FieldCompanyAge       = "company_age"
+	FieldEquityCapital    = "equity_capital"
+	FieldCNAE             = "cnae"
+	FieldSecondaryCNAE    = "secondary_cnae"
+	FieldCity             = "city"
+)
+
+// Operadores das regras.
+const (
+	OpRange   = "range"   // número entre min e max, inclusive; um dos dois pode faltar
+	OpIn      = "in"      // valor igual a um de values
+	OpNotIn   = "not_in"  // valor conhecido e diferente de todos os values
+	OpPrefix  = "prefix"  // código CNAE começando por um de values
+	OpMissing = "missing" // o lead não tem o dado
+)
+
+// Formatos aceitos para o conjunto de regras.
+const (
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+)
+
+var ErrInvalid = errors.New("conjunto de regras inválido")
+
+type kind int
+
+const (
+	numeric kind = iota
+	text
+	cnae
+)
+
+var fields = map[string]kind{
+	FieldRating:           numeric,
+	FieldUserRatingsTotal: numeric,
+	FieldPriceLevel:       numeric,
+	FieldCompanyAge:       numeric,
+	FieldEquityCapital:    numeric,
+	FieldBusinessStatus:   text,
+	FieldCity:             text,
+	FieldCNAE:             cnae,
+	FieldSecondaryCNAE:    cnae,
+}
+
+var ops = map[kind][]string{
+	numeric: {OpRange, OpMissing},
+	text:    {OpIn, OpNotIn, OpMissing},
+	cnae:    {OpIn, OpNotIn, OpPrefix, OpMissing},
+}
+
+// Rule soma Points (que pode ser negativo) à pontuação do lead quando casa.
+type Rule struct {
+	ID          string   `json:"id" yaml:"id"`
+	Description string   `json:"description,omitempty" yaml:"description,omitempty"`
+	Field       string   `json:"field" yaml:"field"`
+	Op          string   `json:"op" yaml:"op"`
+	Min         *float64 `json:"min,omitempty" yaml:"min,omitempty"`
+	Max         *float64 `json:"max,omitempty" yaml:"max,omitempty"`
+	Values      []string `json:"values,omitempty" yaml:"values,omitempty"`
+	Points      float64  `json:"points" yaml:"points"`
+}
+
+// RuleSet é o perfil de cliente ideal (ICP) de um workspace.
+type RuleSet struct {
+	Name        string `json:"name" yaml:"name"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	Rules       []Rule `json:"rules" yaml:"rules"`
+}
+
+// Input são os dados do lead usados na pontuação. Zero em Rating, PriceLevel
+// e EquityCapital significa que o dado não existe; CompanyAge (em anos) só
+// vale com HasCompanyAge.
+type Input struct {
+	Rating              float64
+	UserRatingsTotal    int
+	PriceLevel          int
+	BusinessStatus      string
+	CompanyAge          float64
+	HasCompanyAge       bool
+	EquityCapital       float64
+	PrimaryActivity     string
+	SecondaryActivities string
+	City                string
+}
+
+// RuleResult é o resultado de uma regra para o lead.
+type RuleResult struct {
+	RuleID  string  `json:"rule_id"`
+	Field   string  `json:"field"`
+	Value   string  `json:"value"`
+	Matched bool    `json:"matched"`
+	Points  float64 `json:"points"`
+}
+
+// Result é a pontuação do lead e o que cada regra contribuiu.
+type Result struct {
+	Score     float64      `json:"score"`
+	Breakdown []RuleResult `json:"breakdown"`
+}
+
+// Parse lê o conjunto de regras em JSON ou YAML e o valida. Chaves
+// desconhecidas são recusadas para que um erro de digitação não passe como
+// regra vazia.
+func Parse(data []byte, format string) (*RuleSet, error) {
+	var set RuleSet
+	switch strings.ToLower(format) {
+	case FormatJSON, "":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&set); err != nil {
+			return nil, fmt.Errorf("%w: JSON: %v", ErrInvalid, err)
+		}
+	case FormatYAML, "yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(&set); err != nil && err != io.EOF {
+			return nil, fmt.Errorf("%w: YAML: %v", ErrInvalid, err)
+		}
+	default:
+		return nil, fmt.Errorf("%w: formato desconhecido %q, use json ou yaml", ErrInvalid, format)
+	}
+	if err := set.Validate(); err != nil {
+		return nil, err
+	}
+	return &set, nil
+}
+
+// Validate confere campos, operadores e valores de cada regra e normaliza os
+// nomes de campo e operador para minúsculas.
+func (s *RuleSet) Validate() error {
+	if len(s.Rules) == 0 {
+		return fmt.Errorf("%w: nenhuma regra", ErrInvalid)
+	}
+	seen := make(map[string]bool, len(s.Rules))
+	for i := range s.Rules {
+		r := &s.Rules[i]
+		r.Field = strings.ToLower(strings.TrimSpace(r.Field))
+		r.Op = strings.ToLower(strings.TrimSpace(r.Op))
+		if r.ID == "" {
+			return fmt.Errorf("%w: regra %d sem id", ErrInvalid, i+1)
+		}
+		if seen[r.ID] {
+			return fmt.Errorf("%w: id %q repetido", ErrInvalid, r.ID)
+		}
+		seen[r.ID] = true
+
+		k, ok := fields[r.Field]
+		if !ok {
+			return fmt.Errorf("%w: regra %s: campo desconhecido %q", ErrInvalid, r.ID, r.Field)
+		}
+		if !contains(ops[k], r.Op) {
+			return fmt.Errorf("%w: regra %s: operador %q não vale para %s (use %s)", ErrInvalid, r.ID, r.Op, r.Field, strings.Join(ops[k], ", "))
+		}
+		if math.IsNaN(r.Points) || math.IsInf(r.Points, 0) {
+			return fmt.Errorf("%w: regra %s: pontos inválidos", ErrInvalid, r.ID)
+		}
+
+		switch r.Op {
+		case OpRange:
+			if r.Min == nil && r.Max == nil {
+				return fmt.Errorf("%w: regra %s: range precisa de min ou max", ErrInvalid, r.ID)
+			}
+			if r.Min != nil && r.Max != nil && *r.Min > *r.Max {
+				return fmt.Errorf("%w: regra %s: min maior que max", ErrInvalid, r.ID)
+			}
+		case OpIn, OpNotIn, OpPrefix:
+			if len(r.Values) == 0 {
+				return fmt.Errorf("%w: regra %s: %s precisa de values", ErrInvalid, r.ID, r.Op)
+			}
+			if k == cnae {
+				for _, v := range r.Values {
+					if digits(v) == "" {
+						return fmt.Errorf("%w: regra %s: código CNAE inválido %q", ErrInvalid, r.ID, v)
+					}
+				}
+			}
+		}
+		if r.Op != OpRange && (r.Min != nil || r.Max != nil) {
+			return fmt.Errorf("%w: regra %s: min e max só valem com range", ErrInvalid, r.ID)
+		}
+		if (r.Op == OpRange || r.Op == OpMissing) && len(r.Values) > 0 {
+			return fmt.Errorf("%w: regra %s: values não vale com %s", ErrInvalid, r.ID, r.Op)
+		}
+	}
+	return nil
+}
+
+// Marshal escreve o conjunto de regras no formato pedido.
+func (s *RuleSet) Marshal(format string) ([]byte, error) {
+	switch strings.ToLower(format) {
+	case FormatYAML, "yml":
+		return yaml.Marshal(s)
+	case FormatJSON, "":
+		return json.MarshalIndent(s, "", "  ")
+	}
+	return nil, fmt.Errorf("formato desconhecido %q, use json ou yaml", format)
+}
+
+// Evaluate aplica todas as regras ao lead. Regras sobre um dado que o lead
+// não tem só casam com o operador missing.
+func (s *RuleSet) Evaluate(in Input) Result {
+	result := Result{Breakdown: make([]RuleResult, 0, len(s.Rules))}
+	for _, r := range s.Rules {
+		rr := RuleResult{RuleID: r.ID, Field: r.Field}
+		known := false
+		switch fields[r.Field] {
+		case numeric:
+			var n float64
+			n, known = in.number(r.Field)
+			if known {
+				rr.Value = strconv.FormatFloat(n, 'f', -1, 64)
+			}
+			if r.Op == OpRange && known {
+				rr.Matched = (r.Min == nil || n >= *r.Min) && (r.Max == nil || n <= *r.Max)
+			}
+		case text:
+			v := in.text(r.Field)
+			known = v != ""
+			rr.Value = v
+			if known && r.Op != OpMissing {
+				found := false
+				for _, want := range r.Values {
+					if textnorm.Fold(want) == textnorm.Fold(v) {
+						found = true
+						break
+					}
+				}
+				rr.Matched = found == (r.Op == OpIn)
+			}
+		case cnae:
+			codes := in.cnaes(r.Field)
+			known = len(codes) > 0
+			rr.Value = strings.Join(codes, ",")
+			if known && r.Op != OpMissing {
+				rr.Matched = matchCNAE(codes, r.Values, r.Op)
+			}
+		}
+		if r.Op == OpMissing {
+			rr.Matched = !known
+		}
+		if rr.Matched {
+			rr.Points = r.Points
+			result.Score += r.Points
+		}
+		result.Breakdown = append(result.Breakdown, rr)
+	}
+	result.Score = math.Round(result.Score*100) / 100
+	return result
+}
+
+func (in Input) number(field string) (float64, bool) {
+	switch field {
+	case FieldRating:
+		return in.Rating, in.Rating > 0
+	case FieldUserRatingsTotal:
+		return float64(in.UserRatingsTotal), true
+	case FieldPriceLevel:
+		return float64(in.PriceLevel), in.PriceLevel > 0
+	case FieldCompanyAge:
+		return math.Round(in.CompanyAge*10) / 10, in.HasCompanyAge
+	case FieldEquityCapital:
+		return in.EquityCapital, in.EquityCapital > 0
+	}
+	return 0, false
+}
+
+func (in Input) text(field string) string {
+	switch field {
+	case FieldBusinessStatus:
+		return strings.TrimSpace(in.BusinessStatus)
+	case FieldCity:
+		return strings.TrimSpace(in.City)
+	}
+	return ""
+}
+
+func (in Input) cnaes(field string) []string {
+	switch field {
+	case FieldCNAE:
+		if codes := CNAECodes(in.PrimaryActivity); len(codes) > 0 {
+			return codes[:1]
+		}
+	case FieldSecondaryCNAE:
+		return CNAECodes(in.SecondaryActivities)
+	}
+	return nil
+}
+
+func matchCNAE(codes, values []string, op string) bool {
+	for _, code := range codes {
+		for _, v := range values {
+			want := digits(v)
+			if code == want || (op == OpPrefix && strings.HasPrefix(code, want)) {
+				return op != OpNotIn
+			}
+		}
+	}
+	return op == OpNotIn
+}
+
+var cnaePattern = regexp.MustCompile(`\b(\d{2})\.?(\d{2})-?(\d)(?:\s*[/-]?\s*(\d{2}))?\b`)
+
+// CNAECodes extrai os códigos CNAE de um texto ("56.11-2-01 - Restaurantes",
+// "5611-2/01", "5611201"), só com os dígitos: 5 para a classe, 7 para a
+// subclasse.
+func CNAECodes(s string) []string {
+	var codes []string
+	for _, m := range cnaePattern.FindAllStringSubmatch(s, -1) {
+		codes = append(codes, m[1]+m[2]+m[3]+m[4])
+	}
+	return codes
+}
+
+func digits(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
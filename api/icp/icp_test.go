@@ -0,0 +1,153 @@
+package icp
+
+import (
+	"errors"
+	"testing"
+)
+
+const sampleYAML = `
+name: Restaurantes consolidados
+rules:
+  - id: bem-avaliado
+    field: rating
+    op: range
+    min: 4.3
+    points: 20
+  - id: muitas-avaliacoes
+    field: user_ratings_total
+    op: range
+    min: 200
+    points: 15
+  - id: empresa-madura
+    field: company_age
+    op: range
+    min: 5
+    points: 10
+  - id: sem-fundacao
+    field: company_age
+    op: missing
+    points: -5
+  - id: alimentacao
+    field: cnae
+    op: prefix
+    values: ["56"]
+    points: 25
+  - id: fechado
+    field: business_status
+    op: in
+    values: [CLOSED_PERMANENTLY, CLOSED_TEMPORARILY]
+    points: -100
+  - id: capital
+    field: city
+    op: in
+    values: [São Paulo]
+    points: 5
+`
+
+func TestParseAndEvaluate(t *testing.T) {
+	set, err := Parse([]byte(sampleYAML), FormatYAML)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := set.Evaluate(Input{
+		Rating:           4.6,
+		UserRatingsTotal: 350,
+		BusinessStatus:   "OPERATIONAL",
+		PrimaryActivity:  "56.11-2-01 - Restaurantes e similares",
+		City:             "sao paulo",
+	})
+	// 20 + 15 - 5 + 25 + 5
+	if result.Score != 60 {
+		t.Errorf("Score = %v, breakdown %+v", result.Score, result.Breakdown)
+	}
+	if len(result.Breakdown) != len(set.Rules) {
+		t.Fatalf("breakdown has %d entries", len(result.Breakdown))
+	}
+	age := result.Breakdown[2]
+	if age.RuleID != "empresa-madura" || age.Matched || age.Value != "" {
+		t.Errorf("unknown company age should not match a range: %+v", age)
+	}
+	if cnae := result.Breakdown[4]; !cnae.Matched || cnae.Value != "5611201" || cnae.Points != 25 {
+		t.Errorf("cnae rule = %+v", cnae)
+	}
+
+	closed := set.Evaluate(Input{BusinessStatus: "closed_permanently", CompanyAge: 12.34, HasCompanyAge: true})
+	// 10 - 100
+	if closed.Score != -90 {
+		t.Errorf("closed Score = %v, breakdown %+v", closed.Score, closed.Breakdown)
+	}
+	if v := closed.Breakdown[2].Value; v != "12.3" {
+		t.Errorf("company age value = %q", v)
+	}
+}
+
+func TestParseJSONRoundTrip(t *testing.T) {
+	set, err := Parse([]byte(sampleYAML), FormatYAML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := set.Marshal(FormatJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := Parse(data, FormatJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(again.Rules) != len(set.Rules) || *again.Rules[0].Min != 4.3 {
+		t.Errorf("round trip = %+v", again)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	cases := map[string]string{
+		"no rules":        `{"name": "x", "rules": []}`,
+		"unknown key":     `{"rules": [{"id": "a", "field": "rating", "op": "range", "minimum": 4, "points": 1}]}`,
+		"unknown field":   `{"rules": [{"id": "a", "field": "revenue", "op": "range", "min": 4, "points": 1}]}`,
+		"wrong op":        `{"rules": [{"id": "a", "field": "rating", "op": "in", "values": ["4"], "points": 1}]}`,
+		"empty range":     `{"rules": [{"id": "a", "field": "rating", "op": "range", "points": 1}]}`,
+		"inverted range":  `{"rules": [{"id": "a", "field": "rating", "op": "range", "min": 5, "max": 4, "points": 1}]}`,
+		"missing values":  `{"rules": [{"id": "a", "field": "city", "op": "in", "points": 1}]}`,
+		"bad cnae":        `{"rules": [{"id": "a", "field": "cnae", "op": "prefix", "values": ["food"], "points": 1}]}`,
+		"duplicate id":    `{"rules": [{"id": "a", "field": "city", "op": "missing", "points": 1}, {"id": "a", "field": "cnae", "op": "missing", "points": 1}]}`,
+		"values on range": `{"rules": [{"id": "a", "field": "rating", "op": "range", "min": 4, "values": ["x"], "points": 1}]}`,
+	}
+	for name, doc := range cases {
+		if _, err := Parse([]byte(doc), FormatJSON); !errors.Is(err, ErrInvalid) {
+			t.Errorf("%s: err = %v", name, err)
+		}
+	}
+	if _, err := Parse([]byte(sampleYAML), "toml"); !errors.Is(err, ErrInvalid) {
+		t.Errorf("unknown format: err = %v", err)
+	}
+}
+
+func TestCNAECodes(t *testing.T) {
+	got := CNAECodes("47.21-1-02 Padaria; 5611-2/01 Restaurantes, 10911")
+	want := []string{"4721102", "5611201", "10911"}
+	if len(got) != len(want) {
+		t.Fatalf("CNAECodes = %v", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("CNAECodes[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestNotIn(t *testing.T) {
+	set := &RuleSet{Rules: []Rule{{ID: "fora-varejo", Field: FieldSecondaryCNAE, Op: OpNotIn, Values: []string{"4721-1/02"}, Points: 3}}}
+	if err := set.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if s := set.Evaluate(Input{SecondaryActivities: "4721-1/02, 5611-2/01"}).Score; s != 0 {
+		t.Errorf("listed code matched not_in: %v", s)
+	}
+	if s := set.Evaluate(Input{SecondaryActivities: "5611-2/01"}).Score; s != 3 {
+		t.Errorf("other code = %v", s)
+	}
+	if s := set.Evaluate(Input{}).Score; s != 0 {
+		t.Errorf("unknown value matched not_in: %v", s)
+	}
+}
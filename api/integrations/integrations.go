@@ -0,0 +1,307 @@
+// /api/integrations/integrations.go
+package integrations
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/wbrunovieira/LeadSearchVersion2/db"
+	"github.com/wbrunovieira/LeadSearchVersion2/export"
+	"github.com/wbrunovieira/LeadSearchVersion2/leadfields"
+)
+
+// ErrInvalid indica uma integração mal configurada.
+var ErrInvalid = errors.New("integração inválida")
+
+// Cabeçalhos enviados em toda entrega. A assinatura é
+// "sha256=" + hex(HMAC-SHA256(secret, timestamp + "." + corpo)).
+const (
+	SignatureHeader = "X-LeadSearch-Signature"
+	TimestampHeader = "X-LeadSearch-Timestamp"
+	EventHeader     = "X-LeadSearch-Event"
+	DeliveryHeader  = "X-LeadSearch-Delivery"
+)
+
+// EventTest é o evento enviado por /integrations/test.
+const EventTest = "integration.test"
+
+// DefaultMapping é o corpo enviado quando a integração não tem mapeamento,
+// seguido dos campos personalizados em custom.<chave>.
+var DefaultMapping = map[string]string{
+	"id":           "ID",
+	"name":         "BusinessName",
+	"legal_name":   "RegisteredName",
+	"cnpj":         "CompanyRegistrationID",
+	"phone":        "Phone",
+	"whatsapp":     "Whatsapp",
+	"email":        "Email",
+	"website":      "Website",
+	"address":      "Address",
+	"city":         "City",
+	"state":        "State",
+	"zip_code":     "ZIPCode",
+	"category":     "Category",
+	"quality":      "Quality",
+	"completeness": "CompletenessScore",
+	"icp_score":    "ICPScore",
+	"stage":        "Stage",
+	"assigned_to":  "AssignedTo",
+	"tags":         "Tags",
+}
+
+// Event é o envelope enviado aos webhooks. Integrações rest recebem só Data.
+// Details traz os dados do marco do enriquecimento (fontes consultadas, se o
+// CNPJ foi encontrado...).
+type Event struct {
+	ID          uuid.UUID              `json:"id"`
+	Event       string                 `json:"event"`
+	OccurredAt  time.Time              `json:"occurred_at"`
+	WorkspaceID uuid.UUID              `json:"workspace_id"`
+	LeadID      uuid.UUID              `json:"lead_id"`
+	Data        map[string]interface{} `json:"data"`
+	Details     map[string]interface{} `json:"details,omitempty"`
+}
+
+// Events são todos os eventos que uma integração pode assinar.
+var Events = append(append([]string(nil), db.LeadEvents...), db.MilestoneEvents...)
+
+// Validate confere e normaliza a configuração antes de gravar: tipo, URL,
+// eventos (padrão: criação e alteração do lead), filtros e um mapeamento
+// cujos campos existam no lead ou entre os campos personalizados do
+// workspace.
+func Validate(i *db.Integration, custom []db.CustomFieldDefinition) error {
+	i.Name = strings.TrimSpace(i.Name)
+	if i.Name == "" {
+		return fmt.Errorf("%w: name é obrigatório", ErrInvalid)
+	}
+	i.Kind = strings.ToLower(strings.TrimSpace(i.Kind))
+	if i.Kind == "" {
+		i.Kind = db.IntegrationWebhook
+	}
+	if !contains(db.IntegrationKinds, i.Kind) {
+		return fmt.Errorf("%w: kind deve ser %s", ErrInvalid, strings.Join(db.IntegrationKinds, " ou "))
+	}
+	if err := validURL(i.URL); err != nil {
+		return err
+	}
+	if len(i.Events) == 0 {
+		i.Events = append(i.Events, db.LeadEvents...)
+	}
+	for _, e := range i.Events {
+		if !contains(Events, e) {
+			return fmt.Errorf("%w: evento %q desconhecido; use %s", ErrInvalid, e, strings.Join(Events, ", "))
+		}
+	}
+	if err := validateFilters(i.Filters, custom); err != nil {
+		return err
+	}
+	if err := validateMapping(i.Mapping, custom); err != nil {
+		return err
+	}
+	if i.Kind == db.IntegrationREST {
+		i.UpdateMethod = strings.ToUpper(strings.TrimSpace(i.UpdateMethod))
+		if i.UpdateMethod == "" {
+			i.UpdateMethod = "PATCH"
+		}
+		if i.UpdateMethod != "PATCH" && i.UpdateMethod != "PUT" && i.UpdateMethod != "POST" {
+			return fmt.Errorf("%w: update_method deve ser PATCH, PUT ou POST", ErrInvalid)
+		}
+		if i.UpdateURL != "" {
+			if !strings.Contains(i.UpdateURL, "{id}") {
+				return fmt.Errorf("%w: update_url precisa conter {id}", ErrInvalid)
+			}
+			if err := validURL(strings.ReplaceAll(i.UpdateURL, "{id}", "x")); err != nil {
+				return err
+			}
+		}
+		if i.IDField == "" {
+			i.IDField = "id"
+		}
+	}
+	if i.Secret == "" {
+		i.Secret = NewSecret()
+	}
+	return nil
+}
+
+func validURL(raw string) error {
+	if !strings.HasPrefix(raw, "http://") && !strings.HasPrefix(raw, "https://") {
+		return fmt.Errorf("%w: url deve começar com http:// ou https://", ErrInvalid)
+	}
+	return nil
+}
+
+// validateFilters confere os filtros e troca o nome de cada campo do lead
+// pelo nome exato do campo, como o filtro é avaliado.
+func validateFilters(filters []db.IntegrationFilter, custom []db.CustomFieldDefinition) error {
+	for i := range filters {
+		f := &filters[i]
+		f.Op = strings.ToLower(strings.TrimSpace(f.Op))
+		switch f.Op {
+		case db.FilterPresent, db.FilterMissing:
+			f.Values = nil
+		case db.FilterIn, db.FilterNotIn:
+			if len(f.Values) == 0 {
+				return fmt.Errorf("%w: o filtro de %s precisa de values", ErrInvalid, f.Field)
+			}
+		default:
+			return fmt.Errorf("%w: operador de filtro %q desconhecido; use present, missing, in ou not_in", ErrInvalid, f.Op)
+		}
+		if key := strings.TrimPrefix(f.Field, db.DetailsPrefix); key != f.Field {
+			if strings.TrimSpace(key) == "" {
+				return fmt.Errorf("%w: filtro em details sem chave", ErrInvalid)
+			}
+			continue
+		}
+		columns, err := export.Columns([]string{f.Field}, custom)
+		if err != nil {
+			return fmt.Errorf("%w: filtro: %v", ErrInvalid, err)
+		}
+		f.Field = columns[0].Name
+	}
+	return nil
+}
+
+func validateMapping(mapping map[string]string, custom []db.CustomFieldDefinition) error {
+	targets := make([]string, 0, len(mapping))
+	for target, source := range mapping {
+		if strings.TrimSpace(target) == "" || strings.HasPrefix(target, ".") || strings.HasSuffix(target, ".") || strings.Contains(target, "..") {
+			return fmt.Errorf("%w: campo de destino %q inválido", ErrInvalid, target)
+		}
+		if _, err := export.Columns([]string{source}, custom); err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrInvalid, target, err)
+		}
+		targets = append(targets, target)
+	}
+	// "a" e "a.b" não podem conviver: "a" seria ao mesmo tempo valor e objeto.
+	sort.Strings(targets)
+	for i := 1; i < len(targets); i++ {
+		if strings.HasPrefix(targets[i], targets[i-1]+".") {
+			return fmt.Errorf("%w: os destinos %q e %q se sobrepõem", ErrInvalid, targets[i-1], targets[i])
+		}
+	}
+	return nil
+}
+
+// Build monta o corpo do lead conforme o mapeamento (ou DefaultMapping). Um
+// campo de origem que deixou de existir, como um campo personalizado
+// apagado, é omitido.
+func Build(lead *db.Lead, mapping map[string]string, custom []db.CustomFieldDefinition) map[string]interface{} {
+	if len(mapping) == 0 {
+		mapping = make(map[string]string, len(DefaultMapping)+len(custom))
+		for target, source := range DefaultMapping {
+			mapping[target] = source
+		}
+		for _, d := range custom {
+			mapping[leadfields.CustomPrefix+d.Key] = leadfields.CustomPrefix + d.Key
+		}
+	}
+
+	data := make(map[string]interface{})
+	for target, source := range mapping {
+		columns, err := export.Columns([]string{source}, custom)
+		if err != nil {
+			continue
+		}
+		set(data, strings.Split(target, "."), jsonValue(columns[0].Value(lead)))
+	}
+	return data
+}
+
+func set(data map[string]interface{}, path []string, value interface{}) {
+	for _, key := range path[:len(path)-1] {
+		next, ok := data[key].(map[string]interface{})
+		if !ok {
+			next = make(map[string]interface{})
+			data[key] = next
+		}
+		data = next
+	}
+	data[path[len(path)-1]] = value
+}
+
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case export.Date:
+		if time.Time(v).IsZero() {
+			return nil
+		}
+		return time.Time(v).Format("2006-01-02")
+	case time.Time:
+		if v.IsZero() {
+			return nil
+		}
+		return v.Format(time.RFC3339)
+	case []string:
+		if v == nil {
+			return []string{}
+		}
+	}
+	return v
+}
+
+// NewSecret gera um segredo aleatório para assinar as entregas.
+func NewSecret() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("falha ao gerar segredo: %v", err))
+	}
+	return hex.EncodeToString(b)
+}
+
+// Sign devolve o valor do cabeçalho de assinatura do corpo.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify confere a assinatura de uma entrega recebida e recusa timestamps
+// mais distantes de now que tolerance, para evitar reenvio de entregas
+// antigas. É o que um receptor deve fazer com os cabeçalhos.
+func Verify(secret, signature, timestamp string, body []byte, now time.Time, tolerance time.Duration) error {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("timestamp inválido: %q", timestamp)
+	}
+	if d := now.Sub(time.Unix(ts, 0)); d > tolerance || d < -tolerance {
+		return fmt.Errorf("timestamp fora da tolerância de %s", tolerance)
+	}
+	if !hmac.Equal([]byte(Sign(secret, ts, body)), []byte(signature)) {
+		return errors.New("assinatura não confere")
+	}
+	return nil
+}
+
+// Backoff é a espera antes da tentativa seguinte a attempt falhas: 1 minuto
+// dobrando a cada falha, até 6 horas.
+func Backoff(attempt int) time.Duration {
+	d := time.Minute
+	for i := 1; i < attempt && d < 6*time.Hour; i++ {
+		d *= 2
+	}
+	if d > 6*time.Hour {
+		d = 6 * time.Hour
+	}
+	return d
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,271 @@
+package integrations
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"github.com/wbrunovieira/LeadSearchVersion2/db"
+)
+
+var testDefinitions = []db.CustomFieldDefinition{
+	{Key: "segmento", Label: "Segmento", Type: db.CustomFieldText},
+}
+
+func testLead() *db.Lead {
+	return &db.Lead{
+		ID:                    uuid.MustParse("6f1c4d52-8f0e-4d7a-9a3b-2f5e8c1d0a11"),
+		BusinessName:          "Padaria São João",
+		CompanyRegistrationID: "12345678000195",
+		City:                  "Curitiba",
+		FoundationDate:        sql.NullTime{Time: time.Date(2010, 3, 7, 0, 0, 0, 0, time.UTC), Valid: true},
+		Tags:                  pq.StringArray{"vip"},
+		CustomFields:          map[string]interface{}{"segmento": "Varejo"},
+	}
+}
+
+func TestBuild(t *testing.T) {
+	data := Build(testLead(), map[string]string{
+		"company.name":   "BusinessName",
+		"company.tax_id": "CompanyRegistrationID",
+		"address.city":   "City",
+		"founded":        "FoundationDate",
+		"fields.segment": "custom.segmento",
+		"fields.gone":    "custom.apagado",
+		"labels":         "Tags",
+	}, testDefinitions)
+	want := map[string]interface{}{
+		"company": map[string]interface{}{"name": "Padaria São João", "tax_id": "12345678000195"},
+		"address": map[string]interface{}{"city": "Curitiba"},
+		"founded": "2010-03-07",
+		"fields":  map[string]interface{}{"segment": "Varejo"},
+		"labels":  []string{"vip"},
+	}
+	if !reflect.DeepEqual(data, want) {
+		t.Errorf("Build = %#v, want %#v", data, want)
+	}
+
+	data = Build(testLead(), nil, testDefinitions)
+	if data["name"] != "Padaria São João" || data["id"] != testLead().ID.String() || data["custom.segmento"] != nil {
+		t.Errorf("Build default = %#v", data)
+	}
+	if custom, ok := data["custom"].(map[string]interface{}); !ok || custom["segmento"] != "Varejo" {
+		t.Errorf("Build default custom = %#v", data["custom"])
+	}
+}
+
+func TestValidate(t *testing.T) {
+	i := &db.Integration{Name: " CRM ", URL: "https://crm.example.com/contacts", Kind: "REST"}
+	if err := Validate(i, testDefinitions); err != nil {
+		t.Fatal(err)
+	}
+	if i.Name != "CRM" || i.Kind != db.IntegrationREST || i.UpdateMethod != "PATCH" || i.IDField != "id" ||
+		len(i.Events) != len(db.LeadEvents) || len(i.Secret) != 64 {
+		t.Errorf("Validate normalizou para %+v", i)
+	}
+
+	cases := []db.Integration{
+		{URL: "https://crm.example.com"},
+		{Name: "CRM", URL: "ftp://crm.example.com"},
+		{Name: "CRM", URL: "https://crm.example.com", Kind: "soap"},
+		{Name: "CRM", URL: "https://crm.example.com", Events: pq.StringArray{"lead.deleted"}},
+		{Name: "CRM", URL: "https://crm.example.com", Mapping: map[string]string{"name": "Inexistente"}},
+		{Name: "CRM", URL: "https://crm.example.com", Mapping: map[string]string{"a": "City", "a.b": "State"}},
+		{Name: "CRM", URL: "https://crm.example.com", Mapping: map[string]string{"a..b": "City"}},
+		{Name: "CRM", URL: "https://crm.example.com", Kind: "rest", UpdateURL: "https://crm.example.com/x"},
+		{Name: "CRM", URL: "https://crm.example.com", Kind: "rest", UpdateMethod: "DELETE"},
+		{Name: "CRM", URL: "https://crm.example.com", Filters: []db.IntegrationFilter{{Field: "Inexistente", Op: "present"}}},
+		{Name: "CRM", URL: "https://crm.example.com", Filters: []db.IntegrationFilter{{Field: "City", Op: "in"}}},
+		{Name: "CRM", URL: "https://crm.example.com", Filters: []db.IntegrationFilter{{Field: "City", Op: "like"}}},
+	}
+	for _, c := range cases {
+		if err := Validate(&c, testDefinitions); !errors.Is(err, ErrInvalid) {
+			t.Errorf("Validate(%+v) error = %v, want ErrInvalid", c, err)
+		}
+	}
+}
+
+func TestFilters(t *testing.T) {
+	i := &db.Integration{
+		Name:   "Marcos",
+		URL:    "https://hooks.example.com",
+		Events: pq.StringArray{db.EventLeadLLMExtracted},
+		Filters: []db.IntegrationFilter{
+			{Field: "companyregistrationid", Op: "PRESENT"},
+			{Field: "tags", Op: "in", Values: []string{"VIP"}},
+			{Field: "custom.segmento", Op: "not_in", Values: []string{"atacado"}},
+		},
+	}
+	if err := Validate(i, testDefinitions); err != nil {
+		t.Fatal(err)
+	}
+	if i.Filters[0].Field != "CompanyRegistrationID" || i.Filters[0].Op != db.FilterPresent {
+		t.Errorf("filtro normalizado para %+v", i.Filters[0])
+	}
+	i.Active = true
+
+	lead := testLead()
+	if !i.Matches(lead, nil) {
+		t.Error("Matches = false para lead com CNPJ, tag vip e segmento varejo")
+	}
+	lead.CompanyRegistrationID = ""
+	if i.Matches(lead, nil) {
+		t.Error("Matches = true para lead sem CNPJ")
+	}
+	lead = testLead()
+	lead.CustomFields["segmento"] = "Atacado"
+	if i.Matches(lead, nil) {
+		t.Error("Matches = true para segmento excluído")
+	}
+
+	i.Filters = []db.IntegrationFilter{{Field: "details.cnpj_found", Op: db.FilterPresent}}
+	if err := Validate(i, testDefinitions); err != nil {
+		t.Fatal(err)
+	}
+	if !i.Matches(testLead(), map[string]interface{}{"cnpj_found": true}) ||
+		i.Matches(testLead(), map[string]interface{}{"cnpj_found": false}) || i.Matches(testLead(), nil) {
+		t.Error("filtro em details.cnpj_found")
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"ok":true}`)
+	signature := Sign("segredo", now.Unix(), body)
+	if err := Verify("segredo", signature, "1700000000", body, now.Add(time.Minute), 5*time.Minute); err != nil {
+		t.Errorf("Verify = %v", err)
+	}
+	if err := Verify("outro", signature, "1700000000", body, now, 5*time.Minute); err == nil {
+		t.Error("Verify aceitou outro segredo")
+	}
+	if err := Verify("segredo", signature, "1700000000", []byte(`{"ok":false}`), now, 5*time.Minute); err == nil {
+		t.Error("Verify aceitou outro corpo")
+	}
+	if err := Verify("segredo", signature, "1700000000", body, now.Add(time.Hour), 5*time.Minute); err == nil {
+		t.Error("Verify aceitou timestamp antigo")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	cases := map[int]time.Duration{1: time.Minute, 2: 2 * time.Minute, 4: 8 * time.Minute, 20: 6 * time.Hour}
+	for attempt, want := range cases {
+		if got := Backoff(attempt); got != want {
+			t.Errorf("Backoff(%d) = %s, want %s", attempt, got, want)
+		}
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	cases := map[error]bool{
+		&HTTPError{StatusCode: 500}:       true,
+		&HTTPError{StatusCode: 429}:       true,
+		&HTTPError{StatusCode: 400}:       false,
+		&HTTPError{StatusCode: 404}:       false,
+		errors.New("connection refused"):  true,
+		permanentError("lead não existe"): false,
+	}
+	for err, want := range cases {
+		if got := Retryable(err); got != want {
+			t.Errorf("Retryable(%v) = %v, want %v", err, got, want)
+		}
+	}
+}
+
+func testEvent(name string) Event {
+	lead := testLead()
+	return Event{
+		ID:         uuid.New(),
+		Event:      name,
+		OccurredAt: time.Now(),
+		LeadID:     lead.ID,
+		Data:       Build(lead, map[string]string{"name": "BusinessName"}, nil),
+	}
+}
+
+func TestSendWebhook(t *testing.T) {
+	integration := &db.Integration{Kind: db.IntegrationWebhook, Secret: "segredo", Headers: map[string]string{"Authorization": "Bearer x"}}
+	var received Event
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := Verify("segredo", r.Header.Get(SignatureHeader), r.Header.Get(TimestampHeader), body, time.Now(), time.Minute); err != nil {
+			t.Errorf("assinatura: %v", err)
+		}
+		if r.Method != http.MethodPost || r.Header.Get("Authorization") != "Bearer x" || r.Header.Get(EventHeader) != db.EventLeadCreated {
+			t.Errorf("requisição %s com cabeçalhos %v", r.Method, r.Header)
+		}
+		json.Unmarshal(body, &received)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+	integration.URL = server.URL
+
+	event := testEvent(db.EventLeadCreated)
+	result, err := NewSender().Send(context.Background(), integration, event, "")
+	if err != nil || result.StatusCode != http.StatusAccepted {
+		t.Fatalf("Send = %+v, %v", result, err)
+	}
+	if received.ID != event.ID || received.Data["name"] != "Padaria São João" {
+		t.Errorf("envelope recebido = %+v", received)
+	}
+}
+
+// TestSendREST simula um CRM que cria contatos com POST /contacts e os
+// atualiza com PATCH /contacts/{id}.
+func TestSendREST(t *testing.T) {
+	contacts := map[string]map[string]interface{}{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/contacts":
+			contacts["c-1"] = body
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"data":{"id":"c-1"}}`))
+		case r.Method == http.MethodPatch && strings.HasPrefix(r.URL.Path, "/contacts/"):
+			id := strings.TrimPrefix(r.URL.Path, "/contacts/")
+			if _, ok := contacts[id]; !ok {
+				http.Error(w, "not found", http.StatusNotFound)
+				return
+			}
+			contacts[id] = body
+			w.WriteHeader(http.StatusOK)
+		default:
+			http.Error(w, "bad request", http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	integration := &db.Integration{Name: "CRM", Kind: db.IntegrationREST, URL: server.URL + "/contacts", IDField: "data.id"}
+	if err := Validate(integration, nil); err != nil {
+		t.Fatal(err)
+	}
+	sender := NewSender()
+
+	result, err := sender.Send(context.Background(), integration, testEvent(db.EventLeadCreated), "")
+	if err != nil || result.ExternalID != "c-1" {
+		t.Fatalf("criação = %+v, %v", result, err)
+	}
+	if contacts["c-1"]["name"] != "Padaria São João" {
+		t.Errorf("contato criado = %v", contacts["c-1"])
+	}
+
+	if _, err := sender.Send(context.Background(), integration, testEvent(db.EventLeadUpdated), "c-1"); err != nil {
+		t.Errorf("atualização: %v", err)
+	}
+	_, err = sender.Send(context.Background(), integration, testEvent(db.EventLeadUpdated), "c-2")
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusNotFound || Retryable(err) {
+		t.Errorf("atualização de contato inexistente: %v", err)
+	}
+}
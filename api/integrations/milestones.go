@@ -0,0 +1,26 @@
+// /api/integrations/milestones.go
+package integrations
+
+import (
+	"log"
+
+	"github.com/google/uuid"
+
+	"github.com/wbrunovieira/LeadSearchVersion2/db"
+	"github.com/wbrunovieira/LeadSearchVersion2/rabbitmq"
+)
+
+// HandleLeadEvent agenda as entregas dos marcos anunciados pelos outros
+// serviços em lead_events. lead.saved é agendado pela própria API ao gravar
+// o lead; outros eventos são ignorados.
+func HandleLeadEvent(event rabbitmq.LeadEvent) error {
+	if event.Event == db.EventLeadSaved || !contains(db.MilestoneEvents, event.Event) {
+		return nil
+	}
+	leadID, err := uuid.Parse(event.LeadID)
+	if err != nil {
+		log.Printf("Marco %s com lead_id inválido ignorado: %q", event.Event, event.LeadID)
+		return nil
+	}
+	return db.EnqueueLeadMilestone(leadID, event.Event, event.Details)
+}
@@ -0,0 +1,153 @@
+// /api/integrations/sender.go
+package integrations
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wbrunovieira/LeadSearchVersion2/db"
+)
+
+// Result é a resposta de uma entrega. ExternalID é o ID que um CRM rest deu
+// ao lead ao criá-lo.
+type Result struct {
+	StatusCode int
+	ExternalID string
+}
+
+// HTTPError é uma resposta fora da faixa 2xx.
+type HTTPError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *HTTPError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("HTTP %d", e.StatusCode)
+	}
+	return fmt.Sprintf("HTTP %d: %s", e.StatusCode, e.Body)
+}
+
+// permanentError é uma falha que não se resolve tentando de novo.
+type permanentError string
+
+func (e permanentError) Error() string { return string(e) }
+
+// Retryable diz se vale tentar de novo depois do erro. Respostas 4xx, exceto
+// 408 e 429, indicam um problema que não se resolve sozinho.
+func Retryable(err error) bool {
+	if _, ok := err.(permanentError); ok {
+		return false
+	}
+	if httpErr, ok := err.(*HTTPError); ok {
+		s := httpErr.StatusCode
+		return s >= 500 || s == http.StatusRequestTimeout || s == http.StatusTooManyRequests
+	}
+	return true
+}
+
+// Sender entrega eventos às integrações.
+type Sender struct {
+	Client *http.Client
+}
+
+// NewSender cria um Sender com timeout de 15 segundos por requisição.
+func NewSender() *Sender {
+	return &Sender{Client: &http.Client{Timeout: 15 * time.Second}}
+}
+
+// Send entrega o evento à integração. Webhooks recebem o envelope com POST
+// em URL; integrações rest recebem só os dados, com POST em URL para criar
+// (externalID vazio) ou com UpdateMethod em UpdateURL para atualizar.
+func (s *Sender) Send(ctx context.Context, integration *db.Integration, event Event, externalID string) (Result, error) {
+	method, target := http.MethodPost, integration.URL
+	var payload interface{} = event
+	if integration.Kind == db.IntegrationREST {
+		payload = event.Data
+		if externalID != "" {
+			method, target = integration.UpdateMethod, integration.UpdateURL
+			if method == "" {
+				method = http.MethodPatch
+			}
+			if target == "" {
+				target = strings.TrimRight(integration.URL, "/") + "/{id}"
+			}
+			target = strings.ReplaceAll(target, "{id}", url.PathEscape(externalID))
+		}
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return Result{}, fmt.Errorf("erro ao montar o corpo: %v", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return Result{}, err
+	}
+	for name, value := range integration.Headers {
+		req.Header.Set(name, value)
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "LeadSearch-Integrations/1.0")
+	req.Header.Set(EventHeader, event.Event)
+	req.Header.Set(DeliveryHeader, event.ID.String())
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Sign(integration.Secret, timestamp, body))
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return Result{}, err
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	result := Result{StatusCode: resp.StatusCode}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		snippet := strings.TrimSpace(string(respBody))
+		if len(snippet) > 500 {
+			snippet = snippet[:500]
+		}
+		return result, &HTTPError{StatusCode: resp.StatusCode, Body: snippet}
+	}
+
+	if integration.Kind == db.IntegrationREST && externalID == "" {
+		result.ExternalID = extractID(respBody, integration.IDField)
+	}
+	return result, nil
+}
+
+// extractID lê o ID do registro criado no campo path (com "." para campos
+// aninhados) da resposta JSON. Números viram texto.
+func extractID(body []byte, path string) string {
+	if path == "" {
+		path = "id"
+	}
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return ""
+	}
+	for _, key := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return ""
+		}
+		value = object[key]
+	}
+	switch v := value.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	}
+	return ""
+}
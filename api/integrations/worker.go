@@ -0,0 +1,144 @@
+// /api/integrations/worker.go
+package integrations
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/wbrunovieira/LeadSearchVersion2/db"
+)
+
+// Worker entrega as integrações pendentes em lotes.
+type Worker struct {
+	Sender      *Sender
+	MaxAttempts int
+	BatchSize   int
+}
+
+// StartWorker procura entregas pendentes a cada INTEGRATION_POLL_SECONDS
+// (padrão 10; 0 desliga) e desiste de uma entrega depois de
+// INTEGRATION_MAX_ATTEMPTS tentativas (padrão 8).
+func StartWorker() {
+	interval := envInt("INTEGRATION_POLL_SECONDS", 10)
+	if interval <= 0 {
+		log.Println("Entrega de integrações desligada")
+		return
+	}
+	w := &Worker{Sender: NewSender(), MaxAttempts: envInt("INTEGRATION_MAX_ATTEMPTS", 8), BatchSize: 20}
+
+	go func() {
+		ticker := time.NewTicker(time.Duration(interval) * time.Second)
+		defer ticker.Stop()
+		for range ticker.C {
+			w.RunOnce(context.Background())
+		}
+	}()
+	log.Printf("Entrega de integrações verificando a cada %d segundos", interval)
+}
+
+func envInt(key string, fallback int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	parsed, err := strconv.Atoi(v)
+	if err != nil {
+		log.Printf("%s inválido (%s), usando %d", key, v, fallback)
+		return fallback
+	}
+	return parsed
+}
+
+// RunOnce reserva e envia um lote de entregas vencidas.
+func (w *Worker) RunOnce(ctx context.Context) {
+	// A reserva dura o suficiente para o lote inteiro estourar o timeout.
+	lease := time.Duration(w.BatchSize)*w.Sender.Client.Timeout + time.Minute
+	deliveries, err := db.ClaimDueDeliveries(time.Now(), w.BatchSize, lease)
+	if err != nil {
+		log.Printf("Integrações: %v", err)
+		return
+	}
+	for i := range deliveries {
+		w.deliver(ctx, &deliveries[i])
+	}
+}
+
+func (w *Worker) deliver(ctx context.Context, delivery *db.IntegrationDelivery) {
+	scope := db.InWorkspace(delivery.WorkspaceID)
+	attempt := db.DeliveryAttempt{Attempt: delivery.Attempts + 1}
+	started := time.Now()
+
+	result, err := w.send(ctx, scope, delivery)
+	attempt.DurationMS = time.Since(started).Milliseconds()
+	attempt.StatusCode = result.StatusCode
+	delivery.Attempts++
+	delivery.LastStatusCode = result.StatusCode
+	switch {
+	case err == nil:
+		delivery.Status, delivery.LastError = db.DeliveryDelivered, ""
+		delivery.DeliveredAt = sql.NullTime{Time: time.Now(), Valid: true}
+	case !Retryable(err) || delivery.Attempts >= w.MaxAttempts:
+		attempt.Error, delivery.LastError = err.Error(), err.Error()
+		delivery.Status = db.DeliveryFailed
+		log.Printf("Integrações: entrega %s do lead %s desistida após %d tentativas: %v", delivery.ID, delivery.LeadID, delivery.Attempts, err)
+	default:
+		attempt.Error, delivery.LastError = err.Error(), err.Error()
+		delivery.NextAttemptAt = time.Now().Add(Backoff(delivery.Attempts))
+	}
+	if err := db.RecordDeliveryAttempt(delivery, &attempt); err != nil {
+		log.Printf("Integrações: erro ao registrar a tentativa da entrega %s: %v", delivery.ID, err)
+	}
+}
+
+func (w *Worker) send(ctx context.Context, scope db.Scope, delivery *db.IntegrationDelivery) (Result, error) {
+	integration, err := db.GetIntegration(scope, delivery.IntegrationID)
+	if err != nil {
+		return Result{}, err
+	}
+	if integration == nil || !integration.Active {
+		return Result{}, permanentError("integração apagada ou desativada")
+	}
+	lead, err := db.GetLeadByID(scope, delivery.LeadID)
+	if err != nil {
+		return Result{}, err
+	}
+	if lead == nil {
+		return Result{}, permanentError("lead não existe mais")
+	}
+	custom, err := db.GetCustomFieldDefinitions(scope)
+	if err != nil {
+		return Result{}, err
+	}
+
+	externalID := ""
+	if integration.Kind == db.IntegrationREST {
+		if externalID, err = db.GetIntegrationLink(integration.ID, lead.ID); err != nil {
+			return Result{}, err
+		}
+	}
+	event := Event{
+		ID:          delivery.ID,
+		Event:       delivery.Event,
+		OccurredAt:  delivery.CreatedAt,
+		WorkspaceID: delivery.WorkspaceID,
+		LeadID:      lead.ID,
+		Data:        Build(lead, integration.Mapping, custom),
+		Details:     delivery.Details,
+	}
+	result, err := w.Sender.Send(ctx, integration, event, externalID)
+	if err != nil {
+		return result, err
+	}
+	if result.ExternalID != "" {
+		if err := db.SaveIntegrationLink(integration.ID, lead.ID, result.ExternalID); err != nil {
+			return result, permanentError("registro criado no CRM, mas o ID não foi gravado: " + err.Error())
+		}
+	} else if integration.Kind == db.IntegrationREST && externalID == "" {
+		log.Printf("Integrações: %s criou o lead %s sem devolver o campo %q; a próxima alteração vai criar outro registro", integration.Name, lead.ID, integration.IDField)
+	}
+	return result, nil
+}
@@ -0,0 +1,147 @@
+// /api/leadfields/custom.go
+package leadfields
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/wbrunovieira/LeadSearchVersion2/db"
+)
+
+// CustomPrefix antecede a chave dos campos personalizados nos endpoints de
+// atualização ("custom.segmento").
+const CustomPrefix = "custom."
+
+const (
+	maxTags        = 50
+	maxTagLength   = 50
+	maxCustomText  = 1000
+	maxCustomKey   = 64
+	maxEnumOptions = 100
+)
+
+var customKeyPattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// ValidateCustomDefinition confere a chave, o tipo e as opções de um campo
+// personalizado e normaliza as opções de enum (sem espaços nas pontas e sem
+// repetição).
+func ValidateCustomDefinition(d *db.CustomFieldDefinition) error {
+	if len(d.Key) > maxCustomKey || !customKeyPattern.MatchString(d.Key) {
+		return fmt.Errorf("chave inválida %q: use letras minúsculas, números e _, começando por letra (até %d caracteres)", d.Key, maxCustomKey)
+	}
+	if strings.TrimSpace(d.Label) == "" {
+		d.Label = d.Key
+	}
+	valid := false
+	for _, t := range db.CustomFieldTypes {
+		valid = valid || t == d.Type
+	}
+	if !valid {
+		return fmt.Errorf("tipo inválido %q: use %s", d.Type, strings.Join(db.CustomFieldTypes, ", "))
+	}
+
+	if d.Type != db.CustomFieldEnum {
+		if len(d.Options) > 0 {
+			return fmt.Errorf("options só vale para campos enum")
+		}
+		return nil
+	}
+	var options []string
+	seen := make(map[string]bool)
+	for _, o := range d.Options {
+		o = strings.TrimSpace(o)
+		if o == "" || seen[strings.ToLower(o)] {
+			continue
+		}
+		seen[strings.ToLower(o)] = true
+		options = append(options, o)
+	}
+	if len(options) == 0 || len(options) > maxEnumOptions {
+		return fmt.Errorf("campos enum precisam de 1 a %d opções", maxEnumOptions)
+	}
+	d.Options = options
+	return nil
+}
+
+// Custom monta o campo editável correspondente a um campo personalizado do
+// workspace. null apaga o valor do lead.
+func Custom(d db.CustomFieldDefinition) *Field {
+	key := d.Key
+	f := &Field{
+		Name: CustomPrefix + key, Label: d.Label, EditableBy: editors, clearable: true,
+		get: func(l *db.Lead) interface{} { return l.CustomFields[key] },
+		set: func(l *db.Lead, v interface{}) {
+			if v == nil {
+				delete(l.CustomFields, key)
+				return
+			}
+			if date, ok := v.(sql.NullTime); ok {
+				v = date.Time.Format("2006-01-02")
+			}
+			if l.CustomFields == nil {
+				l.CustomFields = make(map[string]interface{})
+			}
+			l.CustomFields[key] = v
+		},
+	}
+	switch d.Type {
+	case db.CustomFieldNumber:
+		f.Type = TypeNumber
+	case db.CustomFieldDate:
+		f.Type = TypeDate
+	case db.CustomFieldEnum:
+		f.Type, f.Options = TypeEnum, d.Options
+	default:
+		f.Type, f.MaxLength = TypeString, maxCustomText
+	}
+	return f
+}
+
+// ParseTags aceita uma lista de strings ou uma string separada por vírgulas e
+// devolve as tags normalizadas: minúsculas, espaços internos simples, sem
+// vazias nem repetidas, na ordem em que apareceram.
+func ParseTags(value interface{}) ([]string, error) {
+	var raw []string
+	switch v := value.(type) {
+	case nil:
+	case string:
+		raw = strings.Split(v, ",")
+	case []string:
+		raw = v
+	case []interface{}:
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("tags devem ser strings")
+			}
+			raw = append(raw, s)
+		}
+	default:
+		return nil, fmt.Errorf("espera lista de tags")
+	}
+
+	tags := []string{}
+	seen := make(map[string]bool)
+	for _, t := range raw {
+		t = NormalizeTag(t)
+		if t == "" || seen[t] {
+			continue
+		}
+		if len([]rune(t)) > maxTagLength {
+			return nil, fmt.Errorf("tag %q passa de %d caracteres", t, maxTagLength)
+		}
+		seen[t] = true
+		tags = append(tags, t)
+	}
+	if len(tags) > maxTags {
+		return nil, fmt.Errorf("no máximo %d tags por lead", maxTags)
+	}
+	return tags, nil
+}
+
+// NormalizeTag deixa a tag em minúsculas e com espaços simples.
+func NormalizeTag(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), " "))
+}
@@ -0,0 +1,99 @@
+package leadfields
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/wbrunovieira/LeadSearchVersion2/db"
+)
+
+func TestParseTags(t *testing.T) {
+	got, err := ParseTags([]interface{}{" VIP ", "retorno  em março", "vip", ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"vip", "retorno em março"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseTags(list) = %q, want %q", got, want)
+	}
+	got, err = ParseTags("feira, Feira ,atacado")
+	if err != nil || !reflect.DeepEqual(got, []string{"feira", "atacado"}) {
+		t.Errorf("ParseTags(string) = %q, %v", got, err)
+	}
+	if _, err := ParseTags([]interface{}{"ok", 3.0}); err == nil {
+		t.Error("non-string tag should fail")
+	}
+
+	lead := &db.Lead{}
+	f, _ := Lookup("Tags")
+	parsed, _, err := f.Parse([]interface{}{"VIP"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Set(lead, parsed)
+	if len(lead.Tags) != 1 || lead.Tags[0] != "vip" {
+		t.Errorf("Tags = %q", lead.Tags)
+	}
+}
+
+func TestCustomFields(t *testing.T) {
+	lead := &db.Lead{}
+	cases := []struct {
+		definition db.CustomFieldDefinition
+		value      interface{}
+		want       interface{}
+	}{
+		{db.CustomFieldDefinition{Key: "segmento", Type: db.CustomFieldEnum, Options: []string{"Varejo", "Atacado"}}, "varejo", "Varejo"},
+		{db.CustomFieldDefinition{Key: "lojas", Type: db.CustomFieldNumber}, float64(3), float64(3)},
+		{db.CustomFieldDefinition{Key: "renovacao", Type: db.CustomFieldDate}, "2025-02-01", "2025-02-01"},
+		{db.CustomFieldDefinition{Key: "obs", Type: db.CustomFieldText}, " ligar cedo ", "ligar cedo"},
+	}
+	for _, c := range cases {
+		f := Custom(c.definition)
+		if f.Name != CustomPrefix+c.definition.Key {
+			t.Errorf("Name = %q", f.Name)
+		}
+		parsed, _, err := f.Parse(c.value)
+		if err != nil {
+			t.Fatalf("%s: Parse(%v): %v", c.definition.Key, c.value, err)
+		}
+		f.Set(lead, parsed)
+		if got := lead.CustomFields[c.definition.Key]; got != c.want {
+			t.Errorf("%s = %#v, want %#v", c.definition.Key, got, c.want)
+		}
+	}
+
+	segmento := Custom(cases[0].definition)
+	if _, _, err := segmento.Parse("indústria"); err == nil {
+		t.Error("enum should reject values outside the options")
+	}
+	parsed, _, err := segmento.Parse(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	segmento.Set(lead, parsed)
+	if _, ok := lead.CustomFields["segmento"]; ok {
+		t.Error("null should clear the custom field")
+	}
+}
+
+func TestValidateCustomDefinition(t *testing.T) {
+	d := db.CustomFieldDefinition{Key: "segmento", Type: db.CustomFieldEnum, Options: []string{" Varejo", "varejo", "Atacado", ""}}
+	if err := ValidateCustomDefinition(&d); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual([]string(d.Options), []string{"Varejo", "Atacado"}) || d.Label != "segmento" {
+		t.Errorf("definition = %+v", d)
+	}
+
+	for _, bad := range []db.CustomFieldDefinition{
+		{Key: "Segmento", Type: db.CustomFieldText},
+		{Key: "1lojas", Type: db.CustomFieldNumber},
+		{Key: "obs", Type: "json"},
+		{Key: "segmento", Type: db.CustomFieldEnum},
+		{Key: "obs", Type: db.CustomFieldText, Options: []string{"a"}},
+	} {
+		if err := ValidateCustomDefinition(&bad); err == nil {
+			t.Errorf("ValidateCustomDefinition(%+v) should fail", bad)
+		}
+	}
+}
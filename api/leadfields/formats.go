@@ -0,0 +1,75 @@
+// /api/leadfields/formats.go
+package leadfields
+
+import (
+	"fmt"
+	"net/mail"
+	"strings"
+
+	"github.com/wbrunovieira/LeadSearchVersion2/shared/cnpj"
+	"github.com/wbrunovieira/LeadSearchVersion2/shared/phone"
+	"github.com/wbrunovieira/LeadSearchVersion2/shared/social"
+	"github.com/wbrunovieira/LeadSearchVersion2/weburl"
+)
+
+func normalize(format Format, s string) (string, error) {
+	switch format {
+	case FormatCNPJ:
+		return cnpj.Normalize(s)
+	case FormatPhone:
+		n, err := phone.Parse(s)
+		return n.E164, err
+	case FormatURL:
+		return normalizeLink(weburl.KindWebsite, s)
+	case FormatLinkInBio:
+		return normalizeLink(weburl.KindLinkInBio, s)
+	case FormatEmail:
+		return normalizeEmail(s)
+	case FormatInstagram, FormatFacebook, FormatTikTok:
+		return normalizeSocial(social.Network(format), s)
+	}
+	return s, nil
+}
+
+func normalizeSocial(network social.Network, s string) (string, error) {
+	profile, err := social.Parse(s)
+	if err != nil {
+		return "", err
+	}
+	if profile.Network != network {
+		return "", fmt.Errorf("link é de %s, não de %s", profile.Network, network)
+	}
+	return profile.URL, nil
+}
+
+// normalizeLink canonicaliza a URL e exige que ela seja do tipo esperado:
+// um perfil social ou um link de WhatsApp não servem como site.
+func normalizeLink(kind weburl.Kind, s string) (string, error) {
+	link, err := weburl.Classify(s)
+	if err != nil {
+		return "", err
+	}
+	if link.Kind != kind {
+		return "", fmt.Errorf("link é %s, não %s", linkKindLabels[link.Kind], linkKindLabels[kind])
+	}
+	return link.URL, nil
+}
+
+var linkKindLabels = map[weburl.Kind]string{
+	weburl.KindWebsite:   "site",
+	weburl.KindSocial:    "rede social",
+	weburl.KindLinkInBio: "agregador de links",
+	weburl.KindMessaging: "link de mensagem",
+}
+
+func normalizeEmail(s string) (string, error) {
+	addr, err := mail.ParseAddress(s)
+	if err != nil || addr.Address != s {
+		return "", fmt.Errorf("e-mail inválido")
+	}
+	at := strings.LastIndex(addr.Address, "@")
+	if at < 1 || !strings.Contains(addr.Address[at:], ".") {
+		return "", fmt.Errorf("e-mail inválido")
+	}
+	return addr.Address[:at] + strings.ToLower(addr.Address[at:]), nil
+}
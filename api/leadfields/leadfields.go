@@ -0,0 +1,302 @@
+// /api/leadfields/leadfields.go
+package leadfields
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/wbrunovieira/LeadSearchVersion2/db"
+	"github.com/wbrunovieira/LeadSearchVersion2/shared/auth"
+)
+
+// Type é o tipo do valor aceito por um campo, como aparece no JSON.
+type Type string
+
+const (
+	TypeString  Type = "string"
+	TypeDate    Type = "date"
+	TypeInteger Type = "integer"
+	TypeNumber  Type = "number"
+	TypeBoolean Type = "boolean"
+	// TypeEnum aceita uma das Options do campo.
+	TypeEnum Type = "enum"
+	// TypeTags aceita uma lista de strings ou uma string separada por
+	// vírgulas.
+	TypeTags Type = "tags"
+)
+
+// Format refina campos string com uma validação e normalização específicas.
+type Format string
+
+const (
+	FormatText  Format = ""
+	FormatCNPJ  Format = "cnpj"
+	FormatPhone Format = "e164"
+	FormatURL   Format = "url"
+	FormatEmail Format = "email"
+	// FormatLinkInBio é um agregador de links como linktr.ee.
+	FormatLinkInBio Format = "link_in_bio"
+
+	// Perfis de rede social: a URL precisa ser de um perfil da rede e é
+	// gravada na forma canônica.
+	FormatInstagram Format = "instagram"
+	FormatFacebook  Format = "facebook"
+	FormatTikTok    Format = "tiktok"
+)
+
+// Field descreve um atributo editável do lead: tipo, validação, normalização
+// e quais papéis podem alterá-lo. Campos fora do registro não podem ser
+// alterados pelos endpoints de atualização.
+type Field struct {
+	Name       string      `json:"name"`
+	Label      string      `json:"label"`
+	Type       Type        `json:"type"`
+	Format     Format      `json:"format,omitempty"`
+	MaxLength  int         `json:"max_length,omitempty"`
+	Min        *float64    `json:"min,omitempty"`
+	Options    []string    `json:"options,omitempty"`
+	EditableBy []auth.Role `json:"editable_by"`
+
+	// ignored são valores que significam "sem informação" (por exemplo o
+	// texto padrão do enriquecimento) e não sobrescrevem o valor atual.
+	ignored []string
+	// clearable deixa null apagar o valor (campos personalizados).
+	clearable bool
+
+	get func(*db.Lead) interface{}
+	set func(*db.Lead, interface{})
+}
+
+// CanEdit diz se o principal pode alterar o campo.
+func (f *Field) CanEdit(p auth.Principal) bool {
+	return p.CanAny(f.EditableBy...)
+}
+
+// Get devolve o valor atual do campo no lead.
+func (f *Field) Get(lead *db.Lead) interface{} {
+	return f.get(lead)
+}
+
+// Parse valida e normaliza um valor vindo do JSON. ignore indica que o valor
+// deve ser descartado sem erro.
+func (f *Field) Parse(value interface{}) (parsed interface{}, ignore bool, err error) {
+	if value == nil && f.clearable {
+		return nil, false, nil
+	}
+	switch f.Type {
+	case TypeString:
+		s, ok := value.(string)
+		if !ok {
+			return nil, false, fmt.Errorf("campo '%s' espera string", f.Name)
+		}
+		s = strings.TrimSpace(s)
+		for _, v := range f.ignored {
+			if s == v {
+				return nil, true, nil
+			}
+		}
+		if s != "" {
+			if s, err = normalize(f.Format, s); err != nil {
+				return nil, false, fmt.Errorf("campo '%s': %v", f.Name, err)
+			}
+		}
+		if f.MaxLength > 0 && len([]rune(s)) > f.MaxLength {
+			return nil, false, fmt.Errorf("campo '%s' aceita no máximo %d caracteres", f.Name, f.MaxLength)
+		}
+		return s, false, nil
+
+	case TypeDate:
+		s, ok := value.(string)
+		if !ok {
+			return nil, false, fmt.Errorf("campo '%s' espera string no formato YYYY-MM-DD", f.Name)
+		}
+		parsedDate, err := time.Parse("2006-01-02", strings.TrimSpace(s))
+		if err != nil {
+			return nil, false, fmt.Errorf("formato de data inválido para o campo '%s': %v", f.Name, err)
+		}
+		return sql.NullTime{Time: parsedDate, Valid: true}, false, nil
+
+	case TypeInteger, TypeNumber:
+		n, ok := value.(float64)
+		if !ok {
+			return nil, false, fmt.Errorf("campo '%s' espera número", f.Name)
+		}
+		if f.Type == TypeInteger && n != math.Trunc(n) {
+			return nil, false, fmt.Errorf("campo '%s' espera número inteiro", f.Name)
+		}
+		if f.Min != nil && n < *f.Min {
+			return nil, false, fmt.Errorf("campo '%s' não aceita valores menores que %v", f.Name, *f.Min)
+		}
+		if f.Type == TypeInteger {
+			return int(n), false, nil
+		}
+		return n, false, nil
+
+	case TypeBoolean:
+		b, ok := value.(bool)
+		if !ok {
+			return nil, false, fmt.Errorf("campo '%s' espera booleano", f.Name)
+		}
+		return b, false, nil
+
+	case TypeEnum:
+		s, ok := value.(string)
+		if !ok {
+			return nil, false, fmt.Errorf("campo '%s' espera string", f.Name)
+		}
+		for _, option := range f.Options {
+			if strings.EqualFold(option, strings.TrimSpace(s)) {
+				return option, false, nil
+			}
+		}
+		return nil, false, fmt.Errorf("campo '%s' aceita apenas: %s", f.Name, strings.Join(f.Options, ", "))
+
+	case TypeTags:
+		tags, err := ParseTags(value)
+		if err != nil {
+			return nil, false, fmt.Errorf("campo '%s': %v", f.Name, err)
+		}
+		return tags, false, nil
+	}
+	return nil, false, fmt.Errorf("tipo do campo '%s' não suportado", f.Name)
+}
+
+// Set grava no lead um valor já devolvido por Parse.
+func (f *Field) Set(lead *db.Lead, parsed interface{}) {
+	f.set(lead, parsed)
+}
+
+var (
+	editors = []auth.Role{auth.RoleSales, auth.RoleService}
+	zero    = 0.0
+)
+
+func stringField(name, label string, format Format, maxLength int, ptr func(*db.Lead) *string) *Field {
+	return &Field{
+		Name: name, Label: label, Type: TypeString, Format: format, MaxLength: maxLength, EditableBy: editors,
+		get: func(l *db.Lead) interface{} { return *ptr(l) },
+		set: func(l *db.Lead, v interface{}) { *ptr(l) = v.(string) },
+	}
+}
+
+func dateField(name, label string, ptr func(*db.Lead) *sql.NullTime) *Field {
+	return &Field{
+		Name: name, Label: label, Type: TypeDate, EditableBy: editors,
+		get: func(l *db.Lead) interface{} { return *ptr(l) },
+		set: func(l *db.Lead, v interface{}) { *ptr(l) = v.(sql.NullTime) },
+	}
+}
+
+func intField(name, label string, ptr func(*db.Lead) *int) *Field {
+	return &Field{
+		Name: name, Label: label, Type: TypeInteger, Min: &zero, EditableBy: editors,
+		get: func(l *db.Lead) interface{} { return *ptr(l) },
+		set: func(l *db.Lead, v interface{}) { *ptr(l) = v.(int) },
+	}
+}
+
+func numberField(name, label string, ptr func(*db.Lead) *float64) *Field {
+	return &Field{
+		Name: name, Label: label, Type: TypeNumber, Min: &zero, EditableBy: editors,
+		get: func(l *db.Lead) interface{} { return *ptr(l) },
+		set: func(l *db.Lead, v interface{}) { *ptr(l) = v.(float64) },
+	}
+}
+
+func boolField(name, label string, ptr func(*db.Lead) *bool) *Field {
+	return &Field{
+		Name: name, Label: label, Type: TypeBoolean, EditableBy: editors,
+		get: func(l *db.Lead) interface{} { return *ptr(l) },
+		set: func(l *db.Lead, v interface{}) { *ptr(l) = v.(bool) },
+	}
+}
+
+func tagsField(name, label string, ptr func(*db.Lead) *pq.StringArray) *Field {
+	return &Field{
+		Name: name, Label: label, Type: TypeTags, EditableBy: editors,
+		get: func(l *db.Lead) interface{} { return []string(*ptr(l)) },
+		set: func(l *db.Lead, v interface{}) { *ptr(l) = pq.StringArray(v.([]string)) },
+	}
+}
+
+func (f *Field) by(roles ...auth.Role) *Field {
+	f.EditableBy = roles
+	return f
+}
+
+func (f *Field) ignoring(values ...string) *Field {
+	f.ignored = values
+	return f
+}
+
+// registry são os campos do lead que podem ser alterados por
+// /update-lead-field. Identificação (ID, GoogleId, workspace), datas de
+// controle, dados vindos do Google Places e a completude (calculada ao gravar)
+// ficam de fora.
+var registry = map[string]*Field{}
+
+func register(fields ...*Field) {
+	for _, f := range fields {
+		registry[f.Name] = f
+	}
+}
+
+func init() {
+	register(
+		stringField("BusinessName", "Nome fantasia", FormatText, 255, func(l *db.Lead) *string { return &l.BusinessName }),
+		stringField("RegisteredName", "Razão social", FormatText, 255, func(l *db.Lead) *string { return &l.RegisteredName }),
+		dateField("FoundationDate", "Data de fundação", func(l *db.Lead) *sql.NullTime { return &l.FoundationDate }),
+		stringField("CompanyRegistrationID", "CNPJ", FormatCNPJ, 0, func(l *db.Lead) *string { return &l.CompanyRegistrationID }),
+		stringField("Owner", "Sócios e contatos", FormatText, 0, func(l *db.Lead) *string { return &l.Owner }),
+
+		stringField("Address", "Endereço", FormatText, 0, func(l *db.Lead) *string { return &l.Address }),
+		stringField("City", "Cidade", FormatText, 0, func(l *db.Lead) *string { return &l.City }),
+		stringField("State", "Estado", FormatText, 0, func(l *db.Lead) *string { return &l.State }),
+		stringField("Country", "País", FormatText, 0, func(l *db.Lead) *string { return &l.Country }),
+		stringField("ZIPCode", "CEP", FormatText, 20, func(l *db.Lead) *string { return &l.ZIPCode }),
+
+		stringField("Phone", "Telefone", FormatPhone, 50, func(l *db.Lead) *string { return &l.Phone }),
+		stringField("Whatsapp", "WhatsApp", FormatPhone, 50, func(l *db.Lead) *string { return &l.Whatsapp }),
+		stringField("Email", "E-mail", FormatEmail, 0, func(l *db.Lead) *string { return &l.Email }),
+		stringField("Website", "Site", FormatURL, 0, func(l *db.Lead) *string { return &l.Website }),
+		stringField("LinkInBio", "Link na bio", FormatLinkInBio, 0, func(l *db.Lead) *string { return &l.LinkInBio }),
+		stringField("Instagram", "Instagram", FormatInstagram, 0, func(l *db.Lead) *string { return &l.Instagram }),
+		stringField("Facebook", "Facebook", FormatFacebook, 0, func(l *db.Lead) *string { return &l.Facebook }),
+		stringField("TikTok", "TikTok", FormatTikTok, 0, func(l *db.Lead) *string { return &l.TikTok }),
+
+		stringField("Categories", "Categorias", FormatText, 0, func(l *db.Lead) *string { return &l.Categories }),
+		stringField("CompanySize", "Porte", FormatText, 50, func(l *db.Lead) *string { return &l.CompanySize }),
+		numberField("Revenue", "Faturamento", func(l *db.Lead) *float64 { return &l.Revenue }),
+		intField("EmployeesCount", "Funcionários", func(l *db.Lead) *int { return &l.EmployeesCount }),
+		numberField("EquityCapital", "Capital social", func(l *db.Lead) *float64 { return &l.EquityCapital }),
+		stringField("PrimaryActivity", "Atividade principal", FormatText, 0, func(l *db.Lead) *string { return &l.PrimaryActivity }),
+		stringField("SecondaryActivities", "Atividades secundárias", FormatText, 0, func(l *db.Lead) *string { return &l.SecondaryActivities }),
+		stringField("Description", "Descrição", FormatText, 0, func(l *db.Lead) *string { return &l.Description }).
+			ignoring("No description available"),
+		boolField("PermanentlyClosed", "Fechado definitivamente", func(l *db.Lead) *bool { return &l.PermanentlyClosed }),
+
+		tagsField("Tags", "Tags", func(l *db.Lead) *pq.StringArray { return &l.Tags }),
+	)
+}
+
+// Lookup devolve o campo registrado com o nome informado.
+func Lookup(name string) (*Field, bool) {
+	f, ok := registry[name]
+	return f, ok
+}
+
+// All devolve os campos registrados em ordem alfabética.
+func All() []*Field {
+	fields := make([]*Field, 0, len(registry))
+	for _, f := range registry {
+		fields = append(fields, f)
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Name < fields[j].Name })
+	return fields
+}
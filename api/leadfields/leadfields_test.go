@@ -0,0 +1,109 @@
+package leadfields
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/wbrunovieira/LeadSearchVersion2/db"
+	"github.com/wbrunovieira/LeadSearchVersion2/shared/auth"
+)
+
+func TestLookupRejectsProtectedFields(t *testing.T) {
+	for _, name := range []string{"ID", "WorkspaceID", "GoogleId", "CreatedAt", "SearchJobID", "DetailsFetchedAt"} {
+		if _, ok := Lookup(name); ok {
+			t.Errorf("field %s should not be editable", name)
+		}
+	}
+	if _, ok := Lookup("RegisteredName"); !ok {
+		t.Error("RegisteredName should be editable")
+	}
+}
+
+func TestParseAndSet(t *testing.T) {
+	lead := &db.Lead{}
+	cases := []struct {
+		field string
+		value interface{}
+		check func() bool
+	}{
+		{"CompanyRegistrationID", "11.222.333/0001-81", func() bool { return lead.CompanyRegistrationID == "11222333000181" }},
+		{"Phone", "+55 (11) 91234-5678", func() bool { return lead.Phone == "+5511912345678" }},
+		{"Website", "www.Padaria.com.br/contato/?utm_source=google", func() bool { return lead.Website == "https://padaria.com.br/contato" }},
+		{"LinkInBio", "linktr.ee/padaria", func() bool { return lead.LinkInBio == "https://linktr.ee/padaria" }},
+		{"Email", "Contato@Padaria.COM.br", func() bool { return lead.Email == "Contato@padaria.com.br" }},
+		{"FoundationDate", "2010-05-03", func() bool { return lead.FoundationDate.Valid && lead.FoundationDate.Time.Year() == 2010 }},
+		{"Instagram", "instagram.com/PadariaBela", func() bool { return lead.Instagram == "https://www.instagram.com/padariabela/" }},
+		{"EmployeesCount", float64(12), func() bool { return lead.EmployeesCount == 12 }},
+		{"PermanentlyClosed", true, func() bool { return lead.PermanentlyClosed }},
+	}
+	for _, c := range cases {
+		f, _ := Lookup(c.field)
+		parsed, ignore, err := f.Parse(c.value)
+		if err != nil || ignore {
+			t.Fatalf("%s: Parse(%v) = %v, %v", c.field, c.value, ignore, err)
+		}
+		f.Set(lead, parsed)
+		if !c.check() {
+			t.Errorf("%s: unexpected value after Set: %+v", c.field, f.Get(lead))
+		}
+	}
+	if lead.FoundationDate == (sql.NullTime{}) {
+		t.Error("FoundationDate not set")
+	}
+}
+
+func TestParseRejectsInvalidValues(t *testing.T) {
+	cases := []struct {
+		field string
+		value interface{}
+	}{
+		{"CompanyRegistrationID", "11.222.333/0001-82"},
+		{"CompanyRegistrationID", "00000000000000"},
+		{"Phone", "3333-4444"},
+		{"Phone", "+55 11 abc"},
+		{"Website", "javascript:alert(1)"},
+		{"Website", "https://wa.me/5511912345678"},
+		{"LinkInBio", "https://padaria.com.br"},
+		{"Email", "not-an-email"},
+		{"Instagram", "https://www.facebook.com/padariabela"},
+		{"Facebook", "https://www.facebook.com/search/top?q=padaria"},
+		{"FoundationDate", "03/05/2010"},
+		{"EmployeesCount", 1.5},
+		{"EmployeesCount", float64(-1)},
+		{"RegisteredName", 10.0},
+		{"PermanentlyClosed", "yes"},
+	}
+	for _, c := range cases {
+		f, _ := Lookup(c.field)
+		if _, _, err := f.Parse(c.value); err == nil {
+			t.Errorf("%s: expected error for %v", c.field, c.value)
+		}
+	}
+}
+
+func TestParseIgnoresPlaceholder(t *testing.T) {
+	f, _ := Lookup("Description")
+	if _, ignore, err := f.Parse(" No description available "); err != nil || !ignore {
+		t.Errorf("placeholder description should be ignored, got ignore=%v err=%v", ignore, err)
+	}
+}
+
+func TestCanEdit(t *testing.T) {
+	sales := auth.Principal{Role: auth.RoleSales}
+	service := auth.Principal{Role: auth.RoleService}
+	viewer := auth.Principal{Role: auth.RoleViewer}
+
+	name, _ := Lookup("RegisteredName")
+	if !name.CanEdit(sales) || !name.CanEdit(service) || name.CanEdit(viewer) {
+		t.Error("RegisteredName should be editable by sales and service only")
+	}
+	onlyService := stringField("X", "X", FormatText, 0, nil).by(auth.RoleService)
+	if onlyService.CanEdit(sales) || !onlyService.CanEdit(service) {
+		t.Error("by() should restrict editing to the given roles")
+	}
+	for _, computed := range []string{"Quality", "FieldsFilled", "CompletenessScore"} {
+		if _, ok := Lookup(computed); ok {
+			t.Errorf("%s is computed and should not be editable", computed)
+		}
+	}
+}
@@ -0,0 +1,305 @@
+// /api/leadimport/leadimport.go
+package leadimport
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/charmap"
+
+	"github.com/wbrunovieira/LeadSearchVersion2/db"
+	"github.com/wbrunovieira/LeadSearchVersion2/leadfields"
+	"github.com/wbrunovieira/LeadSearchVersion2/textnorm"
+)
+
+// ErrInvalidFile indica um CSV que não dá para importar: sem cabeçalho,
+// mapeamento para coluna ou campo inexistente ou sem coluna de nome.
+var ErrInvalidFile = errors.New("arquivo de importação inválido")
+
+// Ignore, como destino no mapeamento, descarta a coluna.
+const Ignore = "-"
+
+// Mapping liga o cabeçalho de uma coluna do CSV ao campo do lead
+// (BusinessName, CompanyRegistrationID, custom.segmento...).
+type Mapping map[string]string
+
+// RowError é um valor recusado numa linha. Row é a linha do arquivo,
+// contando o cabeçalho como linha 1.
+type RowError struct {
+	Row    int    `json:"row"`
+	Column string `json:"column,omitempty"`
+	Error  string `json:"error"`
+}
+
+// Reader lê um CSV de leads linha a linha, convertendo cada linha num
+// db.Lead com os campos validados e normalizados por leadfields.
+type Reader struct {
+	csv     *csv.Reader
+	header  []string
+	fields  []*leadfields.Field
+	columns map[string]string
+	ignored []string
+}
+
+// NewReader lê o cabeçalho e resolve o mapeamento. Colunas fora do
+// mapeamento cujo cabeçalho seja o nome ou o rótulo de um campo (sem
+// diferença de maiúsculas e acentos) são ligadas a ele; as demais são
+// ignoradas. Aceita UTF-8 (com ou sem BOM) ou Windows-1252, como o Excel
+// salva. delimiter 0 detecta entre ";", "," e tab pelo cabeçalho.
+func NewReader(r io.Reader, delimiter rune, mapping Mapping, custom []db.CustomFieldDefinition) (*Reader, error) {
+	br := bufio.NewReaderSize(r, 64*1024)
+	head, _ := br.Peek(64 * 1024)
+	var in io.Reader = br
+	if !validUTF8Prefix(head) {
+		in = charmap.Windows1252.NewDecoder().Reader(br)
+		head, _ = charmap.Windows1252.NewDecoder().Bytes(head)
+	}
+	head = bytes.TrimPrefix(head, []byte("\ufeff"))
+	if delimiter == 0 {
+		delimiter = detectDelimiter(head)
+	}
+
+	c := csv.NewReader(skipBOM(in))
+	c.Comma = delimiter
+	c.FieldsPerRecord = -1
+	c.LazyQuotes = true
+	c.TrimLeadingSpace = true
+	header, err := c.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: sem cabeçalho: %v", ErrInvalidFile, err)
+	}
+
+	rd := &Reader{csv: c, header: header, fields: make([]*leadfields.Field, len(header)), columns: make(Mapping)}
+	byHeader := make(map[string]int, len(header))
+	for i, h := range header {
+		header[i] = strings.TrimSpace(h)
+		byHeader[header[i]] = i
+	}
+	for column, target := range mapping {
+		i, ok := byHeader[column]
+		if !ok {
+			return nil, fmt.Errorf("%w: a coluna %q do mapeamento não está no arquivo", ErrInvalidFile, column)
+		}
+		if target == "" || target == Ignore {
+			rd.fields[i] = nil
+			continue
+		}
+		f, err := lookup(target, custom)
+		if err != nil {
+			return nil, err
+		}
+		rd.fields[i] = f
+	}
+
+	named := false
+	for i, h := range header {
+		if _, mapped := mapping[h]; !mapped && h != "" {
+			rd.fields[i] = guess(h, custom)
+		}
+		if rd.fields[i] == nil {
+			if h != "" {
+				rd.ignored = append(rd.ignored, h)
+			}
+			continue
+		}
+		for j := 0; j < i; j++ {
+			if rd.fields[j] != nil && rd.fields[j].Name == rd.fields[i].Name {
+				return nil, fmt.Errorf("%w: as colunas %q e %q vão para o mesmo campo %s", ErrInvalidFile, header[j], h, rd.fields[i].Name)
+			}
+		}
+		rd.columns[h] = rd.fields[i].Name
+		named = named || rd.fields[i].Name == "BusinessName" || rd.fields[i].Name == "RegisteredName"
+	}
+	if !named {
+		return nil, fmt.Errorf("%w: nenhuma coluna vai para BusinessName ou RegisteredName", ErrInvalidFile)
+	}
+	return rd, nil
+}
+
+// Columns devolve o mapeamento efetivo: cabeçalho → campo.
+func (r *Reader) Columns() Mapping {
+	return r.columns
+}
+
+// Ignored devolve os cabeçalhos das colunas que não serão importadas.
+func (r *Reader) Ignored() []string {
+	return r.ignored
+}
+
+// Next lê a próxima linha. Devolve o lead montado e a linha do arquivo; se
+// algum valor for recusado, o lead é nil e errs explica cada recusa. No fim
+// do arquivo devolve io.EOF.
+func (r *Reader) Next() (lead *db.Lead, row int, errs []RowError, err error) {
+	for {
+		record, err := r.csv.Read()
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				return nil, parseErr.StartLine, []RowError{{Row: parseErr.StartLine, Error: parseErr.Err.Error()}}, nil
+			}
+			return nil, 0, nil, err
+		}
+		row, _ = r.csv.FieldPos(0)
+		if blank(record) {
+			continue
+		}
+
+		lead = &db.Lead{}
+		for i, value := range record {
+			if i >= len(r.fields) || r.fields[i] == nil {
+				continue
+			}
+			if value = strings.TrimSpace(value); value == "" {
+				continue
+			}
+			f := r.fields[i]
+			parsed, ignore, err := f.Parse(convert(f.Type, value))
+			if err != nil {
+				errs = append(errs, RowError{Row: row, Column: r.header[i], Error: err.Error()})
+				continue
+			}
+			if !ignore {
+				f.Set(lead, parsed)
+			}
+		}
+		if lead.BusinessName == "" {
+			lead.BusinessName = lead.RegisteredName
+		}
+		if lead.BusinessName == "" && len(errs) == 0 {
+			errs = append(errs, RowError{Row: row, Error: "linha sem nome fantasia nem razão social"})
+		}
+		if len(errs) > 0 {
+			return nil, row, errs, nil
+		}
+		return lead, row, nil, nil
+	}
+}
+
+func lookup(name string, custom []db.CustomFieldDefinition) (*leadfields.Field, error) {
+	if key := strings.TrimPrefix(name, leadfields.CustomPrefix); key != name {
+		for _, d := range custom {
+			if d.Key == key {
+				return leadfields.Custom(d), nil
+			}
+		}
+		return nil, fmt.Errorf("%w: %s não é um campo personalizado do workspace", ErrInvalidFile, name)
+	}
+	if f, ok := leadfields.Lookup(name); ok {
+		return f, nil
+	}
+	return nil, fmt.Errorf("%w: campo %q desconhecido", ErrInvalidFile, name)
+}
+
+// guess liga o cabeçalho ao campo com o mesmo nome ou rótulo.
+func guess(header string, custom []db.CustomFieldDefinition) *leadfields.Field {
+	h := textnorm.Fold(header)
+	for _, f := range leadfields.All() {
+		if textnorm.Fold(f.Name) == h || textnorm.Fold(f.Label) == h {
+			return f
+		}
+	}
+	for _, d := range custom {
+		if textnorm.Fold(d.Key) == h || textnorm.Fold(d.Label) == h || textnorm.Fold(leadfields.CustomPrefix+d.Key) == h {
+			return leadfields.Custom(d)
+		}
+	}
+	return nil
+}
+
+// convert transforma o texto da célula no tipo que Field.Parse espera.
+// Números aceitam o formato brasileiro (1.234,56) e datas dd/mm/aaaa.
+func convert(t leadfields.Type, value string) interface{} {
+	switch t {
+	case leadfields.TypeInteger, leadfields.TypeNumber:
+		if n, ok := parseNumber(value); ok {
+			return n
+		}
+	case leadfields.TypeDate:
+		if d, m, y, ok := splitDate(value); ok {
+			return fmt.Sprintf("%s-%s-%s", y, m, d)
+		}
+	case leadfields.TypeBoolean:
+		switch textnorm.Fold(value) {
+		case "sim", "s", "true", "1", "x", "yes":
+			return true
+		case "nao", "n", "false", "0", "no":
+			return false
+		}
+	}
+	return value
+}
+
+func parseNumber(s string) (float64, bool) {
+	s = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(s), "R$"))
+	s = strings.ReplaceAll(s, " ", "")
+	if strings.Contains(s, ",") {
+		s = strings.ReplaceAll(s, ".", "")
+		s = strings.Replace(s, ",", ".", 1)
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	return n, err == nil
+}
+
+func splitDate(s string) (day, month, year string, ok bool) {
+	parts := strings.Split(s, "/")
+	if len(parts) != 3 || len(parts[2]) != 4 {
+		return "", "", "", false
+	}
+	pad := func(p string) string {
+		if len(p) == 1 {
+			return "0" + p
+		}
+		return p
+	}
+	return pad(parts[0]), pad(parts[1]), parts[2], true
+}
+
+func blank(record []string) bool {
+	for _, v := range record {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// validUTF8Prefix diz se o começo do arquivo é UTF-8, tolerando um caractere
+// cortado no fim do trecho lido.
+func validUTF8Prefix(b []byte) bool {
+	for i := 0; i < utf8.UTFMax && len(b) > 0; i++ {
+		if utf8.Valid(b) {
+			return true
+		}
+		b = b[:len(b)-1]
+	}
+	return utf8.Valid(b)
+}
+
+func detectDelimiter(head []byte) rune {
+	line := head
+	if i := bytes.IndexByte(head, '\n'); i >= 0 {
+		line = head[:i]
+	}
+	best, count := ',', bytes.Count(line, []byte(","))
+	for _, d := range []rune{';', '\t'} {
+		if n := bytes.Count(line, []byte(string(d))); n > count {
+			best, count = d, n
+		}
+	}
+	return best
+}
+
+func skipBOM(r io.Reader) io.Reader {
+	br := bufio.NewReader(r)
+	if b, err := br.Peek(3); err == nil && bytes.Equal(b, []byte("\ufeff")) {
+		br.Discard(3)
+	}
+	return br
+}
@@ -0,0 +1,136 @@
+package leadimport
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/text/encoding/charmap"
+
+	"github.com/wbrunovieira/LeadSearchVersion2/db"
+)
+
+var testDefinitions = []db.CustomFieldDefinition{
+	{Key: "segmento", Label: "Segmento", Type: db.CustomFieldEnum, Options: []string{"Varejo", "Atacado"}},
+}
+
+type result struct {
+	lead *db.Lead
+	row  int
+	errs []RowError
+}
+
+func readAll(t *testing.T, r *Reader) []result {
+	t.Helper()
+	var results []result
+	for {
+		lead, row, errs, err := r.Next()
+		if err == io.EOF {
+			return results
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		results = append(results, result{lead, row, errs})
+	}
+}
+
+func TestReader(t *testing.T) {
+	data := "Empresa;CNPJ;Telefone;Cidade;Data de fundação;capital social;Segmento;Observação\n" +
+		"Padaria São João;12.345.678/0001-95;(11) 3333-4444;São Paulo;07/03/2010;1.500,50;varejo;ligar cedo\n" +
+		"\n" +
+		"Mercado Central;123;(11) 91234-5678;Santos;;;;\n" +
+		";;;Curitiba;;;;\n"
+	r, err := NewReader(strings.NewReader("\ufeff"+data), 0, Mapping{"Empresa": "BusinessName", "CNPJ": "CompanyRegistrationID"}, testDefinitions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantColumns := Mapping{
+		"Empresa": "BusinessName", "CNPJ": "CompanyRegistrationID", "Telefone": "Phone", "Cidade": "City",
+		"Data de fundação": "FoundationDate", "capital social": "EquityCapital", "Segmento": "custom.segmento",
+	}
+	if !reflect.DeepEqual(r.Columns(), wantColumns) {
+		t.Errorf("Columns = %v, want %v", r.Columns(), wantColumns)
+	}
+	if !reflect.DeepEqual(r.Ignored(), []string{"Observação"}) {
+		t.Errorf("Ignored = %v", r.Ignored())
+	}
+
+	results := readAll(t, r)
+	if len(results) != 3 {
+		t.Fatalf("got %d rows, want 3", len(results))
+	}
+
+	lead := results[0].lead
+	if lead == nil || results[0].row != 2 {
+		t.Fatalf("row 2 = %+v", results[0])
+	}
+	if lead.BusinessName != "Padaria São João" || lead.CompanyRegistrationID != "12345678000195" ||
+		lead.Phone != "+551133334444" || lead.City != "São Paulo" || lead.EquityCapital != 1500.5 ||
+		!lead.FoundationDate.Time.Equal(time.Date(2010, 3, 7, 0, 0, 0, 0, time.UTC)) ||
+		lead.CustomFields["segmento"] != "Varejo" {
+		t.Errorf("lead = %+v", lead)
+	}
+
+	if results[1].lead != nil || results[1].row != 4 || len(results[1].errs) != 1 || results[1].errs[0].Column != "CNPJ" {
+		t.Errorf("row 4 = %+v", results[1])
+	}
+	if results[2].lead != nil || results[2].row != 5 || len(results[2].errs) != 1 {
+		t.Errorf("row 5 = %+v", results[2])
+	}
+}
+
+func TestReaderWindows1252(t *testing.T) {
+	data, err := charmap.Windows1252.NewEncoder().String("Razão social,Cidade\nAçougue Irmãos,Goiânia\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewReader(strings.NewReader(data), 0, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := readAll(t, r)
+	if len(results) != 1 || results[0].lead == nil {
+		t.Fatalf("results = %+v", results)
+	}
+	// Sem nome fantasia, a razão social vira o nome.
+	if l := results[0].lead; l.BusinessName != "Açougue Irmãos" || l.RegisteredName != "Açougue Irmãos" || l.City != "Goiânia" {
+		t.Errorf("lead = %+v", l)
+	}
+}
+
+func TestReaderInvalidMapping(t *testing.T) {
+	cases := []struct {
+		data    string
+		mapping Mapping
+	}{
+		{"Nome,Cidade\n", Mapping{"Fone": "Phone"}},
+		{"Nome,Cidade\n", Mapping{"Nome": "BusinessName", "Cidade": "Inexistente"}},
+		{"Nome,Cidade\n", Mapping{"Nome": "BusinessName", "Cidade": "custom.nao_existe"}},
+		{"Cidade,Estado\n", nil},
+		{"Nome fantasia,Empresa\n", Mapping{"Empresa": "BusinessName"}},
+	}
+	for _, c := range cases {
+		if _, err := NewReader(strings.NewReader(c.data), 0, c.mapping, testDefinitions); !errors.Is(err, ErrInvalidFile) {
+			t.Errorf("NewReader(%q, %v) error = %v, want ErrInvalidFile", c.data, c.mapping, err)
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	if n, ok := parseNumber("R$ 1.234.567,89"); !ok || n != 1234567.89 {
+		t.Errorf("parseNumber = %v, %v", n, ok)
+	}
+	if n, ok := parseNumber("1234.5"); !ok || n != 1234.5 {
+		t.Errorf("parseNumber = %v, %v", n, ok)
+	}
+	if got := convert("date", "1/2/2020"); got != "2020-02-01" {
+		t.Errorf("convert date = %v", got)
+	}
+	if got := detectDelimiter([]byte("a\tb\tc\n1,2")); got != '\t' {
+		t.Errorf("detectDelimiter = %q", got)
+	}
+}
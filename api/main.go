@@ -6,11 +6,18 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"time"
 
+	"github.com/wbrunovieira/LeadSearchVersion2/completeness"
 	"github.com/wbrunovieira/LeadSearchVersion2/db"
 	"github.com/wbrunovieira/LeadSearchVersion2/handlers"
+	"github.com/wbrunovieira/LeadSearchVersion2/integrations"
 	"github.com/wbrunovieira/LeadSearchVersion2/middleware"
+	"github.com/wbrunovieira/LeadSearchVersion2/progress"
 	"github.com/wbrunovieira/LeadSearchVersion2/rabbitmq"
+	"github.com/wbrunovieira/LeadSearchVersion2/shared/auth"
+	"github.com/wbrunovieira/LeadSearchVersion2/shared/ratelimit"
 )
 
 func main() {
@@ -22,6 +29,14 @@ func main() {
 	}
 	fmt.Println("API rodando na porta", port)
 
+	completenessConfig, err := completeness.FromEnv()
+	if err != nil {
+		log.Fatalf("Erro ao configurar a completude dos leads: %v", err)
+	}
+	if err := db.SetCompleteness(completenessConfig); err != nil {
+		log.Fatalf("Erro ao configurar a completude dos leads: %v", err)
+	}
+
 	if err := db.Connect(); err != nil {
 		log.Fatalf("Erro ao conectar ao banco de dados: %v", err)
 	}
@@ -39,14 +54,132 @@ func main() {
 	}
 	defer rabbitmq.CloseRabbitMQ()
 
+	authenticator, err := auth.FromEnv()
+	if err != nil {
+		log.Fatalf("Erro ao configurar autenticação: %v", err)
+	}
+	if !authenticator.Configured() {
+		log.Println("AUTH_API_KEYS e AUTH_JWT_SECRET vazios: todas as rotas protegidas vão recusar acesso")
+	}
+	if err := db.EnsureBootstrapAdmin(os.Getenv("AUTH_BOOTSTRAP_ADMIN")); err != nil {
+		log.Fatalf("Erro ao criar o administrador inicial: %v", err)
+	}
+	tokenTTL, err := tokenTTLFromEnv()
+	if err != nil {
+		log.Fatalf("Erro ao configurar autenticação: %v", err)
+	}
+	loginLimits, err := loginLimitsFromEnv()
+	if err != nil {
+		log.Fatalf("Erro ao configurar autenticação: %v", err)
+	}
+	viewer := func(h http.HandlerFunc) http.HandlerFunc {
+		return middleware.Require(authenticator, h, auth.RoleViewer)
+	}
+	service := func(h http.HandlerFunc) http.HandlerFunc {
+		return middleware.Require(authenticator, h, auth.RoleService)
+	}
+
 	mux := http.NewServeMux()
-	mux.HandleFunc("/save-leads", handlers.SaveLeadsHandler)
-	mux.HandleFunc("/list-leads", handlers.ListLeadsHandler)
+	mux.HandleFunc("/save-leads", service(handlers.SaveLeadsHandler))
+	mux.HandleFunc("/list-leads", viewer(handlers.ListLeadsHandler))
+	mux.HandleFunc("/export-leads", viewer(handlers.ExportLeadsHandler))
+	mux.HandleFunc("/import-leads", middleware.Require(authenticator, handlers.ImportLeadsHandler, auth.RoleSales))
 	mux.HandleFunc("/health", handlers.HealthHandler)
-	mux.HandleFunc("/update-lead-field", handlers.UpdateLeadHandler)
+	mux.HandleFunc("/login", handlers.LoginHandler(authenticator, tokenTTL, loginLimits))
+	mux.HandleFunc("/users", middleware.Require(authenticator, handlers.UsersHandler, auth.RoleAdmin))
+	mux.HandleFunc("/update-lead-field", middleware.Require(authenticator, handlers.UpdateLeadHandler, auth.RoleSales, auth.RoleService))
+	mux.HandleFunc("/lead-fields", viewer(handlers.LeadFieldsHandler))
+	mux.HandleFunc("/custom-fields", middleware.RequireByMethod(authenticator,
+		map[string]auth.Role{http.MethodGet: auth.RoleViewer}, handlers.CustomFieldsHandler, auth.RoleAdmin))
+	mux.HandleFunc("/lead-phones", middleware.RequireByMethod(authenticator,
+		map[string]auth.Role{http.MethodGet: auth.RoleViewer}, handlers.LeadPhonesHandler, auth.RoleSales, auth.RoleService))
+	mux.HandleFunc("/lead-social-profiles", middleware.RequireByMethod(authenticator,
+		map[string]auth.Role{http.MethodGet: auth.RoleViewer}, handlers.LeadSocialProfilesHandler, auth.RoleSales, auth.RoleService))
+	mux.HandleFunc("/domain-duplicates", viewer(handlers.DomainDuplicatesHandler))
+	mux.HandleFunc("/duplicates", viewer(handlers.DuplicatesHandler))
+	mux.HandleFunc("/duplicates/scan", middleware.Require(authenticator, handlers.DuplicateScanHandler, auth.RoleAdmin))
+	mux.HandleFunc("/duplicates/dismiss", middleware.Require(authenticator, handlers.DuplicateDismissHandler, auth.RoleSales))
+	mux.HandleFunc("/duplicates/merge", middleware.Require(authenticator, handlers.MergeLeadsHandler, auth.RoleSales))
+	mux.HandleFunc("/lead-merges", viewer(handlers.LeadMergesHandler))
+	mux.HandleFunc("/lead-completeness", viewer(handlers.LeadCompletenessHandler))
+	mux.HandleFunc("/lead-icp-scores", viewer(handlers.LeadICPScoresHandler))
+	mux.HandleFunc("/lead-stage", middleware.Require(authenticator, handlers.LeadStageHandler, auth.RoleSales))
+	mux.HandleFunc("/lead-assign", middleware.Require(authenticator, handlers.LeadAssignHandler, auth.RoleSales))
+	mux.HandleFunc("/lead-activities", middleware.RequireByMethod(authenticator,
+		map[string]auth.Role{http.MethodGet: auth.RoleViewer}, handlers.LeadActivitiesHandler, auth.RoleSales))
+	mux.HandleFunc("/pipeline-report", viewer(handlers.PipelineReportHandler))
+	mux.HandleFunc("/icp-rules", middleware.RequireByMethod(authenticator,
+		map[string]auth.Role{http.MethodGet: auth.RoleViewer}, handlers.ICPRulesHandler, auth.RoleAdmin))
+	mux.HandleFunc("/icp-rules/versions", viewer(handlers.ICPRuleVersionsHandler))
+	mux.HandleFunc("/icp-rules/activate", middleware.Require(authenticator, handlers.ICPActivateHandler, auth.RoleAdmin))
+	mux.HandleFunc("/lead-place-details", viewer(handlers.LeadPlaceDetailsHandler))
+	mux.HandleFunc("/known-places", service(handlers.KnownPlacesHandler))
+	mux.HandleFunc("/stale-places", service(handlers.StalePlacesHandler))
+	mux.HandleFunc("/refresh-leads", service(handlers.RefreshLeadsHandler))
+	mux.HandleFunc("/refresh-leads/failures", service(handlers.RefreshFailuresHandler))
+	mux.HandleFunc("/saved-searches", middleware.RequireByMethod(authenticator,
+		map[string]auth.Role{http.MethodGet: auth.RoleViewer}, handlers.SavedSearchesHandler, auth.RoleAdmin))
+	mux.HandleFunc("/saved-searches/claim-due", service(handlers.ClaimDueSavedSearchesHandler))
+	mux.HandleFunc("/saved-searches/run", service(handlers.SavedSearchRunHandler))
+	mux.HandleFunc("/saved-searches/new-leads", viewer(handlers.SavedSearchNewLeadsHandler))
+	mux.HandleFunc("/integrations", middleware.Require(authenticator, handlers.IntegrationsHandler, auth.RoleAdmin))
+	mux.HandleFunc("/integrations/test", middleware.Require(authenticator, handlers.IntegrationTestHandler, auth.RoleAdmin))
+	mux.HandleFunc("/integration-deliveries", viewer(handlers.IntegrationDeliveriesHandler))
+	mux.HandleFunc("/integration-deliveries/retry", middleware.Require(authenticator, handlers.IntegrationDeliveryRetryHandler, auth.RoleAdmin))
+	mux.HandleFunc("/search-progress", middleware.RequireStream(authenticator, handlers.SearchProgressHandler, auth.RoleViewer))
+	mux.HandleFunc("/workspaces", middleware.RequireByMethod(authenticator,
+		map[string]auth.Role{http.MethodGet: auth.RoleViewer}, handlers.WorkspacesHandler, auth.RoleAdmin))
+
+	if err := rabbitmq.ConsumeLeadEvents(integrations.HandleLeadEvent); err != nil {
+		log.Fatalf("Erro ao consumir os eventos de lead: %v", err)
+	}
+	integrations.StartWorker()
+	if err := progress.Start(); err != nil {
+		log.Fatalf("Erro ao assinar o progresso das buscas: %v", err)
+	}
 
 	handler := middleware.CORS(mux)
 
 	log.Println("Starting server on port", port)
 	log.Fatal(http.ListenAndServe(":"+port, handler))
 }
+
+// tokenTTLFromEnv lê AUTH_TOKEN_TTL_MINUTES, a validade dos JWTs emitidos no
+// login. O padrão é uma hora.
+func tokenTTLFromEnv() (time.Duration, error) {
+	v := os.Getenv("AUTH_TOKEN_TTL_MINUTES")
+	if v == "" {
+		return time.Hour, nil
+	}
+	minutes, err := strconv.Atoi(v)
+	if err != nil || minutes <= 0 {
+		return 0, fmt.Errorf("AUTH_TOKEN_TTL_MINUTES inválido: %q", v)
+	}
+	return time.Duration(minutes) * time.Minute, nil
+}
+
+// loginLimitsFromEnv lê LOGIN_RATE_PER_MINUTE e LOGIN_RATE_BURST, as
+// tentativas de login por minuto e a rajada permitidas por IP e por e-mail
+// (padrão 5 e 5; 0 desliga), e TRUST_PROXY_HEADERS.
+func loginLimitsFromEnv() (handlers.LoginLimits, error) {
+	perMinute, burst := 5.0, 5
+	if v := os.Getenv("LOGIN_RATE_PER_MINUTE"); v != "" {
+		parsed, err := strconv.ParseFloat(v, 64)
+		if err != nil || parsed < 0 {
+			return handlers.LoginLimits{}, fmt.Errorf("LOGIN_RATE_PER_MINUTE inválido: %q", v)
+		}
+		perMinute = parsed
+	}
+	if v := os.Getenv("LOGIN_RATE_BURST"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed < 0 {
+			return handlers.LoginLimits{}, fmt.Errorf("LOGIN_RATE_BURST inválido: %q", v)
+		}
+		burst = parsed
+	}
+	log.Printf("Limite de login: %.1f tentativas/min (rajada %d) por IP e por e-mail", perMinute, burst)
+	return handlers.LoginLimits{
+		Limiter:    ratelimit.NewLimiter(perMinute, burst),
+		TrustProxy: os.Getenv("TRUST_PROXY_HEADERS") == "true",
+	}, nil
+}
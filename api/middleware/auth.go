@@ -0,0 +1,62 @@
+// middleware/auth.go
+package middleware
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/wbrunovieira/LeadSearchVersion2/db"
+	"github.com/wbrunovieira/LeadSearchVersion2/shared/auth"
+)
+
+// Require só deixa passar requisições autenticadas com algum dos papéis e
+// coloca no contexto o principal e o escopo de workspace (db.ScopeFromContext).
+func Require(a *auth.Authenticator, next http.HandlerFunc, roles ...auth.Role) http.HandlerFunc {
+	return RequireByMethod(a, nil, next, roles...)
+}
+
+// RequireByMethod é como Require, mas permite exigir outro papel para certos
+// métodos HTTP (por exemplo, GET para viewer e o resto para admin).
+func RequireByMethod(a *auth.Authenticator, byMethod map[string]auth.Role, next http.HandlerFunc, roles ...auth.Role) http.HandlerFunc {
+	return require(a.Authenticate, byMethod, next, roles...)
+}
+
+// RequireStream é como Require, mas também aceita a credencial em
+// ?access_token=, para rotas de server-sent events.
+func RequireStream(a *auth.Authenticator, next http.HandlerFunc, roles ...auth.Role) http.HandlerFunc {
+	return require(a.AuthenticateStream, nil, next, roles...)
+}
+
+func require(authenticate func(*http.Request) (auth.Principal, error), byMethod map[string]auth.Role, next http.HandlerFunc, roles ...auth.Role) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		required := roles
+		if methodRole, ok := byMethod[r.Method]; ok {
+			required = []auth.Role{methodRole}
+		}
+
+		principal, err := authenticate(r)
+		if err != nil {
+			log.Printf("Acesso negado a %s %s de %s: %v", r.Method, r.URL.Path, r.RemoteAddr, err)
+			if errors.Is(err, auth.ErrMissingCredentials) {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="leads"`)
+			}
+			http.Error(w, "Não autenticado", http.StatusUnauthorized)
+			return
+		}
+		if !principal.CanAny(required...) {
+			log.Printf("Acesso negado a %s %s para %s (papel %s, exige %v)", r.Method, r.URL.Path, principal.Name, principal.Role, required)
+			http.Error(w, "Sem permissão", http.StatusForbidden)
+			return
+		}
+		scope, status, err := resolveScope(r, principal)
+		if err != nil {
+			log.Printf("Workspace recusado para %s em %s %s: %v", principal.Name, r.Method, r.URL.Path, err)
+			http.Error(w, err.Error(), status)
+			return
+		}
+
+		ctx := auth.WithPrincipal(r.Context(), principal)
+		next(w, r.WithContext(db.WithScope(ctx, scope)))
+	}
+}
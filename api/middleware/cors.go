@@ -1,13 +1,40 @@
 // middleware/cors.go
 package middleware
 
-import "net/http"
+import (
+	"net/http"
+	"os"
+	"strings"
+)
 
+// defaultCORSOrigins é o front-web rodando no docker-compose.
+const defaultCORSOrigins = "http://localhost:5173"
+
+// CORS libera as origens de CORS_ALLOWED_ORIGINS (lista separada por vírgula;
+// "*" libera todas).
 func CORS(next http.Handler) http.Handler {
+	config := os.Getenv("CORS_ALLOWED_ORIGINS")
+	if config == "" {
+		config = defaultCORSOrigins
+	}
+	allowed := make(map[string]bool)
+	for _, origin := range strings.Split(config, ",") {
+		if origin = strings.TrimRight(strings.TrimSpace(origin), "/"); origin != "" {
+			allowed[origin] = true
+		}
+	}
+
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		origin := r.Header.Get("Origin")
+		switch {
+		case allowed["*"]:
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		case origin != "" && allowed[origin]:
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Add("Vary", "Origin")
+		}
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key, X-Workspace")
 		if r.Method == http.MethodOptions {
 			return
 		}
@@ -0,0 +1,52 @@
+// middleware/workspace.go
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/wbrunovieira/LeadSearchVersion2/db"
+	"github.com/wbrunovieira/LeadSearchVersion2/shared/auth"
+)
+
+// WorkspaceHeader escolhe o workspace da requisição (slug ou ID). Também é
+// aceito o parâmetro ?workspace=.
+const WorkspaceHeader = "X-Workspace"
+
+// resolveScope decide em que workspace a requisição opera:
+//   - o informado no cabeçalho, se o principal tiver acesso a ele;
+//   - sem cabeçalho, o único workspace do principal, se ele tiver só um;
+//   - sem cabeçalho, todos os workspaces para service sem restrição (as rotas
+//     de serviço atuam sobre leads por ID);
+//   - senão, o workspace padrão.
+func resolveScope(r *http.Request, principal auth.Principal) (db.Scope, int, error) {
+	ref := strings.TrimSpace(r.Header.Get(WorkspaceHeader))
+	if ref == "" {
+		ref = strings.TrimSpace(r.URL.Query().Get("workspace"))
+	}
+	if ref == "" {
+		switch {
+		case len(principal.Workspaces) == 1:
+			ref = principal.Workspaces[0]
+		case len(principal.Workspaces) > 1:
+			return db.Scope{}, http.StatusBadRequest, fmt.Errorf("informe o workspace no cabeçalho %s", WorkspaceHeader)
+		case principal.Role == auth.RoleService:
+			return db.AllWorkspaces(), 0, nil
+		default:
+			ref = db.DefaultWorkspaceSlug
+		}
+	}
+
+	ws, err := db.GetWorkspace(ref)
+	if err != nil {
+		return db.Scope{}, http.StatusInternalServerError, fmt.Errorf("falha ao buscar workspace: %v", err)
+	}
+	if ws == nil {
+		return db.Scope{}, http.StatusNotFound, fmt.Errorf("workspace %q não encontrado", ref)
+	}
+	if !principal.CanAccessWorkspace(ws.Slug) {
+		return db.Scope{}, http.StatusForbidden, fmt.Errorf("sem acesso ao workspace %q", ws.Slug)
+	}
+	return db.InWorkspace(ws.ID), 0, nil
+}
@@ -0,0 +1,313 @@
+// /api/progress/progress.go
+package progress
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/wbrunovieira/LeadSearchVersion2/db"
+	"github.com/wbrunovieira/LeadSearchVersion2/rabbitmq"
+)
+
+// Event é um passo de um job de busca enviado ao stream: o progresso da
+// busca no search-google (search.*) ou o enriquecimento de um lead do job
+// (lead.*). ID cresce a cada evento e serve de Last-Event-ID.
+type Event struct {
+	ID          uint64                 `json:"id"`
+	Event       string                 `json:"event"`
+	JobID       string                 `json:"job_id"`
+	LeadID      string                 `json:"lead_id,omitempty"`
+	WorkspaceID uuid.UUID              `json:"workspace_id"`
+	OccurredAt  time.Time              `json:"occurred_at"`
+	Details     map[string]interface{} `json:"details,omitempty"`
+}
+
+var errWorkspaceNotFound = errors.New("workspace não encontrado")
+
+type job struct {
+	events  []Event
+	subs    map[chan Event]struct{}
+	updated time.Time
+}
+
+type leadJob struct {
+	jobID       string
+	workspaceID uuid.UUID
+	seen        time.Time
+}
+
+// Hub guarda os eventos recentes de cada job e os repassa a quem acompanha o
+// job. Um assinante que não der conta dos eventos tem o canal fechado e deve
+// reconectar com o último ID recebido.
+type Hub struct {
+	// Backlog é quantos eventos de cada job ficam guardados para quem
+	// conectar depois; TTL, por quanto tempo um job parado é lembrado.
+	Backlog int
+	TTL     time.Duration
+
+	// resolveLead e resolveWorkspace acham o job de um lead e o ID de um
+	// workspace; trocados nos testes.
+	resolveLead      func(leadID uuid.UUID) (jobID string, workspaceID uuid.UUID, err error)
+	resolveWorkspace func(ref string) (uuid.UUID, error)
+
+	mu         sync.Mutex
+	seq        uint64
+	jobs       map[string]*job
+	leads      map[string]leadJob
+	workspaces map[string]uuid.UUID
+	now        func() time.Time
+}
+
+// NewHub cria um Hub que guarda até 1000 eventos por job durante 2 horas.
+func NewHub() *Hub {
+	return &Hub{
+		Backlog:          1000,
+		TTL:              2 * time.Hour,
+		resolveLead:      leadJobFromDB,
+		resolveWorkspace: workspaceFromDB,
+		jobs:             map[string]*job{},
+		leads:            map[string]leadJob{},
+		workspaces:       map[string]uuid.UUID{},
+		now:              time.Now,
+	}
+}
+
+// Default é o Hub alimentado por Start e lido por /search-progress.
+var Default = NewHub()
+
+// pruneInterval é o intervalo com que Start limpa os jobs e leads parados.
+const pruneInterval = 5 * time.Minute
+
+// Start assina a exchange lead_events, alimenta Default e passa a limpá-lo a
+// cada pruneInterval.
+func Start() error {
+	if err := rabbitmq.SubscribeLeadEvents(Default.Handle); err != nil {
+		return err
+	}
+	Default.StartPruner(pruneInterval)
+	return nil
+}
+
+// Handle converte um evento do RabbitMQ e o publica no job dele. Eventos de
+// lead sem job (o marco chega só com o lead) usam o job que gravou o lead;
+// leads importados ou criados fora de uma busca são ignorados.
+func (h *Hub) Handle(e rabbitmq.LeadEvent) {
+	event := Event{Event: e.Event, JobID: e.JobID, LeadID: e.LeadID, OccurredAt: e.OccurredAt, Details: e.Details}
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = h.now()
+	}
+
+	switch {
+	case strings.HasPrefix(e.Event, "search."):
+		if e.JobID == "" {
+			return
+		}
+		ws, err := h.workspace(e.Workspace)
+		if err != nil {
+			log.Printf("Progresso: workspace %q do job %s: %v", e.Workspace, e.JobID, err)
+			return
+		}
+		event.WorkspaceID = ws
+
+	case strings.HasPrefix(e.Event, "lead."):
+		if e.LeadID == "" {
+			return
+		}
+		if e.JobID != "" {
+			ws, err := h.workspace(e.Workspace)
+			if err != nil {
+				log.Printf("Progresso: workspace %q do lead %s: %v", e.Workspace, e.LeadID, err)
+				return
+			}
+			event.WorkspaceID = ws
+			// A busca reenvia os leads já gravados; o lead entra no job uma vez.
+			if !h.remember(e.LeadID, e.JobID, ws) && e.Event == db.EventLeadSaved {
+				return
+			}
+		} else {
+			jobID, ws, ok := h.jobForLead(e.LeadID)
+			if !ok {
+				return
+			}
+			event.JobID, event.WorkspaceID = jobID, ws
+		}
+
+	default:
+		return
+	}
+	h.Publish(event)
+}
+
+// remember associa o lead ao job e diz se a associação é nova.
+func (h *Hub) remember(leadID, jobID string, workspaceID uuid.UUID) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	known, ok := h.leads[leadID]
+	h.leads[leadID] = leadJob{jobID: jobID, workspaceID: workspaceID, seen: h.now()}
+	return !ok || known.jobID != jobID
+}
+
+func (h *Hub) jobForLead(leadID string) (string, uuid.UUID, bool) {
+	h.mu.Lock()
+	known, ok := h.leads[leadID]
+	h.mu.Unlock()
+	if ok {
+		return known.jobID, known.workspaceID, known.jobID != ""
+	}
+
+	id, err := uuid.Parse(leadID)
+	if err != nil {
+		return "", uuid.Nil, false
+	}
+	jobID, ws, err := h.resolveLead(id)
+	if err != nil {
+		log.Printf("Progresso: erro ao buscar o job do lead %s: %v", leadID, err)
+		return "", uuid.Nil, false
+	}
+	// Lembra também os leads sem job, para não ir ao banco a cada marco.
+	h.remember(leadID, jobID, ws)
+	return jobID, ws, jobID != ""
+}
+
+func (h *Hub) workspace(ref string) (uuid.UUID, error) {
+	if ref == "" {
+		return db.DefaultWorkspaceID, nil
+	}
+	if id, err := uuid.Parse(ref); err == nil {
+		return id, nil
+	}
+	h.mu.Lock()
+	id, ok := h.workspaces[ref]
+	h.mu.Unlock()
+	if ok {
+		return id, nil
+	}
+	id, err := h.resolveWorkspace(ref)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	h.mu.Lock()
+	h.workspaces[ref] = id
+	h.mu.Unlock()
+	return id, nil
+}
+
+// Publish numera o evento, guarda no job e o envia aos assinantes.
+func (h *Hub) Publish(e Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.seq++
+	e.ID = h.seq
+	j := h.jobs[e.JobID]
+	if j == nil {
+		j = &job{subs: map[chan Event]struct{}{}}
+		h.jobs[e.JobID] = j
+	}
+	j.updated = h.now()
+	j.events = append(j.events, e)
+	if len(j.events) > h.Backlog {
+		j.events = j.events[len(j.events)-h.Backlog:]
+	}
+	for ch := range j.subs {
+		select {
+		case ch <- e:
+		default:
+			delete(j.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// Subscribe devolve os eventos guardados do job com ID maior que after e um
+// canal com os próximos. cancel encerra a assinatura.
+func (h *Hub) Subscribe(jobID string, after uint64) (backlog []Event, events <-chan Event, cancel func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	j := h.jobs[jobID]
+	if j == nil {
+		j = &job{subs: map[chan Event]struct{}{}, updated: h.now()}
+		h.jobs[jobID] = j
+	}
+	for _, e := range j.events {
+		if e.ID > after {
+			backlog = append(backlog, e)
+		}
+	}
+	ch := make(chan Event, 64)
+	j.subs[ch] = struct{}{}
+	return backlog, ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if _, ok := j.subs[ch]; ok {
+			delete(j.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// StartPruner roda Prune a cada interval, fora do caminho de Publish, até
+// stop ser chamado.
+func (h *Hub) StartPruner(interval time.Duration) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				h.Prune()
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			ticker.Stop()
+			close(done)
+		})
+	}
+}
+
+// Prune esquece os jobs sem assinantes e os leads parados há mais de TTL.
+func (h *Hub) Prune() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	limit := h.now().Add(-h.TTL)
+	for id, j := range h.jobs {
+		if len(j.subs) == 0 && j.updated.Before(limit) {
+			delete(h.jobs, id)
+		}
+	}
+	for id, l := range h.leads {
+		if l.seen.Before(limit) {
+			delete(h.leads, id)
+		}
+	}
+}
+
+func leadJobFromDB(leadID uuid.UUID) (string, uuid.UUID, error) {
+	lead, err := db.GetLeadByID(db.AllWorkspaces(), leadID)
+	if err != nil || lead == nil {
+		return "", uuid.Nil, err
+	}
+	return lead.SearchJobID, lead.WorkspaceID, nil
+}
+
+func workspaceFromDB(ref string) (uuid.UUID, error) {
+	ws, err := db.GetWorkspace(ref)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if ws == nil {
+		return uuid.Nil, errWorkspaceNotFound
+	}
+	return ws.ID, nil
+}
@@ -0,0 +1,190 @@
+package progress
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/wbrunovieira/LeadSearchVersion2/db"
+	"github.com/wbrunovieira/LeadSearchVersion2/rabbitmq"
+)
+
+var testWorkspace = uuid.MustParse("0b7e6a1c-3f2d-4c5b-8a9e-1d2c3b4a5f60")
+
+func testHub(t *testing.T) *Hub {
+	h := NewHub()
+	h.resolveLead = func(leadID uuid.UUID) (string, uuid.UUID, error) {
+		t.Errorf("resolveLead(%s) chamado", leadID)
+		return "", uuid.Nil, nil
+	}
+	h.resolveWorkspace = func(ref string) (uuid.UUID, error) {
+		if ref != "acme" {
+			return uuid.Nil, errWorkspaceNotFound
+		}
+		return testWorkspace, nil
+	}
+	return h
+}
+
+func names(events []Event) []string {
+	var out []string
+	for _, e := range events {
+		out = append(out, e.Event)
+	}
+	return out
+}
+
+func TestBacklogReplay(t *testing.T) {
+	h := testHub(t)
+	h.Handle(rabbitmq.LeadEvent{Event: "search.started", JobID: "job-1", Workspace: "acme"})
+	h.Handle(rabbitmq.LeadEvent{Event: "search.started", JobID: "job-2"})
+	h.Handle(rabbitmq.LeadEvent{Event: "search.places_found", JobID: "job-1", Workspace: "acme"})
+
+	backlog, _, cancel := h.Subscribe("job-1", 0)
+	defer cancel()
+	if got := names(backlog); len(got) != 2 || got[0] != "search.started" || got[1] != "search.places_found" {
+		t.Fatalf("backlog = %v", got)
+	}
+	if backlog[0].WorkspaceID != testWorkspace {
+		t.Errorf("workspace = %s, want %s", backlog[0].WorkspaceID, testWorkspace)
+	}
+
+	after, _, cancel2 := h.Subscribe("job-1", backlog[0].ID)
+	defer cancel2()
+	if got := names(after); len(got) != 1 || got[0] != "search.places_found" {
+		t.Errorf("backlog depois de %d = %v", backlog[0].ID, got)
+	}
+
+	other, _, cancel3 := h.Subscribe("job-2", 0)
+	defer cancel3()
+	if len(other) != 1 || other[0].WorkspaceID != db.DefaultWorkspaceID {
+		t.Errorf("job-2 = %+v", other)
+	}
+
+	h.Handle(rabbitmq.LeadEvent{Event: "search.started", JobID: "job-3", Workspace: "inexistente"})
+	if missing, _, cancel4 := h.Subscribe("job-3", 0); len(missing) != 0 {
+		t.Errorf("evento de workspace inexistente publicado: %+v", missing)
+	} else {
+		cancel4()
+	}
+}
+
+func TestLeadEvents(t *testing.T) {
+	h := testHub(t)
+	_, events, cancel := h.Subscribe("job-1", 0)
+	defer cancel()
+
+	lead := uuid.New().String()
+	h.Handle(rabbitmq.LeadEvent{Event: db.EventLeadSaved, LeadID: lead, JobID: "job-1", Workspace: "acme"})
+	// A busca reenvia o lead; o segundo lead.saved não aparece.
+	h.Handle(rabbitmq.LeadEvent{Event: db.EventLeadSaved, LeadID: lead, JobID: "job-1", Workspace: "acme"})
+	h.Handle(rabbitmq.LeadEvent{Event: db.EventLeadWebEnriched, LeadID: lead})
+	h.Handle(rabbitmq.LeadEvent{Event: db.EventLeadIndexed, LeadID: lead})
+
+	var got []Event
+	for len(events) > 0 {
+		got = append(got, <-events)
+	}
+	want := []string{db.EventLeadSaved, db.EventLeadWebEnriched, db.EventLeadIndexed}
+	if n := names(got); len(n) != len(want) || n[0] != want[0] || n[1] != want[1] || n[2] != want[2] {
+		t.Fatalf("eventos = %v, want %v", n, want)
+	}
+	for _, e := range got {
+		if e.JobID != "job-1" || e.LeadID != lead || e.WorkspaceID != testWorkspace {
+			t.Errorf("evento %+v", e)
+		}
+	}
+}
+
+func TestLeadWithoutJob(t *testing.T) {
+	h := testHub(t)
+	calls := 0
+	h.resolveLead = func(uuid.UUID) (string, uuid.UUID, error) {
+		calls++
+		return "", testWorkspace, nil
+	}
+	lead := uuid.New().String()
+	h.Handle(rabbitmq.LeadEvent{Event: db.EventLeadWebEnriched, LeadID: lead})
+	h.Handle(rabbitmq.LeadEvent{Event: db.EventLeadIndexed, LeadID: lead})
+	if calls != 1 {
+		t.Errorf("resolveLead chamado %d vezes, want 1", calls)
+	}
+	if len(h.jobs) != 0 {
+		t.Errorf("jobs = %v", h.jobs)
+	}
+}
+
+func TestSlowSubscriber(t *testing.T) {
+	h := testHub(t)
+	_, events, cancel := h.Subscribe("job-1", 0)
+	defer cancel()
+	for i := 0; i < cap(events)+1; i++ {
+		h.Publish(Event{Event: "search.lead_found", JobID: "job-1"})
+	}
+	n := 0
+	for range events {
+		n++
+	}
+	if n != cap(events) {
+		t.Errorf("recebidos %d eventos antes do fechamento, want %d", n, cap(events))
+	}
+
+	h.Backlog = 10
+	h.Publish(Event{Event: "search.finished", JobID: "job-1"})
+	backlog, _, cancel2 := h.Subscribe("job-1", 0)
+	defer cancel2()
+	if len(backlog) != 10 || backlog[9].Event != "search.finished" {
+		t.Errorf("backlog com %d eventos, último %q", len(backlog), backlog[len(backlog)-1].Event)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	h := testHub(t)
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	h.now = func() time.Time { return now }
+	h.Handle(rabbitmq.LeadEvent{Event: "search.started", JobID: "parado", Workspace: "acme"})
+	h.Handle(rabbitmq.LeadEvent{Event: "lead.saved", LeadID: uuid.NewString(), JobID: "parado", Workspace: "acme"})
+	_, _, cancel := h.Subscribe("assistido", 0)
+	defer cancel()
+
+	now = now.Add(h.TTL + time.Minute)
+	h.Handle(rabbitmq.LeadEvent{Event: "search.started", JobID: "novo", Workspace: "acme"})
+	if len(h.jobs) != 3 {
+		t.Fatalf("Publish não deve limpar jobs; jobs = %v", h.jobs)
+	}
+
+	h.Prune()
+	if _, ok := h.jobs["parado"]; ok {
+		t.Error("job parado não foi esquecido")
+	}
+	if _, ok := h.jobs["assistido"]; !ok {
+		t.Error("job com assinante foi esquecido")
+	}
+	if _, ok := h.jobs["novo"]; !ok {
+		t.Error("job recente foi esquecido")
+	}
+	if len(h.leads) != 0 {
+		t.Errorf("leads = %v", h.leads)
+	}
+}
+
+func TestStartPruner(t *testing.T) {
+	h := testHub(t)
+	h.TTL = 0
+	h.Publish(Event{Event: "search.started", JobID: "job-1"})
+	stop := h.StartPruner(time.Millisecond)
+	defer stop()
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		h.mu.Lock()
+		n := len(h.jobs)
+		h.mu.Unlock()
+		if n == 0 {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Error("o pruner não limpou o job")
+}
@@ -0,0 +1,114 @@
+// /api/rabbitmq/events.go
+package rabbitmq
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/wbrunovieira/LeadSearchVersion2/shared/leadevents"
+)
+
+// LeadEventsExchange é a exchange topic em que os serviços anunciam os marcos
+// do enriquecimento e o progresso das buscas; ver shared/leadevents.
+const LeadEventsExchange = leadevents.Exchange
+
+// leadEventsQueue é a fila da API na exchange lead_events.
+const leadEventsQueue = "api_lead_events"
+
+// LeadEvent é a mensagem publicada em lead_events.
+type LeadEvent = leadevents.Event
+
+func declareLeadEvents() error {
+	return leadevents.Declare(ch)
+}
+
+// ConsumeLeadEvents entrega a handle cada evento publicado em lead_events.
+// Mensagem ilegível é descartada; erro de handle devolve a mensagem para a
+// fila.
+func ConsumeLeadEvents(handle func(LeadEvent) error) error {
+	consumer, err := conn.Channel()
+	if err != nil {
+		return fmt.Errorf("failed to open channel: %v", err)
+	}
+	if _, err := consumer.QueueDeclare(leadEventsQueue, true, false, false, false, nil); err != nil {
+		return fmt.Errorf("failed to declare queue: %v", err)
+	}
+	if err := consumer.QueueBind(leadEventsQueue, "lead.#", LeadEventsExchange, false, nil); err != nil {
+		return fmt.Errorf("failed to bind queue: %v", err)
+	}
+	msgs, err := consumer.Consume(leadEventsQueue, "", false, false, false, false, nil)
+	if err != nil {
+		return fmt.Errorf("failed to consume queue: %v", err)
+	}
+
+	go func() {
+		for d := range msgs {
+			var event LeadEvent
+			if err := json.Unmarshal(d.Body, &event); err != nil {
+				log.Printf("Evento de lead ilegível descartado: %v", err)
+				d.Nack(false, false)
+				continue
+			}
+			if event.Event == "" {
+				event.Event = d.RoutingKey
+			}
+			if err := handle(event); err != nil {
+				log.Printf("Erro ao tratar o evento %s do lead %s: %v", event.Event, event.LeadID, err)
+				// Espera um pouco para não girar em falso enquanto o banco
+				// estiver fora.
+				time.Sleep(time.Second)
+				d.Nack(false, true)
+				continue
+			}
+			d.Ack(false)
+		}
+		log.Println("Consumo de lead_events encerrado")
+	}()
+	log.Printf("Aguardando eventos na fila '%s'", leadEventsQueue)
+	return nil
+}
+
+// SubscribeLeadEvents entrega a handle todos os eventos de lead_events numa
+// fila exclusiva desta instância, apagada quando ela para. Ao contrário de
+// ConsumeLeadEvents, cada instância da API recebe todos os eventos, e os que
+// chegarem com ela fora do ar se perdem.
+func SubscribeLeadEvents(handle func(LeadEvent)) error {
+	consumer, err := conn.Channel()
+	if err != nil {
+		return fmt.Errorf("failed to open channel: %v", err)
+	}
+	queue, err := consumer.QueueDeclare("", false, true, true, false, nil)
+	if err != nil {
+		return fmt.Errorf("failed to declare queue: %v", err)
+	}
+	if err := consumer.QueueBind(queue.Name, "#", LeadEventsExchange, false, nil); err != nil {
+		return fmt.Errorf("failed to bind queue: %v", err)
+	}
+	msgs, err := consumer.Consume(queue.Name, "", true, true, false, false, nil)
+	if err != nil {
+		return fmt.Errorf("failed to consume queue: %v", err)
+	}
+
+	go func() {
+		for d := range msgs {
+			var event LeadEvent
+			if err := json.Unmarshal(d.Body, &event); err != nil {
+				log.Printf("Evento ilegível ignorado: %v", err)
+				continue
+			}
+			if event.Event == "" {
+				event.Event = d.RoutingKey
+			}
+			handle(event)
+		}
+		log.Println("Assinatura de lead_events encerrada")
+	}()
+	return nil
+}
+
+// PublishLeadEvent anuncia um evento em lead_events.
+func PublishLeadEvent(event LeadEvent) error {
+	return leadevents.Publish(ch, event)
+}
@@ -63,6 +63,10 @@ func InitRabbitMQ(url string) error {
 		return fmt.Errorf("failed to bind queue: %v", err)
 	}
 
+	if err := declareLeadEvents(); err != nil {
+		return err
+	}
+
 	log.Println("RabbitMQ inicializado com sucesso")
 	return nil
 }
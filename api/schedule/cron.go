@@ -0,0 +1,159 @@
+// /api/schedule/cron.go
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule é uma expressão cron de 5 campos (minuto hora dia-do-mês mês
+// dia-da-semana), com suporte a "*", listas, intervalos, passos ("*/15") e
+// aos atalhos @hourly, @daily, @weekly e @monthly.
+type Schedule struct {
+	expr    string
+	minutes [60]bool
+	hours   [24]bool
+	days    [32]bool
+	months  [13]bool
+	weekday [7]bool
+
+	anyDay     bool
+	anyWeekday bool
+}
+
+var shortcuts = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+}
+
+func Parse(expr string) (*Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	spec := expr
+	if full, ok := shortcuts[strings.ToLower(expr)]; ok {
+		spec = full
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("expressão cron inválida %q: esperava 5 campos", expr)
+	}
+
+	s := &Schedule{expr: expr}
+	if err := parseField(fields[0], 0, 59, s.minutes[:]); err != nil {
+		return nil, fmt.Errorf("minuto: %v", err)
+	}
+	if err := parseField(fields[1], 0, 23, s.hours[:]); err != nil {
+		return nil, fmt.Errorf("hora: %v", err)
+	}
+	if err := parseField(fields[2], 1, 31, s.days[:]); err != nil {
+		return nil, fmt.Errorf("dia do mês: %v", err)
+	}
+	if err := parseField(fields[3], 1, 12, s.months[:]); err != nil {
+		return nil, fmt.Errorf("mês: %v", err)
+	}
+	weekday := fields[4]
+	if weekday == "7" {
+		weekday = "0"
+	}
+	if err := parseField(weekday, 0, 6, s.weekday[:]); err != nil {
+		return nil, fmt.Errorf("dia da semana: %v", err)
+	}
+	s.anyDay = fields[2] == "*"
+	s.anyWeekday = fields[4] == "*"
+	return s, nil
+}
+
+func (s *Schedule) String() string {
+	return s.expr
+}
+
+func parseField(field string, min, max int, set []bool) error {
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if idx := strings.Index(part, "/"); idx != -1 {
+			var err error
+			step, err = strconv.Atoi(part[idx+1:])
+			if err != nil || step <= 0 {
+				return fmt.Errorf("passo inválido em %q", part)
+			}
+			part = part[:idx]
+		}
+
+		lo, hi := min, max
+		switch {
+		case part == "*":
+		case strings.Contains(part, "-"):
+			bounds := strings.SplitN(part, "-", 2)
+			var err1, err2 error
+			lo, err1 = strconv.Atoi(bounds[0])
+			hi, err2 = strconv.Atoi(bounds[1])
+			if err1 != nil || err2 != nil {
+				return fmt.Errorf("intervalo inválido %q", part)
+			}
+		default:
+			v, err := strconv.Atoi(part)
+			if err != nil {
+				return fmt.Errorf("valor inválido %q", part)
+			}
+			lo, hi = v, v
+			if step > 1 {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return fmt.Errorf("valor fora do intervalo %d-%d em %q", min, max, part)
+		}
+		for v := lo; v <= hi; v += step {
+			set[v] = true
+		}
+	}
+	return nil
+}
+
+// Next devolve o primeiro instante, estritamente depois de t, que satisfaz a
+// expressão. Segue a regra do cron: se dia do mês e dia da semana forem ambos
+// restritos, basta um dos dois casar.
+func (s *Schedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if !s.months[t.Month()] {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.hours[t.Hour()] {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.minutes[t.Minute()] {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func (s *Schedule) dayMatches(t time.Time) bool {
+	dom := s.days[t.Day()]
+	dow := s.weekday[int(t.Weekday())]
+	switch {
+	case s.anyDay && s.anyWeekday:
+		return true
+	case s.anyDay:
+		return dow
+	case s.anyWeekday:
+		return dom
+	default:
+		return dom || dow
+	}
+}
@@ -0,0 +1,39 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNext(t *testing.T) {
+	base := time.Date(2026, 10, 19, 10, 7, 30, 0, time.UTC) // segunda-feira
+
+	cases := []struct {
+		expr string
+		want time.Time
+	}{
+		{"*/15 * * * *", time.Date(2026, 10, 19, 10, 15, 0, 0, time.UTC)},
+		{"@daily", time.Date(2026, 10, 20, 0, 0, 0, 0, time.UTC)},
+		{"0 8 * * 1", time.Date(2026, 10, 26, 8, 0, 0, 0, time.UTC)},
+		{"30 9 1 * *", time.Date(2026, 11, 1, 9, 30, 0, 0, time.UTC)},
+		{"0 6 * * 1-5", time.Date(2026, 10, 20, 6, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2026, 10, 25, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, c := range cases {
+		s, err := Parse(c.expr)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", c.expr, err)
+		}
+		if got := s.Next(base); !got.Equal(c.want) {
+			t.Errorf("%s: expected %v, got %v", c.expr, c.want, got)
+		}
+	}
+}
+
+func TestParseRejectsInvalidExpressions(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "*/0 * * * *", "a b c d e"} {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("expected error for %q", expr)
+		}
+	}
+}
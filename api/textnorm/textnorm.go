@@ -0,0 +1,36 @@
+// /api/textnorm/textnorm.go
+package textnorm
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// StripAccents tira os acentos de s ("São João" vira "Sao Joao"). O
+// Transformer é criado a cada chamada porque a cadeia guarda estado e não pode
+// ser usada por duas goroutines ao mesmo tempo.
+func StripAccents(s string) string {
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	out, _, err := transform.String(t, s)
+	if err != nil {
+		return s
+	}
+	return out
+}
+
+// Fold é a forma usada para comparar textos digitados por pessoas: minúsculo,
+// sem acento e sem espaço nas pontas.
+func Fold(s string) string {
+	return StripAccents(strings.ToLower(strings.TrimSpace(s)))
+}
+
+// Words deixa só as letras e números de Fold(s), separados por um espaço.
+func Words(s string) string {
+	return strings.Join(strings.FieldsFunc(Fold(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}), " ")
+}
@@ -0,0 +1,30 @@
+package textnorm
+
+import "testing"
+
+func TestFold(t *testing.T) {
+	cases := map[string]string{
+		"  São Paulo ": "sao paulo",
+		"AÇÚCAR":       "acucar",
+		"Razão Social": "razao social",
+		"":             "",
+	}
+	for in, want := range cases {
+		if got := Fold(in); got != want {
+			t.Errorf("Fold(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestWords(t *testing.T) {
+	cases := map[string]string{
+		"Av. Paulista, 1.000 - Sala 12": "av paulista 1 000 sala 12",
+		"Padaria São João LTDA.":        "padaria sao joao ltda",
+		"--":                            "",
+	}
+	for in, want := range cases {
+		if got := Words(in); got != want {
+			t.Errorf("Words(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+// /api/weburl/weburl.go
+package weburl
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+
+	"github.com/wbrunovieira/LeadSearchVersion2/shared/social"
+)
+
+// Kind diz o que é um link recebido como "site" do lead.
+type Kind string
+
+const (
+	KindWebsite   Kind = "website"
+	KindSocial    Kind = "social"
+	KindLinkInBio Kind = "link_in_bio"
+	KindMessaging Kind = "messaging"
+)
+
+// Serviços de mensagem reconhecidos.
+const (
+	WhatsApp  = "whatsapp"
+	Messenger = "messenger"
+	Telegram  = "telegram"
+)
+
+var ErrInvalid = errors.New("URL inválida")
+
+// Link é um link já classificado. URL está na forma canônica; Domain é o
+// domínio registrável (só para sites próprios) e Profile só vem preenchido
+// quando o link social aponta para um perfil.
+type Link struct {
+	Kind    Kind
+	URL     string
+	Domain  string
+	Service string
+	Profile *social.Profile
+}
+
+// Agregadores de links ("link na bio").
+var linkInBioDomains = map[string]bool{
+	"linktr.ee": true, "beacons.ai": true, "bio.link": true, "lnk.bio": true, "taplink.cc": true,
+	"taplink.ws": true, "campsite.bio": true, "linkin.bio": true, "msha.ke": true, "solo.to": true,
+	"allmylinks.com": true, "linkbio.co": true, "bio.site": true, "hoo.be": true,
+}
+
+var messagingDomains = map[string]string{
+	"wa.me":        WhatsApp,
+	"whatsapp.com": WhatsApp,
+	"m.me":         Messenger,
+	"t.me":         Telegram,
+	"telegram.me":  Telegram,
+}
+
+// Domínios de construtores de site em que o domínio registrável é o da
+// plataforma e não o do negócio. Nesses casos o domínio do lead é o host
+// completo (padaria.wixsite.com) ou nenhum (sites.google.com/view/padaria).
+var sharedHostingDomains = map[string]bool{
+	"google.com": true, "wixsite.com": true, "wordpress.com": true, "godaddysites.com": true,
+	"webnode.page": true, "site123.me": true, "ueniweb.com": true, "negocio.site": true, "business.site": true,
+}
+
+// Parâmetros de rastreamento removidos da URL canônica, além dos utm_*.
+var trackingParams = map[string]bool{
+	"gclid": true, "gbraid": true, "wbraid": true, "fbclid": true, "msclkid": true, "yclid": true,
+	"igshid": true, "igsh": true, "mc_cid": true, "mc_eid": true, "_ga": true, "_gl": true,
+	"srsltid": true, "ref": true, "ref_src": true,
+}
+
+// Classify interpreta um link e diz se é o site do negócio, um perfil social,
+// um agregador de links ou um link de mensagem.
+func Classify(raw string) (Link, error) {
+	u, err := parse(raw)
+	if err != nil {
+		return Link{}, err
+	}
+	host := u.Hostname()
+	registrable := RegistrableDomain(host)
+
+	if _, ok := social.NetworkOf(u.String()); ok {
+		link := Link{Kind: KindSocial, URL: canonical(u)}
+		if profile, err := social.Parse(u.String()); err == nil {
+			link.Profile = &profile
+			link.URL = profile.URL
+		}
+		return link, nil
+	}
+	if service, ok := messagingDomains[registrable]; ok {
+		return Link{Kind: KindMessaging, URL: canonical(u), Service: service}, nil
+	}
+	if linkInBioDomains[registrable] {
+		return Link{Kind: KindLinkInBio, URL: canonical(u)}, nil
+	}
+	return Link{Kind: KindWebsite, URL: canonical(u), Domain: siteDomain(host, registrable)}, nil
+}
+
+// DomainOf devolve o domínio registrável de um site próprio, ou "" se o link
+// não for um site (rede social, agregador, mensagem) ou não puder ser lido.
+func DomainOf(raw string) string {
+	if strings.TrimSpace(raw) == "" {
+		return ""
+	}
+	link, err := Classify(raw)
+	if err != nil || link.Kind != KindWebsite {
+		return ""
+	}
+	return link.Domain
+}
+
+// RegistrableDomain devolve o domínio registrável do host (loja.padaria.com.br
+// vira padaria.com.br) pela Public Suffix List. IPs e hosts que não casam com
+// a lista voltam como vieram.
+func RegistrableDomain(host string) string {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if net.ParseIP(host) != nil {
+		return host
+	}
+	domain, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return host
+	}
+	return domain
+}
+
+func siteDomain(host, registrable string) string {
+	if !sharedHostingDomains[registrable] {
+		return registrable
+	}
+	if host == registrable || host == "sites.google.com" {
+		return ""
+	}
+	return host
+}
+
+// parse aceita endereços sem esquema (assume https) e recusa o que não for
+// http/https com um host com ponto, além de URLs com usuário (o que também
+// pega "mailto:" sem esquema).
+func parse(raw string) (*url.URL, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, ErrInvalid
+	}
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + strings.TrimPrefix(raw, "//")
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.User != nil {
+		return nil, ErrInvalid
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, ErrInvalid
+	}
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "" || !strings.Contains(host, ".") || strings.ContainsAny(host, " _") {
+		return nil, ErrInvalid
+	}
+	if port := u.Port(); port != "" && !(u.Scheme == "http" && port == "80" || u.Scheme == "https" && port == "443") {
+		host = net.JoinHostPort(host, port)
+	}
+	u.Host = host
+	return u, nil
+}
+
+// canonical tira www., fragmento, barra final e parâmetros de
+// rastreamento, e ordena os parâmetros restantes. O esquema informado é
+// mantido; sem esquema vale https.
+func canonical(u *url.URL) string {
+	c := *u
+	c.Fragment = ""
+	c.RawFragment = ""
+	c.Host = strings.TrimPrefix(c.Host, "www.")
+
+	query := c.Query()
+	for key := range query {
+		if strings.HasPrefix(strings.ToLower(key), "utm_") || trackingParams[strings.ToLower(key)] {
+			query.Del(key)
+		}
+	}
+	c.RawQuery = query.Encode()
+	c.ForceQuery = false
+
+	c.Path = strings.TrimRight(c.Path, "/")
+	c.RawPath = ""
+	return c.String()
+}
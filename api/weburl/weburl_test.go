@@ -0,0 +1,92 @@
+package weburl
+
+import "testing"
+
+func TestClassifyWebsite(t *testing.T) {
+	cases := []struct {
+		raw, url, domain string
+	}{
+		{"padariabela.com.br", "https://padariabela.com.br", "padariabela.com.br"},
+		{"https://WWW.PadariaBela.com.br/", "https://padariabela.com.br", "padariabela.com.br"},
+		{"http://www.padariabela.com.br:80/contato/?utm_source=google&utm_medium=organic#mapa", "http://padariabela.com.br/contato", "padariabela.com.br"},
+		{"https://loja.padariabela.com.br/produtos?b=2&a=1&gclid=xyz", "https://loja.padariabela.com.br/produtos?a=1&b=2", "padariabela.com.br"},
+		{"https://padaria.wixsite.com/bela", "https://padaria.wixsite.com/bela", "padaria.wixsite.com"},
+		{"https://sites.google.com/view/padariabela", "https://sites.google.com/view/padariabela", ""},
+		{"https://padariabela.business.site/", "https://padariabela.business.site", "padariabela.business.site"},
+	}
+	for _, c := range cases {
+		link, err := Classify(c.raw)
+		if err != nil {
+			t.Errorf("Classify(%q) error: %v", c.raw, err)
+			continue
+		}
+		if link.Kind != KindWebsite || link.URL != c.url || link.Domain != c.domain {
+			t.Errorf("Classify(%q) = %+v, want website %s (%s)", c.raw, link, c.url, c.domain)
+		}
+	}
+}
+
+func TestClassifyRoutesLinks(t *testing.T) {
+	cases := []struct {
+		raw     string
+		kind    Kind
+		url     string
+		service string
+	}{
+		{"instagram.com/PadariaBela", KindSocial, "https://www.instagram.com/padariabela/", ""},
+		{"https://m.facebook.com/padariabela/?ref=page_internal", KindSocial, "https://www.facebook.com/padariabela", ""},
+		{"https://linktr.ee/padariabela", KindLinkInBio, "https://linktr.ee/padariabela", ""},
+		{"https://wa.me/5511912345678?text=Oi", KindMessaging, "https://wa.me/5511912345678?text=Oi", WhatsApp},
+		{"https://api.whatsapp.com/send?phone=5511912345678", KindMessaging, "https://api.whatsapp.com/send?phone=5511912345678", WhatsApp},
+		{"https://m.me/padariabela", KindMessaging, "https://m.me/padariabela", Messenger},
+		{"t.me/padariabela", KindMessaging, "https://t.me/padariabela", Telegram},
+	}
+	for _, c := range cases {
+		link, err := Classify(c.raw)
+		if err != nil {
+			t.Errorf("Classify(%q) error: %v", c.raw, err)
+			continue
+		}
+		if link.Kind != c.kind || link.URL != c.url || link.Service != c.service || link.Domain != "" {
+			t.Errorf("Classify(%q) = %+v, want %s %s", c.raw, link, c.kind, c.url)
+		}
+	}
+
+	link, err := Classify("https://www.facebook.com/search/top?q=padaria")
+	if err != nil || link.Kind != KindSocial || link.Profile != nil {
+		t.Errorf("Classify(facebook search) = %+v, %v; want social without profile", link, err)
+	}
+}
+
+func TestClassifyInvalid(t *testing.T) {
+	for _, raw := range []string{"", "   ", "ftp://padariabela.com.br", "mailto:contato@padariabela.com.br", "localhost", "http://padaria bela.com"} {
+		if link, err := Classify(raw); err == nil {
+			t.Errorf("Classify(%q) = %+v, want error", raw, link)
+		}
+	}
+}
+
+func TestDomainOf(t *testing.T) {
+	if got := DomainOf("https://www.padariabela.com.br/contato"); got != "padariabela.com.br" {
+		t.Errorf("DomainOf(site) = %q", got)
+	}
+	for _, raw := range []string{"", "https://www.instagram.com/padariabela/", "https://linktr.ee/padariabela", "not a url"} {
+		if got := DomainOf(raw); got != "" {
+			t.Errorf("DomainOf(%q) = %q, want empty", raw, got)
+		}
+	}
+}
+
+func TestRegistrableDomain(t *testing.T) {
+	cases := map[string]string{
+		"loja.padariabela.com.br": "padariabela.com.br",
+		"WWW.Example.CO.UK.":      "example.co.uk",
+		"padariabela.com":         "padariabela.com",
+		"192.168.0.1":             "192.168.0.1",
+	}
+	for host, want := range cases {
+		if got := RegistrableDomain(host); got != want {
+			t.Errorf("RegistrableDomain(%q) = %q, want %q", host, got, want)
+		}
+	}
+}
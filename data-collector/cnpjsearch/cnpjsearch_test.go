@@ -0,0 +1,37 @@
+package cnpjsearch
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/wbrunovieira/LeadSearchVersion2/shared/fakes"
+)
+
+func TestFetchDataCNPJBIZAgainstFake(t *testing.T) {
+	server := fakes.NewServer(t, "testdata",
+		fakes.Route{Method: "GET", Path: "/procura/Padaria Bela Paulista", Fixture: "procura.html"},
+		fakes.Route{Method: "GET", Path: "/12345678000195", Fixture: "company.html"},
+	)
+	t.Setenv("CNPJBIZ_BASE_URL", server.URL)
+	sleep = func() {}
+	t.Cleanup(func() { sleep = SleepRandom })
+
+	result, err := FetchDataCNPJBIZ("Padaria Bela Paulista", "São Paulo")
+	if err != nil {
+		t.Fatalf("FetchDataCNPJBIZ failed: %v", err)
+	}
+	if result["cnpj"] != "12345678000195" {
+		t.Errorf("picked the wrong city: %v", result)
+	}
+	details, ok := result["details"].(map[string]string)
+	if !ok {
+		t.Fatalf("details missing: %v", result)
+	}
+	if !strings.Contains(details["razaoSocial"], "PADARIA BELA PAULISTA LTDA") {
+		t.Errorf("unexpected razaoSocial %q", details["razaoSocial"])
+	}
+
+	if _, err := FetchDataCNPJBIZ("Padaria Bela Paulista", "Recife"); err == nil {
+		t.Error("expected an error when no CNPJ matches the city")
+	}
+}
@@ -3,13 +3,14 @@ package cnpjsearch
 import (
 	"fmt"
 	"net/http"
+	"os"
 	"strings"
 
 	"github.com/PuerkitoBio/goquery"
 )
 
 func FetchCompanyDetailsCNPJBIZ(cnpj string) (map[string]string, error) {
-	url := fmt.Sprintf("https://cnpj.biz/%s", cnpj)
+	url := fmt.Sprintf("%s/%s", cnpjBizBaseURL(), cnpj)
 
 	client := &http.Client{}
 	req, err := http.NewRequest("GET", url, nil)
@@ -41,6 +42,16 @@ func FetchCompanyDetailsCNPJBIZ(cnpj string) (map[string]string, error) {
 	return details, nil
 }
 
+// cnpjBizBaseURL devolve a raiz do cnpj.biz, que pode ser trocada por
+// CNPJBIZ_BASE_URL (por exemplo, para um servidor falso nos testes).
+func cnpjBizBaseURL() string {
+	baseURL := os.Getenv("CNPJBIZ_BASE_URL")
+	if baseURL == "" {
+		baseURL = "https://cnpj.biz"
+	}
+	return strings.TrimRight(baseURL, "/")
+}
+
 func extractData(doc *goquery.Document, label string, selector string) string {
 	var data string
 	doc.Find(selector).Each(func(i int, s *goquery.Selection) {
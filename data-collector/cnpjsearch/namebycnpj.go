@@ -9,13 +9,14 @@ import (
 	"time"
 
 	"github.com/PuerkitoBio/goquery"
+	"github.com/wbrunovieira/LeadSearchVersion2/shared/cnpj"
 )
 
 func FetchDataCNPJBIZ(companyName string, cityName string) (map[string]interface{}, error) {
 	result := make(map[string]interface{})
 
 	searchQuery := strings.ReplaceAll(companyName, " ", "%20")
-	url := fmt.Sprintf("https://cnpj.biz/procura/%s", searchQuery)
+	url := fmt.Sprintf("%s/procura/%s", cnpjBizBaseURL(), searchQuery)
 	log.Printf("url do FetchDataCNPJBIZ : %v", url)
 
 	client := &http.Client{}
@@ -39,34 +40,39 @@ func FetchDataCNPJBIZ(companyName string, cityName string) (map[string]interface
 
 	found := false
 
+	// Cada resultado vem como "CNPJ / Cidade - UF"; o CNPJ pode estar
+	// pontuado, então a cidade é o que vem depois da última barra.
 	doc.Find(".flex.items-center.text-sm.text-gray-500").EachWithBreak(func(index int, element *goquery.Selection) bool {
 		cnpjData := strings.TrimSpace(element.Text())
-		if strings.Contains(cnpjData, "/") {
-			cnpjParts := strings.Split(cnpjData, "/")
-			if len(cnpjParts) >= 2 {
-				cnpjCity := strings.TrimSpace(cnpjParts[1])
-				if strings.Contains(strings.ToLower(cnpjCity), strings.ToLower(cityName)) {
-					cnpj := strings.TrimSpace(cnpjParts[0])
-
-					// Captura os detalhes da empresa utilizando a função refatorada
-					details, err := FetchCompanyDetailsCNPJBIZ(cnpj)
-					if err == nil {
-						result["details"] = details
-					} else {
-						result["details_error"] = err.Error()
-					}
+		sep := strings.LastIndex(cnpjData, "/")
+		if sep == -1 {
+			return true // continua o loop
+		}
+		cnpjCity := strings.TrimSpace(cnpjData[sep+1:])
+		if !strings.Contains(strings.ToLower(cnpjCity), strings.ToLower(cityName)) {
+			return true
+		}
+		companyCNPJ, err := cnpj.Normalize(cnpjData[:sep])
+		if err != nil {
+			log.Printf("CNPJ inválido no cnpj.biz (%q): %v", cnpjData, err)
+			return true
+		}
 
-					result["cnpj"] = cnpj
-					result["city"] = cnpjCity
-					found = true
-					return false // interrompe o loop
-				}
-			}
+		// Captura os detalhes da empresa utilizando a função refatorada
+		details, err := FetchCompanyDetailsCNPJBIZ(companyCNPJ)
+		if err == nil {
+			result["details"] = details
+		} else {
+			result["details_error"] = err.Error()
 		}
-		return true // continua o loop
+
+		result["cnpj"] = companyCNPJ
+		result["city"] = cnpjCity
+		found = true
+		return false // interrompe o loop
 	})
 
-	SleepRandom()
+	sleep()
 
 	if !found {
 		return nil, fmt.Errorf("no CNPJ data found for company %s in city %s", companyName, cityName)
@@ -74,6 +80,9 @@ func FetchDataCNPJBIZ(companyName string, cityName string) (map[string]interface
 	return result, nil
 }
 
+// sleep espaça as consultas ao cnpj.biz; os testes trocam por um no-op.
+var sleep = SleepRandom
+
 func SleepRandom() {
 	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
 	time.Sleep(time.Duration(2+rng.Intn(2)) * time.Second)
@@ -9,7 +9,8 @@ import (
 )
 
 type Lead struct {
-	ID uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	ID          uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	WorkspaceID uuid.UUID `gorm:"type:uuid;index" json:"WorkspaceID"`
 
 	BusinessName   string       `gorm:"size:255" json:"BusinessName"`
 	RegisteredName string       `gorm:"size:255" json:"registered_name"`
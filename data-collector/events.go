@@ -0,0 +1,12 @@
+package main
+
+// webEnrichedDetails resume o que a busca na web encontrou para o lead.
+func webEnrichedDetails(data CombinedLeadData) map[string]interface{} {
+	cnpj, _ := data.CNPJData["cnpj"].(string)
+	return map[string]interface{}{
+		"tavily":     data.TavilyData != nil,
+		"serper":     data.SerperData != nil,
+		"cnpj_found": cnpj != "",
+		"cnpj":       cnpj,
+	}
+}
@@ -9,9 +9,12 @@ require (
 	github.com/google/uuid v1.6.0
 	github.com/joho/godotenv v1.5.1
 	github.com/streadway/amqp v1.1.0
+	github.com/wbrunovieira/LeadSearchVersion2/shared v0.0.0
 )
 
 require (
 	github.com/andybalholm/cascadia v1.3.3 // indirect
 	golang.org/x/net v0.35.0 // indirect
 )
+
+replace github.com/wbrunovieira/LeadSearchVersion2/shared => ../shared
@@ -13,6 +13,7 @@ import (
 	"github.com/wbrunovieira/LeadSearchVersion2/data-collector/common"
 	"github.com/wbrunovieira/LeadSearchVersion2/data-collector/serper"
 	"github.com/wbrunovieira/LeadSearchVersion2/data-collector/tavily"
+	"github.com/wbrunovieira/LeadSearchVersion2/shared/leadevents"
 )
 
 var (
@@ -67,6 +68,10 @@ func initRabbitMQ() {
 		log.Fatalf("Erro ao declarar exchange fanout: %v", err)
 	}
 
+	if err := leadevents.Declare(rabbitCh); err != nil {
+		log.Fatal(err)
+	}
+
 	_, err = rabbitCh.QueueDeclare(
 		"lead_queue", // nome da fila
 		true,         // durable
@@ -168,6 +173,10 @@ func processLeadMessage(body []byte) {
 		log.Printf("Erro ao publicar dados combinados no RabbitMQ: %v", err)
 	} else {
 		log.Printf("Dados combinados publicados com sucesso para o lead ID: %s", lead.ID)
+		event := leadevents.Event{Event: "lead.web_enriched", LeadID: lead.ID.String(), Details: webEnrichedDetails(combinedData)}
+		if err := leadevents.Publish(rabbitCh, event); err != nil {
+			log.Printf("Erro ao anunciar o enriquecimento do lead %s: %v", lead.ID, err)
+		}
 	}
 }
 
@@ -203,6 +212,10 @@ func main() {
 	defer rabbitCh.Close()
 	defer rabbitConn.Close()
 
-	log.Println("Servidor iniciado na porta 8080...")
-	log.Fatal(http.ListenAndServe(":8080", nil))
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
+	log.Printf("Servidor iniciado na porta %s...", port)
+	log.Fatal(http.ListenAndServe(":"+port, nil))
 }
@@ -7,6 +7,7 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"strings"
 )
 
 func FetchSerperDataForCNPJ(name, city string, numResults int) (map[string]interface{}, error) {
@@ -22,7 +23,11 @@ func FetchSerperDataForCNPJ(name, city string, numResults int) (map[string]inter
 		return nil, fmt.Errorf("erro ao converter payload para JSON: %v", err)
 	}
 
-	apiURL := "https://google.serper.dev/search"
+	baseURL := os.Getenv("SERPER_BASE_URL")
+	if baseURL == "" {
+		baseURL = "https://google.serper.dev"
+	}
+	apiURL := strings.TrimRight(baseURL, "/") + "/search"
 	req, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(payloadBytes))
 	if err != nil {
 		return nil, fmt.Errorf("erro ao criar a requisição: %v", err)
@@ -0,0 +1,37 @@
+package serper
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/wbrunovieira/LeadSearchVersion2/shared/fakes"
+)
+
+func TestFetchSerperDataForCNPJAgainstFake(t *testing.T) {
+	server := fakes.NewServer(t, "testdata",
+		fakes.Route{Method: "POST", Path: "/search", Fixture: "search.json"},
+	)
+	t.Setenv("SERPER_BASE_URL", server.URL)
+	t.Setenv("SERPER_API_KEY", "serper-test")
+
+	result, err := FetchSerperDataForCNPJ("Padaria Bela Paulista", "São Paulo", 5)
+	if err != nil {
+		t.Fatalf("FetchSerperDataForCNPJ failed: %v", err)
+	}
+	organic, ok := result["organic"].([]interface{})
+	if !ok || len(organic) != 1 {
+		t.Fatalf("unexpected organic results: %v", result["organic"])
+	}
+
+	req := server.Requests()[0]
+	if got := req.Header.Get("X-API-KEY"); got != "serper-test" {
+		t.Errorf("unexpected X-API-KEY %q", got)
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal(req.Body, &payload); err != nil {
+		t.Fatalf("invalid payload: %v", err)
+	}
+	if payload["q"] != "Padaria Bela Paulista, São Paulo CNPJ" {
+		t.Errorf("unexpected query %v", payload["q"])
+	}
+}
@@ -8,8 +8,8 @@ import (
 	"log"
 	"net/http"
 	"os"
-
 	"sort"
+	"strings"
 )
 
 type TavilyResult struct {
@@ -44,7 +44,11 @@ func FetchTavilyData(query string, maxResults int) (*TavilyResponse, error) {
 		return nil, fmt.Errorf("erro ao converter payload para JSON: %v", err)
 	}
 
-	req, err := http.NewRequest("POST", "https://api.tavily.com/search", bytes.NewBuffer(payloadBytes))
+	baseURL := os.Getenv("TAVILY_BASE_URL")
+	if baseURL == "" {
+		baseURL = "https://api.tavily.com"
+	}
+	req, err := http.NewRequest("POST", strings.TrimRight(baseURL, "/")+"/search", bytes.NewBuffer(payloadBytes))
 	if err != nil {
 		return nil, fmt.Errorf("erro ao criar a requisição: %v", err)
 	}
@@ -0,0 +1,39 @@
+package tavily
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/wbrunovieira/LeadSearchVersion2/shared/fakes"
+)
+
+func TestFetchTavilyDataAgainstFake(t *testing.T) {
+	server := fakes.NewServer(t, "testdata",
+		fakes.Route{Method: "POST", Path: "/search", Fixture: "search.json"},
+	)
+	t.Setenv("TAVILY_BASE_URL", server.URL)
+	t.Setenv("TAVILY_API_KEY", "tvly-test")
+
+	resp, err := FetchTavilyData("Padaria Bela Paulista São Paulo CNPJ", 5)
+	if err != nil {
+		t.Fatalf("FetchTavilyData failed: %v", err)
+	}
+	if len(resp.Results) != 2 || resp.Results[0].Score < resp.Results[1].Score {
+		t.Errorf("results should be sorted by score: %+v", resp.Results)
+	}
+
+	requests := server.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("expected 1 request, got %d", len(requests))
+	}
+	if got := requests[0].Header.Get("Authorization"); got != "Bearer tvly-test" {
+		t.Errorf("unexpected Authorization header %q", got)
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal(requests[0].Body, &payload); err != nil {
+		t.Fatalf("invalid payload: %v", err)
+	}
+	if payload["max_results"] != float64(5) {
+		t.Errorf("unexpected max_results: %v", payload["max_results"])
+	}
+}
@@ -4,7 +4,10 @@ go 1.23
 
 toolchain go1.24.0
 
-require github.com/elastic/go-elasticsearch/v8 v8.17.1
+require (
+	github.com/elastic/go-elasticsearch/v8 v8.17.1
+	github.com/wbrunovieira/LeadSearchVersion2/shared v0.0.0
+)
 
 require (
 	github.com/elastic/elastic-transport-go/v8 v8.6.1 // indirect
@@ -15,3 +18,5 @@ require (
 	go.opentelemetry.io/otel/metric v1.28.0 // indirect
 	go.opentelemetry.io/otel/trace v1.28.0 // indirect
 )
+
+replace github.com/wbrunovieira/LeadSearchVersion2/shared => ../shared
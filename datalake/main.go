@@ -9,6 +9,7 @@ import (
 
 	elasticsearch "github.com/elastic/go-elasticsearch/v8"
 	"github.com/streadway/amqp"
+	"github.com/wbrunovieira/LeadSearchVersion2/shared/leadevents"
 )
 
 type CombinedLeadData struct {
@@ -78,6 +79,10 @@ func initAMQP() {
 		log.Fatalf("Erro ao declarar exchange fanout: %v", err)
 	}
 
+	if err := leadevents.Declare(amqpCh); err != nil {
+		log.Fatal(err)
+	}
+
 	// Cria fila exclusiva para o datalake
 	_, err = amqpCh.QueueDeclare(
 		"datalake_queue", // nome único da fila
@@ -155,6 +160,23 @@ func indexCombinedData(body []byte) {
 		return
 	}
 	log.Println("Documento indexado com sucesso no índice 'combined_leads'.")
+
+	var indexed struct {
+		ID string `json:"_id"`
+	}
+	json.NewDecoder(res.Body).Decode(&indexed)
+	lead, _ := doc.Lead.(map[string]interface{})
+	leadID, _ := lead["id"].(string)
+	if leadID == "" {
+		return
+	}
+	event := leadevents.Event{Event: "lead.indexed", LeadID: leadID, Details: map[string]interface{}{
+		"index":       "combined_leads",
+		"document_id": indexed.ID,
+	}}
+	if err := leadevents.Publish(amqpCh, event); err != nil {
+		log.Printf("Erro ao anunciar a indexação do lead %s: %v", leadID, err)
+	}
 }
 
 func main() {
@@ -0,0 +1,257 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/wbrunovieira/LeadSearchVersion2/shared/fakes"
+)
+
+// fakeServer sobe um servidor falso com as fixtures dos testdata dos serviços
+// (os caminhos das rotas são relativos à raiz do repositório).
+func fakeServer(t *testing.T, routes ...fakes.Route) *fakes.Server {
+	t.Helper()
+	return fakes.NewServer(t, "..", routes...)
+}
+
+// fakeElasticsearch aceita qualquer indexação e guarda os documentos recebidos.
+type fakeElasticsearch struct {
+	mu   sync.Mutex
+	docs []map[string]interface{}
+}
+
+func (es *fakeElasticsearch) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// O cliente v8 recusa servidores sem este cabeçalho.
+	w.Header().Set("X-Elastic-Product", "Elasticsearch")
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method == http.MethodGet && r.URL.Path == "/" {
+		w.Write([]byte(`{"version":{"number":"8.11.0","build_flavor":"default"},"tagline":"You Know, for Search"}`))
+		return
+	}
+	var doc map[string]interface{}
+	json.NewDecoder(r.Body).Decode(&doc)
+	es.mu.Lock()
+	es.docs = append(es.docs, doc)
+	es.mu.Unlock()
+	w.WriteHeader(http.StatusCreated)
+	w.Write([]byte(`{"result":"created","_id":"1"}`))
+}
+
+func (es *fakeElasticsearch) count() int {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	return len(es.docs)
+}
+
+func freePort(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("sem porta livre: %v", err)
+	}
+	defer l.Close()
+	return fmt.Sprint(l.Addr().(*net.TCPAddr).Port)
+}
+
+// startService compila o serviço do diretório informado e o executa com env.
+func startService(t *testing.T, dir string, env ...string) {
+	t.Helper()
+	bin := filepath.Join(t.TempDir(), dir)
+	build := exec.Command("go", "build", "-o", bin, ".")
+	build.Dir = filepath.Join("..", dir)
+	if out, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("go build %s: %v\n%s", dir, err, out)
+	}
+
+	cmd := exec.Command(bin)
+	cmd.Dir = t.TempDir()
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdout = &prefixWriter{t: t, prefix: dir}
+	cmd.Stderr = cmd.Stdout
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("start %s: %v", dir, err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+}
+
+type prefixWriter struct {
+	t      *testing.T
+	prefix string
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		w.t.Logf("[%s] %s", w.prefix, line)
+	}
+	return len(p), nil
+}
+
+func waitHealthy(t *testing.T, url string) {
+	t.Helper()
+	deadline := time.Now().Add(60 * time.Second)
+	for time.Now().Before(deadline) {
+		resp, err := http.Get(url)
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				return
+			}
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+	t.Fatalf("%s não respondeu a tempo", url)
+}
+
+func TestSearchToDatalakeOffline(t *testing.T) {
+	rabbitURL := os.Getenv("E2E_RABBITMQ_URL")
+	dbHost := os.Getenv("E2E_DB_HOST")
+	if rabbitURL == "" || dbHost == "" {
+		t.Skip("defina E2E_RABBITMQ_URL e E2E_DB_HOST para rodar o teste de ponta a ponta")
+	}
+
+	googleServer := fakeServer(t,
+		fakes.Route{Method: "GET", Path: "/geocode/json", Fixture: "search-google/googleplaces/testdata/geocode.json"},
+		fakes.Route{Method: "GET", Path: "/place/textsearch/json", Fixture: "search-google/googleplaces/testdata/textsearch.json"},
+		fakes.Route{Method: "GET", Path: "/place/details/json", Fixture: "search-google/googleplaces/testdata/details.json"},
+	)
+	tavilyServer := fakeServer(t,
+		fakes.Route{Method: "POST", Path: "/search", Fixture: "data-collector/tavily/testdata/search.json"},
+	)
+	serperServer := fakeServer(t,
+		fakes.Route{Method: "POST", Path: "/search", Fixture: "data-collector/serper/testdata/search.json"},
+	)
+	cnpjBizServer := fakeServer(t,
+		fakes.Route{Method: "GET", Path: "/procura/", Fixture: "data-collector/cnpjsearch/testdata/procura.html"},
+		fakes.Route{Method: "GET", Path: "/", Fixture: "data-collector/cnpjsearch/testdata/company.html"},
+	)
+	invertextoServer := fakeServer(t,
+		fakes.Route{Method: "GET", Path: "/v1/cnpj/", ContentType: "application/json",
+			Body: []byte(`{"cnpj":"12345678000195","razao_social":"PADARIA BELA PAULISTA LTDA"}`)},
+	)
+	ollamaServer := fakeServer(t,
+		fakes.Route{Method: "POST", Path: "/api/chat", Fixture: "forwarder/olhama/testdata/chat.json"},
+	)
+
+	es := &fakeElasticsearch{}
+	esServer := httptest.NewServer(es)
+	t.Cleanup(esServer.Close)
+
+	apiPort := freePort(t)
+	apiURL := "http://127.0.0.1:" + apiPort
+	searchPort := freePort(t)
+
+	// Credenciais de teste: o search-google e o forwarder falam com a API como
+	// service e o teste dispara a busca como admin.
+	const adminKey, serviceKey = "e2e-admin-key", "e2e-service-key"
+	apiKeys := "e2e-admin:admin:" + adminKey + ",e2e-services:service:" + serviceKey
+
+	startService(t, "api",
+		"PORT="+apiPort,
+		"AUTH_API_KEYS="+apiKeys,
+		"RABBITMQ_URL="+rabbitURL,
+		"DB_HOST="+dbHost,
+		"DB_PORT="+envOr("E2E_DB_PORT", "5432"),
+		"DB_USER="+envOr("E2E_DB_USER", "leads_user"),
+		"DB_PASSWORD="+envOr("E2E_DB_PASSWORD", "secret_password"),
+		"DB_NAME="+envOr("E2E_DB_NAME", "leads_db"),
+	)
+	waitHealthy(t, apiURL+"/health")
+
+	startService(t, "search-google",
+		"PORT="+searchPort,
+		"API_URL="+apiURL,
+		"API_TOKEN="+serviceKey,
+		"AUTH_API_KEYS="+apiKeys,
+		"DATA_DIR="+t.TempDir(),
+		"GOOGLE_PLACES_API_KEY=e2e-key",
+		"GOOGLE_MAPS_BASE_URL="+googleServer.URL,
+		"PLACE_REFRESH_INTERVAL_HOURS=0",
+		"SAVED_SEARCH_POLL_SECONDS=0",
+	)
+	startService(t, "data-collector",
+		"PORT="+freePort(t),
+		"RABBITMQ_URL="+rabbitURL,
+		"TAVILY_API_KEY=e2e-key",
+		"TAVILY_BASE_URL="+tavilyServer.URL,
+		"SERPER_API_KEY=e2e-key",
+		"SERPER_BASE_URL="+serperServer.URL,
+		"CNPJBIZ_BASE_URL="+cnpjBizServer.URL,
+	)
+	startService(t, "forwarder",
+		"PORT="+freePort(t),
+		"RABBITMQ_URL="+rabbitURL,
+		"API_URL="+apiURL,
+		"API_TOKEN="+serviceKey,
+		"OLHAMA_URL="+ollamaServer.URL+"/api/chat",
+		"CNPJBIZ_BASE_URL="+cnpjBizServer.URL,
+		"INVERTEXTO_API_TOKEN=e2e-token",
+		"INVERTEXTO_BASE_URL="+invertextoServer.URL,
+	)
+	startService(t, "datalake",
+		"RABBITMQ_URL="+rabbitURL,
+		"ELASTICSEARCH_URL="+esServer.URL,
+	)
+	waitHealthy(t, "http://127.0.0.1:"+searchPort+"/health")
+
+	req, _ := http.NewRequest("GET", "http://127.0.0.1:"+searchPort+"/start-search?category_id=padaria&zipcode_id=01310100&radius=1000&max_results=1", nil)
+	req.Header.Set("Authorization", "Bearer "+adminKey)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("start-search: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("start-search devolveu %d", resp.StatusCode)
+	}
+
+	deadline := time.Now().Add(2 * time.Minute)
+	for time.Now().Before(deadline) {
+		if lead, ok := findLead(apiURL, adminKey, "ChIJ-fake-padaria"); ok && lead["RegisteredName"] == "PADARIA BELA PAULISTA LTDA" && es.count() > 0 {
+			return
+		}
+		time.Sleep(2 * time.Second)
+	}
+	t.Fatalf("o lead não foi enriquecido a tempo (documentos no datalake: %d)", es.count())
+}
+
+func findLead(apiURL, token, placeID string) (map[string]interface{}, bool) {
+	req, _ := http.NewRequest("GET", apiURL+"/list-leads", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, false
+	}
+	defer resp.Body.Close()
+	var leads []map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&leads); err != nil {
+		return nil, false
+	}
+	for _, lead := range leads {
+		if lead["GoogleId"] == placeID {
+			return lead, true
+		}
+	}
+	return nil, false
+}
+
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
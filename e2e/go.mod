@@ -0,0 +1,7 @@
+module github.com/wbrunovieira/LeadSearchVersion2/e2e
+
+go 1.23.0
+
+require github.com/wbrunovieira/LeadSearchVersion2/shared v0.0.0
+
+replace github.com/wbrunovieira/LeadSearchVersion2/shared => ../shared
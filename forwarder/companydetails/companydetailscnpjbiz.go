@@ -3,13 +3,23 @@ package companydetails
 import (
 	"fmt"
 	"net/http"
+	"os"
 	"strings"
 
 	"github.com/PuerkitoBio/goquery"
+	"github.com/wbrunovieira/LeadSearchVersion2/shared/cnpj"
 )
 
-func FetchCompanyDetailsCNPJBIZ(cnpj string) (map[string]string, error) {
-	url := fmt.Sprintf("https://cnpj.biz/%s", cnpj)
+func FetchCompanyDetailsCNPJBIZ(companyCNPJ string) (map[string]string, error) {
+	digits, err := cnpj.Normalize(companyCNPJ)
+	if err != nil {
+		return nil, fmt.Errorf("CNPJ %q inválido: %v", companyCNPJ, err)
+	}
+	baseURL := os.Getenv("CNPJBIZ_BASE_URL")
+	if baseURL == "" {
+		baseURL = "https://cnpj.biz"
+	}
+	url := fmt.Sprintf("%s/%s", strings.TrimRight(baseURL, "/"), digits)
 
 	client := &http.Client{}
 	req, err := http.NewRequest("GET", url, nil)
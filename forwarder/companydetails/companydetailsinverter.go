@@ -7,17 +7,26 @@ import (
 	"io"
 	"net/http"
 	"os"
-	"regexp"
+	"strings"
+
+	"github.com/wbrunovieira/LeadSearchVersion2/shared/cnpj"
 )
 
-func FetchCNPJDataInverter(cnpj string) (map[string]interface{}, error) {
+func FetchCNPJDataInverter(companyCNPJ string) (map[string]interface{}, error) {
 
-	cleanCNPJ := regexp.MustCompile(`\D`).ReplaceAllString(cnpj, "")
+	cleanCNPJ, err := cnpj.Normalize(companyCNPJ)
+	if err != nil {
+		return nil, fmt.Errorf("CNPJ %q inválido: %v", companyCNPJ, err)
+	}
 	apiToken := os.Getenv("INVERTEXTO_API_TOKEN")
 	if apiToken == "" {
 		return nil, fmt.Errorf("INVERTEXTO_API_TOKEN não definida")
 	}
-	apiURL := fmt.Sprintf("https://api.invertexto.com/v1/cnpj/%s?token=%s", cleanCNPJ, apiToken)
+	baseURL := os.Getenv("INVERTEXTO_BASE_URL")
+	if baseURL == "" {
+		baseURL = "https://api.invertexto.com"
+	}
+	apiURL := fmt.Sprintf("%s/v1/cnpj/%s?token=%s", strings.TrimRight(baseURL, "/"), cleanCNPJ, apiToken)
 
 	resp, err := http.Get(apiURL)
 	if err != nil {
@@ -8,6 +8,7 @@ require (
 	github.com/joho/godotenv v1.5.1
 	github.com/streadway/amqp v1.1.0
 	github.com/wbrunovieira/LeadSearchVersion2/data-collector v0.0.0-20250316174953-d689c41defe8
+	github.com/wbrunovieira/LeadSearchVersion2/shared v0.0.0
 )
 
 require (
@@ -19,3 +20,5 @@ require (
 	github.com/PuerkitoBio/goquery v1.10.2
 	github.com/google/uuid v1.6.0 // indirect
 )
+
+replace github.com/wbrunovieira/LeadSearchVersion2/shared => ../shared
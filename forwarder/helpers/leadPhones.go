@@ -0,0 +1,62 @@
+package helpers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// LeadPhone é um telefone encontrado no enriquecimento e a origem dele.
+type LeadPhone struct {
+	Number string `json:"number"`
+	Source string `json:"source"`
+}
+
+// AddLeadPhones envia para a API os telefones encontrados para o lead. A API
+// normaliza, classifica e ignora os que ela já conhece.
+func AddLeadPhones(leadID string, phones []LeadPhone) error {
+	if len(phones) == 0 {
+		return nil
+	}
+
+	apiURL := os.Getenv("API_URL")
+	if apiURL == "" {
+		apiURL = "http://api:8085"
+	}
+	jsonData, err := json.Marshal(map[string]interface{}{"id": leadID, "phones": phones})
+	if err != nil {
+		return fmt.Errorf("erro ao converter telefones para JSON: %v", err)
+	}
+
+	req, err := http.NewRequest("POST", strings.TrimRight(apiURL, "/")+"/lead-phones", bytes.NewReader(jsonData))
+	if err != nil {
+		return fmt.Errorf("erro ao criar a requisição: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token := os.Getenv("API_TOKEN"); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("erro ao enviar telefones: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%w (status %d): %s", ErrRejected, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("erro ao enviar telefones (status %d): %s", resp.StatusCode, string(body))
+	}
+	log.Printf("Telefones do lead %s enviados: %s", leadID, strings.TrimSpace(string(body)))
+	return nil
+}
@@ -0,0 +1,64 @@
+package helpers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// SocialProfile é um link de rede social encontrado no enriquecimento e a
+// origem dele.
+type SocialProfile struct {
+	URL    string `json:"url"`
+	Source string `json:"source"`
+}
+
+// AddLeadSocialProfiles envia para a API os perfis sociais encontrados para o
+// lead. A API valida os links, grava a forma canônica e devolve em
+// "rejected" os que não são perfis.
+func AddLeadSocialProfiles(leadID string, profiles []SocialProfile) error {
+	if len(profiles) == 0 {
+		return nil
+	}
+
+	apiURL := os.Getenv("API_URL")
+	if apiURL == "" {
+		apiURL = "http://api:8085"
+	}
+	jsonData, err := json.Marshal(map[string]interface{}{"id": leadID, "profiles": profiles})
+	if err != nil {
+		return fmt.Errorf("erro ao converter perfis sociais para JSON: %v", err)
+	}
+
+	req, err := http.NewRequest("POST", strings.TrimRight(apiURL, "/")+"/lead-social-profiles", bytes.NewReader(jsonData))
+	if err != nil {
+		return fmt.Errorf("erro ao criar a requisição: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token := os.Getenv("API_TOKEN"); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("erro ao enviar perfis sociais: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%w (status %d): %s", ErrRejected, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("erro ao enviar perfis sociais (status %d): %s", resp.StatusCode, string(body))
+	}
+	log.Printf("Perfis sociais do lead %s enviados: %s", leadID, strings.TrimSpace(string(body)))
+	return nil
+}
@@ -3,13 +3,21 @@ package helpers
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"os"
+	"strings"
 	"time"
 )
 
+// ErrRejected indica que a API recusou o valor ou não achou o lead (400/404).
+// Repetir a mesma atualização não adianta, então a mensagem não deve voltar
+// para a fila.
+var ErrRejected = errors.New("atualização recusada pela API")
+
 func UpdateLeadField(leadID string, field string, value interface{}) error {
 
 	if field == "FoundationDate" {
@@ -19,7 +27,11 @@ func UpdateLeadField(leadID string, field string, value interface{}) error {
 		}
 	}
 
-	updateURL := "http://api:8085/update-lead-field"
+	apiURL := os.Getenv("API_URL")
+	if apiURL == "" {
+		apiURL = "http://api:8085"
+	}
+	updateURL := strings.TrimRight(apiURL, "/") + "/update-lead-field"
 	payload := map[string]interface{}{
 		"id":    leadID,
 		"field": field,
@@ -37,8 +49,11 @@ func UpdateLeadField(leadID string, field string, value interface{}) error {
 		return fmt.Errorf("erro ao criar a requisição: %v", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
+	if token := os.Getenv("API_TOKEN"); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
 
-	log.Printf("updateLeadField req: %v", req)
+	log.Printf("updateLeadField req: %s %s", req.Method, req.URL)
 	client := &http.Client{Timeout: 30 * time.Second}
 	resp, err := client.Do(req)
 	if err != nil {
@@ -46,6 +61,10 @@ func UpdateLeadField(leadID string, field string, value interface{}) error {
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusNotFound {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("%w (status %d): %s", ErrRejected, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
 	if resp.StatusCode >= 300 {
 		body, _ := io.ReadAll(resp.Body)
 		return fmt.Errorf("erro na atualização (status %d): %s", resp.StatusCode, string(body))
@@ -0,0 +1,52 @@
+package helpers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/wbrunovieira/LeadSearchVersion2/shared/fakes"
+)
+
+func TestUpdateLeadFieldAgainstFakeAPI(t *testing.T) {
+	server := fakes.NewServer(t, "testdata",
+		fakes.Route{Method: "PUT", Path: "/update-lead-field", Fixture: "update.json"},
+	)
+	t.Setenv("API_URL", server.URL)
+	t.Setenv("API_TOKEN", "forwarder-key")
+
+	if err := UpdateLeadField("lead-1", "RegisteredName", "PADARIA BELA PAULISTA LTDA"); err != nil {
+		t.Fatalf("UpdateLeadField failed: %v", err)
+	}
+	if err := UpdateLeadField("lead-1", "FoundationDate", ""); err != nil {
+		t.Fatalf("empty FoundationDate should be skipped: %v", err)
+	}
+
+	requests := server.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("expected 1 request, got %d", len(requests))
+	}
+	if got := requests[0].Header.Get("Authorization"); got != "Bearer forwarder-key" {
+		t.Errorf("service credential not sent: %q", got)
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal(requests[0].Body, &payload); err != nil {
+		t.Fatalf("invalid payload: %v", err)
+	}
+	if payload["id"] != "lead-1" || payload["field"] != "RegisteredName" {
+		t.Errorf("unexpected payload: %v", payload)
+	}
+}
+
+func TestUpdateLeadFieldRejected(t *testing.T) {
+	server := fakes.NewServer(t, "testdata",
+		fakes.Route{Method: "PUT", Path: "/update-lead-field", Fixture: "rejected.txt", Status: http.StatusBadRequest},
+	)
+	t.Setenv("API_URL", server.URL)
+
+	err := UpdateLeadField("lead-1", "CompanyRegistrationID", "12.345.678/0001-90")
+	if !errors.Is(err, ErrRejected) {
+		t.Fatalf("expected ErrRejected, got %v", err)
+	}
+}
@@ -0,0 +1,35 @@
+package olhama
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/wbrunovieira/LeadSearchVersion2/forwarder/types"
+	"github.com/wbrunovieira/LeadSearchVersion2/shared/fakes"
+)
+
+func TestCallOlhamaAgainstFake(t *testing.T) {
+	server := fakes.NewServer(t, "testdata",
+		fakes.Route{Method: "POST", Path: "/api/chat", Fixture: "chat.json"},
+	)
+	t.Setenv("OLHAMA_URL", server.URL+"/api/chat")
+
+	resp, err := CallOlhama(types.CombinedLeadData{Prompt: "Extraia os dados"})
+	if err != nil {
+		t.Fatalf("CallOlhama failed: %v", err)
+	}
+	if resp.RegisteredName != "PADARIA BELA PAULISTA LTDA" || resp.CNPJ != "12.345.678/0001-95" {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+	if resp.RedesSociais.Instagram == "" {
+		t.Errorf("social profiles not parsed: %+v", resp.RedesSociais)
+	}
+
+	var payload types.OlhamaPayload
+	if err := json.Unmarshal(server.Requests()[0].Body, &payload); err != nil {
+		t.Fatalf("invalid payload: %v", err)
+	}
+	if len(payload.Messages) != 2 || payload.Stream {
+		t.Errorf("unexpected payload: %+v", payload)
+	}
+}
@@ -0,0 +1,44 @@
+package rabbitmq
+
+import (
+	"github.com/wbrunovieira/LeadSearchVersion2/forwarder/helpers"
+	"github.com/wbrunovieira/LeadSearchVersion2/forwarder/types"
+	"github.com/wbrunovieira/LeadSearchVersion2/shared/phone"
+)
+
+// collectPhones junta os telefones encontrados no enriquecimento: o WhatsApp
+// extraído pelo modelo, os telefones do cnpj.biz e os que aparecem no
+// conteúdo do Tavily. Cada número vai uma vez por origem.
+func collectPhones(data types.CombinedLeadData, resp *types.OlhamaResponse) []helpers.LeadPhone {
+	var phones []helpers.LeadPhone
+	seen := make(map[string]bool)
+	add := func(source string, numbers []phone.Number) {
+		for _, n := range numbers {
+			if key := source + n.E164; !seen[key] {
+				seen[key] = true
+				phones = append(phones, helpers.LeadPhone{Number: n.E164, Source: source})
+			}
+		}
+	}
+
+	if resp != nil && resp.RedesSociais.WhatsApp != "" {
+		if n, err := phone.Parse(resp.RedesSociais.WhatsApp); err == nil {
+			add("llm", []phone.Number{n})
+		}
+	}
+
+	add("cnpj_biz", phone.Find(data.CompanyDetailsCnpjBiz["telefone"]))
+	if details, ok := data.CNPJData["details"].(map[string]interface{}); ok {
+		if telefone, ok := details["telefone"].(string); ok {
+			add("cnpj_biz", phone.Find(telefone))
+		}
+	}
+
+	add("tavily", phone.Find(data.TavilyExtra.Phone))
+	if data.TavilyData != nil {
+		for _, result := range data.TavilyData.Results {
+			add("tavily", phone.Find(result.Content))
+		}
+	}
+	return phones
+}
@@ -0,0 +1,38 @@
+package rabbitmq
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/wbrunovieira/LeadSearchVersion2/forwarder/types"
+)
+
+func TestCollectPhones(t *testing.T) {
+	var data types.CombinedLeadData
+	payload := `{
+		"tavily_data": {"results": [{"content": "Padaria Bela Paulista. Tel: (11) 3333-4444. CNPJ 12.345.678/0001-95"}]},
+		"cnpj_data": {"details": {"telefone": "Telefone(s): (11) 3333-4444"}},
+		"company_details": {"telefone": "Telefone(s): (11) 3333-4444 / (11) 2222-1111"}
+	}`
+	if err := json.Unmarshal([]byte(payload), &data); err != nil {
+		t.Fatalf("invalid payload: %v", err)
+	}
+	resp := &types.OlhamaResponse{}
+	resp.RedesSociais.WhatsApp = "https://wa.me/5511912345678"
+
+	got := collectPhones(data, resp)
+	want := []string{
+		"llm +5511912345678",
+		"cnpj_biz +551133334444",
+		"cnpj_biz +551122221111",
+		"tavily +551133334444",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("collectPhones = %+v", got)
+	}
+	for i, p := range got {
+		if p.Source+" "+p.Number != want[i] {
+			t.Errorf("phone %d = %s %s, want %s", i, p.Source, p.Number, want[i])
+		}
+	}
+}
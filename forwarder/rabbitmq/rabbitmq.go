@@ -2,6 +2,7 @@ package rabbitmq
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"os"
 
@@ -10,6 +11,8 @@ import (
 	"github.com/wbrunovieira/LeadSearchVersion2/forwarder/helpers"
 	"github.com/wbrunovieira/LeadSearchVersion2/forwarder/olhama"
 	"github.com/wbrunovieira/LeadSearchVersion2/forwarder/types"
+	"github.com/wbrunovieira/LeadSearchVersion2/shared/cnpj"
+	"github.com/wbrunovieira/LeadSearchVersion2/shared/leadevents"
 )
 
 var (
@@ -52,6 +55,10 @@ func Connect() {
 		log.Fatalf("Erro ao declarar exchange fanout: %v", err)
 	}
 
+	if err := leadevents.Declare(Ch); err != nil {
+		log.Fatal(err)
+	}
+
 	// Cria fila exclusiva para o forwarder
 	_, err = Ch.QueueDeclare(
 		"forwarder_queue", // nome único da fila
@@ -106,7 +113,7 @@ func ConsumeQueue() {
 			log.Printf("ID do Lead: %s", data.Lead.ID)
 
 			// Etapa 1: Chamada inicial à IA (Olhama) para extração dos dados básicos.
-			data.Prompt = "Por favor, analise os dados do lead a seguir, obtidos por buscas no Google e complementados pelas chaves \"TavilyData\", \"SerperData\" e \"CNPJData\". Extraia e identifique estritamente os seguintes dados:\n- Razão Social da empresa (RegisteredName)\n- CNPJ\n- Contatos: insira o(s) nome(s) do(s) responsável(is) pela empresa, concatenando-os em uma única string separada por vírgula\n- Data de Fundação\n- Website\n- Redes Sociais: retorne um objeto com as chaves \"Facebook\", \"Instagram\", \"TikTok\" e \"WhatsApp\"\n\nInstruções adicionais:\n1. Retorne a resposta estritamente no formato JSON, sem nenhum texto adicional fora do JSON.\n2. Inclua um bloco interno de raciocínio entre <think> e </think> que contenha uma breve explicação do processo de extração dos dados.\n3. Não crie um campo separado para \"Responsavel\"; utilize apenas o campo \"Contatos\".\n4. Se algum campo não estiver disponível, retorne null (para dados ausentes) ou uma string vazia, conforme apropriado.\n5. A estrutura do JSON deve ser exatamente conforme especificado, sem campos extras.\n6. Caso seja identificado mais de um CNPJ nos dados, verifique o endereço do lead para confirmar qual CNPJ corresponde à unidade correta.\n\nExemplo de saída:\n{\n  \"RegisteredName\": \"Nome da Razão Social\",\n  \"CNPJ\": \"XX.XXX.XXX/0001-XX\",\n  \"Contatos\": \"Nome1, Nome2\",\n  \"DataFundacao\": \"AAAA-MM-DD\",\n  \"Website\": \"https://exemplo.com\",\n  \"RedesSociais\": {\n    \"Facebook\": \"url ou null\",\n    \"Instagram\": \"url ou null\",\n    \"TikTok\": \"url ou null\",\n    \"WhatsApp\": \"url ou null\"\n  },\n  \"<think>\": \"Breve explicação de como os dados foram extraídos...\"\n}"
+			data.Prompt = "Por favor, analise os dados do lead a seguir, obtidos por buscas no Google e complementados pelas chaves \"TavilyData\", \"SerperData\" e \"CNPJData\". Extraia e identifique estritamente os seguintes dados:\n- Razão Social da empresa (RegisteredName)\n- CNPJ\n- Contatos: insira o(s) nome(s) do(s) responsável(is) pela empresa, concatenando-os em uma única string separada por vírgula\n- Data de Fundação\n- Website\n- Redes Sociais: retorne um objeto com as chaves \"Facebook\", \"Instagram\", \"TikTok\", \"LinkedIn\", \"YouTube\", \"X\" e \"WhatsApp\", usando apenas links de perfil (nunca posts, vídeos ou buscas)\n\nInstruções adicionais:\n1. Retorne a resposta estritamente no formato JSON, sem nenhum texto adicional fora do JSON.\n2. Inclua um bloco interno de raciocínio entre <think> e </think> que contenha uma breve explicação do processo de extração dos dados.\n3. Não crie um campo separado para \"Responsavel\"; utilize apenas o campo \"Contatos\".\n4. Se algum campo não estiver disponível, retorne null (para dados ausentes) ou uma string vazia, conforme apropriado.\n5. A estrutura do JSON deve ser exatamente conforme especificado, sem campos extras.\n6. Caso seja identificado mais de um CNPJ nos dados, verifique o endereço do lead para confirmar qual CNPJ corresponde à unidade correta.\n\nExemplo de saída:\n{\n  \"RegisteredName\": \"Nome da Razão Social\",\n  \"CNPJ\": \"XX.XXX.XXX/0001-XX\",\n  \"Contatos\": \"Nome1, Nome2\",\n  \"DataFundacao\": \"AAAA-MM-DD\",\n  \"Website\": \"https://exemplo.com\",\n  \"RedesSociais\": {\n    \"Facebook\": \"url ou null\",\n    \"Instagram\": \"url ou null\",\n    \"TikTok\": \"url ou null\",\n    \"LinkedIn\": \"url ou null\",\n    \"YouTube\": \"url ou null\",\n    \"X\": \"url ou null\",\n    \"WhatsApp\": \"url ou null\"\n  },\n  \"<think>\": \"Breve explicação de como os dados foram extraídos...\"\n}"
 			log.Printf("Enviado para Olhama: %+v", data)
 
 			response, err := olhama.Publish(data)
@@ -126,49 +133,61 @@ func ConsumeQueue() {
 			log.Printf("Bloco de Raciocínio (Olhama): %s", olhamaResp.Think)
 			log.Printf("RegisteredName: %s", olhamaResp.RegisteredName)
 
-			// Atualiza campos básicos retornados
-			if err := helpers.UpdateLeadField(data.Lead.ID.String(), "RegisteredName", olhamaResp.RegisteredName); err != nil {
-				log.Printf("Erro ao atualizar RegisteredName: %v", err)
-				d.Nack(false, true)
+			// Atualiza campos básicos retornados. Valor recusado pela API (inválido)
+			// só é registrado; falha de comunicação devolve a mensagem para a fila.
+			update := func(field string, value interface{}) bool {
+				err := helpers.UpdateLeadField(data.Lead.ID.String(), field, value)
+				if errors.Is(err, helpers.ErrRejected) {
+					log.Printf("Valor de %s ignorado: %v", field, err)
+					return true
+				}
+				if err != nil {
+					log.Printf("Erro ao atualizar %s: %v", field, err)
+					d.Nack(false, true)
+					return false
+				}
+				return true
+			}
+
+			// O CNPJ do modelo só é gravado e usado nas consultas se os dígitos
+			// verificadores baterem.
+			companyCNPJ, cnpjErr := cnpj.Normalize(olhamaResp.CNPJ)
+			if cnpjErr != nil && olhamaResp.CNPJ != "" {
+				log.Printf("CNPJ %q devolvido pelo Olhama é inválido: %v", olhamaResp.CNPJ, cnpjErr)
+			}
+
+			if !update("RegisteredName", olhamaResp.RegisteredName) {
 				continue
 			}
-			if err := helpers.UpdateLeadField(data.Lead.ID.String(), "CompanyRegistrationID", olhamaResp.CNPJ); err != nil {
-				log.Printf("Erro ao atualizar CompanyRegistrationID: %v", err)
-				d.Nack(false, true)
+			if cnpjErr == nil && !update("CompanyRegistrationID", companyCNPJ) {
 				continue
 			}
-			if err := helpers.UpdateLeadField(data.Lead.ID.String(), "Owner", olhamaResp.Contatos); err != nil {
-				log.Printf("Erro ao atualizar Owner: %v", err)
-				d.Nack(false, true)
+			if !update("Owner", olhamaResp.Contatos) {
 				continue
 			}
-			if err := helpers.UpdateLeadField(data.Lead.ID.String(), "FoundationDate", olhamaResp.DataDeFundacao); err != nil {
-				log.Printf("Erro ao atualizar FoundationDate: %v", err)
-				d.Nack(false, true)
+			if !update("FoundationDate", olhamaResp.DataDeFundacao) {
 				continue
 			}
-			if err := helpers.UpdateLeadField(data.Lead.ID.String(), "Website", olhamaResp.Website); err != nil {
-				log.Printf("Erro ao atualizar Website: %v", err)
-				d.Nack(false, true)
+			if !update("Website", olhamaResp.Website) {
 				continue
 			}
-			// RedesSociais field doesn't exist in database, skipping update
-			// if err := helpers.UpdateLeadField(data.Lead.ID.String(), "RedesSociais", olhamaResp.RedesSociais); err != nil {
-			// 	log.Printf("Erro ao atualizar RedesSociais: %v", err)
-			// 	d.Nack(false, true)
-			// 	continue
-			// }
+			// Perfis sociais do modelo e do Tavily vão para lead_social_profiles;
+			// a API valida cada link e recusa os que não são perfis.
+			profiles := collectSocialProfiles(data, &olhamaResp)
+			if err := helpers.AddLeadSocialProfiles(data.Lead.ID.String(), profiles); err != nil {
+				log.Printf("Erro ao enviar perfis sociais do lead: %v", err)
+			}
 
 			// Etapa 2: Enriquecimento dos dados com informações externas usando o CNPJ retornado.
-			if olhamaResp.CNPJ != "" {
-				companyDetails, err := companydetails.FetchCompanyDetailsCNPJBIZ(olhamaResp.CNPJ)
+			if cnpjErr == nil {
+				companyDetails, err := companydetails.FetchCompanyDetailsCNPJBIZ(companyCNPJ)
 				if err != nil {
 					log.Printf("Erro ao buscar detalhes via CNPJBIZ: %v", err)
 				} else {
 					data.CompanyDetailsCnpjBiz = companyDetails
 				}
 
-				inverterData, err := companydetails.FetchCNPJDataInverter(olhamaResp.CNPJ)
+				inverterData, err := companydetails.FetchCNPJDataInverter(companyCNPJ)
 				if err != nil {
 					log.Printf("Erro ao buscar dados via Invertexto: %v", err)
 				} else {
@@ -176,11 +195,29 @@ func ConsumeQueue() {
 				}
 			}
 
+			// Telefones do modelo, do cnpj.biz e do Tavily vão para lead_phones.
+			phones := collectPhones(data, &olhamaResp)
+			if err := helpers.AddLeadPhones(data.Lead.ID.String(), phones); err != nil {
+				log.Printf("Erro ao enviar telefones do lead: %v", err)
+			}
+
 			// Etapa 3: Skip second Ollama call for now due to timeout issues
 			// TODO: Fix Ollama2 timeout/truncation issue
 			log.Printf("Skipping Olhama2 analysis due to timeout issues")
 
 			log.Printf("MensagemWhatsApp (Olhama original): %s", olhamaResp.Message.Content)
+
+			event := leadevents.Event{Event: "lead.llm_extracted", LeadID: data.Lead.ID.String(), Details: map[string]interface{}{
+				"cnpj_found":      cnpjErr == nil,
+				"cnpj":            companyCNPJ,
+				"registered_name": olhamaResp.RegisteredName != "",
+				"website":         olhamaResp.Website != "",
+				"phones":          len(phones),
+				"social_profiles": len(profiles),
+			}}
+			if err := leadevents.Publish(Ch, event); err != nil {
+				log.Printf("Erro ao anunciar a extração do lead %s: %v", data.Lead.ID, err)
+			}
 			d.Ack(false)
 		}
 	}()
@@ -0,0 +1,40 @@
+package rabbitmq
+
+import (
+	"github.com/wbrunovieira/LeadSearchVersion2/forwarder/helpers"
+	"github.com/wbrunovieira/LeadSearchVersion2/forwarder/types"
+	"github.com/wbrunovieira/LeadSearchVersion2/shared/social"
+)
+
+// collectSocialProfiles junta os perfis sociais encontrados no
+// enriquecimento: os links devolvidos pelo modelo e os resultados do Tavily
+// que são páginas de perfil. Só vão links que passam em social.Parse, já na
+// forma canônica, uma vez por origem.
+func collectSocialProfiles(data types.CombinedLeadData, resp *types.OlhamaResponse) []helpers.SocialProfile {
+	var profiles []helpers.SocialProfile
+	seen := make(map[string]bool)
+	add := func(source, raw string) {
+		profile, err := social.Parse(raw)
+		if err != nil {
+			return
+		}
+		if key := source + profile.URL; !seen[key] {
+			seen[key] = true
+			profiles = append(profiles, helpers.SocialProfile{URL: profile.URL, Source: source})
+		}
+	}
+
+	if resp != nil {
+		redes := resp.RedesSociais
+		for _, raw := range []string{redes.Instagram, redes.Facebook, redes.TikTok, redes.LinkedIn, redes.YouTube, redes.X} {
+			add("llm", raw)
+		}
+	}
+
+	if data.TavilyData != nil {
+		for _, result := range data.TavilyData.Results {
+			add("tavily", result.URL)
+		}
+	}
+	return profiles
+}
@@ -0,0 +1,45 @@
+package rabbitmq
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/wbrunovieira/LeadSearchVersion2/forwarder/types"
+)
+
+func TestCollectSocialProfiles(t *testing.T) {
+	var data types.CombinedLeadData
+	payload := `{
+		"tavily_data": {"results": [
+			{"url": "https://www.instagram.com/padariabela/?hl=pt-br"},
+			{"url": "https://www.instagram.com/p/C1a2b3c4d5/"},
+			{"url": "https://padariabela.com.br/contato"},
+			{"url": "https://br.linkedin.com/company/padaria-bela"}
+		]}
+	}`
+	if err := json.Unmarshal([]byte(payload), &data); err != nil {
+		t.Fatalf("invalid payload: %v", err)
+	}
+	resp := &types.OlhamaResponse{}
+	resp.RedesSociais.Instagram = "instagram.com/PadariaBela"
+	resp.RedesSociais.Facebook = "url ou null"
+	resp.RedesSociais.TikTok = "https://www.tiktok.com/@padariabela/video/7300000000000000000"
+	resp.RedesSociais.X = "https://twitter.com/PadariaBela"
+
+	got := collectSocialProfiles(data, resp)
+	want := []string{
+		"llm https://www.instagram.com/padariabela/",
+		"llm https://www.tiktok.com/@padariabela",
+		"llm https://x.com/padariabela",
+		"tavily https://www.instagram.com/padariabela/",
+		"tavily https://www.linkedin.com/company/padaria-bela/",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("collectSocialProfiles = %+v", got)
+	}
+	for i, p := range got {
+		if p.Source+" "+p.URL != want[i] {
+			t.Errorf("profile %d = %s %s, want %s", i, p.Source, p.URL, want[i])
+		}
+	}
+}
@@ -57,6 +57,9 @@ type OlhamaResponse struct {
 		Facebook  string `json:"Facebook"`
 		Instagram string `json:"Instagram"`
 		TikTok    string `json:"TikTok"`
+		LinkedIn  string `json:"LinkedIn"`
+		YouTube   string `json:"YouTube"`
+		X         string `json:"X"`
 		WhatsApp  string `json:"WhatsApp"`
 	} `json:"RedesSociais"`
 	AnaliseEmpresa interface{} `json:"AnaliseEmpresa"`
@@ -73,6 +76,9 @@ type MinimalOlhamaResponse struct {
 		Facebook  string `json:"Facebook"`
 		Instagram string `json:"Instagram"`
 		TikTok    string `json:"TikTok"`
+		LinkedIn  string `json:"LinkedIn"`
+		YouTube   string `json:"YouTube"`
+		X         string `json:"X"`
 		WhatsApp  string `json:"WhatsApp"`
 	} `json:"RedesSociais"`
 }
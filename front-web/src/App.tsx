@@ -1,16 +1,39 @@
 // src/App.tsx
 
+import { useState } from 'react';
+import Login from './components/Login';
 import SearchLeads from './components/SearchLeads';
+import { Session, clearSession, loadSession } from './utils/session';
 
 function App() {
+  const [session, setSession] = useState<Session | null>(loadSession);
+  const [notice, setNotice] = useState<string>('');
+
+  const handleLogout = (reason = '') => {
+    clearSession();
+    setSession(null);
+    setNotice(reason);
+  };
 
   return (
     <div className="bg-slate-100 text-black p-4 max-w-4xl mx-auto my-8 rounded-lg shadow-md">
-     <SearchLeads />
-
-     
+      {session ? (
+        <SearchLeads
+          session={session}
+          onLogout={() => handleLogout()}
+          onUnauthorized={() => handleLogout('Sua sessão expirou. Entre novamente.')}
+        />
+      ) : (
+        <Login
+          notice={notice}
+          onLogin={s => {
+            setNotice('');
+            setSession(s);
+          }}
+        />
+      )}
     </div>
   );
 }
 
-export default App;
\ No newline at end of file
+export default App;
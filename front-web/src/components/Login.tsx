@@ -0,0 +1,75 @@
+// /src/components/Login.tsx
+import React, { FormEvent, useState } from 'react';
+import { Session, login } from '../utils/session';
+
+interface LoginProps {
+  onLogin: (session: Session) => void;
+  notice?: string;
+}
+
+const Login: React.FC<LoginProps> = ({ onLogin, notice }) => {
+  const [email, setEmail] = useState<string>('');
+  const [password, setPassword] = useState<string>('');
+  const [error, setError] = useState<string>('');
+  const [loading, setLoading] = useState<boolean>(false);
+
+  const handleSubmit = async (e: FormEvent) => {
+    e.preventDefault();
+    setLoading(true);
+    setError('');
+    try {
+      onLogin(await login(email, password));
+    } catch (err) {
+      setError(err instanceof Error ? err.message : 'Erro de conexão com o backend');
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  return (
+    <form onSubmit={handleSubmit} className="bg-white p-6 rounded-md max-w-sm mx-auto">
+      <h1 className="text-2xl font-bold text-blue-600 mb-6">Entrar</h1>
+
+      {notice && !error && (
+        <div className="mb-4 p-3 rounded-md bg-blue-50 text-blue-800 border border-blue-300">{notice}</div>
+      )}
+
+      <label className="block text-gray-700 font-medium mb-2">E-mail:</label>
+      <input
+        type="email"
+        value={email}
+        onChange={e => setEmail(e.target.value)}
+        autoComplete="username"
+        required
+        className="w-full p-2 mb-4 border border-gray-300 rounded text-black"
+      />
+
+      <label className="block text-gray-700 font-medium mb-2">Senha:</label>
+      <input
+        type="password"
+        value={password}
+        onChange={e => setPassword(e.target.value)}
+        autoComplete="current-password"
+        required
+        className="w-full p-2 mb-4 border border-gray-300 rounded text-black"
+      />
+
+      {error && (
+        <div className="mb-4 p-3 rounded-md bg-red-50 text-red-800 border border-red-300">
+          <span className="inline-block mr-2">❌</span>
+          {error}
+        </div>
+      )}
+
+      <button
+        type="submit"
+        disabled={loading}
+        className="bg-blue-500 hover:bg-blue-600 text-white py-2 px-6 rounded-md font-semibold disabled:opacity-50"
+      >
+        {loading ? 'Entrando...' : 'Entrar'}
+      </button>
+    </form>
+  );
+};
+
+export default Login;
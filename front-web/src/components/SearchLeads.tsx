@@ -1,10 +1,16 @@
-import { useState } from "react";
+import React, { useState } from "react";
 import { LeadAPI, LeadFront, mapLeadAPIToFront } from "../interfaces/leads";
 import { categories } from "../utils/categories";
+import { Session, authHeaders } from "../utils/session";
 import LeadList from "./LeadList";
 
+interface SearchLeadsProps {
+  session: Session;
+  onLogout: () => void;
+  onUnauthorized: () => void;
+}
 
-const SearchLeads = () => {
+const SearchLeads: React.FC<SearchLeadsProps> = ({ session, onLogout, onUnauthorized }) => {
 
     const [categoryID, setCategoryID] = useState<string>('');
     const [zipcodeID, setZipcodeID] = useState<string>('');
@@ -17,6 +23,7 @@ const SearchLeads = () => {
   
     const BACKEND_URL_Search_GOOGLE = import.meta.env.VITE_BACKEND_SEARCH_URL;
     const BACKEND_URL_API = import.meta.env.VITE_BACKEND_API_URL;
+    const headers = authHeaders(session);
   
     const handleStartSearch = async () => {
       try {
@@ -26,7 +33,7 @@ const SearchLeads = () => {
         const normalizedZipcode = zipcodeID.replace(/\D/g, '');
   
         const url = `${BACKEND_URL_Search_GOOGLE}/start-search?category_id=${categoryID}&zipcode_id=${normalizedZipcode}&radius=${radius}&max_results=${maxResults}&country=${country}`;
-        const response = await fetch(url);
+        const response = await fetch(url, { headers });
         console.log('url', url);
         console.log('zipcodeID', zipcodeID);
         console.log('normalizedZipcode', normalizedZipcode);
@@ -36,6 +43,15 @@ const SearchLeads = () => {
           setMessage(`Busca concluída com sucesso! ${text}`);
           setMessageType('success');
     
+        } else if (response.status === 401) {
+          onUnauthorized();
+        } else if (response.status === 403) {
+          setMessage('Seu usuário não tem permissão para iniciar buscas.');
+          setMessageType('error');
+        } else if (response.status === 429) {
+          const retryAfter = response.headers.get('Retry-After');
+          setMessage(`Muitas buscas em pouco tempo. Tente novamente em ${retryAfter ?? 'alguns'} segundos.`);
+          setMessageType('error');
         } else {
           setMessage('Erro ao iniciar a busca');
           setMessageType('error');
@@ -50,9 +66,11 @@ const SearchLeads = () => {
   const handleGetLeads = async () => {
     try {
       const url = `${BACKEND_URL_API}/list-leads`;
-      const response = await fetch(url);
+      const response = await fetch(url, { headers });
       console.log('leads list response', response);
-      if (response.ok) {
+      if (response.status === 401) {
+        onUnauthorized();
+      } else if (response.ok) {
         const rawData: LeadAPI[] = await response.json();
   
         const data: LeadFront[] = rawData.map(mapLeadAPIToFront);
@@ -70,9 +88,17 @@ const SearchLeads = () => {
   };
   return (
        <div className="bg-slate-100 text-black p-4 max-w-4xl mx-auto my-8 rounded-lg shadow-md">
-      <h1 className="text-3xl font-bold text-blue-600 mb-6">
-        Buscar Leads no Google Places
-      </h1>
+      <div className="flex items-center justify-between mb-6">
+        <h1 className="text-3xl font-bold text-blue-600">
+          Buscar Leads no Google Places
+        </h1>
+        <div className="text-sm text-gray-600">
+          {session.user.name || session.user.email} ({session.user.role})
+          <button onClick={onLogout} className="ml-3 text-blue-600 hover:underline">
+            Sair
+          </button>
+        </div>
+      </div>
 
 
       <div className="mb-4 bg-white p-4 rounded-md">
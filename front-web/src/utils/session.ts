@@ -0,0 +1,77 @@
+// /src/utils/session.ts
+
+// A sessão guarda o JWT devolvido por /login só na aba atual (sessionStorage).
+// Nenhuma credencial vai no bundle: quem abre o front precisa entrar.
+
+export interface SessionUser {
+  id: string;
+  email: string;
+  name: string;
+  role: string;
+  workspaces: string[];
+}
+
+export interface Session {
+  token: string;
+  expiresAt: string;
+  user: SessionUser;
+}
+
+const STORAGE_KEY = 'leads.session';
+
+export const loadSession = (): Session | null => {
+  const raw = sessionStorage.getItem(STORAGE_KEY);
+  if (!raw) {
+    return null;
+  }
+  try {
+    const session: Session = JSON.parse(raw);
+    if (new Date(session.expiresAt).getTime() <= Date.now()) {
+      sessionStorage.removeItem(STORAGE_KEY);
+      return null;
+    }
+    return session;
+  } catch {
+    sessionStorage.removeItem(STORAGE_KEY);
+    return null;
+  }
+};
+
+export const saveSession = (session: Session) => {
+  sessionStorage.setItem(STORAGE_KEY, JSON.stringify(session));
+};
+
+export const clearSession = () => {
+  sessionStorage.removeItem(STORAGE_KEY);
+};
+
+export const login = async (email: string, password: string): Promise<Session> => {
+  const response = await fetch(`${import.meta.env.VITE_BACKEND_API_URL}/login`, {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ email, password }),
+  });
+  if (response.status === 401) {
+    throw new Error('E-mail ou senha inválidos');
+  }
+  if (response.status === 429) {
+    const seconds = response.headers.get('Retry-After') ?? '60';
+    throw new Error(`Muitas tentativas de login. Tente de novo em ${seconds} segundos.`);
+  }
+  if (!response.ok) {
+    throw new Error('Não foi possível entrar. Tente novamente.');
+  }
+  const data = await response.json();
+  const session: Session = { token: data.token, expiresAt: data.expires_at, user: data.user };
+  saveSession(session);
+  return session;
+};
+
+export const authHeaders = (session: Session): Record<string, string> => {
+  const headers: Record<string, string> = { Authorization: `Bearer ${session.token}` };
+  const workspace = import.meta.env.VITE_WORKSPACE;
+  if (workspace) {
+    headers['X-Workspace'] = workspace;
+  }
+  return headers;
+};
@@ -0,0 +1,85 @@
+// /search-google/access.go
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/wbrunovieira/LeadSearchVersion2/shared/auth"
+)
+
+var authenticator *auth.Authenticator
+
+func initAuth() error {
+	var err error
+	authenticator, err = auth.FromEnv()
+	if err != nil {
+		return err
+	}
+	if !authenticator.Configured() {
+		log.Println("AUTH_API_KEYS e AUTH_JWT_SECRET vazios: todas as rotas protegidas vão recusar acesso")
+	}
+	return nil
+}
+
+// requireRole só deixa passar requisições autenticadas com o papel exigido.
+// byMethod permite exigir outro papel para certos métodos.
+func requireRole(role auth.Role, byMethod map[string]auth.Role, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		required := role
+		if methodRole, ok := byMethod[r.Method]; ok {
+			required = methodRole
+		}
+
+		principal, err := authenticator.Authenticate(r)
+		if err != nil {
+			log.Printf("Acesso negado a %s %s de %s: %v", r.Method, r.URL.Path, r.RemoteAddr, err)
+			if errors.Is(err, auth.ErrMissingCredentials) {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="leads"`)
+			}
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !principal.Can(required) {
+			log.Printf("Acesso negado a %s %s para %s (papel %s, exige %s)", r.Method, r.URL.Path, principal.Name, principal.Role, required)
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		next(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+	}
+}
+
+// apiTokenTransport coloca a credencial de serviço (API_TOKEN) nas chamadas
+// do search-google para a API.
+type apiTokenTransport struct {
+	base http.RoundTripper
+}
+
+func (t apiTokenTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	token := os.Getenv("API_TOKEN")
+	if token == "" {
+		return t.base.RoundTrip(r)
+	}
+	r = r.Clone(r.Context())
+	r.Header.Set("Authorization", "Bearer "+token)
+	return t.base.RoundTrip(r)
+}
+
+// corsAllowedOrigins lê CORS_ALLOWED_ORIGINS (lista separada por vírgula;
+// "*" libera todas). O padrão é o front-web do docker-compose.
+func corsAllowedOrigins() map[string]bool {
+	config := os.Getenv("CORS_ALLOWED_ORIGINS")
+	if config == "" {
+		config = "http://localhost:5173"
+	}
+	allowed := make(map[string]bool)
+	for _, origin := range strings.Split(config, ",") {
+		if origin = strings.TrimRight(strings.TrimSpace(origin), "/"); origin != "" {
+			allowed[origin] = true
+		}
+	}
+	return allowed
+}
@@ -0,0 +1,214 @@
+// /search-google/apiclient.go
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/wbrunovieira/LeadSearchVersion2/shared/places"
+)
+
+var apiClient = &http.Client{
+	Timeout:   30 * time.Second,
+	Transport: apiTokenTransport{base: http.DefaultTransport},
+}
+
+func apiBaseURL() string {
+	if base := os.Getenv("API_URL"); base != "" {
+		return base
+	}
+	return "http://api:8085"
+}
+
+// postToWorkspace faz um POST na API dentro do workspace informado (slug ou
+// ID). Sem workspace, a API usa o escopo padrão da credencial.
+func postToWorkspace(path, workspace string, payload []byte) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodPost, apiBaseURL()+path, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if workspace != "" {
+		req.Header.Set("X-Workspace", workspace)
+	}
+	return apiClient.Do(req)
+}
+
+// detailsMaxAgeDays é a idade máxima dos detalhes de um lead para que o
+// search-google não chame o Place Details de novo.
+func detailsMaxAgeDays() int {
+	if v, err := strconv.Atoi(os.Getenv("PLACE_DETAILS_MAX_AGE_DAYS")); err == nil && v > 0 {
+		return v
+	}
+	return 30
+}
+
+// fetchFreshPlaceIDs pergunta à API quais PlaceIDs já são leads do workspace
+// com detalhes recentes. Com anyAge, devolve todos os que já são leads.
+func fetchFreshPlaceIDs(workspace string, placeIDs []string, anyAge bool) (map[string]bool, error) {
+	payload, err := json.Marshal(map[string]interface{}{
+		"place_ids":    placeIDs,
+		"max_age_days": detailsMaxAgeDays(),
+		"any_age":      anyAge,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("erro ao converter PlaceIDs para JSON: %v", err)
+	}
+
+	resp, err := postToWorkspace("/known-places", workspace, payload)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao consultar places conhecidos: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("API retornou status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result struct {
+		Fresh []string `json:"fresh"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("erro ao decodificar places conhecidos: %v", err)
+	}
+	fresh := make(map[string]bool, len(result.Fresh))
+	for _, id := range result.Fresh {
+		fresh[id] = true
+	}
+	return fresh, nil
+}
+
+func fetchStalePlaceIDs(olderThanDays, limit int) ([]string, error) {
+	query := url.Values{}
+	query.Set("older_than_days", strconv.Itoa(olderThanDays))
+	query.Set("limit", strconv.Itoa(limit))
+
+	resp, err := apiClient.Get(apiBaseURL() + "/stale-places?" + query.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("erro ao buscar places desatualizados: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("API retornou status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result struct {
+		PlaceIDs []string `json:"place_ids"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("erro ao decodificar places desatualizados: %v", err)
+	}
+	return result.PlaceIDs, nil
+}
+
+func sendRefreshedPlaces(refreshed []places.Place) error {
+	payload, err := json.Marshal(refreshed)
+	if err != nil {
+		return fmt.Errorf("erro ao converter places para JSON: %v", err)
+	}
+
+	resp, err := apiClient.Post(apiBaseURL()+"/refresh-leads", "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("erro ao enviar places atualizados: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("API retornou status %d: %s", resp.StatusCode, string(body))
+	}
+	log.Printf("%d places atualizados enviados para a API", len(refreshed))
+	return nil
+}
+
+// sendRefreshFailures informa à API os places cujo Place Details falhou
+// (failed) ou não existe mais (notFound), para que saiam do começo da fila de
+// /stale-places.
+func sendRefreshFailures(failed, notFound []string) error {
+	payload, err := json.Marshal(map[string][]string{"place_ids": failed, "not_found": notFound})
+	if err != nil {
+		return fmt.Errorf("erro ao converter falhas para JSON: %v", err)
+	}
+
+	resp, err := apiClient.Post(apiBaseURL()+"/refresh-leads/failures", "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("erro ao enviar falhas da atualização: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("API retornou status %d: %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+// savedSearch é a busca agendada como a API a devolve.
+type savedSearch struct {
+	ID            string  `json:"id"`
+	WorkspaceID   string  `json:"workspace_id"`
+	WorkspaceSlug string  `json:"workspace_slug"`
+	Name          string  `json:"name"`
+	CategoryID    string  `json:"category_id"`
+	ZipCode       string  `json:"zipcode"`
+	Country       string  `json:"country"`
+	Radius        int     `json:"radius"`
+	MaxResults    int     `json:"max_results"`
+	BudgetUSD     float64 `json:"budget_usd"`
+}
+
+// workspace é o slug do workspace da busca, que é como o consumo do job
+// fica registrado; o ID só vale para APIs antigas que não mandam o slug.
+func (s savedSearch) workspace() string {
+	if s.WorkspaceSlug != "" {
+		return s.WorkspaceSlug
+	}
+	return s.WorkspaceID
+}
+
+func claimDueSavedSearches() ([]savedSearch, error) {
+	resp, err := apiClient.Post(apiBaseURL()+"/saved-searches/claim-due", "application/json", nil)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao buscar buscas agendadas: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("API retornou status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var searches []savedSearch
+	if err := json.NewDecoder(resp.Body).Decode(&searches); err != nil {
+		return nil, fmt.Errorf("erro ao decodificar buscas agendadas: %v", err)
+	}
+	return searches, nil
+}
+
+func reportSavedSearchRun(id, jobID string, runErr error) error {
+	errMsg := ""
+	if runErr != nil {
+		errMsg = runErr.Error()
+	}
+	payload, err := json.Marshal(map[string]string{"id": id, "job_id": jobID, "error": errMsg})
+	if err != nil {
+		return fmt.Errorf("erro ao converter resultado para JSON: %v", err)
+	}
+
+	resp, err := apiClient.Post(apiBaseURL()+"/saved-searches/run", "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("erro ao registrar execução: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("API retornou status %d: %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+// /search-google/budget.go
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/wbrunovieira/LeadSearchVersion2/search-google/googleplaces"
+	"github.com/wbrunovieira/LeadSearchVersion2/search-google/usage"
+	"github.com/wbrunovieira/LeadSearchVersion2/shared/auth"
+)
+
+var ledger *usage.Ledger
+
+// ledgerFlushInterval é de quanto em quanto tempo as chamadas contabilizadas
+// em memória são gravadas em places_usage.json.
+const ledgerFlushInterval = 10 * time.Second
+
+// dataDir é onde o search-google guarda seu estado (tokens de página,
+// consumo, caches). Em produção é o volume /app/lead-search.
+func dataDir() string {
+	if dir := os.Getenv("DATA_DIR"); dir != "" {
+		return dir
+	}
+	return "/app/lead-search"
+}
+
+func initLedger() error {
+	budget := usage.Budget{}
+	for env, target := range map[string]*float64{
+		"PLACES_DAILY_BUDGET_USD":   &budget.DailyUSD,
+		"PLACES_MONTHLY_BUDGET_USD": &budget.MonthlyUSD,
+		"PLACES_JOB_BUDGET_USD":     &budget.JobUSD,
+	} {
+		value := os.Getenv(env)
+		if value == "" {
+			continue
+		}
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("%s inválido: %v", env, err)
+		}
+		*target = parsed
+	}
+
+	prices, err := usage.ParsePrices(os.Getenv("PLACES_PRICES"))
+	if err != nil {
+		return fmt.Errorf("PLACES_PRICES inválido: %v", err)
+	}
+
+	ledger, err = usage.NewLedger(dataDir()+"/places_usage.json", budget, prices)
+	if err != nil {
+		return err
+	}
+	onShutdown(ledger.StartFlusher(ledgerFlushInterval))
+	log.Printf("Orçamento do Google Places: diário=US$%.2f mensal=US$%.2f por job=US$%.2f (0 = sem limite)",
+		budget.DailyUSD, budget.MonthlyUSD, budget.JobUSD)
+	return nil
+}
+
+// estimateSearchCalls estima o pior caso de chamadas de uma busca: um
+// geocode, até maxPages páginas de Text Search (20 resultados cada) e um
+// Place Details por resultado.
+func estimateSearchCalls(maxResults, maxPages int, detailsFields []string) usage.Counts {
+	pages := (maxResults + 19) / 20
+	if pages > maxPages {
+		pages = maxPages
+	}
+	if pages < 1 {
+		pages = 1
+	}
+	calls := usage.Counts{
+		usage.SKUGeocoding:  1,
+		usage.SKUTextSearch: pages,
+	}
+	for _, sku := range googleplaces.DetailsSKUs(detailsFields) {
+		calls[sku] += maxResults
+	}
+	return calls
+}
+
+func newJobID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		log.Printf("Erro ao gerar ID do job: %v", err)
+	}
+	return hex.EncodeToString(b)
+}
+
+func usageHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método não permitido. Use GET.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Quem só enxerga alguns workspaces vê só o consumo dos jobs deles; job de
+	// outro workspace responde como inexistente.
+	principal, _ := auth.FromContext(r.Context())
+	restricted := len(principal.Workspaces) > 0
+
+	w.Header().Set("Content-Type", "application/json")
+	if jobID := r.URL.Query().Get("job_id"); jobID != "" {
+		job, ok := ledger.Job(jobID)
+		if !ok || restricted && (job.Workspace == "" || !principal.CanAccessWorkspace(job.Workspace)) {
+			http.Error(w, "Job não encontrado", http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(job)
+		return
+	}
+	if restricted {
+		json.NewEncoder(w).Encode(ledger.ReportFor(principal.CanAccessWorkspace))
+		return
+	}
+	json.NewEncoder(w).Encode(ledger.Report())
+}
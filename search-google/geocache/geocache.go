@@ -0,0 +1,228 @@
+// /search-google/geocache/geocache.go
+package geocache
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wbrunovieira/LeadSearchVersion2/search-google/googleplaces"
+)
+
+// Entry é um CEP já geocodificado.
+type Entry struct {
+	Country    string                     `json:"country"`
+	PostalCode string                     `json:"postal_code"`
+	Result     googleplaces.GeocodeResult `json:"result"`
+	FetchedAt  time.Time                  `json:"fetched_at"`
+	Hits       int                        `json:"hits"`
+}
+
+// Cache guarda geocodificações de CEP em um arquivo JSON, chaveadas por país
+// e CEP, para não pagar o Geocoding a cada busca no mesmo lugar. Put grava na
+// hora; a contagem de acertos fica em memória até o próximo Flush.
+type Cache struct {
+	mu      sync.Mutex
+	path    string
+	ttl     time.Duration
+	entries map[string]*Entry
+	dirty   bool
+	now     func() time.Time
+}
+
+func New(path string, ttl time.Duration) (*Cache, error) {
+	c := &Cache{
+		path:    path,
+		ttl:     ttl,
+		entries: make(map[string]*Entry),
+		now:     time.Now,
+	}
+
+	file, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			log.Printf("Cache de geocodificação %s não encontrado. Começando vazio.", path)
+			return c, nil
+		}
+		return nil, fmt.Errorf("erro ao ler o cache de geocodificação: %v", err)
+	}
+	if len(strings.TrimSpace(string(file))) > 0 {
+		if err := json.Unmarshal(file, &c.entries); err != nil {
+			return nil, fmt.Errorf("erro ao fazer parse do cache de geocodificação: %v", err)
+		}
+	}
+	return c, nil
+}
+
+// Key normaliza país e CEP: país em minúsculas e CEP só com dígitos e letras.
+func Key(country, postalCode string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(postalCode) {
+		if (r >= '0' && r <= '9') || (r >= 'A' && r <= 'Z') {
+			b.WriteRune(r)
+		}
+	}
+	return strings.ToLower(strings.TrimSpace(country)) + "|" + b.String()
+}
+
+// PostalCode valida o CEP recebido numa busca e devolve só os dígitos, sem
+// perder os zeros à esquerda (01310-100 vira 01310100, a mesma forma de Key).
+func PostalCode(raw string) (string, error) {
+	var b strings.Builder
+	for _, r := range strings.TrimSpace(raw) {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == '-' || r == '.' || r == ' ':
+		default:
+			return "", fmt.Errorf("CEP inválido: %q", raw)
+		}
+	}
+	if b.Len() == 0 {
+		return "", fmt.Errorf("CEP inválido: %q", raw)
+	}
+	return b.String(), nil
+}
+
+// Get devolve a entrada se ela existir e ainda estiver dentro do TTL, e conta
+// o acerto.
+func (c *Cache) Get(country, postalCode string) (*Entry, bool) {
+	return c.get(country, postalCode, true)
+}
+
+// Peek é o Get sem contar acerto, para consultas ao cache que não são buscas.
+func (c *Cache) Peek(country, postalCode string) (*Entry, bool) {
+	return c.get(country, postalCode, false)
+}
+
+func (c *Cache) get(country, postalCode string, hit bool) (*Entry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[Key(country, postalCode)]
+	if !ok || c.expired(entry) {
+		return nil, false
+	}
+	if hit {
+		entry.Hits++
+		c.dirty = true
+	}
+	out := *entry
+	return &out, true
+}
+
+func (c *Cache) Put(country, postalCode string, result googleplaces.GeocodeResult) (*Entry, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := &Entry{
+		Country:    strings.ToLower(strings.TrimSpace(country)),
+		PostalCode: strings.TrimSpace(postalCode),
+		Result:     result,
+		FetchedAt:  c.now(),
+	}
+	c.entries[Key(country, postalCode)] = entry
+	out := *entry
+	return &out, c.save()
+}
+
+// Lookup devolve a entrada do cache ou, se ela não existir ou tiver expirado,
+// geocodifica com o serviço e guarda o resultado.
+func (c *Cache) Lookup(service *googleplaces.Service, country, postalCode string) (*Entry, error) {
+	if entry, ok := c.Get(country, postalCode); ok {
+		log.Printf("Geocodificação do CEP %s (%s) encontrada no cache", postalCode, country)
+		return entry, nil
+	}
+	result, err := service.Geocode(postalCode, country)
+	if err != nil {
+		return nil, err
+	}
+	return c.Put(country, postalCode, *result)
+}
+
+// Entries lista as entradas do cache, indicando se cada uma já expirou.
+func (c *Cache) Entries() []EntryStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	list := make([]EntryStatus, 0, len(c.entries))
+	for _, entry := range c.entries {
+		list = append(list, EntryStatus{Entry: *entry, Expired: c.expired(entry)})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return Key(list[i].Country, list[i].PostalCode) < Key(list[j].Country, list[j].PostalCode)
+	})
+	return list
+}
+
+type EntryStatus struct {
+	Entry
+	Expired bool `json:"expired"`
+}
+
+func (c *Cache) expired(entry *Entry) bool {
+	return c.ttl > 0 && c.now().Sub(entry.FetchedAt) > c.ttl
+}
+
+// Flush grava o cache se houver acertos ainda não persistidos.
+func (c *Cache) Flush() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.dirty {
+		return nil
+	}
+	return c.save()
+}
+
+// StartFlusher roda Flush a cada interval até stop ser chamado; stop faz um
+// último Flush, para ser usado no desligamento.
+func (c *Cache) StartFlusher(interval time.Duration) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				if err := c.Flush(); err != nil {
+					log.Printf("Erro ao salvar o cache de geocodificação: %v", err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			ticker.Stop()
+			close(done)
+			if err := c.Flush(); err != nil {
+				log.Printf("Erro ao salvar o cache de geocodificação: %v", err)
+			}
+		})
+	}
+}
+
+func (c *Cache) save() error {
+	if dir := filepath.Dir(c.path); dir != "" {
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+			return fmt.Errorf("erro ao criar o diretório %s: %v", dir, err)
+		}
+	}
+	bytes, err := json.MarshalIndent(c.entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("erro ao serializar o cache de geocodificação: %v", err)
+	}
+	if err := os.WriteFile(c.path, bytes, 0644); err != nil {
+		return fmt.Errorf("erro ao salvar o cache de geocodificação: %v", err)
+	}
+	c.dirty = false
+	return nil
+}
@@ -0,0 +1,81 @@
+package geocache
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/wbrunovieira/LeadSearchVersion2/search-google/googleplaces"
+)
+
+func TestCacheRespectsTTLAndPersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "geocode_cache.json")
+	cache, err := New(path, 24*time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	cache.now = func() time.Time { return now }
+
+	if _, err := cache.Put("BR", "01310-100", googleplaces.GeocodeResult{Lat: -23.56, Lng: -46.65, Locality: "São Paulo"}); err != nil {
+		t.Fatalf("put failed: %v", err)
+	}
+	entry, ok := cache.Get("br", "01310100")
+	if !ok || entry.Result.Locality != "São Paulo" {
+		t.Fatalf("expected cached entry, got %+v (ok=%v)", entry, ok)
+	}
+
+	reloaded, err := New(path, 24*time.Hour)
+	if err != nil {
+		t.Fatalf("reload failed: %v", err)
+	}
+	reloaded.now = func() time.Time { return now.Add(25 * time.Hour) }
+	if _, ok := reloaded.Get("br", "01310100"); ok {
+		t.Fatal("expected entry to be expired after TTL")
+	}
+}
+
+func TestPostalCodeKeepsLeadingZeros(t *testing.T) {
+	for _, raw := range []string{"01310-100", "01310100", " 01.310-100 "} {
+		got, err := PostalCode(raw)
+		if err != nil || got != "01310100" {
+			t.Errorf("PostalCode(%q) = %q, %v; want 01310100", raw, got, err)
+		}
+		if Key("br", got) != Key("br", "01310-100") {
+			t.Errorf("key of %q should match the warmed entry", raw)
+		}
+	}
+	for _, raw := range []string{"", "abc", "01310-10x"} {
+		if _, err := PostalCode(raw); err == nil {
+			t.Errorf("PostalCode(%q) should fail", raw)
+		}
+	}
+}
+
+func TestCacheKeepsHitsInMemoryUntilFlush(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "geocode_cache.json")
+	cache, err := New(path, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := cache.Put("br", "01310100", googleplaces.GeocodeResult{Locality: "São Paulo"}); err != nil {
+		t.Fatalf("put failed: %v", err)
+	}
+	cache.Get("br", "01310100")
+	cache.Get("br", "01310100")
+	if entry, _ := cache.Peek("br", "01310100"); entry.Hits != 2 {
+		t.Fatalf("expected 2 hits and Peek not to count, got %d", entry.Hits)
+	}
+
+	reloaded, _ := New(path, 0)
+	if entry, _ := reloaded.Peek("br", "01310100"); entry.Hits != 0 {
+		t.Fatalf("hits should not be written on every Get, got %d", entry.Hits)
+	}
+
+	stop := cache.StartFlusher(time.Hour)
+	stop()
+	reloaded, _ = New(path, 0)
+	if entry, _ := reloaded.Peek("br", "01310100"); entry.Hits != 2 {
+		t.Fatalf("stop should flush the hits, got %d", entry.Hits)
+	}
+}
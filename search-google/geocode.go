@@ -0,0 +1,121 @@
+// /search-google/geocode.go
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/wbrunovieira/LeadSearchVersion2/search-google/geocache"
+	"github.com/wbrunovieira/LeadSearchVersion2/search-google/googleplaces"
+	"github.com/wbrunovieira/LeadSearchVersion2/search-google/usage"
+)
+
+var geocodeCache *geocache.Cache
+
+// geocodeFlushInterval é de quanto em quanto tempo a contagem de acertos do
+// cache é gravada em geocode_cache.json.
+const geocodeFlushInterval = time.Minute
+
+func initGeocodeCache() error {
+	ttlDays := 90
+	if value := os.Getenv("GEOCODE_CACHE_TTL_DAYS"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("GEOCODE_CACHE_TTL_DAYS inválido: %v", err)
+		}
+		ttlDays = parsed
+	}
+
+	var err error
+	geocodeCache, err = geocache.New(dataDir()+"/geocode_cache.json", time.Duration(ttlDays)*24*time.Hour)
+	if err != nil {
+		return err
+	}
+	onShutdown(geocodeCache.StartFlusher(geocodeFlushInterval))
+	return nil
+}
+
+// geocodeCacheHandler expõe o cache de CEPs:
+//   - GET lista as entradas, ou uma só com ?country=X&zipcode=Y (sem chamar o Google);
+//   - POST {"country": "br", "zipcodes": ["..."]} aquece o cache, geocodificando
+//     apenas os CEPs ausentes ou expirados.
+func geocodeCacheHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		zipcode := r.URL.Query().Get("zipcode")
+		if zipcode == "" {
+			json.NewEncoder(w).Encode(geocodeCache.Entries())
+			return
+		}
+		country := r.URL.Query().Get("country")
+		if country == "" {
+			country = "br"
+		}
+		entry, ok := geocodeCache.Peek(country, zipcode)
+		if !ok {
+			http.Error(w, "CEP não encontrado no cache", http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(entry)
+
+	case http.MethodPost:
+		var req struct {
+			Country  string   `json:"country"`
+			Zipcodes []string `json:"zipcodes"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "JSON inválido", http.StatusBadRequest)
+			return
+		}
+		if req.Country == "" {
+			req.Country = "br"
+		}
+
+		apiKey := os.Getenv("GOOGLE_PLACES_API_KEY")
+		if apiKey == "" {
+			http.Error(w, "API key not provided", http.StatusInternalServerError)
+			return
+		}
+
+		jobID := "warm-" + newJobID()
+		estimate := usage.Counts{usage.SKUGeocoding: len(req.Zipcodes)}
+		if err := ledger.StartJob(jobID, "", 0, estimate); err != nil {
+			http.Error(w, err.Error(), http.StatusPaymentRequired)
+			return
+		}
+		service := googleplaces.NewService(apiKey)
+		service.Meter = ledger.ForJob(jobID)
+
+		status := "completed"
+		results := make(map[string]interface{}, len(req.Zipcodes))
+		for _, zipcode := range req.Zipcodes {
+			entry, err := geocodeCache.Lookup(service, req.Country, zipcode)
+			if err != nil {
+				log.Printf("Erro ao aquecer o cache para o CEP %s: %v", zipcode, err)
+				results[zipcode] = map[string]string{"error": err.Error()}
+				if errors.Is(err, usage.ErrBudgetExceeded) {
+					status = "paused"
+					break
+				}
+				continue
+			}
+			results[zipcode] = entry
+		}
+		if err := ledger.FinishJob(jobID, status); err != nil {
+			log.Printf("Erro ao finalizar contabilização do job %s: %v", jobID, err)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(results)
+
+	default:
+		http.Error(w, "Método não permitido. Use GET ou POST.", http.StatusMethodNotAllowed)
+	}
+}
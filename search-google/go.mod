@@ -7,6 +7,10 @@ toolchain go1.24.0
 require (
 	github.com/go-resty/resty/v2 v2.16.5
 	github.com/joho/godotenv v1.5.1
+	github.com/streadway/amqp v1.1.0
+	github.com/wbrunovieira/LeadSearchVersion2/shared v0.0.0
 )
 
 require golang.org/x/net v0.33.0 // indirect
+
+replace github.com/wbrunovieira/LeadSearchVersion2/shared => ../shared
@@ -0,0 +1,92 @@
+package googleplaces
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/wbrunovieira/LeadSearchVersion2/search-google/usage"
+	"github.com/wbrunovieira/LeadSearchVersion2/shared/fakes"
+)
+
+type countingMeter map[string]int
+
+func (m countingMeter) Charge(skus ...string) error {
+	for _, sku := range skus {
+		m[sku]++
+	}
+	return nil
+}
+
+func newFakePlacesService(t *testing.T) (*Service, *fakes.Server) {
+	t.Helper()
+	server := fakes.NewServer(t, "testdata",
+		fakes.Route{Method: "GET", Path: "/geocode/json", Fixture: "geocode.json"},
+		fakes.Route{Method: "GET", Path: "/place/textsearch/json", Fixture: "textsearch.json"},
+		fakes.Route{Method: "GET", Path: "/place/details/json", Fixture: "details.json"},
+	)
+	previous := TokenDir
+	TokenDir = t.TempDir()
+	t.Cleanup(func() { TokenDir = previous })
+	t.Setenv("GOOGLE_MAPS_BASE_URL", server.URL)
+	return NewService("test-key"), server
+}
+
+func TestSearchFlowAgainstFakePlaces(t *testing.T) {
+	service, server := newFakePlacesService(t)
+	meter := countingMeter{}
+	service.Meter = meter
+
+	geocoded, err := service.Geocode("01310100", "br")
+	if err != nil {
+		t.Fatalf("geocode failed: %v", err)
+	}
+	if geocoded.Locality != "São Paulo" || geocoded.State != "SP" {
+		t.Errorf("unexpected locality: %+v", geocoded)
+	}
+
+	places, err := service.SearchPlaces("padaria", geocoded.Location(), 1000, 3, 5)
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if len(places) != 1 || places[0].PlaceID != "ChIJ-fake-padaria" {
+		t.Fatalf("unexpected places: %+v", places)
+	}
+
+	details, err := service.GetPlaceDetails(places[0].PlaceID)
+	if err != nil {
+		t.Fatalf("details failed: %v", err)
+	}
+	details.MergeSearchResult(places[0])
+
+	if details.City != "São Paulo" || details.ZIPCode != "01310-100" {
+		t.Errorf("unexpected address: city=%q zip=%q", details.City, details.ZIPCode)
+	}
+	if details.UserRatingsTotal != 812 || details.PriceLevel != 2 {
+		t.Errorf("text search attributes lost: %+v", details)
+	}
+	if details.GoogleMapsURL == "" || len(details.OpeningHours) != 2 || len(details.Reviews) != 1 || len(details.Photos) != 1 {
+		t.Errorf("rich details not parsed: %+v", details)
+	}
+
+	if meter[usage.SKUGeocoding] != 1 || meter[usage.SKUTextSearch] != 1 || meter[usage.SKUDetails] != 1 {
+		t.Errorf("unexpected metering: %v", meter)
+	}
+
+	for _, req := range server.Requests() {
+		if req.Query["key"][0] != "test-key" {
+			t.Errorf("request %s without API key", req.Path)
+		}
+	}
+}
+
+func TestPlaceDetailsNotFound(t *testing.T) {
+	server := fakes.NewServer(t, "testdata",
+		fakes.Route{Method: "GET", Path: "/place/details/json", Fixture: "details_not_found.json"},
+	)
+	t.Setenv("GOOGLE_MAPS_BASE_URL", server.URL)
+
+	_, err := NewService("test-key").GetPlaceDetails("ChIJ-fechado")
+	if !errors.Is(err, ErrPlaceNotFound) {
+		t.Fatalf("expected ErrPlaceNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,127 @@
+// /search-google/googleplaces/fields.go
+package googleplaces
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/wbrunovieira/LeadSearchVersion2/search-google/usage"
+)
+
+// Cada grupo de campos do Place Details é cobrado por um SKU diferente
+// (Basic, Contact e Atmosphere). Os tiers abaixo permitem escolher, via
+// configuração, quais SKUs uma busca está disposta a pagar.
+var detailsFieldTiers = map[string][]string{
+	"basic": {
+		"place_id", "name", "formatted_address", "address_components",
+		"geometry", "url", "business_status", "types", "vicinity", "photos",
+	},
+	"contact": {
+		"international_phone_number", "website", "opening_hours",
+	},
+	"atmosphere": {
+		"rating", "user_ratings_total", "price_level", "reviews", "editorial_summary",
+	},
+}
+
+// DefaultDetailsTiers são os tiers usados quando nada é configurado.
+var DefaultDetailsTiers = []string{"basic", "contact", "atmosphere"}
+
+// DetailsFieldMask monta a lista de campos do Place Details para os tiers
+// informados, sem repetições e na ordem em que aparecem.
+func DetailsFieldMask(tiers []string) ([]string, error) {
+	var fields []string
+	seen := make(map[string]bool)
+	for _, tier := range tiers {
+		tier = strings.ToLower(strings.TrimSpace(tier))
+		if tier == "" {
+			continue
+		}
+		tierFields, ok := detailsFieldTiers[tier]
+		if !ok {
+			return nil, fmt.Errorf("tier de campos desconhecido: %s", tier)
+		}
+		for _, f := range tierFields {
+			if !seen[f] {
+				seen[f] = true
+				fields = append(fields, f)
+			}
+		}
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("nenhum campo selecionado para o Place Details")
+	}
+	return fields, nil
+}
+
+// ParseDetailsFields interpreta a configuração de campos. Aceita nomes de
+// tiers ("basic,contact") e campos avulsos ("geometry,reviews") misturados.
+// Campo fora dos tiers é recusado: um erro de digitação só apareceria como
+// 400 do Google ou como um campo que nunca vem.
+func ParseDetailsFields(config string) ([]string, error) {
+	if strings.TrimSpace(config) == "" {
+		return DetailsFieldMask(DefaultDetailsTiers)
+	}
+
+	var fields []string
+	seen := make(map[string]bool)
+	for _, item := range strings.Split(config, ",") {
+		item = strings.ToLower(strings.TrimSpace(item))
+		if item == "" {
+			continue
+		}
+		expanded := []string{item}
+		if _, isTier := detailsFieldTiers[item]; isTier {
+			expanded, _ = DetailsFieldMask([]string{item})
+		} else if !knownDetailsField(item) {
+			return nil, fmt.Errorf("campo do Place Details desconhecido: %s", item)
+		}
+		for _, f := range expanded {
+			if !seen[f] {
+				seen[f] = true
+				fields = append(fields, f)
+			}
+		}
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("nenhum campo selecionado para o Place Details")
+	}
+	return fields, nil
+}
+
+func knownDetailsField(field string) bool {
+	for _, fields := range detailsFieldTiers {
+		for _, f := range fields {
+			if f == field {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// DetailsSKUs devolve os SKUs cobrados por uma chamada ao Place Details com o
+// field mask informado: o SKU base sempre, e Contact/Atmosphere se algum campo
+// desses tiers for pedido.
+func DetailsSKUs(fields []string) []string {
+	skus := []string{usage.SKUDetails}
+	requested := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		requested[f] = true
+	}
+	for _, tier := range []struct {
+		name string
+		sku  string
+	}{
+		{"contact", usage.SKUDetailsContact},
+		{"atmosphere", usage.SKUDetailsAtmosphere},
+	} {
+		for _, f := range detailsFieldTiers[tier.name] {
+			if requested[f] {
+				skus = append(skus, tier.sku)
+				break
+			}
+		}
+	}
+	return skus
+}
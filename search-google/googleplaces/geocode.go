@@ -0,0 +1,64 @@
+// /search-google/googleplaces/geocode.go
+package googleplaces
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	// Limites aceitos pelo parâmetro radius do Text Search.
+	MinSearchRadius = 500
+	MaxSearchRadius = 50000
+
+	earthRadiusMeters = 6371000
+)
+
+type LatLng struct {
+	Lat float64 `json:"lat"`
+	Lng float64 `json:"lng"`
+}
+
+type Viewport struct {
+	Northeast LatLng `json:"northeast"`
+	Southwest LatLng `json:"southwest"`
+}
+
+// GeocodeResult é o que guardamos de uma resposta do Geocoding para um CEP.
+type GeocodeResult struct {
+	Lat              float64  `json:"lat"`
+	Lng              float64  `json:"lng"`
+	Viewport         Viewport `json:"viewport"`
+	Locality         string   `json:"locality"`
+	State            string   `json:"state"`
+	FormattedAddress string   `json:"formatted_address"`
+}
+
+// Location devolve as coordenadas no formato "lat,lng" usado pelo Text Search.
+func (g GeocodeResult) Location() string {
+	return fmt.Sprintf("%f,%f", g.Lat, g.Lng)
+}
+
+// DefaultRadius sugere um raio de busca que cubra o viewport do CEP: a
+// distância do centro até o canto mais distante, limitada ao intervalo aceito
+// pelo Places.
+func (g GeocodeResult) DefaultRadius() int {
+	center := LatLng{Lat: g.Lat, Lng: g.Lng}
+	radius := math.Max(
+		haversine(center, g.Viewport.Northeast),
+		haversine(center, g.Viewport.Southwest),
+	)
+	if g.Viewport.Northeast == (LatLng{}) && g.Viewport.Southwest == (LatLng{}) {
+		radius = 0
+	}
+	return int(math.Min(math.Max(math.Round(radius), MinSearchRadius), MaxSearchRadius))
+}
+
+func haversine(a, b LatLng) float64 {
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+	dLat := toRad(b.Lat - a.Lat)
+	dLng := toRad(b.Lng - a.Lng)
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRad(a.Lat))*math.Cos(toRad(b.Lat))*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadiusMeters * math.Asin(math.Sqrt(h))
+}
@@ -3,6 +3,7 @@ package googleplaces
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -10,10 +11,31 @@ import (
 	"time"
 
 	"github.com/go-resty/resty/v2"
+	"github.com/wbrunovieira/LeadSearchVersion2/search-google/usage"
+	"github.com/wbrunovieira/LeadSearchVersion2/shared/places"
 )
 
+// DefaultBaseURL é a raiz das APIs do Google Maps. Pode ser trocada por
+// GOOGLE_MAPS_BASE_URL para apontar para um servidor falso nos testes.
+const DefaultBaseURL = "https://maps.googleapis.com/maps/api"
+
+// ErrPlaceNotFound é o NOT_FOUND do Place Details: o Google não conhece mais
+// o PlaceID, em geral porque o estabelecimento fechou.
+var ErrPlaceNotFound = errors.New("place não encontrado no Google")
+
+// TokenDir é onde fica o next_page_tokens.json.
+var TokenDir = "/app/lead-search"
+
 type Service struct {
-	APIKey string
+	APIKey  string
+	BaseURL string
+
+	// DetailsFields é o field mask enviado ao Place Details.
+	DetailsFields []string
+
+	// Meter contabiliza cada chamada antes de ela ser feita. Nil desliga a
+	// contabilização.
+	Meter usage.Meter
 }
 
 type TokenStore struct {
@@ -32,18 +54,49 @@ type PlaceResult struct {
 	Vicinity          string   `json:"vicinity"`
 	PermanentlyClosed bool     `json:"permanently_closed"`
 	Types             []string `json:"types"`
+	Geometry          struct {
+		Location struct {
+			Lat float64 `json:"lat"`
+			Lng float64 `json:"lng"`
+		} `json:"location"`
+	} `json:"geometry"`
+}
+
+func (s *Service) charge(skus ...string) error {
+	if s.Meter == nil {
+		return nil
+	}
+	return s.Meter.Charge(skus...)
 }
 
 func NewService(apiKey string) *Service {
-	return &Service{APIKey: apiKey}
+	fields, _ := DetailsFieldMask(DefaultDetailsTiers)
+	baseURL := DefaultBaseURL
+	if v := os.Getenv("GOOGLE_MAPS_BASE_URL"); v != "" {
+		baseURL = strings.TrimRight(v, "/")
+	}
+	return &Service{APIKey: apiKey, BaseURL: baseURL, DetailsFields: fields}
 }
 
 func (s *Service) GeocodeZip(zipCode string, country string) (string, error) {
+	result, err := s.Geocode(zipCode, country)
+	if err != nil {
+		return "", err
+	}
+	return result.Location(), nil
+}
+
+// Geocode resolve um CEP para coordenadas, viewport e localidade.
+func (s *Service) Geocode(zipCode string, country string) (*GeocodeResult, error) {
 	log.Printf("Buscando coordenadas para o zipCode: %s", zipCode)
 
+	if err := s.charge(usage.SKUGeocoding); err != nil {
+		return nil, err
+	}
+
 	client := resty.New()
 
-	geocodeURL := "https://maps.googleapis.com/maps/api/geocode/json"
+	geocodeURL := s.BaseURL + "/geocode/json"
 	resp, err := client.R().
 		SetQueryParams(map[string]string{
 			"address":    zipCode,
@@ -53,16 +106,23 @@ func (s *Service) GeocodeZip(zipCode string, country string) (string, error) {
 		Get(geocodeURL)
 
 	if err != nil {
-		return "", fmt.Errorf("error connecting to Geocoding API: %v", err)
+		return nil, fmt.Errorf("error connecting to Geocoding API: %v", err)
 	}
 
 	var result struct {
 		Results []struct {
+			FormattedAddress  string `json:"formatted_address"`
+			AddressComponents []struct {
+				LongName  string   `json:"long_name"`
+				ShortName string   `json:"short_name"`
+				Types     []string `json:"types"`
+			} `json:"address_components"`
 			Geometry struct {
-				Location struct {
-					Lat float64 `json:"lat"`
-					Lng float64 `json:"lng"`
-				} `json:"location"`
+				Location LatLng `json:"location"`
+				Viewport struct {
+					Northeast LatLng `json:"northeast"`
+					Southwest LatLng `json:"southwest"`
+				} `json:"viewport"`
 			} `json:"geometry"`
 		} `json:"results"`
 		Status       string `json:"status"`
@@ -71,20 +131,39 @@ func (s *Service) GeocodeZip(zipCode string, country string) (string, error) {
 
 	err = json.Unmarshal(resp.Body(), &result)
 	if err != nil {
-		return "", fmt.Errorf("error parsing geocode response: %v", err)
+		return nil, fmt.Errorf("error parsing geocode response: %v", err)
 	}
 
 	if result.Status != "OK" {
-		return "", fmt.Errorf("geocoding API error: %s, message: %s", result.Status, result.ErrorMessage)
+		return nil, fmt.Errorf("geocoding API error: %s, message: %s", result.Status, result.ErrorMessage)
 	}
 
-	if len(result.Results) > 0 {
-		lat := result.Results[0].Geometry.Location.Lat
-		lng := result.Results[0].Geometry.Location.Lng
-		return fmt.Sprintf("%f,%f", lat, lng), nil
+	if len(result.Results) == 0 {
+		return nil, fmt.Errorf("no results found for zipCode: %s", zipCode)
 	}
 
-	return "", fmt.Errorf("no results found for zipCode: %s", zipCode)
+	first := result.Results[0]
+	geocoded := &GeocodeResult{
+		Lat:              first.Geometry.Location.Lat,
+		Lng:              first.Geometry.Location.Lng,
+		Viewport:         Viewport{Northeast: first.Geometry.Viewport.Northeast, Southwest: first.Geometry.Viewport.Southwest},
+		FormattedAddress: first.FormattedAddress,
+	}
+	for _, component := range first.AddressComponents {
+		for _, ctype := range component.Types {
+			switch ctype {
+			case "locality":
+				geocoded.Locality = component.LongName
+			case "administrative_area_level_2":
+				if geocoded.Locality == "" {
+					geocoded.Locality = component.LongName
+				}
+			case "administrative_area_level_1":
+				geocoded.State = component.ShortName
+			}
+		}
+	}
+	return geocoded, nil
 }
 
 func generateQueryKey(query string, location string, radius int) string {
@@ -93,8 +172,8 @@ func generateQueryKey(query string, location string, radius int) string {
 
 func loadToken(queryKey string) (string, int, int, error) {
 
-	const directory = "/app/lead-search"
-	const filePath = directory + "/next_page_tokens.json"
+	directory := TokenDir
+	filePath := directory + "/next_page_tokens.json"
 
 	var tokenStore TokenStore
 
@@ -156,7 +235,8 @@ func loadToken(queryKey string) (string, int, int, error) {
 func saveToken(queryKey string, token string, pagesFetched int, leadsExtracted int) error {
 	var tokenStore TokenStore
 
-	file, err := os.ReadFile("next_page_tokens.json")
+	filePath := TokenDir + "/next_page_tokens.json"
+	file, err := os.ReadFile(filePath)
 	if err == nil {
 		err = json.Unmarshal(file, &tokenStore)
 		if err != nil {
@@ -182,7 +262,7 @@ func saveToken(queryKey string, token string, pagesFetched int, leadsExtracted i
 		return fmt.Errorf("erro ao fazer marshal dos tokens: %v", err)
 	}
 
-	err = os.WriteFile("/app/lead-search/next_page_tokens.json", tokenStoreBytes, 0644)
+	err = os.WriteFile(filePath, tokenStoreBytes, 0644)
 	if err != nil {
 		return fmt.Errorf("erro ao salvar o arquivo JSON: %v", err)
 	}
@@ -191,12 +271,12 @@ func saveToken(queryKey string, token string, pagesFetched int, leadsExtracted i
 	return nil
 }
 
-func (s *Service) SearchPlaces(query string, location string, radius int, maxPages int, maxResults int) ([]map[string]interface{}, error) {
+func (s *Service) SearchPlaces(query string, location string, radius int, maxPages int, maxResults int) ([]places.Place, error) {
 	log.Printf("Iniciando busca de lugares para query: '%s', location: '%s', radius: %d e maxPages: %d", query, location, radius, maxPages)
 	client := resty.New()
-	url := "https://maps.googleapis.com/maps/api/place/textsearch/json"
+	url := s.BaseURL + "/place/textsearch/json"
 
-	var allPlaces []map[string]interface{}
+	var allPlaces []places.Place
 	queryKey := generateQueryKey(query, location, radius)
 	log.Printf("QueryKey gerado: %s", queryKey)
 
@@ -219,6 +299,14 @@ func (s *Service) SearchPlaces(query string, location string, radius int, maxPag
 			log.Printf("Usando pagetoken: %s", pageToken)
 		}
 
+		if err := s.charge(usage.SKUTextSearch); err != nil {
+			log.Printf("Busca interrompida pelo orçamento: %v", err)
+			if len(allPlaces) > 0 {
+				return allPlaces, nil
+			}
+			return nil, err
+		}
+
 		log.Printf("Enviando requisição para URL: %s com parâmetros: %+v", url, params)
 		resp, err := client.R().
 			SetQueryParams(params).
@@ -254,18 +342,7 @@ func (s *Service) SearchPlaces(query string, location string, radius int, maxPag
 			}
 
 			for _, place := range result.Results {
-				placeDetails := map[string]interface{}{
-					"Name":              place.Name,
-					"FormattedAddress":  place.FormattedAddress,
-					"PlaceID":           place.PlaceID,
-					"Rating":            place.Rating,
-					"UserRatingsTotal":  place.UserRatingsTotal,
-					"PriceLevel":        place.PriceLevel,
-					"BusinessStatus":    place.BusinessStatus,
-					"Vicinity":          place.Vicinity,
-					"PermanentlyClosed": place.PermanentlyClosed,
-					"Types":             place.Types,
-				}
+				placeDetails := newPlaceFromResult(place)
 				allPlaces = append(allPlaces, placeDetails)
 				leadsExtracted++
 				log.Printf("Lead extraído: %+v", placeDetails)
@@ -309,18 +386,22 @@ func (s *Service) SearchPlaces(query string, location string, radius int, maxPag
 	return allPlaces, nil
 }
 
-func (s *Service) GetPlaceDetails(placeID string) (map[string]interface{}, error) {
+func (s *Service) GetPlaceDetails(placeID string) (*places.Place, error) {
 	log.Printf("Iniciando busca dos detalhes do lugar para PlaceID: %s", placeID)
+	if err := s.charge(DetailsSKUs(s.DetailsFields)...); err != nil {
+		return nil, err
+	}
 	client := resty.New()
 
-	url := "https://maps.googleapis.com/maps/api/place/details/json"
-	log.Printf("Chamada à URL: %s com os parâmetros: place_id=%s, fields=name,formatted_address,international_phone_number,website,rating,address_components,editorial_summary", url, placeID)
+	url := s.BaseURL + "/place/details/json"
+	fields := strings.Join(s.DetailsFields, ",")
+	log.Printf("Chamada à URL: %s com os parâmetros: place_id=%s, fields=%s", url, placeID, fields)
 
 	resp, err := client.R().
 		SetQueryParams(map[string]string{
 			"place_id": placeID,
 			"key":      s.APIKey,
-			"fields":   "name,formatted_address,international_phone_number,website,rating,address_components,editorial_summary",
+			"fields":   fields,
 		}).
 		Get(url)
 
@@ -347,6 +428,36 @@ func (s *Service) GetPlaceDetails(placeID string) (map[string]interface{}, error
 				EditorialSummary struct {
 					Overview string `json:"overview"`
 				} `json:"editorial_summary"`
+				Geometry struct {
+					Location struct {
+						Lat float64 `json:"lat"`
+						Lng float64 `json:"lng"`
+					} `json:"location"`
+				} `json:"geometry"`
+				URL              string   `json:"url"`
+				UserRatingsTotal int      `json:"user_ratings_total"`
+				PriceLevel       int      `json:"price_level"`
+				BusinessStatus   string   `json:"business_status"`
+				Vicinity         string   `json:"vicinity"`
+				Types            []string `json:"types"`
+				OpeningHours     struct {
+					OpenNow     *bool    `json:"open_now"`
+					WeekdayText []string `json:"weekday_text"`
+				} `json:"opening_hours"`
+				Photos []struct {
+					PhotoReference   string   `json:"photo_reference"`
+					Width            int      `json:"width"`
+					Height           int      `json:"height"`
+					HTMLAttributions []string `json:"html_attributions"`
+				} `json:"photos"`
+				Reviews []struct {
+					AuthorName              string `json:"author_name"`
+					Rating                  int    `json:"rating"`
+					Text                    string `json:"text"`
+					Language                string `json:"language"`
+					Time                    int64  `json:"time"`
+					RelativeTimeDescription string `json:"relative_time_description"`
+				} `json:"reviews"`
 			} `json:"result"`
 			Status       string `json:"status"`
 			ErrorMessage string `json:"error_message"`
@@ -359,6 +470,9 @@ func (s *Service) GetPlaceDetails(placeID string) (map[string]interface{}, error
 		}
 
 		log.Printf("API retornou status: %s", result.Status)
+		if result.Status == "NOT_FOUND" {
+			return nil, fmt.Errorf("%w: %s", ErrPlaceNotFound, placeID)
+		}
 		if result.Status != "OK" {
 			log.Printf("Erro da API: %s, mensagem: %s", result.Status, result.ErrorMessage)
 			return nil, fmt.Errorf("error from API: %s, message: %s", result.Status, result.ErrorMessage)
@@ -416,18 +530,47 @@ func (s *Service) GetPlaceDetails(placeID string) (map[string]interface{}, error
 		}
 		log.Printf("Descrição final definida: %s", description)
 
-		details := map[string]interface{}{
-			"Name":                     result.Result.Name,
-			"FormattedAddress":         address,
-			"InternationalPhoneNumber": result.Result.InternationalPhoneNumber,
-			"Website":                  result.Result.Website,
-			"Rating":                   result.Result.Rating,
-			"City":                     city,
-			"State":                    state,
-			"ZIPCode":                  zipCode,
-			"Country":                  country,
-			"PlaceID":                  placeID,
-			"Description":              description,
+		details := &places.Place{
+			SchemaVersion:            places.SchemaVersion,
+			PlaceID:                  placeID,
+			Name:                     result.Result.Name,
+			FormattedAddress:         address,
+			InternationalPhoneNumber: result.Result.InternationalPhoneNumber,
+			Website:                  result.Result.Website,
+			Rating:                   result.Result.Rating,
+			City:                     city,
+			State:                    state,
+			ZIPCode:                  zipCode,
+			Country:                  country,
+			Description:              description,
+			Latitude:                 result.Result.Geometry.Location.Lat,
+			Longitude:                result.Result.Geometry.Location.Lng,
+			GoogleMapsURL:            result.Result.URL,
+			UserRatingsTotal:         result.Result.UserRatingsTotal,
+			PriceLevel:               result.Result.PriceLevel,
+			BusinessStatus:           result.Result.BusinessStatus,
+			Vicinity:                 result.Result.Vicinity,
+			Types:                    result.Result.Types,
+			OpeningHours:             result.Result.OpeningHours.WeekdayText,
+			OpenNow:                  result.Result.OpeningHours.OpenNow,
+		}
+		for _, photo := range result.Result.Photos {
+			details.Photos = append(details.Photos, places.PlacePhoto{
+				Reference:    photo.PhotoReference,
+				Width:        photo.Width,
+				Height:       photo.Height,
+				Attributions: photo.HTMLAttributions,
+			})
+		}
+		for _, review := range result.Result.Reviews {
+			details.Reviews = append(details.Reviews, places.PlaceReview{
+				AuthorName:   review.AuthorName,
+				Rating:       review.Rating,
+				Text:         review.Text,
+				Language:     review.Language,
+				Time:         review.Time,
+				RelativeTime: review.RelativeTimeDescription,
+			})
 		}
 		log.Printf("Detalhes do lugar obtidos: %+v", details)
 		return details, nil
@@ -1,14 +1,55 @@
-
 package googleplaces
 
 import (
+	"strings"
 	"testing"
 )
 
 func TestNewService(t *testing.T) {
-    apiKey := "test-key"
-    service := NewService(apiKey)
-    if service.APIKey != apiKey {
-        t.Errorf("expected APIKey %v, got %v", apiKey, service.APIKey)
-    }
+	apiKey := "test-key"
+	service := NewService(apiKey)
+	if service.APIKey != apiKey {
+		t.Errorf("expected APIKey %v, got %v", apiKey, service.APIKey)
+	}
+}
+
+func TestParseDetailsFieldsMixesTiersAndFields(t *testing.T) {
+	fields, err := ParseDetailsFields("contact, reviews, website")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"international_phone_number", "website", "opening_hours", "reviews"}
+	if len(fields) != len(want) {
+		t.Fatalf("expected %v, got %v", want, fields)
+	}
+	for i := range want {
+		if fields[i] != want[i] {
+			t.Errorf("field %d: expected %q, got %q", i, want[i], fields[i])
+		}
+	}
+
+	if _, err := DetailsFieldMask([]string{"premium"}); err == nil {
+		t.Error("expected error for unknown tier")
+	}
+	if _, err := ParseDetailsFields("basic, webiste"); err == nil || !strings.Contains(err.Error(), "webiste") {
+		t.Errorf("expected error naming the unknown field, got %v", err)
+	}
+}
+
+func TestDefaultRadiusFromViewport(t *testing.T) {
+	g := GeocodeResult{
+		Lat: -23.5613, Lng: -46.6565,
+		Viewport: Viewport{
+			Northeast: LatLng{Lat: -23.5513, Lng: -46.6465},
+			Southwest: LatLng{Lat: -23.5713, Lng: -46.6665},
+		},
+	}
+	radius := g.DefaultRadius()
+	if radius < 1400 || radius > 1600 {
+		t.Errorf("expected radius around 1500m, got %d", radius)
+	}
+
+	if r := (GeocodeResult{Lat: -23.5, Lng: -46.6}).DefaultRadius(); r != MinSearchRadius {
+		t.Errorf("expected minimum radius without viewport, got %d", r)
+	}
 }
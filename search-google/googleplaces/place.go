@@ -0,0 +1,22 @@
+// /search-google/googleplaces/place.go
+package googleplaces
+
+import "github.com/wbrunovieira/LeadSearchVersion2/shared/places"
+
+func newPlaceFromResult(r PlaceResult) places.Place {
+	return places.Place{
+		SchemaVersion:     places.SchemaVersion,
+		PlaceID:           r.PlaceID,
+		Name:              r.Name,
+		FormattedAddress:  r.FormattedAddress,
+		Rating:            r.Rating,
+		UserRatingsTotal:  r.UserRatingsTotal,
+		PriceLevel:        r.PriceLevel,
+		BusinessStatus:    r.BusinessStatus,
+		Vicinity:          r.Vicinity,
+		PermanentlyClosed: r.PermanentlyClosed,
+		Types:             r.Types,
+		Latitude:          r.Geometry.Location.Lat,
+		Longitude:         r.Geometry.Location.Lng,
+	}
+}
@@ -0,0 +1,132 @@
+// /search-google/limits.go
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/wbrunovieira/LeadSearchVersion2/search-google/googleplaces"
+	"github.com/wbrunovieira/LeadSearchVersion2/shared/auth"
+	"github.com/wbrunovieira/LeadSearchVersion2/shared/ratelimit"
+)
+
+// Limites das rotas que geram chamadas pagas ao Google. Os valores padrão
+// cabem no uso do front; tudo pode ser ajustado por variável de ambiente.
+var (
+	requestLimiter    *ratelimit.Limiter
+	searchConcurrency *ratelimit.Concurrency
+	trustProxyHeaders bool
+
+	maxResultsCap   = 60
+	maxRadiusMeters = googleplaces.MaxSearchRadius
+)
+
+// searchBusyRetryAfter é o Retry-After sugerido quando não há vaga para mais
+// uma busca simultânea.
+const searchBusyRetryAfter = 30 * time.Second
+
+func initLimits() error {
+	perMinute, err := envFloat("SEARCH_RATE_PER_MINUTE", 6)
+	if err != nil {
+		return err
+	}
+	burst, err := envInt("SEARCH_RATE_BURST", 3)
+	if err != nil {
+		return err
+	}
+	maxConcurrent, err := envInt("SEARCH_MAX_CONCURRENT", 4)
+	if err != nil {
+		return err
+	}
+	maxPerClient, err := envInt("SEARCH_MAX_CONCURRENT_PER_CLIENT", 1)
+	if err != nil {
+		return err
+	}
+	if maxResultsCap, err = envInt("SEARCH_MAX_RESULTS", maxResultsCap); err != nil {
+		return err
+	}
+	if maxRadiusMeters, err = envInt("SEARCH_MAX_RADIUS_METERS", maxRadiusMeters); err != nil {
+		return err
+	}
+	trustProxyHeaders = os.Getenv("TRUST_PROXY_HEADERS") == "true"
+
+	requestLimiter = ratelimit.NewLimiter(perMinute, burst)
+	searchConcurrency = ratelimit.NewConcurrency(maxConcurrent, maxPerClient)
+	log.Printf("Limites de busca: %.1f req/min (rajada %d), %d buscas simultâneas (%d por cliente), max_results<=%d, radius<=%d m",
+		perMinute, burst, maxConcurrent, maxPerClient, maxResultsCap, maxRadiusMeters)
+	return nil
+}
+
+func envInt(key string, fallback int) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < 0 {
+		return 0, fmt.Errorf("%s inválido: %s", key, value)
+	}
+	return parsed, nil
+}
+
+func envFloat(key string, fallback float64) (float64, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil || parsed < 0 {
+		return 0, fmt.Errorf("%s inválido: %s", key, value)
+	}
+	return parsed, nil
+}
+
+// withRateLimit aplica o limite por cliente. OPTIONS passa direto para não
+// gastar fichas com o preflight do CORS.
+func withRateLimit(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions {
+			next(w, r)
+			return
+		}
+		client := ratelimit.ClientKey(r, trustProxyHeaders)
+		if ok, wait := requestLimiter.Allow(client); !ok {
+			log.Printf("Cliente %s acima do limite de requisições em %s", client, r.URL.Path)
+			tooManyRequests(w, wait, "Rate limit exceeded")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// withPrincipalRateLimit aplica o mesmo limite à credencial autenticada, para
+// quem troca de IP continuar sendo contado. Vai dentro de requireRole.
+func withPrincipalRateLimit(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		client := principalKey(r)
+		if ok, wait := requestLimiter.Allow(client); !ok {
+			log.Printf("%s acima do limite de requisições em %s", client, r.URL.Path)
+			tooManyRequests(w, wait, "Rate limit exceeded")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// principalKey identifica quem fez a requisição autenticada nos limites; sem
+// principal no contexto cai no IP.
+func principalKey(r *http.Request) string {
+	if principal, ok := auth.FromContext(r.Context()); ok && principal.Name != "" {
+		return "principal:" + principal.Name
+	}
+	return ratelimit.ClientKey(r, trustProxyHeaders)
+}
+
+func tooManyRequests(w http.ResponseWriter, retryAfter time.Duration, message string) {
+	seconds := ratelimit.SetRetryAfter(w, retryAfter)
+	http.Error(w, fmt.Sprintf("%s, retry in %d seconds", message, seconds), http.StatusTooManyRequests)
+}
@@ -2,8 +2,8 @@
 package main
 
 import (
-	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -11,7 +11,11 @@ import (
 	"strconv"
 
 	"github.com/joho/godotenv"
+	"github.com/wbrunovieira/LeadSearchVersion2/search-google/geocache"
 	"github.com/wbrunovieira/LeadSearchVersion2/search-google/googleplaces"
+	"github.com/wbrunovieira/LeadSearchVersion2/search-google/usage"
+	"github.com/wbrunovieira/LeadSearchVersion2/shared/auth"
+	"github.com/wbrunovieira/LeadSearchVersion2/shared/places"
 )
 
 func main() {
@@ -28,12 +32,41 @@ func main() {
 	}
 	fmt.Println("API rodando na porta", port)
 
+	googleplaces.TokenDir = dataDir()
+	handleShutdown()
+	if err := initLedger(); err != nil {
+		log.Fatalf("Erro ao inicializar contabilização do Google Places: %v", err)
+	}
+	if _, err := googleplaces.ParseDetailsFields(os.Getenv("GOOGLE_PLACES_DETAILS_FIELDS")); err != nil {
+		log.Fatalf("Configuração GOOGLE_PLACES_DETAILS_FIELDS inválida: %v", err)
+	}
+	if err := initGeocodeCache(); err != nil {
+		log.Fatalf("Erro ao inicializar o cache de geocodificação: %v", err)
+	}
+	if err := initLimits(); err != nil {
+		log.Fatalf("Erro ao configurar os limites de busca: %v", err)
+	}
+	if err := initAuth(); err != nil {
+		log.Fatalf("Erro ao configurar autenticação: %v", err)
+	}
+	if err := initProgress(); err != nil {
+		log.Fatalf("Erro ao configurar o progresso das buscas: %v", err)
+	}
+
 	mux := http.NewServeMux()
 
-	mux.HandleFunc("/start-search", func(w http.ResponseWriter, r *http.Request) {
-		log.Printf("Requisição em /start-search: Método=%s, Query=%s", r.Method, r.URL.RawQuery)
+	// Buscas e aquecimento de cache geram custo: só admin. O limite por IP vem
+	// antes da autenticação para também segurar tentativas de credencial; o
+	// por credencial, depois, vale para quem troca de IP.
+	mux.HandleFunc("/start-search", withRateLimit(requireRole(auth.RoleAdmin, nil, withPrincipalRateLimit(func(w http.ResponseWriter, r *http.Request) {
+		log.Printf("Requisição em /start-search: Método=%s", r.Method)
 		startSearchHandler(w, r)
-	})
+	}))))
+
+	mux.HandleFunc("/usage", requireRole(auth.RoleViewer, nil, usageHandler))
+	mux.HandleFunc("/geocode-cache", withRateLimit(requireRole(auth.RoleAdmin,
+		map[string]auth.Role{http.MethodGet: auth.RoleViewer}, withPrincipalRateLimit(geocodeCacheHandler))))
+	mux.HandleFunc("/refresh-details", withRateLimit(requireRole(auth.RoleAdmin, nil, withPrincipalRateLimit(refreshDetailsHandler))))
 
 	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		log.Println("health hit")
@@ -45,6 +78,9 @@ func main() {
 		w.Write([]byte("OK"))
 	})
 
+	startDetailsRefresher()
+	startScheduler()
+
 	handlerComCORS := withCORS(mux)
 
 	log.Println("Starting server on port", port)
@@ -74,40 +110,85 @@ func startSearchHandler(w http.ResponseWriter, r *http.Request) {
 		categoryID, zipcodeIDString, radiusStr, maxResultsStr, country)
 
 	// Verifica se os parâmetros obrigatórios foram enviados
-	if categoryID == "" || zipcodeIDString == "" || radiusStr == "" {
+	if categoryID == "" || zipcodeIDString == "" {
 		log.Println("startSearchHandler: Parâmetros obrigatórios faltando")
-		http.Error(w, "Missing required parameters (category_id, zipcode_id, radius)", http.StatusBadRequest)
+		http.Error(w, "Missing required parameters (category_id, zipcode_id)", http.StatusBadRequest)
 		return
 	}
 
-	// Conversões dos parâmetros numéricos
-	radiusInt, err := strconv.Atoi(radiusStr)
-	if err != nil {
-		log.Printf("startSearchHandler: Valor de radius inválido: %s", radiusStr)
-		http.Error(w, "Invalid radius value", http.StatusBadRequest)
-		return
+	// Conversões dos parâmetros numéricos. Sem radius, o raio é derivado do
+	// viewport do CEP.
+	var radiusInt int
+	var err error
+	if radiusStr != "" {
+		radiusInt, err = strconv.Atoi(radiusStr)
+		if err != nil || radiusInt < 0 {
+			log.Printf("startSearchHandler: Valor de radius inválido: %s", radiusStr)
+			http.Error(w, "Invalid radius value", http.StatusBadRequest)
+			return
+		}
+		if maxRadiusMeters > 0 && radiusInt > maxRadiusMeters {
+			log.Printf("startSearchHandler: radius %d acima do limite de %d m", radiusInt, maxRadiusMeters)
+			http.Error(w, fmt.Sprintf("radius above the limit of %d meters", maxRadiusMeters), http.StatusBadRequest)
+			return
+		}
+		log.Printf("startSearchHandler: Radius convertido com sucesso: %d", radiusInt)
 	}
-	log.Printf("startSearchHandler: Radius convertido com sucesso: %d", radiusInt)
 
-	zipcodeID, err := strconv.Atoi(zipcodeIDString)
+	zipcode, err := geocache.PostalCode(zipcodeIDString)
 	if err != nil {
 		log.Printf("startSearchHandler: Valor de zipcode_id inválido: %s", zipcodeIDString)
 		http.Error(w, "Invalid zipcode_id value", http.StatusBadRequest)
 		return
 	}
-	log.Printf("startSearchHandler: zipcodeID convertido com sucesso: %d", zipcodeID)
+	log.Printf("startSearchHandler: CEP normalizado: %s", zipcode)
 
 	maxResults := 1
 	if maxResultsStr != "" {
 		maxResults, err = strconv.Atoi(maxResultsStr)
-		if err != nil {
+		if err != nil || maxResults < 1 {
 			log.Printf("startSearchHandler: Valor de max_results inválido: %s", maxResultsStr)
 			http.Error(w, "Invalid max_results value", http.StatusBadRequest)
 			return
 		}
+		if maxResultsCap > 0 && maxResults > maxResultsCap {
+			log.Printf("startSearchHandler: max_results %d acima do limite de %d", maxResults, maxResultsCap)
+			http.Error(w, fmt.Sprintf("max_results above the limit of %d", maxResultsCap), http.StatusBadRequest)
+			return
+		}
 	}
 	log.Printf("startSearchHandler: maxResults definido: %d", maxResults)
 
+	var budgetUSD float64
+	if budgetStr := r.URL.Query().Get("budget_usd"); budgetStr != "" {
+		budgetUSD, err = strconv.ParseFloat(budgetStr, 64)
+		if err != nil || budgetUSD < 0 {
+			log.Printf("startSearchHandler: Valor de budget_usd inválido: %s", budgetStr)
+			http.Error(w, "Invalid budget_usd value", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Os leads vão para o workspace pedido; quem só tem acesso a um workspace
+	// não precisa informá-lo.
+	principal, _ := auth.FromContext(r.Context())
+	workspace := r.Header.Get("X-Workspace")
+	if workspace == "" {
+		workspace = r.URL.Query().Get("workspace")
+	}
+	if workspace == "" && len(principal.Workspaces) == 1 {
+		workspace = principal.Workspaces[0]
+	}
+	if workspace == "" && len(principal.Workspaces) > 1 {
+		http.Error(w, "Missing workspace", http.StatusBadRequest)
+		return
+	}
+	if workspace != "" && !principal.CanAccessWorkspace(workspace) {
+		log.Printf("startSearchHandler: %s não tem acesso ao workspace %s", principal.Name, workspace)
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
 	apiKey := os.Getenv("GOOGLE_PLACES_API_KEY")
 	if apiKey == "" {
 		log.Println("startSearchHandler: API key não provida")
@@ -116,8 +197,45 @@ func startSearchHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	log.Println("startSearchHandler: API key obtida")
 
-	// Passa o parâmetro country para a função de início de pesquisa
-	err = startSearch(apiKey, categoryID, zipcodeID, radiusInt, maxResults, country)
+	// O cliente pode escolher o job_id para acompanhar o progresso desde o
+	// início; a busca só responde quando termina.
+	jobID := r.URL.Query().Get("job_id")
+	if jobID == "" {
+		jobID = newJobID()
+	} else if !validJobID.MatchString(jobID) {
+		http.Error(w, "Invalid job_id (8 to 64 letters, digits, - or _)", http.StatusBadRequest)
+		return
+	} else if _, exists := ledger.Job(jobID); exists {
+		http.Error(w, "job_id already used", http.StatusConflict)
+		return
+	}
+
+	req := searchRequest{
+		JobID:      jobID,
+		CategoryID: categoryID,
+		Zipcode:    zipcode,
+		Radius:     radiusInt,
+		MaxResults: maxResults,
+		Country:    country,
+		BudgetUSD:  budgetUSD,
+		Workspace:  workspace,
+	}
+
+	client := principalKey(r)
+	release, ok := searchConcurrency.Acquire(client)
+	if !ok {
+		log.Printf("startSearchHandler: Sem vaga para mais uma busca de %s (%d rodando)", client, searchConcurrency.Running())
+		tooManyRequests(w, searchBusyRetryAfter, "Too many searches running")
+		return
+	}
+	defer release()
+
+	err = startSearch(apiKey, req)
+	if errors.Is(err, usage.ErrBudgetExceeded) {
+		log.Printf("startSearchHandler: Pesquisa %s bloqueada pelo orçamento: %v", req.JobID, err)
+		http.Error(w, fmt.Sprintf("Search %s stopped by budget: %v", req.JobID, err), http.StatusPaymentRequired)
+		return
+	}
 	if err != nil {
 		log.Printf("startSearchHandler: Erro ao iniciar pesquisa: %v", err)
 		http.Error(w, fmt.Sprintf("Failed to start search: %v", err), http.StatusInternalServerError)
@@ -125,55 +243,151 @@ func startSearchHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	log.Println("startSearchHandler: Pesquisa iniciada com sucesso")
-	fmt.Fprintf(w, "Search started for categoryID: %s, zipcodeID: %d, radius: %d, country: %s", categoryID, zipcodeID, radiusInt, country)
+	fmt.Fprintf(w, "Search started for categoryID: %s, zipcodeID: %s, radius: %d, country: %s, job: %s", categoryID, zipcode, radiusInt, country, req.JobID)
 }
 
-func startSearch(apiKey string, categoryID string, zipcodeID, radius, maxResults int, country string) error {
-	log.Printf("Iniciando pesquisa: categoryID=%s, zipcodeID=%d, radius=%d, maxResults=%d, country=%s",
-		categoryID, zipcodeID, radius, maxResults, country)
+type searchRequest struct {
+	JobID      string
+	CategoryID string
+	Zipcode    string // só dígitos, com os zeros à esquerda
+	Radius     int
+	MaxResults int
+	Country    string
+	BudgetUSD  float64
+
+	// Workspace (slug ou ID) onde os leads serão gravados. Vazio usa o
+	// workspace padrão da API.
+	Workspace string
+
+	// SavedSearchID é preenchido quando a busca vem do agendador. Nesse caso
+	// só places que ainda não são leads são enviados.
+	SavedSearchID string
+}
 
-	service := googleplaces.NewService(apiKey)
+func startSearch(apiKey string, req searchRequest) (err error) {
+	categoryID, zipcode, radius, maxResults, country := req.CategoryID, req.Zipcode, req.Radius, req.MaxResults, req.Country
+	log.Printf("Iniciando pesquisa %s: categoryID=%s, zipcode=%s, radius=%d, maxResults=%d, country=%s",
+		req.JobID, categoryID, zipcode, radius, maxResults, country)
 
-	zipcodeString := strconv.Itoa(zipcodeID)
+	// Buscas agendadas não passam pelo handler; os tetos valem para elas também.
+	if maxResultsCap > 0 && maxResults > maxResultsCap {
+		log.Printf("max_results %d acima do limite; usando %d", maxResults, maxResultsCap)
+		maxResults = maxResultsCap
+	}
 
-	locationStr, err := service.GeocodeZip(zipcodeString, country)
+	service := googleplaces.NewService(apiKey)
+	detailsFields, err := googleplaces.ParseDetailsFields(os.Getenv("GOOGLE_PLACES_DETAILS_FIELDS"))
 	if err != nil {
-		return fmt.Errorf("erro ao geocodificar o CEP %d: %v", zipcodeID, err)
+		return fmt.Errorf("configuração GOOGLE_PLACES_DETAILS_FIELDS inválida: %v", err)
 	}
-	log.Printf("Localização obtida para o CEP %s: %s", zipcodeString, locationStr)
+	service.DetailsFields = detailsFields
 
 	maxPages := 3
-	places, err := service.SearchPlaces(categoryID, locationStr, radius, maxPages, maxResults)
+	if err := ledger.StartJob(req.JobID, req.Workspace, req.BudgetUSD, estimateSearchCalls(maxResults, maxPages, detailsFields)); err != nil {
+		return err
+	}
+	service.Meter = ledger.ForJob(req.JobID)
+	totalLeadsExtracted := 0
+	publishProgress(req, eventSearchStarted, map[string]interface{}{
+		"category_id":     categoryID,
+		"zipcode":         zipcode,
+		"country":         country,
+		"max_results":     maxResults,
+		"saved_search_id": req.SavedSearchID,
+	})
+	defer func() {
+		status := "completed"
+		if errors.Is(err, usage.ErrBudgetExceeded) {
+			status = "paused"
+		} else if err != nil {
+			status = "failed"
+		}
+		finished := map[string]interface{}{"status": status, "leads": totalLeadsExtracted}
+		if err != nil {
+			finished["error"] = err.Error()
+		}
+		publishProgress(req, eventSearchFinished, finished)
+		if finishErr := ledger.FinishJob(req.JobID, status); finishErr != nil {
+			log.Printf("Erro ao finalizar contabilização do job %s: %v", req.JobID, finishErr)
+		}
+	}()
+
+	geocoded, err := geocodeCache.Lookup(service, country, zipcode)
 	if err != nil {
-		return fmt.Errorf("erro ao buscar lugares: %v", err)
+		return fmt.Errorf("erro ao geocodificar o CEP %s: %w", zipcode, err)
 	}
+	locationStr := geocoded.Result.Location()
+	log.Printf("Localização obtida para o CEP %s: %s (%s)", zipcode, locationStr, geocoded.Result.Locality)
 
-	totalLeadsExtracted := 0
-	var leads []map[string]interface{}
+	if radius <= 0 {
+		radius = geocoded.Result.DefaultRadius()
+		log.Printf("Raio não informado; usando %d m a partir do viewport do CEP", radius)
+	}
+	if maxRadiusMeters > 0 && radius > maxRadiusMeters {
+		log.Printf("radius %d acima do limite; usando %d m", radius, maxRadiusMeters)
+		radius = maxRadiusMeters
+	}
 
-	for _, place := range places {
-		placeID, ok := place["PlaceID"].(string)
-		if !ok {
-			log.Println("PlaceID não encontrado ou não é string")
+	found, err := service.SearchPlaces(categoryID, locationStr, radius, maxPages, maxResults)
+	if err != nil {
+		return fmt.Errorf("erro ao buscar lugares: %w", err)
+	}
+
+	placeIDs := make([]string, 0, len(found))
+	for _, place := range found {
+		placeIDs = append(placeIDs, place.PlaceID)
+	}
+	fresh, err := fetchFreshPlaceIDs(req.Workspace, placeIDs, req.SavedSearchID != "")
+	if err != nil {
+		log.Printf("Não foi possível consultar places conhecidos, buscando detalhes de todos: %v", err)
+		fresh = map[string]bool{}
+	}
+	publishProgress(req, eventSearchPlacesFound, map[string]interface{}{
+		"places":  len(found),
+		"skipped": len(fresh),
+		"radius":  radius,
+	})
+
+	var leads []places.Place
+
+	for _, place := range found {
+		if place.PlaceID == "" {
+			log.Println("PlaceID não encontrado")
+			continue
+		}
+		if fresh[place.PlaceID] {
+			log.Printf("Place %s já é lead com detalhes recentes. Pulando Place Details.", place.PlaceID)
 			continue
 		}
 
-		details, err := service.GetPlaceDetails(placeID)
+		details, err := service.GetPlaceDetails(place.PlaceID)
+		if errors.Is(err, usage.ErrBudgetExceeded) {
+			log.Printf("Pesquisa %s pausada após %d leads: %v", req.JobID, totalLeadsExtracted, err)
+			return err
+		}
 		if err != nil {
 			log.Printf("Erro ao obter detalhes do place: %v", err)
 			continue
 		}
 
-		details["Category"] = categoryID
-		details["Radius"] = radius
+		details.MergeSearchResult(place)
+		details.Category = categoryID
+		details.Radius = radius
+		details.JobID = req.JobID
+		details.SavedSearchID = req.SavedSearchID
 
 		totalLeadsExtracted++
 		log.Printf("Lead #%d obtido: %+v", totalLeadsExtracted, details)
-		leads = append(leads, details)
+		leads = append(leads, *details)
 
-		if err := sendLeadsToAPI(leads); err != nil {
+		if err := sendLeadsToAPI(req.Workspace, leads); err != nil {
 			return fmt.Errorf("erro ao enviar leads para a API: %v", err)
 		}
+		publishProgress(req, eventSearchLeadFound, map[string]interface{}{
+			"place_id": details.PlaceID,
+			"name":     details.Name,
+			"count":    totalLeadsExtracted,
+		})
 
 		if totalLeadsExtracted >= maxResults {
 			log.Printf("Limite de %d resultados atingido.", maxResults)
@@ -187,10 +401,19 @@ func startSearch(apiKey string, categoryID string, zipcodeID, radius, maxResults
 }
 
 func withCORS(next http.Handler) http.Handler {
+	allowed := corsAllowedOrigins()
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
+		origin := r.Header.Get("Origin")
+		switch {
+		case allowed["*"]:
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		case origin != "" && allowed[origin]:
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Add("Vary", "Origin")
+		}
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key, X-Workspace")
+		w.Header().Set("Access-Control-Expose-Headers", "Retry-After")
 		if r.Method == http.MethodOptions {
 			return
 		}
@@ -198,7 +421,7 @@ func withCORS(next http.Handler) http.Handler {
 	})
 }
 
-func sendLeadsToAPI(leads []map[string]interface{}) error {
+func sendLeadsToAPI(workspace string, leads []places.Place) error {
 	log.Printf("Iniciando envio de %d leads para a API...", len(leads))
 
 	jsonData, err := json.Marshal(leads)
@@ -208,10 +431,9 @@ func sendLeadsToAPI(leads []map[string]interface{}) error {
 	}
 	log.Printf("Leads convertidos para JSON com sucesso. Tamanho do payload: %d bytes", len(jsonData))
 
-	apiURL := "http://api:8085/save-leads"
-	log.Printf("Enviando requisição POST para %s", apiURL)
+	log.Printf("Enviando requisição POST para %s/save-leads (workspace %q)", apiBaseURL(), workspace)
 
-	resp, err := http.Post(apiURL, "application/json", bytes.NewBuffer(jsonData))
+	resp, err := postToWorkspace("/save-leads", workspace, jsonData)
 	if err != nil {
 		log.Printf("Erro ao enviar requisição para a API: %v", err)
 		return fmt.Errorf("erro ao enviar requisição para a API: %v", err)
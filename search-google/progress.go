@@ -0,0 +1,130 @@
+// /search-google/progress.go
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/streadway/amqp"
+	"github.com/wbrunovieira/LeadSearchVersion2/shared/leadevents"
+)
+
+// Eventos de progresso de uma busca, publicados na exchange lead_events com o
+// nome do evento como routing key. A API os repassa ao front por
+// /search-progress.
+const (
+	eventSearchStarted     = "search.started"
+	eventSearchPlacesFound = "search.places_found"
+	eventSearchLeadFound   = "search.lead_found"
+	eventSearchFinished    = "search.finished"
+)
+
+// validJobID aceita o job_id escolhido pelo cliente, que assim pode abrir o
+// stream de progresso antes de começar a busca.
+var validJobID = regexp.MustCompile(`^[A-Za-z0-9_-]{8,64}$`)
+
+// progressCh é o canal em que o progresso é publicado; nil enquanto o
+// RabbitMQ está fora e a reconexão não terminou.
+var (
+	progressMu sync.Mutex
+	progressCh *amqp.Channel
+)
+
+// Espera entre as tentativas de reconectar o canal do progresso.
+const (
+	progressRetryMin = time.Second
+	progressRetryMax = 30 * time.Second
+)
+
+// initProgress conecta ao RabbitMQ de RABBITMQ_URL para anunciar o progresso
+// das buscas. Sem a variável, o progresso não é publicado.
+func initProgress() error {
+	url := os.Getenv("RABBITMQ_URL")
+	if url == "" {
+		log.Println("RABBITMQ_URL não definida: progresso das buscas não será publicado")
+		return nil
+	}
+	if err := connectProgress(url); err != nil {
+		return err
+	}
+	log.Println("Progresso das buscas publicado em", leadevents.Exchange)
+	return nil
+}
+
+// connectProgress abre o canal do progresso e, quando ele fechar (RabbitMQ
+// reiniciado, conexão perdida), reconecta em segundo plano.
+func connectProgress(url string) error {
+	conn, err := amqp.Dial(url)
+	if err != nil {
+		return fmt.Errorf("erro ao conectar ao RabbitMQ: %v", err)
+	}
+	ch, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("erro ao abrir canal do RabbitMQ: %v", err)
+	}
+	if err := leadevents.Declare(ch); err != nil {
+		conn.Close()
+		return err
+	}
+
+	closed := ch.NotifyClose(make(chan *amqp.Error, 1))
+	progressMu.Lock()
+	progressCh = ch
+	progressMu.Unlock()
+
+	go func() {
+		reason := <-closed
+		progressMu.Lock()
+		progressCh = nil
+		progressMu.Unlock()
+		conn.Close()
+		log.Printf("Canal do progresso das buscas fechado (%v); reconectando", reason)
+		reconnectProgress(url)
+	}()
+	return nil
+}
+
+// reconnectProgress tenta reabrir o canal até conseguir. Só a primeira falha
+// vai para o log, para um RabbitMQ fora do ar não encher o log.
+func reconnectProgress(url string) {
+	wait := progressRetryMin
+	for attempt := 1; ; attempt++ {
+		time.Sleep(wait)
+		err := connectProgress(url)
+		if err == nil {
+			log.Println("Canal do progresso das buscas reconectado")
+			return
+		}
+		if attempt == 1 {
+			log.Printf("Erro ao reconectar o progresso das buscas, tentando de novo em segundo plano: %v", err)
+		}
+		if wait *= 2; wait > progressRetryMax {
+			wait = progressRetryMax
+		}
+	}
+}
+
+// publishProgress anuncia um evento da busca. Falhas só vão para o log: o
+// progresso não pode interromper a busca.
+func publishProgress(req searchRequest, event string, details map[string]interface{}) {
+	progressMu.Lock()
+	ch := progressCh
+	progressMu.Unlock()
+	if ch == nil {
+		return
+	}
+	err := leadevents.Publish(ch, leadevents.Event{
+		Event:     event,
+		JobID:     req.JobID,
+		Workspace: req.Workspace,
+		Details:   details,
+	})
+	if err != nil {
+		log.Printf("Erro ao publicar o progresso do job %s: %v", req.JobID, err)
+	}
+}
@@ -0,0 +1,151 @@
+// /search-google/refresh.go
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/wbrunovieira/LeadSearchVersion2/search-google/googleplaces"
+	"github.com/wbrunovieira/LeadSearchVersion2/search-google/usage"
+	"github.com/wbrunovieira/LeadSearchVersion2/shared/places"
+)
+
+// startDetailsRefresher roda refreshStaleDetails a cada
+// PLACE_REFRESH_INTERVAL_HOURS horas. Sem a variável, o job fica desligado e
+// só roda via POST /refresh-details.
+func startDetailsRefresher() {
+	hours, err := strconv.Atoi(os.Getenv("PLACE_REFRESH_INTERVAL_HOURS"))
+	if err != nil || hours <= 0 {
+		log.Println("Atualização periódica de detalhes desligada (PLACE_REFRESH_INTERVAL_HOURS não definido)")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(time.Duration(hours) * time.Hour)
+		defer ticker.Stop()
+		for range ticker.C {
+			if _, err := refreshStaleDetails(refreshBatchSize()); err != nil {
+				log.Printf("Erro na atualização periódica de detalhes: %v", err)
+			}
+		}
+	}()
+	log.Printf("Atualização de detalhes agendada a cada %d horas", hours)
+}
+
+func refreshBatchSize() int {
+	if v, err := strconv.Atoi(os.Getenv("PLACE_REFRESH_BATCH_SIZE")); err == nil && v > 0 {
+		return v
+	}
+	return 50
+}
+
+// refreshStaleDetails busca de novo o Place Details dos leads com detalhes
+// mais velhos que PLACE_DETAILS_MAX_AGE_DAYS e envia o resultado para a API.
+func refreshStaleDetails(limit int) (int, error) {
+	apiKey := os.Getenv("GOOGLE_PLACES_API_KEY")
+	if apiKey == "" {
+		return 0, fmt.Errorf("API key not provided")
+	}
+
+	placeIDs, err := fetchStalePlaceIDs(detailsMaxAgeDays(), limit)
+	if err != nil {
+		return 0, err
+	}
+	if len(placeIDs) == 0 {
+		log.Println("Nenhum lead com detalhes desatualizados")
+		return 0, nil
+	}
+
+	service := googleplaces.NewService(apiKey)
+	detailsFields, err := googleplaces.ParseDetailsFields(os.Getenv("GOOGLE_PLACES_DETAILS_FIELDS"))
+	if err != nil {
+		return 0, fmt.Errorf("configuração GOOGLE_PLACES_DETAILS_FIELDS inválida: %v", err)
+	}
+	service.DetailsFields = detailsFields
+
+	jobID := "refresh-" + newJobID()
+	estimate := usage.Counts{}
+	for _, sku := range googleplaces.DetailsSKUs(detailsFields) {
+		estimate[sku] = len(placeIDs)
+	}
+	if err := ledger.StartJob(jobID, "", 0, estimate); err != nil {
+		return 0, err
+	}
+	service.Meter = ledger.ForJob(jobID)
+
+	status := "completed"
+	var refreshed []places.Place
+	var failed, notFound []string
+	for _, placeID := range placeIDs {
+		details, err := service.GetPlaceDetails(placeID)
+		if errors.Is(err, usage.ErrBudgetExceeded) {
+			log.Printf("Atualização %s pausada pelo orçamento: %v", jobID, err)
+			status = "paused"
+			break
+		}
+		if errors.Is(err, googleplaces.ErrPlaceNotFound) {
+			log.Printf("Place %s não existe mais no Google", placeID)
+			notFound = append(notFound, placeID)
+			continue
+		}
+		if err != nil {
+			log.Printf("Erro ao atualizar detalhes do place %s: %v", placeID, err)
+			failed = append(failed, placeID)
+			continue
+		}
+		refreshed = append(refreshed, *details)
+	}
+
+	// Sem registrar as falhas, os mesmos places voltariam no topo de todo lote.
+	if len(failed) > 0 || len(notFound) > 0 {
+		if err := sendRefreshFailures(failed, notFound); err != nil {
+			log.Printf("Erro ao registrar as falhas da atualização %s: %v", jobID, err)
+		}
+	}
+
+	if len(refreshed) > 0 {
+		if err := sendRefreshedPlaces(refreshed); err != nil {
+			status = "failed"
+			ledger.FinishJob(jobID, status)
+			return 0, err
+		}
+	}
+	if err := ledger.FinishJob(jobID, status); err != nil {
+		log.Printf("Erro ao finalizar contabilização do job %s: %v", jobID, err)
+	}
+	log.Printf("Atualização %s concluída: %d de %d leads atualizados", jobID, len(refreshed), len(placeIDs))
+	return len(refreshed), nil
+}
+
+func refreshDetailsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método não permitido. Use POST.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	limit := refreshBatchSize()
+	if v := r.URL.Query().Get("limit"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed <= 0 {
+			http.Error(w, "Invalid limit value", http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+
+	count, err := refreshStaleDetails(limit)
+	if errors.Is(err, usage.ErrBudgetExceeded) {
+		http.Error(w, err.Error(), http.StatusPaymentRequired)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to refresh details: %v", err), http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprintf(w, "%d leads atualizados", count)
+}
@@ -0,0 +1,108 @@
+// /search-google/scheduler.go
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/wbrunovieira/LeadSearchVersion2/search-google/geocache"
+)
+
+// startScheduler consulta a API a cada SAVED_SEARCH_POLL_SECONDS (padrão 60)
+// e roda as buscas agendadas que venceram. Com valor 0 o agendador fica
+// desligado.
+func startScheduler() {
+	interval := 60
+	if v := os.Getenv("SAVED_SEARCH_POLL_SECONDS"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil {
+			log.Printf("SAVED_SEARCH_POLL_SECONDS inválido (%s), usando %d", v, interval)
+		} else {
+			interval = parsed
+		}
+	}
+	if interval <= 0 {
+		log.Println("Agendador de buscas desligado")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(time.Duration(interval) * time.Second)
+		defer ticker.Stop()
+		for range ticker.C {
+			runDueSavedSearches()
+		}
+	}()
+	log.Printf("Agendador de buscas verificando a cada %d segundos", interval)
+}
+
+// schedulerClientKey é o cliente do agendador no limite de buscas simultâneas.
+const schedulerClientKey = "agendador"
+
+// schedulerSlotWait é o intervalo entre as tentativas de conseguir vaga.
+var schedulerSlotWait = 5 * time.Second
+
+// acquireSchedulerSlot espera uma vaga no limite de buscas simultâneas. As
+// buscas vencidas já foram reivindicadas na API, então esperam a vez em vez
+// de serem puladas.
+func acquireSchedulerSlot() func() {
+	waiting := false
+	for {
+		if release, ok := searchConcurrency.Acquire(schedulerClientKey); ok {
+			return release
+		}
+		if !waiting {
+			log.Printf("Agendador: sem vaga para mais uma busca (%d rodando), aguardando", searchConcurrency.Running())
+			waiting = true
+		}
+		time.Sleep(schedulerSlotWait)
+	}
+}
+
+func runDueSavedSearches() {
+	searches, err := claimDueSavedSearches()
+	if err != nil {
+		log.Printf("Agendador: %v", err)
+		return
+	}
+
+	apiKey := os.Getenv("GOOGLE_PLACES_API_KEY")
+	if apiKey == "" && len(searches) > 0 {
+		log.Println("Agendador: API key não provida, buscas agendadas não serão executadas")
+		for _, saved := range searches {
+			reportSavedSearchRun(saved.ID, "", fmt.Errorf("API key not provided"))
+		}
+		return
+	}
+
+	for _, saved := range searches {
+		req := searchRequest{
+			JobID:         newJobID(),
+			CategoryID:    saved.CategoryID,
+			Radius:        saved.Radius,
+			MaxResults:    saved.MaxResults,
+			Country:       saved.Country,
+			BudgetUSD:     saved.BudgetUSD,
+			Workspace:     saved.workspace(),
+			SavedSearchID: saved.ID,
+		}
+		log.Printf("Agendador: rodando busca %q (%s) como job %s", saved.Name, saved.ID, req.JobID)
+
+		var runErr error
+		req.Zipcode, runErr = geocache.PostalCode(saved.ZipCode)
+		if runErr == nil {
+			release := acquireSchedulerSlot()
+			runErr = startSearch(apiKey, req)
+			release()
+		}
+		if runErr != nil {
+			log.Printf("Agendador: busca %s falhou: %v", saved.ID, runErr)
+		}
+		if err := reportSavedSearchRun(saved.ID, req.JobID, runErr); err != nil {
+			log.Printf("Agendador: %v", err)
+		}
+	}
+}
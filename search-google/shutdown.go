@@ -0,0 +1,41 @@
+// /search-google/shutdown.go
+package main
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+var (
+	shutdownMu    sync.Mutex
+	shutdownHooks []func()
+)
+
+// onShutdown registra o que precisa rodar antes do processo sair, como gravar
+// o estado mantido em memória (consumo, contagem de acertos do cache).
+func onShutdown(hook func()) {
+	shutdownMu.Lock()
+	defer shutdownMu.Unlock()
+	shutdownHooks = append(shutdownHooks, hook)
+}
+
+// handleShutdown roda os hooks registrados ao receber SIGINT ou SIGTERM e
+// encerra o processo.
+func handleShutdown() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		log.Printf("Sinal %v recebido; gravando o estado antes de sair", sig)
+		shutdownMu.Lock()
+		hooks := shutdownHooks
+		shutdownMu.Unlock()
+		for _, hook := range hooks {
+			hook()
+		}
+		os.Exit(0)
+	}()
+}
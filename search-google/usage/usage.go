@@ -0,0 +1,497 @@
+// /search-google/usage/usage.go
+package usage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SKUs cobrados pelas APIs do Google usadas pelo search-google.
+const (
+	SKUGeocoding         = "geocoding"
+	SKUTextSearch        = "text_search"
+	SKUDetails           = "details"
+	SKUDetailsContact    = "details_contact"
+	SKUDetailsAtmosphere = "details_atmosphere"
+)
+
+// DefaultPrices é o preço em USD por 1000 chamadas de cada SKU (tabela legada
+// do Places/Geocoding). Pode ser sobrescrito por PLACES_PRICES.
+var DefaultPrices = map[string]float64{
+	SKUGeocoding:         5.00,
+	SKUTextSearch:        32.00,
+	SKUDetails:           17.00,
+	SKUDetailsContact:    3.00,
+	SKUDetailsAtmosphere: 5.00,
+}
+
+// ParsePrices lê sobrescritas de preço no formato "sku=usd_por_1000,...".
+func ParsePrices(config string) (map[string]float64, error) {
+	prices := make(map[string]float64, len(DefaultPrices))
+	for sku, price := range DefaultPrices {
+		prices[sku] = price
+	}
+	for _, item := range strings.Split(config, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("preço inválido: %s", item)
+		}
+		price, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("preço inválido para %s: %v", parts[0], err)
+		}
+		prices[strings.TrimSpace(parts[0])] = price
+	}
+	return prices, nil
+}
+
+var ErrBudgetExceeded = errors.New("orçamento do Google Places excedido")
+
+// Budget define os limites de gasto em USD. Zero significa sem limite.
+type Budget struct {
+	DailyUSD   float64 `json:"daily_usd"`
+	MonthlyUSD float64 `json:"monthly_usd"`
+	JobUSD     float64 `json:"job_usd"`
+}
+
+// Counts é o número de chamadas por SKU.
+type Counts map[string]int
+
+func (c Counts) clone() Counts {
+	out := make(Counts, len(c))
+	for sku, n := range c {
+		out[sku] = n
+	}
+	return out
+}
+
+// JobUsage é o consumo de um job. Workspace é o slug do workspace da busca;
+// fica vazio nos jobs do sistema (aquecimento do cache, atualização de
+// detalhes).
+type JobUsage struct {
+	JobID      string    `json:"job_id"`
+	Workspace  string    `json:"workspace,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at,omitempty"`
+	Status     string    `json:"status"`
+	BudgetUSD  float64   `json:"budget_usd"`
+	Calls      Counts    `json:"calls"`
+	CostUSD    float64   `json:"cost_usd"`
+}
+
+func (j *JobUsage) clone() JobUsage {
+	out := *j
+	out.Calls = j.Calls.clone()
+	return out
+}
+
+type ledgerData struct {
+	Days map[string]Counts    `json:"days"`
+	Jobs map[string]*JobUsage `json:"jobs"`
+}
+
+// HistoryDays é por quantos dias o ledger guarda o consumo diário e os jobs.
+// Cobre sempre o mês corrente, que é o que o orçamento mensal precisa.
+const HistoryDays = 62
+
+// Ledger contabiliza as chamadas por SKU, por dia e por job, e persiste tudo
+// em um arquivo JSON ao lado do next_page_tokens.json. StartJob e FinishJob
+// gravam na hora; as chamadas de Charge ficam em memória até o próximo Flush.
+type Ledger struct {
+	mu     sync.Mutex
+	path   string
+	prices map[string]float64
+	budget Budget
+	data   ledgerData
+	dirty  bool
+	now    func() time.Time
+}
+
+func NewLedger(path string, budget Budget, prices map[string]float64) (*Ledger, error) {
+	if prices == nil {
+		prices = DefaultPrices
+	}
+	l := &Ledger{
+		path:   path,
+		prices: prices,
+		budget: budget,
+		data: ledgerData{
+			Days: make(map[string]Counts),
+			Jobs: make(map[string]*JobUsage),
+		},
+		now: time.Now,
+	}
+
+	file, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			log.Printf("Arquivo de consumo %s não encontrado. Começando do zero.", path)
+			return l, nil
+		}
+		return nil, fmt.Errorf("erro ao ler o arquivo de consumo: %v", err)
+	}
+	if len(strings.TrimSpace(string(file))) > 0 {
+		if err := json.Unmarshal(file, &l.data); err != nil {
+			return nil, fmt.Errorf("erro ao fazer parse do arquivo de consumo: %v", err)
+		}
+	}
+	if l.data.Days == nil {
+		l.data.Days = make(map[string]Counts)
+	}
+	if l.data.Jobs == nil {
+		l.data.Jobs = make(map[string]*JobUsage)
+	}
+	return l, nil
+}
+
+// Cost calcula o custo em USD de um conjunto de chamadas.
+func (l *Ledger) Cost(calls Counts) float64 {
+	var total float64
+	for sku, n := range calls {
+		total += l.prices[sku] * float64(n) / 1000
+	}
+	return math.Round(total*10000) / 10000
+}
+
+// StartJob registra um novo job do workspace e o recusa se a estimativa de
+// chamadas já estourar o orçamento diário, mensal ou do próprio job.
+func (l *Ledger) StartJob(jobID, workspace string, jobBudgetUSD float64, estimate Counts) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if jobBudgetUSD <= 0 {
+		jobBudgetUSD = l.budget.JobUSD
+	}
+	estimated := l.Cost(estimate)
+	if jobBudgetUSD > 0 && estimated > jobBudgetUSD {
+		return fmt.Errorf("%w: custo estimado US$%.4f acima do limite do job (US$%.2f)", ErrBudgetExceeded, estimated, jobBudgetUSD)
+	}
+	if err := l.checkGlobal(estimated); err != nil {
+		return err
+	}
+
+	l.data.Jobs[jobID] = &JobUsage{
+		JobID:     jobID,
+		Workspace: workspace,
+		StartedAt: l.now(),
+		Status:    "running",
+		BudgetUSD: jobBudgetUSD,
+		Calls:     make(Counts),
+	}
+	return l.save()
+}
+
+// Charge contabiliza as chamadas dos SKUs para o job antes de elas serem
+// feitas. Se algum orçamento estourar, nada é registrado e o erro retornado
+// embrulha ErrBudgetExceeded.
+func (l *Ledger) Charge(jobID string, skus ...string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	calls := make(Counts, len(skus))
+	for _, sku := range skus {
+		calls[sku]++
+	}
+	price := l.Cost(calls)
+
+	job := l.data.Jobs[jobID]
+	if job != nil && job.BudgetUSD > 0 && job.CostUSD+price > job.BudgetUSD {
+		return fmt.Errorf("%w: limite do job %s (US$%.2f) atingido", ErrBudgetExceeded, jobID, job.BudgetUSD)
+	}
+	if err := l.checkGlobal(price); err != nil {
+		return err
+	}
+
+	day := l.now().Format("2006-01-02")
+	if l.data.Days[day] == nil {
+		l.data.Days[day] = make(Counts)
+	}
+	for sku, n := range calls {
+		l.data.Days[day][sku] += n
+		if job != nil {
+			job.Calls[sku] += n
+		}
+	}
+	if job != nil {
+		job.CostUSD = l.Cost(job.Calls)
+	}
+	l.dirty = true
+	return nil
+}
+
+// FinishJob marca o job como concluído, pausado ou com falha.
+func (l *Ledger) FinishJob(jobID string, status string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	job := l.data.Jobs[jobID]
+	if job == nil {
+		return nil
+	}
+	job.Status = status
+	job.FinishedAt = l.now()
+	return l.save()
+}
+
+func (l *Ledger) checkGlobal(extra float64) error {
+	now := l.now()
+	if l.budget.DailyUSD > 0 {
+		spent := l.Cost(l.data.Days[now.Format("2006-01-02")])
+		if spent+extra > l.budget.DailyUSD {
+			return fmt.Errorf("%w: limite diário de US$%.2f (gasto: US$%.4f)", ErrBudgetExceeded, l.budget.DailyUSD, spent)
+		}
+	}
+	if l.budget.MonthlyUSD > 0 {
+		spent := l.Cost(l.monthCounts(now.Format("2006-01")))
+		if spent+extra > l.budget.MonthlyUSD {
+			return fmt.Errorf("%w: limite mensal de US$%.2f (gasto: US$%.4f)", ErrBudgetExceeded, l.budget.MonthlyUSD, spent)
+		}
+	}
+	return nil
+}
+
+func (l *Ledger) monthCounts(month string) Counts {
+	total := make(Counts)
+	for day, counts := range l.data.Days {
+		if strings.HasPrefix(day, month) {
+			for sku, n := range counts {
+				total[sku] += n
+			}
+		}
+	}
+	return total
+}
+
+// Flush grava o ledger se houver chamadas ainda não persistidas.
+func (l *Ledger) Flush() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.dirty {
+		return nil
+	}
+	return l.save()
+}
+
+// StartFlusher roda Flush a cada interval até stop ser chamado; stop faz um
+// último Flush, para ser usado no desligamento.
+func (l *Ledger) StartFlusher(interval time.Duration) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				if err := l.Flush(); err != nil {
+					log.Printf("Erro ao gravar o consumo do Google Places: %v", err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			ticker.Stop()
+			close(done)
+			if err := l.Flush(); err != nil {
+				log.Printf("Erro ao gravar o consumo do Google Places: %v", err)
+			}
+		})
+	}
+}
+
+// prune descarta os dias e os jobs mais antigos que HistoryDays; jobs sem
+// fim registrado contam pelo início.
+func (l *Ledger) prune() {
+	cutoff := l.now().AddDate(0, 0, -HistoryDays)
+	cutoffDay := cutoff.Format("2006-01-02")
+	for day := range l.data.Days {
+		if day < cutoffDay {
+			delete(l.data.Days, day)
+		}
+	}
+	for id, job := range l.data.Jobs {
+		last := job.FinishedAt
+		if last.IsZero() {
+			last = job.StartedAt
+		}
+		if last.Before(cutoff) {
+			delete(l.data.Jobs, id)
+		}
+	}
+}
+
+// save grava o ledger num arquivo temporário e o renomeia por cima do atual,
+// para uma queda no meio da escrita não truncar o consumo registrado.
+func (l *Ledger) save() error {
+	l.prune()
+
+	dir := filepath.Dir(l.path)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return fmt.Errorf("erro ao criar o diretório %s: %v", dir, err)
+	}
+	bytes, err := json.Marshal(l.data)
+	if err != nil {
+		return fmt.Errorf("erro ao serializar consumo: %v", err)
+	}
+	tmp, err := os.CreateTemp(dir, filepath.Base(l.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("erro ao criar o arquivo temporário de consumo: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return fmt.Errorf("erro ao salvar o arquivo de consumo: %v", err)
+	}
+	if _, err := tmp.Write(bytes); err != nil {
+		tmp.Close()
+		return fmt.Errorf("erro ao salvar o arquivo de consumo: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("erro ao salvar o arquivo de consumo: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), l.path); err != nil {
+		return fmt.Errorf("erro ao salvar o arquivo de consumo: %v", err)
+	}
+	l.dirty = false
+	return nil
+}
+
+type PeriodUsage struct {
+	Period  string  `json:"period"`
+	Calls   Counts  `json:"calls"`
+	CostUSD float64 `json:"cost_usd"`
+}
+
+type Report struct {
+	Budget Budget             `json:"budget"`
+	Prices map[string]float64 `json:"prices_per_1000"`
+	Today  PeriodUsage        `json:"today"`
+	Month  PeriodUsage        `json:"month"`
+	Days   []PeriodUsage      `json:"days"`
+	Jobs   []JobUsage         `json:"jobs"`
+}
+
+// Report monta o resumo exposto em GET /usage.
+func (l *Ledger) Report() Report {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	today := now.Format("2006-01-02")
+	month := now.Format("2006-01")
+
+	todayCounts := l.data.Days[today].clone()
+	monthCounts := l.monthCounts(month)
+
+	report := Report{
+		Budget: l.budget,
+		Prices: l.prices,
+		Today:  PeriodUsage{Period: today, Calls: todayCounts, CostUSD: l.Cost(todayCounts)},
+		Month:  PeriodUsage{Period: month, Calls: monthCounts, CostUSD: l.Cost(monthCounts)},
+	}
+	for day, counts := range l.data.Days {
+		report.Days = append(report.Days, PeriodUsage{Period: day, Calls: counts.clone(), CostUSD: l.Cost(counts)})
+	}
+	sort.Slice(report.Days, func(i, j int) bool { return report.Days[i].Period > report.Days[j].Period })
+	for _, job := range l.data.Jobs {
+		report.Jobs = append(report.Jobs, job.clone())
+	}
+	sort.Slice(report.Jobs, func(i, j int) bool { return report.Jobs[i].StartedAt.After(report.Jobs[j].StartedAt) })
+	return report
+}
+
+// ReportFor é o Report de quem só enxerga alguns workspaces: traz só os jobs
+// desses workspaces, e os totais do dia, do mês e de cada dia somam apenas
+// esses jobs (pelo dia em que começaram), sem revelar o gasto dos outros.
+func (l *Ledger) ReportFor(allowed func(workspace string) bool) Report {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	today := now.Format("2006-01-02")
+	month := now.Format("2006-01")
+
+	report := Report{
+		Budget: l.budget,
+		Prices: l.prices,
+		Today:  PeriodUsage{Period: today, Calls: make(Counts)},
+		Month:  PeriodUsage{Period: month, Calls: make(Counts)},
+	}
+	days := make(map[string]Counts)
+	for _, job := range l.data.Jobs {
+		if job.Workspace == "" || !allowed(job.Workspace) {
+			continue
+		}
+		report.Jobs = append(report.Jobs, job.clone())
+
+		day := job.StartedAt.Format("2006-01-02")
+		if days[day] == nil {
+			days[day] = make(Counts)
+		}
+		for sku, n := range job.Calls {
+			days[day][sku] += n
+			if day == today {
+				report.Today.Calls[sku] += n
+			}
+			if strings.HasPrefix(day, month) {
+				report.Month.Calls[sku] += n
+			}
+		}
+	}
+	report.Today.CostUSD = l.Cost(report.Today.Calls)
+	report.Month.CostUSD = l.Cost(report.Month.Calls)
+	for day, counts := range days {
+		report.Days = append(report.Days, PeriodUsage{Period: day, Calls: counts, CostUSD: l.Cost(counts)})
+	}
+	sort.Slice(report.Days, func(i, j int) bool { return report.Days[i].Period > report.Days[j].Period })
+	sort.Slice(report.Jobs, func(i, j int) bool { return report.Jobs[i].StartedAt.After(report.Jobs[j].StartedAt) })
+	return report
+}
+
+// Job devolve o consumo de um job específico.
+func (l *Ledger) Job(jobID string) (JobUsage, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	job, ok := l.data.Jobs[jobID]
+	if !ok {
+		return JobUsage{}, false
+	}
+	return job.clone(), true
+}
+
+// Meter é o que os clientes do Google usam para contabilizar cada chamada.
+type Meter interface {
+	Charge(skus ...string) error
+}
+
+type jobMeter struct {
+	ledger *Ledger
+	jobID  string
+}
+
+func (m jobMeter) Charge(skus ...string) error {
+	return m.ledger.Charge(m.jobID, skus...)
+}
+
+// ForJob devolve um Meter que atribui as chamadas ao job informado.
+func (l *Ledger) ForJob(jobID string) Meter {
+	return jobMeter{ledger: l, jobID: jobID}
+}
@@ -0,0 +1,152 @@
+package usage
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLedgerEnforcesBudgets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.json")
+	ledger, err := NewLedger(path, Budget{DailyUSD: 0.1}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ledger.now = func() time.Time { return time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC) }
+
+	// 3 text searches = US$0.096, a fourth would cross the daily limit.
+	if err := ledger.StartJob("job-1", "", 0, Counts{SKUTextSearch: 3}); err != nil {
+		t.Fatalf("job should be accepted: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := ledger.Charge("job-1", SKUTextSearch); err != nil {
+			t.Fatalf("charge %d should succeed: %v", i, err)
+		}
+	}
+	if err := ledger.Charge("job-1", SKUTextSearch); !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("expected ErrBudgetExceeded, got %v", err)
+	}
+
+	if err := ledger.StartJob("job-2", "", 0, Counts{SKUGeocoding: 1}); !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("job-2 should be refused, got %v", err)
+	}
+
+	if err := ledger.Flush(); err != nil {
+		t.Fatalf("flush failed: %v", err)
+	}
+	reloaded, err := NewLedger(path, Budget{}, nil)
+	if err != nil {
+		t.Fatalf("reload failed: %v", err)
+	}
+	job, ok := reloaded.Job("job-1")
+	if !ok || job.Calls[SKUTextSearch] != 3 {
+		t.Fatalf("expected persisted job with 3 text searches, got %+v", job)
+	}
+}
+
+func TestLedgerJobBudget(t *testing.T) {
+	ledger, err := NewLedger(filepath.Join(t.TempDir(), "usage.json"), Budget{}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := ledger.StartJob("big", "", 0.05, Counts{SKUDetails: 10}); !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("expected job over its own budget to be refused, got %v", err)
+	}
+}
+
+func TestLedgerReportForWorkspaces(t *testing.T) {
+	ledger, err := NewLedger(filepath.Join(t.TempDir(), "usage.json"), Budget{}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ledger.now = func() time.Time { return time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC) }
+
+	for job, workspace := range map[string]string{"a": "cliente-a", "b": "cliente-b", "warmup": ""} {
+		if err := ledger.StartJob(job, workspace, 0, Counts{}); err != nil {
+			t.Fatalf("StartJob %s failed: %v", job, err)
+		}
+		if err := ledger.Charge(job, SKUTextSearch); err != nil {
+			t.Fatalf("Charge %s failed: %v", job, err)
+		}
+	}
+	if err := ledger.Charge("a", SKUTextSearch); err != nil {
+		t.Fatalf("Charge failed: %v", err)
+	}
+
+	report := ledger.ReportFor(func(workspace string) bool { return workspace == "cliente-a" })
+	if len(report.Jobs) != 1 || report.Jobs[0].JobID != "a" {
+		t.Fatalf("expected only job a, got %+v", report.Jobs)
+	}
+	if report.Today.Calls[SKUTextSearch] != 2 || report.Month.Calls[SKUTextSearch] != 2 {
+		t.Errorf("totals must only count cliente-a: today %v, month %v", report.Today.Calls, report.Month.Calls)
+	}
+	if len(report.Days) != 1 || report.Days[0].Calls[SKUTextSearch] != 2 {
+		t.Errorf("unexpected days: %+v", report.Days)
+	}
+
+	if all := ledger.Report(); all.Today.Calls[SKUTextSearch] != 4 || len(all.Jobs) != 3 {
+		t.Errorf("unrestricted report changed: %+v", all)
+	}
+}
+
+func TestLedgerChargeWaitsForFlush(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.json")
+	ledger, err := NewLedger(path, Budget{}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := ledger.StartJob("job-1", "", 0, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := ledger.Charge("job-1", SKUDetails); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	reloaded, _ := NewLedger(path, Budget{}, nil)
+	if job, _ := reloaded.Job("job-1"); job.Calls[SKUDetails] != 0 {
+		t.Fatalf("charge should stay in memory until flush, got %+v", job)
+	}
+
+	stop := ledger.StartFlusher(time.Hour)
+	stop()
+	reloaded, _ = NewLedger(path, Budget{}, nil)
+	if job, _ := reloaded.Job("job-1"); job.Calls[SKUDetails] != 1 {
+		t.Fatalf("stop should flush pending charges, got %+v", job)
+	}
+	if matches, _ := filepath.Glob(path + ".*.tmp"); len(matches) != 0 {
+		t.Errorf("temporary files left behind: %v", matches)
+	}
+}
+
+func TestLedgerPrunesOldHistory(t *testing.T) {
+	ledger, err := NewLedger(filepath.Join(t.TempDir(), "usage.json"), Budget{}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	ledger.now = func() time.Time { return now.AddDate(0, 0, -HistoryDays-1) }
+	if err := ledger.StartJob("old", "", 0, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := ledger.Charge("old", SKUTextSearch); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := ledger.FinishJob("old", "completed"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ledger.now = func() time.Time { return now }
+	if err := ledger.StartJob("new", "", 0, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := ledger.Job("old"); ok {
+		t.Error("job finished before the history window should be pruned")
+	}
+	if _, ok := ledger.Job("new"); !ok {
+		t.Error("current job should be kept")
+	}
+	if report := ledger.Report(); len(report.Days) != 0 {
+		t.Errorf("old days should be pruned, got %+v", report.Days)
+	}
+}
@@ -0,0 +1,206 @@
+// /shared/auth/auth.go
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+type Role string
+
+// Papéis de acesso. viewer só lê, sales também edita leads, admin pode tudo e
+// service é o papel dos outros serviços (search-google, forwarder).
+const (
+	RoleViewer  Role = "viewer"
+	RoleSales   Role = "sales"
+	RoleAdmin   Role = "admin"
+	RoleService Role = "service"
+)
+
+var roleRank = map[Role]int{RoleViewer: 1, RoleSales: 2, RoleAdmin: 3}
+
+func ParseRole(s string) (Role, error) {
+	role := Role(strings.ToLower(strings.TrimSpace(s)))
+	if _, ok := roleRank[role]; ok || role == RoleService {
+		return role, nil
+	}
+	return "", fmt.Errorf("papel desconhecido: %q", s)
+}
+
+// Principal é quem fez a requisição. Workspaces lista os slugs de workspace
+// que ele pode acessar; vazio significa todos.
+type Principal struct {
+	Name       string   `json:"name"`
+	Role       Role     `json:"role"`
+	Method     string   `json:"method"`
+	Workspaces []string `json:"workspaces,omitempty"`
+}
+
+// CanAccessWorkspace diz se o principal pode acessar o workspace (por slug).
+func (p Principal) CanAccessWorkspace(slug string) bool {
+	if len(p.Workspaces) == 0 {
+		return true
+	}
+	for _, ws := range p.Workspaces {
+		if ws == slug {
+			return true
+		}
+	}
+	return false
+}
+
+// Can diz se o principal tem o papel exigido. Admin pode tudo; service só
+// acessa rotas de service; os demais seguem a ordem viewer < sales < admin.
+func (p Principal) Can(required Role) bool {
+	if p.Role == RoleAdmin || p.Role == required {
+		return true
+	}
+	if p.Role == RoleService || required == RoleService {
+		return false
+	}
+	return roleRank[p.Role] >= roleRank[required]
+}
+
+// CanAny diz se o principal tem pelo menos um dos papéis.
+func (p Principal) CanAny(roles ...Role) bool {
+	for _, role := range roles {
+		if p.Can(role) {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	ErrMissingCredentials = errors.New("credenciais ausentes")
+	ErrInvalidCredentials = errors.New("credenciais inválidas")
+	ErrJWTDisabled        = errors.New("AUTH_JWT_SECRET não definido")
+)
+
+// Authenticator valida API keys estáticas e JWTs HS256.
+type Authenticator struct {
+	keys      map[[sha256.Size]byte]Principal
+	jwtSecret []byte
+	now       func() time.Time
+}
+
+// New lê as API keys no formato "nome:papel:chave,..." e o segredo dos JWTs.
+// O papel pode vir restrito a workspaces: "nome:sales@cliente-a|cliente-b:chave".
+// Os dois são opcionais, mas sem nenhum deles toda rota protegida recusa.
+func New(apiKeys, jwtSecret string) (*Authenticator, error) {
+	a := &Authenticator{
+		keys:      make(map[[sha256.Size]byte]Principal),
+		jwtSecret: []byte(jwtSecret),
+		now:       time.Now,
+	}
+	for _, item := range strings.Split(apiKeys, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		parts := strings.SplitN(item, ":", 3)
+		if len(parts) != 3 || parts[0] == "" || parts[2] == "" {
+			return nil, fmt.Errorf("API key inválida: esperava nome:papel:chave")
+		}
+		roleSpec, workspaceSpec, _ := strings.Cut(parts[1], "@")
+		role, err := ParseRole(roleSpec)
+		if err != nil {
+			return nil, fmt.Errorf("API key %s: %v", parts[0], err)
+		}
+		var workspaces []string
+		for _, ws := range strings.Split(workspaceSpec, "|") {
+			if ws = strings.TrimSpace(ws); ws != "" {
+				workspaces = append(workspaces, ws)
+			}
+		}
+		a.keys[sha256.Sum256([]byte(parts[2]))] = Principal{Name: parts[0], Role: role, Method: "api_key", Workspaces: workspaces}
+	}
+	return a, nil
+}
+
+// FromEnv monta o Authenticator a partir de AUTH_API_KEYS e AUTH_JWT_SECRET.
+func FromEnv() (*Authenticator, error) {
+	return New(os.Getenv("AUTH_API_KEYS"), os.Getenv("AUTH_JWT_SECRET"))
+}
+
+// Configured diz se existe alguma forma de autenticar.
+func (a *Authenticator) Configured() bool {
+	return len(a.keys) > 0 || len(a.jwtSecret) > 0
+}
+
+// IssueToken emite um JWT com o segredo do Authenticator e devolve também o
+// instante em que ele expira. Sem AUTH_JWT_SECRET devolve ErrJWTDisabled.
+func (a *Authenticator) IssueToken(subject string, role Role, ttl time.Duration, workspaces ...string) (string, time.Time, error) {
+	if len(a.jwtSecret) == 0 {
+		return "", time.Time{}, ErrJWTDisabled
+	}
+	now := a.now()
+	token, err := SignJWT(a.jwtSecret, subject, role, ttl, now, workspaces...)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, now.Add(ttl), nil
+}
+
+// Authenticate lê a credencial de "Authorization: Bearer" ou de "X-API-Key".
+// Tokens com três partes separadas por ponto são tratados como JWT.
+func (a *Authenticator) Authenticate(r *http.Request) (Principal, error) {
+	return a.verify(headerToken(r))
+}
+
+// AuthenticateStream é como Authenticate, mas também aceita o parâmetro
+// access_token, para o EventSource, que não manda cabeçalhos. Só serve para
+// rotas de stream: token na URL acaba em logs e no histórico do navegador.
+func (a *Authenticator) AuthenticateStream(r *http.Request) (Principal, error) {
+	token := headerToken(r)
+	if token == "" {
+		token = strings.TrimSpace(r.URL.Query().Get("access_token"))
+	}
+	return a.verify(token)
+}
+
+func headerToken(r *http.Request) string {
+	if header := r.Header.Get("Authorization"); strings.HasPrefix(header, "Bearer ") {
+		if token := strings.TrimSpace(strings.TrimPrefix(header, "Bearer ")); token != "" {
+			return token
+		}
+	}
+	return strings.TrimSpace(r.Header.Get("X-API-Key"))
+}
+
+func (a *Authenticator) verify(token string) (Principal, error) {
+	if token == "" {
+		return Principal{}, ErrMissingCredentials
+	}
+
+	if strings.Count(token, ".") == 2 && len(a.jwtSecret) > 0 {
+		return verifyJWT(token, a.jwtSecret, a.now())
+	}
+
+	sum := sha256.Sum256([]byte(token))
+	for hash, principal := range a.keys {
+		if subtle.ConstantTimeCompare(hash[:], sum[:]) == 1 {
+			return principal, nil
+		}
+	}
+	return Principal{}, ErrInvalidCredentials
+}
+
+type contextKey struct{}
+
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, contextKey{}, p)
+}
+
+// FromContext devolve o principal autenticado da requisição, se houver.
+func FromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(contextKey{}).(Principal)
+	return p, ok
+}
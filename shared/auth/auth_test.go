@@ -0,0 +1,136 @@
+package auth
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAuthenticateAPIKey(t *testing.T) {
+	a, err := New("front:viewer:view-key, forwarder:service:svc:key", "")
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	r := httptest.NewRequest("GET", "/list-leads", nil)
+	r.Header.Set("Authorization", "Bearer svc:key")
+	p, err := a.Authenticate(r)
+	if err != nil || p.Name != "forwarder" || p.Role != RoleService {
+		t.Fatalf("unexpected principal %+v, err %v", p, err)
+	}
+
+	r = httptest.NewRequest("GET", "/list-leads", nil)
+	r.Header.Set("X-API-Key", "view-key")
+	if p, err := a.Authenticate(r); err != nil || p.Role != RoleViewer {
+		t.Errorf("X-API-Key not accepted: %+v, %v", p, err)
+	}
+
+	r = httptest.NewRequest("GET", "/list-leads", nil)
+	if _, err := a.Authenticate(r); !errors.Is(err, ErrMissingCredentials) {
+		t.Errorf("expected ErrMissingCredentials, got %v", err)
+	}
+	r.Header.Set("X-API-Key", "wrong")
+	if _, err := a.Authenticate(r); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("expected ErrInvalidCredentials, got %v", err)
+	}
+
+	a, _ = New("cliente-a:sales@cliente-a|cliente-b:ws-key", "")
+	r = httptest.NewRequest("GET", "/list-leads", nil)
+	r.Header.Set("X-API-Key", "ws-key")
+	p, err = a.Authenticate(r)
+	if err != nil || p.Role != RoleSales || !p.CanAccessWorkspace("cliente-b") || p.CanAccessWorkspace("default") {
+		t.Errorf("workspace restriction not parsed: %+v, %v", p, err)
+	}
+
+	if _, err := New("front:owner:key", ""); err == nil {
+		t.Error("unknown roles should be rejected")
+	}
+}
+
+func TestAuthenticateStreamQueryToken(t *testing.T) {
+	a, _ := New("front:viewer:view-key", "")
+
+	r := httptest.NewRequest("GET", "/search-progress?job_id=x&access_token=view-key", nil)
+	if _, err := a.Authenticate(r); !errors.Is(err, ErrMissingCredentials) {
+		t.Errorf("Authenticate must ignore access_token, got %v", err)
+	}
+	if p, err := a.AuthenticateStream(r); err != nil || p.Name != "front" {
+		t.Errorf("AuthenticateStream = %+v, %v", p, err)
+	}
+}
+
+func TestAuthenticateJWT(t *testing.T) {
+	secret := []byte("s3cret")
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	a, _ := New("", string(secret))
+	a.now = func() time.Time { return now }
+
+	token, err := SignJWT(secret, "ana@example.com", RoleSales, time.Hour, now)
+	if err != nil {
+		t.Fatalf("SignJWT failed: %v", err)
+	}
+	r := httptest.NewRequest("GET", "/list-leads", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	p, err := a.Authenticate(r)
+	if err != nil || p.Name != "ana@example.com" || p.Role != RoleSales || p.Method != "jwt" {
+		t.Fatalf("unexpected principal %+v, err %v", p, err)
+	}
+
+	a.now = func() time.Time { return now.Add(2 * time.Hour) }
+	if _, err := a.Authenticate(r); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("expired token should be rejected, got %v", err)
+	}
+
+	forged, _ := SignJWT([]byte("other"), "ana@example.com", RoleAdmin, time.Hour, now)
+	r.Header.Set("Authorization", "Bearer "+forged)
+	a.now = func() time.Time { return now }
+	if _, err := a.Authenticate(r); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("token signed with another secret should be rejected, got %v", err)
+	}
+}
+
+func TestIssueToken(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	if _, _, err := (&Authenticator{now: time.Now}).IssueToken("ana@example.com", RoleSales, time.Hour); !errors.Is(err, ErrJWTDisabled) {
+		t.Fatalf("expected ErrJWTDisabled without secret, got %v", err)
+	}
+
+	a, _ := New("", "s3cret")
+	a.now = func() time.Time { return now }
+	token, expiresAt, err := a.IssueToken("ana@example.com", RoleSales, time.Hour, "cliente-a")
+	if err != nil {
+		t.Fatalf("IssueToken failed: %v", err)
+	}
+	if !expiresAt.Equal(now.Add(time.Hour)) {
+		t.Errorf("unexpected expiry %v", expiresAt)
+	}
+	r := httptest.NewRequest("GET", "/start-search", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	p, err := a.Authenticate(r)
+	if err != nil || p.Role != RoleSales || !p.CanAccessWorkspace("cliente-a") || p.CanAccessWorkspace("cliente-b") {
+		t.Fatalf("unexpected principal %+v, err %v", p, err)
+	}
+}
+
+func TestPrincipalCan(t *testing.T) {
+	cases := []struct {
+		role     Role
+		required Role
+		want     bool
+	}{
+		{RoleViewer, RoleViewer, true},
+		{RoleViewer, RoleSales, false},
+		{RoleSales, RoleViewer, true},
+		{RoleSales, RoleAdmin, false},
+		{RoleAdmin, RoleService, true},
+		{RoleService, RoleService, true},
+		{RoleService, RoleViewer, false},
+		{RoleSales, RoleService, false},
+	}
+	for _, c := range cases {
+		if got := (Principal{Role: c.role}).Can(c.required); got != c.want {
+			t.Errorf("%s.Can(%s) = %v, want %v", c.role, c.required, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+// /shared/auth/jwt.go
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ,omitempty"`
+}
+
+// Claims são os campos do JWT que o serviço entende.
+type Claims struct {
+	Subject    string   `json:"sub"`
+	Role       string   `json:"role"`
+	Workspaces []string `json:"workspaces,omitempty"`
+	ExpiresAt  int64    `json:"exp"`
+	NotBefore  int64    `json:"nbf,omitempty"`
+	IssuedAt   int64    `json:"iat,omitempty"`
+}
+
+// SignJWT emite um JWT HS256 para o sujeito e papel informados, opcionalmente
+// restrito a alguns workspaces.
+func SignJWT(secret []byte, subject string, role Role, ttl time.Duration, now time.Time, workspaces ...string) (string, error) {
+	header, err := json.Marshal(jwtHeader{Alg: "HS256", Typ: "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(Claims{
+		Subject:    subject,
+		Role:       string(role),
+		Workspaces: workspaces,
+		ExpiresAt:  now.Add(ttl).Unix(),
+		IssuedAt:   now.Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	signingInput := b64(header) + "." + b64(claims)
+	return signingInput + "." + b64(sign(secret, signingInput)), nil
+}
+
+func verifyJWT(token string, secret []byte, now time.Time) (Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Principal{}, ErrInvalidCredentials
+	}
+
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil || header.Alg != "HS256" {
+		return Principal{}, fmt.Errorf("%w: algoritmo do JWT não suportado", ErrInvalidCredentials)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(signature, sign(secret, parts[0]+"."+parts[1])) {
+		return Principal{}, fmt.Errorf("%w: assinatura do JWT inválida", ErrInvalidCredentials)
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return Principal{}, fmt.Errorf("%w: claims do JWT inválidas", ErrInvalidCredentials)
+	}
+	if claims.ExpiresAt == 0 || now.Unix() >= claims.ExpiresAt {
+		return Principal{}, fmt.Errorf("%w: JWT expirado", ErrInvalidCredentials)
+	}
+	if claims.NotBefore != 0 && now.Unix() < claims.NotBefore {
+		return Principal{}, fmt.Errorf("%w: JWT ainda não é válido", ErrInvalidCredentials)
+	}
+	role, err := ParseRole(claims.Role)
+	if err != nil {
+		return Principal{}, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+	return Principal{Name: claims.Subject, Role: role, Method: "jwt", Workspaces: claims.Workspaces}, nil
+}
+
+func sign(secret []byte, input string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(input))
+	return mac.Sum(nil)
+}
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,121 @@
+// /shared/cnpj/cnpj.go
+package cnpj
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	ErrLength      = errors.New("CNPJ deve ter 14 dígitos")
+	ErrCheckDigits = errors.New("dígito verificador do CNPJ inválido")
+	ErrRepeated    = errors.New("CNPJ com todos os dígitos iguais")
+)
+
+// Establishment indica se o CNPJ é da matriz ou de uma filial, pelo número de
+// ordem (os 4 dígitos depois da raiz): 0001 é sempre a matriz.
+type Establishment string
+
+const (
+	Matriz Establishment = "matriz"
+	Filial Establishment = "filial"
+)
+
+var (
+	firstWeights  = []int{5, 4, 3, 2, 9, 8, 7, 6, 5, 4, 3, 2}
+	secondWeights = []int{6, 5, 4, 3, 2, 9, 8, 7, 6, 5, 4, 3, 2}
+
+	// pattern acha CNPJs em texto livre, pontuados ou não.
+	pattern = regexp.MustCompile(`\b\d{2}\.?\d{3}\.?\d{3}/?\d{4}-?\d{2}\b`)
+)
+
+func digits(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, s)
+}
+
+func checkDigit(base string, weights []int) byte {
+	sum := 0
+	for i, w := range weights {
+		sum += int(base[i]-'0') * w
+	}
+	if rest := sum % 11; rest >= 2 {
+		return byte('0' + 11 - rest)
+	}
+	return '0'
+}
+
+// Normalize aceita o CNPJ com ou sem pontuação, confere os dígitos
+// verificadores e devolve só os 14 dígitos.
+func Normalize(s string) (string, error) {
+	d := digits(s)
+	if len(d) != 14 {
+		return "", ErrLength
+	}
+	if strings.Count(d, d[:1]) == len(d) {
+		return "", ErrRepeated
+	}
+	if checkDigit(d, firstWeights) != d[12] || checkDigit(d, secondWeights) != d[13] {
+		return "", ErrCheckDigits
+	}
+	return d, nil
+}
+
+func Valid(s string) bool {
+	_, err := Normalize(s)
+	return err == nil
+}
+
+// Format devolve o CNPJ no formato de exibição XX.XXX.XXX/XXXX-XX. Se o valor
+// não for um CNPJ válido, ele volta sem alteração.
+func Format(s string) string {
+	d, err := Normalize(s)
+	if err != nil {
+		return s
+	}
+	return fmt.Sprintf("%s.%s.%s/%s-%s", d[:2], d[2:5], d[5:8], d[8:12], d[12:])
+}
+
+// Root devolve a raiz do CNPJ (8 primeiros dígitos), comum à matriz e às
+// filiais da mesma empresa.
+func Root(s string) (string, error) {
+	d, err := Normalize(s)
+	if err != nil {
+		return "", err
+	}
+	return d[:8], nil
+}
+
+// EstablishmentOf diz se o CNPJ é da matriz ou de uma filial.
+func EstablishmentOf(s string) (Establishment, error) {
+	d, err := Normalize(s)
+	if err != nil {
+		return "", err
+	}
+	if d[8:12] == "0001" {
+		return Matriz, nil
+	}
+	return Filial, nil
+}
+
+// Find devolve os CNPJs válidos encontrados no texto, normalizados e sem
+// repetição, na ordem em que aparecem.
+func Find(text string) []string {
+	var found []string
+	seen := make(map[string]bool)
+	for _, match := range pattern.FindAllString(text, -1) {
+		d, err := Normalize(match)
+		if err != nil || seen[d] {
+			continue
+		}
+		seen[d] = true
+		found = append(found, d)
+	}
+	return found
+}
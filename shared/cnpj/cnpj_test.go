@@ -0,0 +1,59 @@
+package cnpj
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	cases := []struct {
+		in   string
+		want string
+		err  error
+	}{
+		{"12.345.678/0001-95", "12345678000195", nil},
+		{"12345678000195", "12345678000195", nil},
+		{" 11.222.333/0001-81 ", "11222333000181", nil},
+		{"12.345.678/0001-90", "", ErrCheckDigits},
+		{"11.111.111/1111-11", "", ErrRepeated},
+		{"12.345.678/0001", "", ErrLength},
+		{"", "", ErrLength},
+	}
+	for _, c := range cases {
+		got, err := Normalize(c.in)
+		if got != c.want || !errors.Is(err, c.err) {
+			t.Errorf("Normalize(%q) = %q, %v; want %q, %v", c.in, got, err, c.want, c.err)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	if got := Format("12345678000195"); got != "12.345.678/0001-95" {
+		t.Errorf("Format = %q", got)
+	}
+	if got := Format("não é cnpj"); got != "não é cnpj" {
+		t.Errorf("Format should keep invalid input, got %q", got)
+	}
+}
+
+func TestEstablishmentAndRoot(t *testing.T) {
+	if e, err := EstablishmentOf("12.345.678/0001-95"); err != nil || e != Matriz {
+		t.Errorf("expected matriz, got %q, %v", e, err)
+	}
+	if e, err := EstablishmentOf("11.222.333/0002-62"); err != nil || e != Filial {
+		t.Errorf("expected filial, got %q, %v", e, err)
+	}
+	if root, err := Root("11.222.333/0002-62"); err != nil || root != "11222333" {
+		t.Errorf("Root = %q, %v", root, err)
+	}
+}
+
+func TestFind(t *testing.T) {
+	text := "Padaria Bela Paulista LTDA - CNPJ 12.345.678/0001-95. Filial: 11222333000262; " +
+		"inválido 12.345.678/0001-90; de novo 12345678000195"
+	want := []string{"12345678000195", "11222333000262"}
+	if got := Find(text); !reflect.DeepEqual(got, want) {
+		t.Errorf("Find = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,132 @@
+// /shared/fakes/fakes.go
+
+// Package fakes sobe servidores httptest que respondem com fixtures gravadas,
+// para testar os clientes de APIs externas sem rede.
+package fakes
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// T é a parte do *testing.T usada pelos servidores falsos, para o pacote não
+// depender de testing fora dos testes.
+type T interface {
+	Helper()
+	Fatalf(format string, args ...interface{})
+	Cleanup(func())
+}
+
+// Route associa método e caminho a um arquivo de fixture. Caminhos terminados
+// em "/" valem como prefixo, como no http.ServeMux, e o prefixo mais longo
+// ganha. Body, quando preenchido, é a resposta no lugar do arquivo, com o
+// ContentType informado. Status zero vira 200.
+type Route struct {
+	Method      string
+	Path        string
+	Fixture     string
+	Body        []byte
+	ContentType string
+	Status      int
+}
+
+type response struct {
+	body        []byte
+	contentType string
+	status      int
+}
+
+// Request é uma requisição recebida pelo servidor falso.
+type Request struct {
+	Method string
+	Path   string
+	Query  map[string][]string
+	Header http.Header
+	Body   []byte
+}
+
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests []Request
+}
+
+// NewServer sobe um servidor que responde cada rota com o conteúdo do arquivo
+// dir/Fixture (ou com Body), com o Content-Type da extensão, e 404 para o
+// resto. O servidor é fechado ao fim do teste.
+func NewServer(t T, dir string, routes ...Route) *Server {
+	t.Helper()
+
+	exact := make(map[string]response, len(routes))
+	prefixes := make(map[string]response)
+	for _, route := range routes {
+		r := response{body: route.Body, contentType: route.ContentType, status: route.Status}
+		if r.body == nil {
+			body, err := os.ReadFile(filepath.Join(dir, route.Fixture))
+			if err != nil {
+				t.Fatalf("fixture %s: %v", route.Fixture, err)
+			}
+			r.body = body
+		}
+		if r.contentType == "" {
+			r.contentType = mime.TypeByExtension(filepath.Ext(route.Fixture))
+		}
+		if r.status == 0 {
+			r.status = http.StatusOK
+		}
+		key := route.Method + " " + route.Path
+		if strings.HasSuffix(route.Path, "/") {
+			prefixes[key] = r
+		} else {
+			exact[key] = r
+		}
+	}
+
+	s := &Server{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		s.mu.Lock()
+		s.requests = append(s.requests, Request{
+			Method: r.Method,
+			Path:   r.URL.Path,
+			Query:  r.URL.Query(),
+			Header: r.Header.Clone(),
+			Body:   body,
+		})
+		s.mu.Unlock()
+
+		key := r.Method + " " + r.URL.Path
+		resp, ok := exact[key]
+		if !ok {
+			longest := ""
+			for prefix, candidate := range prefixes {
+				if strings.HasPrefix(key, prefix) && len(prefix) > len(longest) {
+					longest, resp, ok = prefix, candidate, true
+				}
+			}
+		}
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", resp.contentType)
+		w.WriteHeader(resp.status)
+		w.Write(resp.body)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Requests devolve uma cópia das requisições recebidas até agora.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
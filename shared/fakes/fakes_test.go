@@ -0,0 +1,52 @@
+package fakes
+
+import (
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestServerMatchesLongestPrefix(t *testing.T) {
+	server := NewServer(t, "",
+		Route{Method: "GET", Path: "/", Body: []byte("company"), ContentType: "text/html"},
+		Route{Method: "GET", Path: "/procura/", Body: []byte("procura"), ContentType: "text/html"},
+		Route{Method: "POST", Path: "/search", Body: []byte(`{}`), ContentType: "application/json", Status: http.StatusCreated},
+	)
+
+	for path, want := range map[string]string{
+		"/procura/Padaria%20Bela": "procura",
+		"/12345678000195":         "company",
+	} {
+		resp, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != want {
+			t.Errorf("GET %s = %q, want %q", path, body, want)
+		}
+	}
+
+	resp, err := http.Post(server.URL+"/search", "application/json", nil)
+	if err != nil {
+		t.Fatalf("POST /search: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated || resp.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("unexpected response: %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	resp, err = http.Get(server.URL + "/search")
+	if err != nil {
+		t.Fatalf("GET /search: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("GET /search should fall back to the / prefix, got %d", resp.StatusCode)
+	}
+
+	if got := len(server.Requests()); got != 4 {
+		t.Errorf("expected 4 recorded requests, got %d", got)
+	}
+}
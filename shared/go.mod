@@ -0,0 +1,5 @@
+module github.com/wbrunovieira/LeadSearchVersion2/shared
+
+go 1.23
+
+require github.com/streadway/amqp v1.1.0
@@ -0,0 +1,75 @@
+// /shared/leadevents/leadevents.go
+package leadevents
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+// Exchange é a exchange topic em que os serviços anunciam os marcos do
+// enriquecimento (lead.saved, lead.web_enriched, lead.llm_extracted,
+// lead.indexed) e o progresso das buscas (search.*), com o nome do evento
+// como routing key. A API os repassa às integrações e ao stream de progresso.
+const Exchange = "lead_events"
+
+// Event é a mensagem publicada em lead_events. Os eventos de busca trazem
+// JobID e Workspace (slug ou ID, vazio para o padrão) e não têm LeadID.
+type Event struct {
+	Event      string                 `json:"event"`
+	LeadID     string                 `json:"lead_id,omitempty"`
+	JobID      string                 `json:"job_id,omitempty"`
+	Workspace  string                 `json:"workspace,omitempty"`
+	OccurredAt time.Time              `json:"occurred_at"`
+	Details    map[string]interface{} `json:"details,omitempty"`
+}
+
+// Publisher é a parte do *amqp.Channel usada para publicar.
+type Publisher interface {
+	Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error
+}
+
+// Declare declara a exchange lead_events no canal.
+func Declare(ch *amqp.Channel) error {
+	err := ch.ExchangeDeclare(
+		Exchange, // nome do exchange
+		"topic",  // tipo (routing key = nome do evento)
+		true,     // durable
+		false,    // auto-deleted
+		false,    // internal
+		false,    // no-wait
+		nil,      // arguments
+	)
+	if err != nil {
+		return fmt.Errorf("erro ao declarar exchange de eventos: %v", err)
+	}
+	return nil
+}
+
+// Publish publica o evento em lead_events com o nome dele como routing key.
+// OccurredAt vazio vira o instante da publicação.
+func Publish(p Publisher, event Event) error {
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now()
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("erro ao converter o evento para JSON: %v", err)
+	}
+	err = p.Publish(
+		Exchange,    // exchange
+		event.Event, // routing key
+		false,       // mandatory
+		false,       // immediate
+		amqp.Publishing{
+			ContentType: "application/json",
+			Body:        body,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("erro ao publicar o evento %s: %v", event.Event, err)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package leadevents
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/streadway/amqp"
+)
+
+type recordingPublisher struct {
+	exchange, key string
+	msg           amqp.Publishing
+	err           error
+}
+
+func (p *recordingPublisher) Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	p.exchange, p.key, p.msg = exchange, key, msg
+	return p.err
+}
+
+func TestPublish(t *testing.T) {
+	p := &recordingPublisher{}
+	err := Publish(p, Event{Event: "lead.indexed", LeadID: "abc", Details: map[string]interface{}{"index": "combined_leads"}})
+	if err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	if p.exchange != Exchange || p.key != "lead.indexed" || p.msg.ContentType != "application/json" {
+		t.Fatalf("unexpected publishing: exchange=%q key=%q type=%q", p.exchange, p.key, p.msg.ContentType)
+	}
+
+	var got Event
+	if err := json.Unmarshal(p.msg.Body, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.LeadID != "abc" || got.OccurredAt.IsZero() || got.Details["index"] != "combined_leads" {
+		t.Errorf("unexpected body: %s", p.msg.Body)
+	}
+	var raw map[string]interface{}
+	json.Unmarshal(p.msg.Body, &raw)
+	if _, ok := raw["job_id"]; ok {
+		t.Errorf("empty job_id should be omitted: %s", p.msg.Body)
+	}
+}
+
+func TestPublishError(t *testing.T) {
+	p := &recordingPublisher{err: errors.New("canal fechado")}
+	if err := Publish(p, Event{Event: "lead.saved"}); err == nil {
+		t.Fatal("expected the channel error to be returned")
+	}
+}
@@ -0,0 +1,183 @@
+// /shared/phone/phone.go
+package phone
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Kind classifica o número. Só números brasileiros são classificados; os
+// demais ficam como KindUnknown.
+type Kind string
+
+const (
+	KindMobile   Kind = "mobile"
+	KindLandline Kind = "landline"
+	KindUnknown  Kind = "unknown"
+)
+
+var (
+	ErrEmpty      = errors.New("telefone vazio")
+	ErrLength     = errors.New("telefone com quantidade de dígitos inválida")
+	ErrAreaCode   = errors.New("DDD inválido")
+	ErrSubscriber = errors.New("número de assinante inválido")
+)
+
+// Number é um telefone já normalizado.
+type Number struct {
+	E164     string `json:"e164"`
+	Country  string `json:"country"`
+	AreaCode string `json:"area_code,omitempty"`
+	Kind     Kind   `json:"kind"`
+}
+
+// WhatsAppURL devolve o link wa.me do número. Só celulares (e números de fora
+// do Brasil, que não dá para classificar) têm link.
+func (n Number) WhatsAppURL() string {
+	if n.Kind == KindLandline || n.E164 == "" {
+		return ""
+	}
+	return "https://wa.me/" + strings.TrimPrefix(n.E164, "+")
+}
+
+// Format devolve o número para exibição: "+55 11 91234-5678" para números
+// brasileiros e o E.164 para os demais.
+func (n Number) Format() string {
+	if n.Country != "BR" {
+		return n.E164
+	}
+	local := strings.TrimPrefix(n.E164, "+55"+n.AreaCode)
+	split := len(local) - 4
+	return fmt.Sprintf("+55 %s %s-%s", n.AreaCode, local[:split], local[split:])
+}
+
+func digits(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, s)
+}
+
+// fromWhatsAppURL extrai o número de links wa.me e api.whatsapp.com.
+func fromWhatsAppURL(raw string) (string, bool) {
+	lower := strings.ToLower(raw)
+	if !strings.Contains(lower, "wa.me/") && !strings.Contains(lower, "whatsapp.com/") {
+		return "", false
+	}
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", false
+	}
+	if p := u.Query().Get("phone"); p != "" {
+		return "+" + digits(p), true
+	}
+	return "+" + digits(u.Path), true
+}
+
+// Parse normaliza um telefone para E.164. Aceita formato internacional
+// ("+55 (11) 91234-5678", "0055..."), nacional com ou sem DDD de operadora
+// ("(11) 91234-5678", "0 21 11 91234-5678") e links do WhatsApp. Números sem
+// código do país são tratados como brasileiros.
+//
+// No Brasil, celular tem 9 dígitos começando por 9 (regra do nono dígito). Um
+// número de 8 dígitos começando por 6 a 9 é um celular no formato antigo e
+// ganha o 9 na frente; de 2 a 5 é fixo.
+func Parse(raw string) (Number, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return Number{}, ErrEmpty
+	}
+	if fromURL, ok := fromWhatsAppURL(raw); ok {
+		raw = fromURL
+	}
+
+	d := digits(raw)
+	international := strings.HasPrefix(raw, "+")
+	if !international && strings.HasPrefix(d, "00") {
+		d, international = d[2:], true
+	}
+	if !international && strings.HasPrefix(d, "55") && (len(d) == 12 || len(d) == 13) {
+		international = true
+	}
+
+	if international {
+		if len(d) < 8 || len(d) > 15 || d[0] == '0' {
+			return Number{}, ErrLength
+		}
+		if !strings.HasPrefix(d, "55") {
+			return Number{E164: "+" + d, Country: countryOf(d), Kind: KindUnknown}, nil
+		}
+		return parseBrazilian(d[2:])
+	}
+
+	// Nacional: tira o 0 de discagem e, se sobrar, o código da operadora.
+	if strings.HasPrefix(d, "0") {
+		d = d[1:]
+		if len(d) == 12 || len(d) == 13 {
+			d = d[2:]
+		}
+	}
+	return parseBrazilian(d)
+}
+
+func parseBrazilian(national string) (Number, error) {
+	if len(national) != 10 && len(national) != 11 {
+		return Number{}, ErrLength
+	}
+	area, local := national[:2], national[2:]
+	if area[0] == '0' || area[1] == '0' {
+		return Number{}, ErrAreaCode
+	}
+
+	kind := KindLandline
+	switch {
+	case len(local) == 9 && local[0] == '9':
+		kind = KindMobile
+	case len(local) == 9:
+		return Number{}, ErrSubscriber
+	case local[0] >= '6':
+		local, kind = "9"+local, KindMobile
+	case local[0] < '2':
+		return Number{}, ErrSubscriber
+	}
+	return Number{E164: "+55" + area + local, Country: "BR", AreaCode: area, Kind: kind}, nil
+}
+
+// countryOf reconhece só os códigos de país mais comuns nos leads; o resto
+// fica vazio.
+func countryOf(d string) string {
+	for prefix, country := range map[string]string{"1": "US", "351": "PT", "54": "AR", "598": "UY", "595": "PY", "56": "CL", "34": "ES"} {
+		if strings.HasPrefix(d, prefix) {
+			return country
+		}
+	}
+	return ""
+}
+
+// candidate acha trechos que parecem telefone em texto livre. Barra não
+// entra, para não confundir com CNPJ.
+var candidate = regexp.MustCompile(`(?:\+|\b)\d[\d\s().-]{6,18}\d\b`)
+
+// Find devolve os telefones válidos encontrados no texto, sem repetição e na
+// ordem em que aparecem.
+func Find(text string) []Number {
+	var found []Number
+	seen := make(map[string]bool)
+	for _, match := range candidate.FindAllString(text, -1) {
+		n, err := Parse(match)
+		if err != nil || seen[n.E164] {
+			continue
+		}
+		seen[n.E164] = true
+		found = append(found, n)
+	}
+	return found
+}
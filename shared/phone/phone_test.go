@@ -0,0 +1,76 @@
+package phone
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		in   string
+		e164 string
+		kind Kind
+	}{
+		{"+55 11 91234-5678", "+5511912345678", KindMobile},
+		{"(11) 91234-5678", "+5511912345678", KindMobile},
+		{"(11) 3333-4444", "+551133334444", KindLandline},
+		{"011 3333-4444", "+551133334444", KindLandline},
+		{"0 21 11 91234-5678", "+5511912345678", KindMobile},
+		{"0055 11 3333 4444", "+551133334444", KindLandline},
+		{"5511912345678", "+5511912345678", KindMobile},
+		{"(21) 8765-4321", "+5521987654321", KindMobile},
+		{"https://wa.me/5511912345678", "+5511912345678", KindMobile},
+		{"https://api.whatsapp.com/send?phone=5511912345678&text=oi", "+5511912345678", KindMobile},
+		{"+1 (415) 555-0100", "+14155550100", KindUnknown},
+	}
+	for _, c := range cases {
+		n, err := Parse(c.in)
+		if err != nil || n.E164 != c.e164 || n.Kind != c.kind {
+			t.Errorf("Parse(%q) = %+v, %v; want %s %s", c.in, n, err, c.e164, c.kind)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	cases := []struct {
+		in  string
+		err error
+	}{
+		{"", ErrEmpty},
+		{"3333-4444", ErrLength},
+		{"(10) 3333-4444", ErrAreaCode},
+		{"(11) 81234-5678", ErrSubscriber},
+		{"(11) 1234-5678", ErrSubscriber},
+	}
+	for _, c := range cases {
+		if _, err := Parse(c.in); !errors.Is(err, c.err) {
+			t.Errorf("Parse(%q) error = %v, want %v", c.in, err, c.err)
+		}
+	}
+}
+
+func TestWhatsAppURLAndFormat(t *testing.T) {
+	mobile, _ := Parse("(11) 91234-5678")
+	landline, _ := Parse("(11) 3333-4444")
+	if got := mobile.WhatsAppURL(); got != "https://wa.me/5511912345678" {
+		t.Errorf("WhatsAppURL = %q", got)
+	}
+	if got := landline.WhatsAppURL(); got != "" {
+		t.Errorf("landline should have no WhatsApp link, got %q", got)
+	}
+	if got := mobile.Format(); got != "+55 11 91234-5678" {
+		t.Errorf("Format = %q", got)
+	}
+	if got := landline.Format(); got != "+55 11 3333-4444" {
+		t.Errorf("Format = %q", got)
+	}
+}
+
+func TestFind(t *testing.T) {
+	text := "Padaria Bela Paulista - CNPJ 12.345.678/0001-95, CEP 01310-100. " +
+		"Fale conosco: (11) 3333-4444 ou WhatsApp (11) 91234-5678. Tel: 11 3333-4444. Aberta desde 2010-05-03."
+	found := Find(text)
+	if len(found) != 2 || found[0].E164 != "+551133334444" || found[1].E164 != "+5511912345678" {
+		t.Errorf("Find = %+v", found)
+	}
+}
@@ -0,0 +1,118 @@
+// /shared/places/place.go
+package places
+
+import "fmt"
+
+// SchemaVersion é a versão do payload Place trocado entre o search-google e a
+// API (POST /save-leads e /refresh-leads). Incremente sempre que um campo
+// mudar de nome ou de tipo; a API recusa versões maiores do que a que conhece.
+const SchemaVersion = 1
+
+// Place é o DTO tipado de um estabelecimento do Google Places. Os nomes JSON
+// são os mesmos do payload antigo baseado em map, então envios sem
+// SchemaVersion (versão 0) continuam sendo aceitos.
+type Place struct {
+	SchemaVersion int `json:"SchemaVersion"`
+
+	PlaceID                  string   `json:"PlaceID"`
+	Name                     string   `json:"Name"`
+	FormattedAddress         string   `json:"FormattedAddress"`
+	InternationalPhoneNumber string   `json:"InternationalPhoneNumber"`
+	Website                  string   `json:"Website"`
+	Rating                   float64  `json:"Rating"`
+	UserRatingsTotal         int      `json:"UserRatingsTotal"`
+	PriceLevel               int      `json:"PriceLevel"`
+	BusinessStatus           string   `json:"BusinessStatus"`
+	Vicinity                 string   `json:"Vicinity"`
+	PermanentlyClosed        bool     `json:"PermanentlyClosed"`
+	Types                    []string `json:"Types"`
+	Description              string   `json:"Description"`
+
+	Latitude      float64       `json:"Latitude"`
+	Longitude     float64       `json:"Longitude"`
+	GoogleMapsURL string        `json:"GoogleMapsURL"`
+	OpeningHours  []string      `json:"OpeningHours"`
+	OpenNow       *bool         `json:"OpenNow"`
+	Photos        []PlacePhoto  `json:"Photos"`
+	Reviews       []PlaceReview `json:"Reviews"`
+
+	City    string `json:"City"`
+	State   string `json:"State"`
+	ZIPCode string `json:"ZIPCode"`
+	Country string `json:"Country"`
+
+	Category string `json:"Category"`
+	Radius   int    `json:"Radius"`
+
+	// JobID identifica a execução de busca que encontrou o place e
+	// SavedSearchID a busca agendada que a disparou, quando houver.
+	JobID         string `json:"JobID"`
+	SavedSearchID string `json:"SavedSearchID"`
+}
+
+// PlacePhoto guarda a referência da foto; a imagem em si é obtida depois pelo
+// endpoint de Place Photos, que tem cobrança própria.
+type PlacePhoto struct {
+	Reference    string   `json:"Reference"`
+	Width        int      `json:"Width"`
+	Height       int      `json:"Height"`
+	Attributions []string `json:"Attributions"`
+}
+
+// PlaceReview é uma das avaliações recentes devolvidas pelo Place Details.
+type PlaceReview struct {
+	AuthorName   string `json:"AuthorName"`
+	Rating       int    `json:"Rating"`
+	Text         string `json:"Text"`
+	Language     string `json:"Language"`
+	Time         int64  `json:"Time"`
+	RelativeTime string `json:"RelativeTime"`
+}
+
+// Validate rejeita payloads de uma versão de schema mais nova que a conhecida.
+func (p Place) Validate() error {
+	if p.SchemaVersion > SchemaVersion {
+		return fmt.Errorf("versão de schema %d não suportada (máxima: %d)", p.SchemaVersion, SchemaVersion)
+	}
+	return nil
+}
+
+// MergeSearchResult completa os detalhes com os atributos que só vêm da
+// Text Search (UserRatingsTotal, BusinessStatus, Types...). Valores já
+// preenchidos pelo Details têm prioridade.
+func (p *Place) MergeSearchResult(s Place) {
+	if p.PlaceID == "" {
+		p.PlaceID = s.PlaceID
+	}
+	if p.Name == "" {
+		p.Name = s.Name
+	}
+	if p.FormattedAddress == "" {
+		p.FormattedAddress = s.FormattedAddress
+	}
+	if p.Rating == 0 {
+		p.Rating = s.Rating
+	}
+	if p.UserRatingsTotal == 0 {
+		p.UserRatingsTotal = s.UserRatingsTotal
+	}
+	if p.PriceLevel == 0 {
+		p.PriceLevel = s.PriceLevel
+	}
+	if p.BusinessStatus == "" {
+		p.BusinessStatus = s.BusinessStatus
+	}
+	if p.Vicinity == "" {
+		p.Vicinity = s.Vicinity
+	}
+	if !p.PermanentlyClosed {
+		p.PermanentlyClosed = s.PermanentlyClosed
+	}
+	if len(p.Types) == 0 {
+		p.Types = s.Types
+	}
+	if p.Latitude == 0 && p.Longitude == 0 {
+		p.Latitude = s.Latitude
+		p.Longitude = s.Longitude
+	}
+}
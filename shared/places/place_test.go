@@ -0,0 +1,42 @@
+package places
+
+import "testing"
+
+func TestMergeSearchResultKeepsTextSearchAttributes(t *testing.T) {
+	details := Place{PlaceID: "abc", Name: "Padaria Central", Rating: 4.7}
+	search := Place{
+		PlaceID:          "abc",
+		Name:             "Padaria",
+		Rating:           4.5,
+		UserRatingsTotal: 120,
+		BusinessStatus:   "OPERATIONAL",
+		Types:            []string{"bakery", "food"},
+	}
+
+	details.MergeSearchResult(search)
+
+	if details.Name != "Padaria Central" || details.Rating != 4.7 {
+		t.Errorf("details fields should win, got name=%q rating=%v", details.Name, details.Rating)
+	}
+	if details.UserRatingsTotal != 120 {
+		t.Errorf("expected UserRatingsTotal 120, got %d", details.UserRatingsTotal)
+	}
+	if details.BusinessStatus != "OPERATIONAL" {
+		t.Errorf("expected BusinessStatus OPERATIONAL, got %q", details.BusinessStatus)
+	}
+	if len(details.Types) != 2 {
+		t.Errorf("expected 2 types, got %v", details.Types)
+	}
+}
+
+func TestValidateRejectsNewerSchema(t *testing.T) {
+	if err := (Place{}).Validate(); err != nil {
+		t.Errorf("payload without SchemaVersion should be accepted: %v", err)
+	}
+	if err := (Place{SchemaVersion: SchemaVersion}).Validate(); err != nil {
+		t.Errorf("current schema should be accepted: %v", err)
+	}
+	if err := (Place{SchemaVersion: SchemaVersion + 1}).Validate(); err == nil {
+		t.Error("newer schema should be rejected")
+	}
+}
@@ -0,0 +1,163 @@
+// /shared/ratelimit/ratelimit.go
+package ratelimit
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter é um token bucket por cliente: cada cliente ganha PerMinute fichas
+// por minuto, acumulando no máximo Burst.
+type Limiter struct {
+	mu        sync.Mutex
+	perMinute float64
+	burst     float64
+	buckets   map[string]*bucket
+	now       func() time.Time
+}
+
+// NewLimiter cria um limitador. perMinute <= 0 desliga o limite.
+func NewLimiter(perMinute float64, burst int) *Limiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &Limiter{
+		perMinute: perMinute,
+		burst:     float64(burst),
+		buckets:   make(map[string]*bucket),
+		now:       time.Now,
+	}
+}
+
+// Allow consome uma ficha do cliente. Se não houver ficha, devolve false e
+// quanto tempo falta para a próxima.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	if l.perMinute <= 0 {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.prune(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Minutes()*l.perMinute)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - b.tokens) / l.perMinute * float64(time.Minute))
+	return false, wait
+}
+
+// prune descarta buckets que já estariam cheios de novo, para o mapa não
+// crescer sem limite com clientes que não voltam.
+func (l *Limiter) prune(now time.Time) {
+	full := time.Duration(l.burst / l.perMinute * float64(time.Minute))
+	for key, b := range l.buckets {
+		if now.Sub(b.last) > full {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Concurrency limita quantas buscas rodam ao mesmo tempo, no total e por
+// cliente. Zero significa sem limite.
+type Concurrency struct {
+	mu        sync.Mutex
+	maxTotal  int
+	maxClient int
+	total     int
+	running   map[string]int
+}
+
+func NewConcurrency(maxTotal, maxPerClient int) *Concurrency {
+	return &Concurrency{
+		maxTotal:  maxTotal,
+		maxClient: maxPerClient,
+		running:   make(map[string]int),
+	}
+}
+
+// Acquire reserva uma vaga para o cliente. Se conseguir, o release devolvido
+// precisa ser chamado quando a busca terminar.
+func (c *Concurrency) Acquire(key string) (release func(), ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.maxTotal > 0 && c.total >= c.maxTotal {
+		return nil, false
+	}
+	if c.maxClient > 0 && c.running[key] >= c.maxClient {
+		return nil, false
+	}
+	c.total++
+	c.running[key]++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			c.total--
+			if c.running[key]--; c.running[key] <= 0 {
+				delete(c.running, key)
+			}
+		})
+	}, true
+}
+
+// Running devolve quantas buscas estão rodando agora.
+func (c *Concurrency) Running() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.total
+}
+
+// ClientKey identifica o cliente pelo IP. Com trustProxy, usa o último
+// endereço do X-Forwarded-For, o que o proxy confiável acrescentou; os
+// anteriores vêm do próprio cliente e podem ser qualquer coisa.
+func ClientKey(r *http.Request, trustProxy bool) string {
+	if trustProxy {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			entries := strings.Split(forwarded, ",")
+			if last := strings.TrimSpace(entries[len(entries)-1]); last != "" {
+				return last
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// SetRetryAfter grava o cabeçalho Retry-After em segundos (no mínimo 1) e
+// devolve o valor, para a mensagem do 429.
+func SetRetryAfter(w http.ResponseWriter, retryAfter time.Duration) int {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	return seconds
+}
@@ -0,0 +1,86 @@
+package ratelimit
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLimiterRefillsPerClient(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	l := NewLimiter(6, 2)
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.Allow("a"); !ok {
+			t.Fatalf("request %d should fit in the burst", i+1)
+		}
+	}
+	ok, wait := l.Allow("a")
+	if ok {
+		t.Fatal("third request should be limited")
+	}
+	if wait != 10*time.Second {
+		t.Errorf("expected 10s until the next token, got %v", wait)
+	}
+	if ok, _ := l.Allow("b"); !ok {
+		t.Error("other clients should not be affected")
+	}
+
+	now = now.Add(10 * time.Second)
+	if ok, _ := l.Allow("a"); !ok {
+		t.Error("a token should be back after 10s")
+	}
+}
+
+func TestLimiterDisabled(t *testing.T) {
+	l := NewLimiter(0, 1)
+	for i := 0; i < 100; i++ {
+		if ok, _ := l.Allow("a"); !ok {
+			t.Fatal("a zero rate should disable the limiter")
+		}
+	}
+}
+
+func TestConcurrencyLimits(t *testing.T) {
+	c := NewConcurrency(2, 1)
+
+	releaseA, ok := c.Acquire("a")
+	if !ok {
+		t.Fatal("first search should start")
+	}
+	if _, ok := c.Acquire("a"); ok {
+		t.Error("client a should be limited to one search")
+	}
+	releaseB, ok := c.Acquire("b")
+	if !ok {
+		t.Fatal("client b should start")
+	}
+	if _, ok := c.Acquire("c"); ok {
+		t.Error("total limit should block client c")
+	}
+
+	releaseA()
+	releaseA()
+	if c.Running() != 1 {
+		t.Errorf("release should be idempotent, running=%d", c.Running())
+	}
+	if _, ok := c.Acquire("c"); !ok {
+		t.Error("client c should start after a slot is released")
+	}
+	releaseB()
+}
+
+func TestClientKey(t *testing.T) {
+	r := httptest.NewRequest("GET", "/start-search", nil)
+	r.RemoteAddr = "10.0.0.5:41234"
+	// O cliente mandou "198.51.100.9" e o proxy acrescentou o IP real.
+	r.Header.Set("X-Forwarded-For", "198.51.100.9, 203.0.113.7")
+
+	if got := ClientKey(r, false); got != "10.0.0.5" {
+		t.Errorf("without trustProxy expected the remote address, got %q", got)
+	}
+	if got := ClientKey(r, true); got != "203.0.113.7" {
+		t.Errorf("with trustProxy expected the address added by the proxy, got %q", got)
+	}
+}
@@ -0,0 +1,214 @@
+// /shared/social/social.go
+package social
+
+import (
+	"errors"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+type Network string
+
+const (
+	Instagram Network = "instagram"
+	Facebook  Network = "facebook"
+	TikTok    Network = "tiktok"
+	LinkedIn  Network = "linkedin"
+	YouTube   Network = "youtube"
+	X         Network = "x"
+)
+
+var (
+	// ErrEmpty cobre os "vazios" que o modelo devolve como texto: "null",
+	// "N/A", "-" e afins.
+	ErrEmpty = errors.New("link de rede social vazio")
+	// ErrNotSocial indica uma URL que não é de nenhuma rede conhecida.
+	ErrNotSocial = errors.New("URL não é de rede social conhecida")
+	// ErrNotProfile indica uma URL da rede que não aponta para um perfil
+	// (busca, post, vídeo, compartilhamento, login...).
+	ErrNotProfile = errors.New("URL não é de um perfil")
+)
+
+// Profile é um perfil já validado, com a URL canônica da rede.
+type Profile struct {
+	Network Network `json:"network"`
+	Handle  string  `json:"handle"`
+	URL     string  `json:"url"`
+}
+
+var emptyValues = map[string]bool{
+	"": true, "null": true, "nil": true, "none": true, "n/a": true, "na": true,
+	"-": true, "undefined": true, "url ou null": true, "não encontrado": true, "nao encontrado": true,
+}
+
+var hosts = map[string]Network{
+	"instagram.com": Instagram,
+	"instagr.am":    Instagram,
+	"facebook.com":  Facebook,
+	"fb.com":        Facebook,
+	"fb.me":         Facebook,
+	"tiktok.com":    TikTok,
+	"linkedin.com":  LinkedIn,
+	"youtube.com":   YouTube,
+	"youtu.be":      YouTube,
+	"twitter.com":   X,
+	"x.com":         X,
+}
+
+// Caminhos de cada rede que não são perfis.
+var reserved = map[Network]map[string]bool{
+	Instagram: set("p", "reel", "reels", "explore", "stories", "accounts", "direct", "tv", "about", "developer", "legal", "web"),
+	Facebook: set("search", "sharer", "sharer.php", "share", "share.php", "groups", "events", "watch", "photo", "photo.php",
+		"photos", "story.php", "permalink.php", "login", "login.php", "hashtag", "marketplace", "help", "policies", "dialog", "plugins", "reel"),
+	X: set("search", "hashtag", "intent", "share", "home", "i", "explore", "login", "signup", "settings", "messages", "notifications"),
+}
+
+var (
+	instagramHandle = regexp.MustCompile(`^[a-z0-9._]{1,30}$`)
+	facebookHandle  = regexp.MustCompile(`^[a-z0-9.\-]{2,80}$`)
+	tiktokHandle    = regexp.MustCompile(`^[a-z0-9._]{2,24}$`)
+	xHandle         = regexp.MustCompile(`^[A-Za-z0-9_]{1,15}$`)
+	slug            = regexp.MustCompile(`^[A-Za-z0-9_.%\-]{2,100}$`)
+	numericID       = regexp.MustCompile(`^\d{5,}$`)
+)
+
+func set(values ...string) map[string]bool {
+	m := make(map[string]bool, len(values))
+	for _, v := range values {
+		m[v] = true
+	}
+	return m
+}
+
+// NetworkOf diz de qual rede é a URL, sem validar se é um perfil.
+func NetworkOf(raw string) (Network, bool) {
+	u, err := parseURL(raw)
+	if err != nil {
+		return "", false
+	}
+	network, ok := hosts[baseHost(u.Hostname())]
+	return network, ok
+}
+
+func parseURL(raw string) (*url.URL, error) {
+	raw = strings.TrimSpace(raw)
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + strings.TrimPrefix(raw, "//")
+	}
+	return url.Parse(raw)
+}
+
+// baseHost tira prefixos como www., m., mobile. e pt-br. do host.
+func baseHost(host string) string {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	for domain := range hosts {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return domain
+		}
+	}
+	return host
+}
+
+// Parse valida um link de rede social e devolve o perfil com a URL canônica.
+// Só aceita links de perfil: buscas, posts, vídeos e links de
+// compartilhamento são recusados.
+func Parse(raw string) (Profile, error) {
+	if emptyValues[strings.ToLower(strings.TrimSpace(raw))] {
+		return Profile{}, ErrEmpty
+	}
+	u, err := parseURL(raw)
+	if err != nil || u.Host == "" {
+		return Profile{}, ErrNotSocial
+	}
+	network, ok := hosts[baseHost(u.Hostname())]
+	if !ok {
+		return Profile{}, ErrNotSocial
+	}
+
+	var segments []string
+	for _, s := range strings.Split(u.Path, "/") {
+		if s != "" {
+			segments = append(segments, s)
+		}
+	}
+	if len(segments) == 0 && !(network == Facebook && u.Query().Get("id") != "") {
+		return Profile{}, ErrNotProfile
+	}
+	first := ""
+	if len(segments) > 0 {
+		first = strings.ToLower(segments[0])
+	}
+	if reserved[network][first] {
+		return Profile{}, ErrNotProfile
+	}
+
+	switch network {
+	case Instagram:
+		if !instagramHandle.MatchString(first) {
+			return Profile{}, ErrNotProfile
+		}
+		return Profile{Network: network, Handle: first, URL: "https://www.instagram.com/" + first + "/"}, nil
+
+	case Facebook:
+		switch {
+		case first == "profile.php" || first == "":
+			id := u.Query().Get("id")
+			if !numericID.MatchString(id) {
+				return Profile{}, ErrNotProfile
+			}
+			return Profile{Network: network, Handle: id, URL: "https://www.facebook.com/profile.php?id=" + id}, nil
+		case first == "pages" || first == "people":
+			// facebook.com/pages/Nome/123456
+			if len(segments) < 3 || !numericID.MatchString(segments[2]) {
+				return Profile{}, ErrNotProfile
+			}
+			return Profile{Network: network, Handle: segments[2], URL: "https://www.facebook.com/" + segments[2]}, nil
+		case !facebookHandle.MatchString(first):
+			return Profile{}, ErrNotProfile
+		}
+		return Profile{Network: network, Handle: first, URL: "https://www.facebook.com/" + first}, nil
+
+	case TikTok:
+		handle := strings.TrimPrefix(first, "@")
+		if handle == first || !tiktokHandle.MatchString(handle) {
+			return Profile{}, ErrNotProfile
+		}
+		return Profile{Network: network, Handle: handle, URL: "https://www.tiktok.com/@" + handle}, nil
+
+	case LinkedIn:
+		if len(segments) < 2 || !slug.MatchString(segments[1]) {
+			return Profile{}, ErrNotProfile
+		}
+		switch first {
+		case "company", "in", "school", "showcase":
+			handle := strings.ToLower(segments[1])
+			return Profile{Network: network, Handle: handle, URL: "https://www.linkedin.com/" + first + "/" + handle + "/"}, nil
+		}
+		return Profile{}, ErrNotProfile
+
+	case YouTube:
+		if baseHost(u.Hostname()) == "youtu.be" {
+			return Profile{}, ErrNotProfile
+		}
+		if strings.HasPrefix(first, "@") && slug.MatchString(first[1:]) {
+			return Profile{Network: network, Handle: first[1:], URL: "https://www.youtube.com/" + first}, nil
+		}
+		switch first {
+		case "channel", "c", "user":
+			if len(segments) < 2 || !slug.MatchString(segments[1]) {
+				return Profile{}, ErrNotProfile
+			}
+			return Profile{Network: network, Handle: segments[1], URL: "https://www.youtube.com/" + first + "/" + segments[1]}, nil
+		}
+		return Profile{}, ErrNotProfile
+
+	case X:
+		handle := segments[0]
+		if !xHandle.MatchString(handle) || len(segments) > 1 && segments[1] == "status" {
+			return Profile{}, ErrNotProfile
+		}
+		return Profile{Network: network, Handle: strings.ToLower(handle), URL: "https://x.com/" + strings.ToLower(handle)}, nil
+	}
+	return Profile{}, ErrNotSocial
+}
@@ -0,0 +1,59 @@
+package social
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseProfiles(t *testing.T) {
+	cases := []struct {
+		in      string
+		network Network
+		url     string
+	}{
+		{"https://www.instagram.com/PadariaBela/?hl=pt-br", Instagram, "https://www.instagram.com/padariabela/"},
+		{"instagram.com/padariabela", Instagram, "https://www.instagram.com/padariabela/"},
+		{"http://m.facebook.com/padariabela/", Facebook, "https://www.facebook.com/padariabela"},
+		{"https://pt-br.facebook.com/profile.php?id=100012345678", Facebook, "https://www.facebook.com/profile.php?id=100012345678"},
+		{"https://www.facebook.com/pages/Padaria-Bela/123456789", Facebook, "https://www.facebook.com/123456789"},
+		{"https://www.tiktok.com/@padariabela?lang=pt", TikTok, "https://www.tiktok.com/@padariabela"},
+		{"https://br.linkedin.com/company/padaria-bela", LinkedIn, "https://www.linkedin.com/company/padaria-bela/"},
+		{"https://www.youtube.com/@PadariaBela", YouTube, "https://www.youtube.com/@padariabela"},
+		{"https://youtube.com/channel/UC1234567890abcdef", YouTube, "https://www.youtube.com/channel/UC1234567890abcdef"},
+		{"https://twitter.com/PadariaBela", X, "https://x.com/padariabela"},
+	}
+	for _, c := range cases {
+		p, err := Parse(c.in)
+		if err != nil || p.Network != c.network || p.URL != c.url {
+			t.Errorf("Parse(%q) = %+v, %v; want %s %s", c.in, p, err, c.network, c.url)
+		}
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	cases := []struct {
+		in  string
+		err error
+	}{
+		{"null", ErrEmpty},
+		{" N/A ", ErrEmpty},
+		{"url ou null", ErrEmpty},
+		{"https://padariabela.com.br", ErrNotSocial},
+		{"https://www.instagram.com/", ErrNotProfile},
+		{"https://www.instagram.com/p/Cx123abc/", ErrNotProfile},
+		{"https://www.instagram.com/explore/tags/padaria/", ErrNotProfile},
+		{"https://www.facebook.com/search/top?q=padaria", ErrNotProfile},
+		{"https://www.facebook.com/sharer/sharer.php?u=x", ErrNotProfile},
+		{"https://www.tiktok.com/search?q=padaria", ErrNotProfile},
+		{"https://www.linkedin.com/search/results/all/?keywords=padaria", ErrNotProfile},
+		{"https://www.youtube.com/watch?v=abc", ErrNotProfile},
+		{"https://youtu.be/abc", ErrNotProfile},
+		{"https://x.com/padariabela/status/123", ErrNotProfile},
+		{"https://x.com/search?q=padaria", ErrNotProfile},
+	}
+	for _, c := range cases {
+		if p, err := Parse(c.in); !errors.Is(err, c.err) {
+			t.Errorf("Parse(%q) = %+v, %v; want %v", c.in, p, err, c.err)
+		}
+	}
+}